@@ -41,6 +41,23 @@ const (
 	// DefaultEventsFlushInterval is the default value for EventsConfig.FlushInterval if not specified.
 	DefaultEventsFlushInterval = time.Second * 5
 
+	// DefaultEventsSpoolMaxSize is the default value for EventsConfig.SpoolMaxSize if not specified.
+	DefaultEventsSpoolMaxSize = 100 * 1024 * 1024 // 100MiB
+
+	// DefaultEventsSinkMaxFileSize is the default value for EventsConfig.SinkMaxFileSize if not specified.
+	DefaultEventsSinkMaxFileSize = 100 * 1024 * 1024 // 100MiB
+
+	// DefaultEventsSinkMaxFiles is the default value for EventsConfig.SinkMaxFiles if not specified.
+	DefaultEventsSinkMaxFiles = 10
+
+	// DefaultEventsContextKeysCapacity is the default value for EventsConfig.ContextKeysCapacity if not
+	// specified. This is the same as the SDK default.
+	DefaultEventsContextKeysCapacity = 1000
+
+	// DefaultEventsContextKeysFlushInterval is the default value for EventsConfig.ContextKeysFlushInterval
+	// if not specified. This is the same as the SDK default.
+	DefaultEventsContextKeysFlushInterval = time.Minute * 5
+
 	// DefaultDisconnectedStatusTime is the default value for MainConfig.DisconnectedStatusTime if not specified.
 	DefaultDisconnectedStatusTime = time.Minute
 
@@ -50,9 +67,16 @@ const (
 	// DefaultPrometheusPort is the default value for PrometheusConfig.Port if not specified.
 	DefaultPrometheusPort = 8031
 
+	// DefaultAdminHost is the default value for AdminConfig.Host if not specified. The admin API is
+	// reachable only from the local host unless another address is configured.
+	DefaultAdminHost = "127.0.0.1"
+
 	// DefaultBigSegmentsStaleThreshold is the default value for MainConfig.BigSegmentsStaleThreshold if not specified.
 	DefaultBigSegmentsStaleThreshold = time.Minute * 5
 
+	// DefaultShutdownDrainTimeout is the default value for MainConfig.ShutdownDrainTimeout if not specified.
+	DefaultShutdownDrainTimeout = time.Second * 10
+
 	// AutoConfigEnvironmentIDPlaceholder is a string that can appear within
 	// AutoConfigConfig.EnvDataStorePrefix or AutoConfigConfig.EnvDataStoreTableName to indicate that
 	// the environment ID should be substituted at that point.
@@ -123,11 +147,16 @@ type Config struct {
 	Environment map[string]*EnvConfig
 	Filters     map[string]*FiltersConfig
 	Proxy       ProxyConfig
+	Admin       AdminConfig
+
+	EventDestination map[string]*EventDestinationConfig
 
 	// Optional configuration for metrics integrations. Note that unlike the other fields in Config,
 	// MetricsConfig is not the name of a configuration file section; the actual sections are the
 	// structs within this struct (Datadog, etc.).
 	MetricsConfig
+
+	secretFiles []string // see SecretFiles
 }
 
 // MainConfig contains global configuration options for Relay.
@@ -148,15 +177,25 @@ type MainConfig struct {
 	InitTimeout                      ct.OptDuration           `conf:"INIT_TIMEOUT"`
 	HeartbeatInterval                ct.OptDuration           `conf:"HEARTBEAT_INTERVAL"`
 	MaxClientConnectionTime          ct.OptDuration           `conf:"MAX_CLIENT_CONNECTION_TIME"`
+	MaxClientConnectionTimeJitter    ct.OptDuration           `conf:"MAX_CLIENT_CONNECTION_TIME_JITTER"`
+	MaxStreamConnections             ct.OptIntGreaterThanZero `conf:"MAX_STREAM_CONNECTIONS"`
+	MaxStreamConnectionsPerEnv       ct.OptIntGreaterThanZero `conf:"MAX_STREAM_CONNECTIONS_PER_ENV"`
 	DisconnectedStatusTime           ct.OptDuration           `conf:"DISCONNECTED_STATUS_TIME"`
 	TLSEnabled                       bool                     `conf:"TLS_ENABLED"`
 	TLSCert                          string                   `conf:"TLS_CERT"`
 	TLSKey                           string                   `conf:"TLS_KEY"`
 	TLSMinVersion                    OptTLSVersion            `conf:"TLS_MIN_VERSION"`
+	TLSClientCA                      string                   `conf:"TLS_CLIENT_CA"`
 	LogLevel                         OptLogLevel              `conf:"LOG_LEVEL"`
 	BigSegmentsStaleAsDegraded       bool                     `conf:"BIG_SEGMENTS_STALE_AS_DEGRADED"`
 	BigSegmentsStaleThreshold        ct.OptDuration           `conf:"BIG_SEGMENTS_STALE_THRESHOLD"`
 	ExpiredCredentialCleanupInterval ct.OptDuration           `conf:"EXPIRED_CREDENTIAL_CLEANUP_INTERVAL"`
+	ReadinessPolicy                  ReadinessPolicy          `conf:"READINESS_POLICY"`
+	ReadinessMinPercent              ct.OptIntGreaterThanZero `conf:"READINESS_MIN_PERCENT"`
+	ShutdownDrainTimeout             ct.OptDuration           `conf:"SHUTDOWN_DRAIN_TIMEOUT"`
+	ClientSideEvalStreams            bool                     `conf:"CLIENT_SIDE_EVAL_STREAMS"`
+	CompressServerSideStreams        bool                     `conf:"COMPRESS_SERVER_SIDE_STREAMS"`
+	WebSocketStreams                 bool                     `conf:"WEBSOCKET_STREAMS"`
 }
 
 // AutoConfigConfig contains configuration parameters for the auto-configuration feature.
@@ -184,12 +223,29 @@ type OfflineModeConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type EventsConfig struct {
-	EventsURI             ct.OptURLAbsolute        `conf:"EVENTS_HOST"`
-	SendEvents            bool                     `conf:"USE_EVENTS"`
-	FlushInterval         ct.OptDuration           `conf:"EVENTS_FLUSH_INTERVAL"`
-	Capacity              ct.OptIntGreaterThanZero `conf:"EVENTS_CAPACITY"`
-	InlineUsers           bool                     `conf:"EVENTS_INLINE_USERS"`
-	MaxInboundPayloadSize ct.OptBase2Bytes         `conf:"EVENTS_MAX_INBOUND_PAYLOAD_SIZE"`
+	EventsURI                ct.OptURLAbsolute        `conf:"EVENTS_HOST"`
+	SendEvents               bool                     `conf:"USE_EVENTS"`
+	FlushInterval            ct.OptDuration           `conf:"EVENTS_FLUSH_INTERVAL"`
+	Capacity                 ct.OptIntGreaterThanZero `conf:"EVENTS_CAPACITY"`
+	InlineUsers              bool                     `conf:"EVENTS_INLINE_USERS"`
+	MaxInboundPayloadSize    ct.OptBase2Bytes         `conf:"EVENTS_MAX_INBOUND_PAYLOAD_SIZE"`
+	SpoolDir                 string                   `conf:"EVENTS_SPOOL_DIR"`
+	SpoolMaxSize             ct.OptBase2Bytes         `conf:"EVENTS_SPOOL_MAX_SIZE"`
+	Sink                     EventsSinkKind           `conf:"EVENTS_SINK"`
+	SinkPath                 string                   `conf:"EVENTS_SINK_PATH"`
+	SinkMaxFileSize          ct.OptBase2Bytes         `conf:"EVENTS_SINK_MAX_FILE_SIZE"`
+	SinkMaxFiles             ct.OptIntGreaterThanZero `conf:"EVENTS_SINK_MAX_FILES"`
+	SinkURI                  ct.OptURLAbsolute        `conf:"EVENTS_SINK_URI"`
+	OverloadPolicy           EventsOverloadPolicy     `conf:"EVENTS_OVERLOAD_POLICY"`
+	HighWaterMark            ct.OptIntGreaterThanZero `conf:"EVENTS_HIGH_WATER_MARK"`
+	MaxMemoryPerEnv          ct.OptBase2Bytes         `conf:"EVENTS_MAX_MEMORY_PER_ENV"`
+	MaxMemory                ct.OptBase2Bytes         `conf:"EVENTS_MAX_MEMORY"`
+	ContextKeysCapacity      ct.OptIntGreaterThanZero `conf:"EVENTS_CONTEXT_KEYS_CAPACITY"`
+	ContextKeysFlushInterval ct.OptDuration           `conf:"EVENTS_CONTEXT_KEYS_FLUSH_INTERVAL"`
+	OTLPEndpoint             ct.OptURLAbsolute        `conf:"EVENTS_OTLP_ENDPOINT"`
+	OTLPProtocol             EventsOTLPProtocol       `conf:"EVENTS_OTLP_PROTOCOL"`
+	OTLPSignal               EventsOTLPSignal         `conf:"EVENTS_OTLP_SIGNAL"`
+	OTLPHeader               ct.OptStringList         `conf:"EVENTS_OTLP_HEADER"` // see ParseEventDestinationHeader
 }
 
 // RedisConfig configures the optional Redis integration.
@@ -252,25 +308,42 @@ type DynamoDBConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type EnvConfig struct {
-	SDKKey        SDKKey           // set from env var LD_ENV_envname
-	MobileKey     MobileKey        `conf:"LD_MOBILE_KEY_"`
-	EnvID         EnvironmentID    `conf:"LD_CLIENT_SIDE_ID_"`
-	Prefix        string           `conf:"LD_PREFIX_"`     // used only if Redis, Consul, or DynamoDB is enabled
-	TableName     string           `conf:"LD_TABLE_NAME_"` // used only if DynamoDB is enabled
-	AllowedOrigin ct.OptStringList `conf:"LD_ALLOWED_ORIGIN_"`
-	AllowedHeader ct.OptStringList `conf:"LD_ALLOWED_HEADER_"`
-	SecureMode    bool             `conf:"LD_SECURE_MODE_"`
-	LogLevel      OptLogLevel      `conf:"LD_LOG_LEVEL_"`
-	TTL           ct.OptDuration   `conf:"LD_TTL_"`
-	ProjKey       string           `conf:"LD_PROJ_KEY_"`
-	FilterKey     FilterKey        // injected based on [filters] section
-	Offline       bool             // set to true if this environment was created in offline mode
+	SDKKey            SDKKey           // set from env var LD_ENV_envname
+	MobileKey         MobileKey        `conf:"LD_MOBILE_KEY_"`
+	EnvID             EnvironmentID    `conf:"LD_CLIENT_SIDE_ID_"`
+	Prefix            string           `conf:"LD_PREFIX_"`     // used only if Redis, Consul, or DynamoDB is enabled
+	TableName         string           `conf:"LD_TABLE_NAME_"` // used only if DynamoDB is enabled
+	AllowedOrigin     ct.OptStringList `conf:"LD_ALLOWED_ORIGIN_"`
+	AllowedHeader     ct.OptStringList `conf:"LD_ALLOWED_HEADER_"`
+	AllowedClientCert ct.OptStringList `conf:"LD_ALLOWED_CLIENT_CERT_"` // used only if MainConfig.TLSClientCA is set
+	SecureMode        bool             `conf:"LD_SECURE_MODE_"`
+	LogLevel          OptLogLevel      `conf:"LD_LOG_LEVEL_"`
+	TTL               ct.OptDuration   `conf:"LD_TTL_"`
+	ProjKey           string           `conf:"LD_PROJ_KEY_"`
+	FilterKey         FilterKey        // injected based on [filters] section
+	Offline           bool             // set to true if this environment was created in offline mode
+
+	DropCustomEvent       ct.OptStringList `conf:"LD_DROP_CUSTOM_EVENT_"`       // event key patterns
+	SampleCustomEvent     ct.OptStringList `conf:"LD_SAMPLE_CUSTOM_EVENT_"`     // see ParseCustomEventSampling
+	StripContextAttribute ct.OptStringList `conf:"LD_STRIP_CONTEXT_ATTRIBUTE_"` // attribute names
+	HashContextAttribute  ct.OptStringList `conf:"LD_HASH_CONTEXT_ATTRIBUTE_"`  // attribute names
+	DropIdentifyEvents    ct.OptStringList `conf:"LD_DROP_IDENTIFY_EVENTS_"`    // SDK kinds: server, mobile, js
 }
 
 type FiltersConfig struct {
 	Keys ct.OptStringList `conf:"LD_FILTER_KEYS_"`
 }
 
+// EventDestinationConfig describes an additional destination for analytics events, which receives
+// events at the same time as LaunchDarkly (or the events sink, if one is configured).
+//
+// This corresponds to an [EventDestination "NAME"] section in the configuration file.
+type EventDestinationConfig struct {
+	URI    ct.OptURLAbsolute    `conf:"EVENT_DESTINATION_URI_"`
+	Header ct.OptStringList     `conf:"EVENT_DESTINATION_HEADER_"` // see ParseEventDestinationHeader
+	Mode   EventDestinationMode `conf:"EVENT_DESTINATION_MODE_"`
+}
+
 // ProxyConfig represents all the supported proxy options.
 //
 // Since configuration options can be set either programmatically, or from a file, or from environment
@@ -285,6 +358,25 @@ type ProxyConfig struct {
 	CACertFiles ct.OptStringList  `conf:"PROXY_CA_CERTS"`
 }
 
+// AdminConfig configures the optional administrative API, which is used only if Port is set.
+//
+// The administrative API runs on its own port, separately from the SDK endpoints, and every request
+// to it must provide Token as a bearer token in the Authorization header. If EventDebug is true, it
+// also provides a stream of the analytics event payloads that Relay receives and delivers.
+//
+// This corresponds to the [Admin] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type AdminConfig struct {
+	Port                    ct.OptIntGreaterThanZero `conf:"ADMIN_PORT"`
+	Host                    string                   `conf:"ADMIN_HOST"`
+	Token                   string                   `conf:"ADMIN_TOKEN"`
+	EventDebug              bool                     `conf:"ADMIN_EVENT_DEBUG"`
+	EventDebugSamplingRatio ct.OptIntGreaterThanZero `conf:"ADMIN_EVENT_DEBUG_SAMPLING_RATIO"`
+}
+
 // MetricsConfig contains configurations for optional metrics integrations.
 //
 // This corresponds to the [Datadog], [Stackdriver], and [Prometheus] sections in the configuration file.
@@ -3,6 +3,7 @@ package config
 import (
 	"crypto/tls"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
@@ -16,6 +17,14 @@ func errBadTLSVersion(s string) error {
 	return fmt.Errorf("%q is not a valid TLS version", s)
 }
 
+func errBadEventDestinationHeader(s string) error {
+	return fmt.Errorf(`%q is not a valid event destination header; expected "Name: value"`, s)
+}
+
+func errBadCustomEventSampling(s string) error {
+	return fmt.Errorf(`%q is not a valid custom event sampling rule; expected "keyPattern=ratio", with a ratio from 0 to 1`, s)
+}
+
 // SDKKey is a type tag to indicate when a string is used as a server-side SDK key for a LaunchDarkly
 // environment.
 type SDKKey string
@@ -42,6 +51,129 @@ type FilterKey string
 // DefaultFilter represents the lack of a filter, meaning a full LaunchDarkly environment.
 const DefaultFilter = FilterKey("")
 
+// ReadinessPolicy determines when Relay's readiness endpoint reports that it is ready to receive traffic.
+// An empty value is equivalent to ReadinessAllEnvironments.
+type ReadinessPolicy string
+
+const (
+	// ReadinessAllEnvironments means Relay is ready once every environment has been initialized.
+	ReadinessAllEnvironments ReadinessPolicy = "allEnvironments"
+
+	// ReadinessMinPercent means Relay is ready once at least MainConfig.ReadinessMinPercent percent of
+	// the environments have been initialized.
+	ReadinessMinPercent ReadinessPolicy = "minPercent"
+
+	// ReadinessAutoConfigReceived means Relay is ready once it has received the list of environments
+	// from auto-configuration, whether or not they have been initialized yet.
+	ReadinessAutoConfigReceived ReadinessPolicy = "autoConfigReceived"
+)
+
+// UnmarshalText allows the ReadinessPolicy type to be set from environment variables.
+func (p *ReadinessPolicy) UnmarshalText(data []byte) error {
+	*p = ReadinessPolicy(string(data))
+	return nil
+}
+
+// EventsSinkKind determines where Relay sends analytics events, if not to the LaunchDarkly events service.
+// An empty value means events are sent to the events service.
+type EventsSinkKind string
+
+const (
+	// EventsSinkFile means events are written to newline-delimited JSON files, in EventsConfig.SinkPath.
+	EventsSinkFile EventsSinkKind = "file"
+
+	// EventsSinkStdout means events are written to standard output as newline-delimited JSON.
+	EventsSinkStdout EventsSinkKind = "stdout"
+
+	// EventsSinkWebhook means events are posted to EventsConfig.SinkURI.
+	EventsSinkWebhook EventsSinkKind = "webhook"
+)
+
+// UnmarshalText allows the EventsSinkKind type to be set from environment variables.
+func (k *EventsSinkKind) UnmarshalText(data []byte) error {
+	*k = EventsSinkKind(string(data))
+	return nil
+}
+
+// EventsOverloadPolicy determines what Relay does with analytics events that it receives when it already
+// has too many events waiting to be delivered. An empty value is equivalent to EventsOverloadDrop.
+type EventsOverloadPolicy string
+
+const (
+	// EventsOverloadDrop means Relay accepts the events, but discards them. This is the default.
+	EventsOverloadDrop EventsOverloadPolicy = "drop"
+
+	// EventsOverloadReject429 means Relay rejects the request with a 429 status and a Retry-After header,
+	// so that the SDK can keep the events and try again later.
+	EventsOverloadReject429 EventsOverloadPolicy = "reject-429"
+
+	// EventsOverloadReject503 is the same as EventsOverloadReject429, but with a 503 status.
+	EventsOverloadReject503 EventsOverloadPolicy = "reject-503"
+)
+
+// UnmarshalText allows the EventsOverloadPolicy type to be set from environment variables.
+func (p *EventsOverloadPolicy) UnmarshalText(data []byte) error {
+	*p = EventsOverloadPolicy(string(data))
+	return nil
+}
+
+// EventsOTLPProtocol determines how Relay sends analytics events to an OpenTelemetry collector, if
+// EventsConfig.OTLPEndpoint is set. An empty value is equivalent to EventsOTLPHTTP.
+type EventsOTLPProtocol string
+
+const (
+	// EventsOTLPHTTP means events are posted to the collector with OTLP/HTTP, in protobuf encoding.
+	EventsOTLPHTTP EventsOTLPProtocol = "http"
+
+	// EventsOTLPGRPC means events are sent to the collector with OTLP/gRPC.
+	EventsOTLPGRPC EventsOTLPProtocol = "grpc"
+)
+
+// UnmarshalText allows the EventsOTLPProtocol type to be set from environment variables.
+func (p *EventsOTLPProtocol) UnmarshalText(data []byte) error {
+	*p = EventsOTLPProtocol(string(data))
+	return nil
+}
+
+// EventsOTLPSignal determines what kind of OpenTelemetry data Relay produces from analytics events, if
+// EventsConfig.OTLPEndpoint is set. An empty value is equivalent to EventsOTLPLogs.
+type EventsOTLPSignal string
+
+const (
+	// EventsOTLPLogs means each event becomes a log record.
+	EventsOTLPLogs EventsOTLPSignal = "logs"
+
+	// EventsOTLPTraces means each event becomes an event on a span that represents the request that
+	// delivered it to Relay.
+	EventsOTLPTraces EventsOTLPSignal = "traces"
+)
+
+// UnmarshalText allows the EventsOTLPSignal type to be set from environment variables.
+func (s *EventsOTLPSignal) UnmarshalText(data []byte) error {
+	*s = EventsOTLPSignal(string(data))
+	return nil
+}
+
+// EventDestinationMode determines which events are sent to an additional event destination. An empty
+// value is equivalent to EventDestinationSummarized.
+type EventDestinationMode string
+
+const (
+	// EventDestinationSummarized means the destination receives the same payloads that are sent to
+	// LaunchDarkly, after Relay has summarized events from older SDKs.
+	EventDestinationSummarized EventDestinationMode = "summarized"
+
+	// EventDestinationRaw means the destination receives each payload of events as it was received
+	// from an SDK, without summarization.
+	EventDestinationRaw EventDestinationMode = "raw"
+)
+
+// UnmarshalText allows the EventDestinationMode type to be set from environment variables.
+func (m *EventDestinationMode) UnmarshalText(data []byte) error {
+	*m = EventDestinationMode(string(data))
+	return nil
+}
+
 func last4Chars(s string) string {
 	if len(s) < 4 { // COVERAGE: doesn't happen in unit tests, also can't happen with real environments
 		return s
@@ -256,3 +388,38 @@ func (o OptTLSVersion) String() string {
 		return fmt.Sprintf("unknown (%d)", o.value)
 	}
 }
+
+// CustomEventSampling is a parsed value of the EnvConfig.SampleCustomEvent option, which says that
+// only the specified fraction of custom events whose keys match a pattern should be forwarded.
+type CustomEventSampling struct {
+	// KeyPattern is a custom event key, in which "*" matches any sequence of characters.
+	KeyPattern string
+	// Ratio is the fraction of matching events to keep, from 0 to 1.
+	Ratio float64
+}
+
+// ParseCustomEventSampling parses a value of the EnvConfig.SampleCustomEvent option, in the format
+// "keyPattern=ratio". Since event keys may themselves contain "=", the ratio is taken from after the
+// last "=".
+func ParseCustomEventSampling(s string) (CustomEventSampling, error) {
+	pos := strings.LastIndex(s, "=")
+	if pos <= 0 {
+		return CustomEventSampling{}, errBadCustomEventSampling(s)
+	}
+	ratio, err := strconv.ParseFloat(strings.TrimSpace(s[pos+1:]), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return CustomEventSampling{}, errBadCustomEventSampling(s)
+	}
+	return CustomEventSampling{KeyPattern: strings.TrimSpace(s[:pos]), Ratio: ratio}, nil
+}
+
+// ParseEventDestinationHeader parses a value of the EventDestinationConfig.Header option, in the
+// format "Name: value".
+func ParseEventDestinationHeader(s string) (name, value string, err error) {
+	name, value, found := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", errBadEventDestinationHeader(s)
+	}
+	return name, strings.TrimSpace(value), nil
+}
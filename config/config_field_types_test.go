@@ -91,3 +91,51 @@ func TestOptTLSVersion(t *testing.T) {
 		assert.Equal(t, "unknown (9999)", NewOptTLSVersion(9999).String())
 	})
 }
+
+func TestParseCustomEventSampling(t *testing.T) {
+	for _, val := range []struct {
+		s        string
+		expected CustomEventSampling
+	}{
+		{"page-view=0.25", CustomEventSampling{KeyPattern: "page-view", Ratio: 0.25}},
+		{"page-* = 1", CustomEventSampling{KeyPattern: "page-*", Ratio: 1}},
+		{"a=b=0", CustomEventSampling{KeyPattern: "a=b", Ratio: 0}},
+	} {
+		t.Run(val.s, func(t *testing.T) {
+			s, err := ParseCustomEventSampling(val.s)
+			assert.NoError(t, err)
+			assert.Equal(t, val.expected, s)
+		})
+	}
+
+	for _, s := range []string{"page-view", "=0.5", "page-view=x", "page-view=1.5", "page-view=-1"} {
+		t.Run(s, func(t *testing.T) {
+			_, err := ParseCustomEventSampling(s)
+			assert.Equal(t, errBadCustomEventSampling(s), err)
+		})
+	}
+}
+
+func TestParseEventDestinationHeader(t *testing.T) {
+	for _, val := range []struct {
+		s, name, value string
+	}{
+		{"Authorization: Bearer xyz", "Authorization", "Bearer xyz"},
+		{"X-Empty:", "X-Empty", ""},
+		{" X-Url : http://a:1 ", "X-Url", "http://a:1"},
+	} {
+		t.Run(val.s, func(t *testing.T) {
+			name, value, err := ParseEventDestinationHeader(val.s)
+			assert.NoError(t, err)
+			assert.Equal(t, val.name, name)
+			assert.Equal(t, val.value, value)
+		})
+	}
+
+	for _, s := range []string{"no-colon", ": value", "Bad Name: value"} {
+		t.Run(s, func(t *testing.T) {
+			_, _, err := ParseEventDestinationHeader(s)
+			assert.Equal(t, errBadEventDestinationHeader(s), err)
+		})
+	}
+}
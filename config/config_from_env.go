@@ -29,6 +29,10 @@ func LoadConfigFromEnvironment(c *Config, loggers ldlog.Loggers) error {
 		return result.GetError()
 	}
 
+	if err := resolveSecretReferences(c); err != nil {
+		return err
+	}
+
 	return ValidateConfig(c, loggers)
 }
 
@@ -40,6 +44,7 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 	reader.ReadStruct(&c.Main, false)
 
 	reader.ReadStruct(&c.AutoConfig, false)
+	readSecretFileVar(reader, "AUTO_CONFIG_KEY", "AUTO_CONFIG_KEY_FILE", (*string)(&c.AutoConfig.Key))
 
 	reader.ReadStruct(&c.OfflineMode, false)
 
@@ -61,14 +66,28 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 	reader.ReadStruct(&c.Events, false)
 	rejectObsoleteVariableName("EVENTS_SAMPLING_INTERVAL", "", reader)
 
-	for envName, envKey := range reader.FindPrefixedValues("LD_ENV_") {
+	// An environment can get its SDK key from LD_ENV_name or, if the key is in a file, LD_FILE_ENV_name.
+	// The per-environment secret file variables put FILE_ after LD_ rather than adding _FILE to the end,
+	// because an environment name could itself end in _FILE.
+	envKeys := reader.FindPrefixedValues("LD_ENV_")
+	envKeyFiles := reader.FindPrefixedValues("LD_FILE_ENV_")
+	envNames := make(map[string]struct{}, len(envKeys)+len(envKeyFiles))
+	for envName := range envKeys {
+		envNames[envName] = struct{}{}
+	}
+	for envName := range envKeyFiles {
+		envNames[envName] = struct{}{}
+	}
+	for envName := range envNames {
 		var ec EnvConfig
 		if c.Environment[envName] != nil {
 			ec = *c.Environment[envName]
 		}
-		ec.SDKKey = SDKKey(envKey)
+		ec.SDKKey = SDKKey(envKeys[envName])
+		readSecretFileVar(reader, "LD_ENV_"+envName, "LD_FILE_ENV_"+envName, (*string)(&ec.SDKKey))
 		subReader := reader.WithVarNameSuffix(envName)
 		subReader.ReadStruct(&ec, false)
+		readSecretFileVar(reader, "LD_MOBILE_KEY_"+envName, "LD_FILE_MOBILE_KEY_"+envName, (*string)(&ec.MobileKey))
 		rejectObsoleteVariableName("LD_TTL_MINUTES_"+envName, "LD_TTL_"+envName, reader)
 		if c.Environment == nil {
 			c.Environment = make(map[string]*EnvConfig)
@@ -89,6 +108,19 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 		c.Filters[projKey] = &fc
 	}
 
+	for destName := range reader.FindPrefixedValues("EVENT_DESTINATION_URI_") {
+		var dc EventDestinationConfig
+		if c.EventDestination[destName] != nil {
+			dc = *c.EventDestination[destName]
+		}
+		subReader := reader.WithVarNameSuffix(destName)
+		subReader.ReadStruct(&dc, false)
+		if c.EventDestination == nil {
+			c.EventDestination = make(map[string]*EventDestinationConfig)
+		}
+		c.EventDestination[destName] = &dc
+	}
+
 	useRedis := false
 	reader.Read("USE_REDIS", &useRedis)
 	if useRedis || c.Redis.Host != "" || c.Redis.URL.IsDefined() {
@@ -97,6 +129,7 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 			portStr = fmt.Sprintf("%d", c.Redis.Port.GetOrElse(0))
 		}
 		reader.ReadStruct(&c.Redis, false)
+		readSecretFileVar(reader, "REDIS_PASSWORD", "REDIS_PASSWORD_FILE", &c.Redis.Password)
 		reader.Read("REDIS_PORT", &portStr) // handled separately because it could be a string or a number
 
 		if portStr != "" {
@@ -148,6 +181,9 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 	reader.ReadStruct(&c.MetricsConfig.Prometheus, false)
 
 	reader.ReadStruct(&c.Proxy, false)
+	readSecretFileVar(reader, "PROXY_AUTH_PASSWORD", "PROXY_AUTH_PASSWORD_FILE", &c.Proxy.Password)
+
+	reader.ReadStruct(&c.Admin, false)
 
 	return reader.Result()
 }
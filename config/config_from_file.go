@@ -16,10 +16,23 @@ func errLoadingConfigFile(path string, err error) error {
 
 // LoadConfigFile reads a configuration file into a Config struct and performs basic validation.
 //
+// If the file name ends in ".yaml", ".yml", or ".json", it is parsed as YAML or JSON; otherwise it is
+// parsed in the INI-like format that is described in docs/configuration.md.
+//
 // The Config parameter should be initialized with default values first.
 func LoadConfigFile(c *Config, path string, loggers ldlog.Loggers) error {
-	if err := gcfg.ReadFileInto(c, path); err != nil {
-		return errLoadingConfigFile(path, FilterGcfgError(err))
+	var err error
+	if isStructuredConfigFile(path) {
+		err = readStructuredConfigFileInto(c, path)
+	} else {
+		err = FilterGcfgError(gcfg.ReadFileInto(c, path))
+	}
+	if err != nil {
+		return errLoadingConfigFile(path, err)
+	}
+
+	if err := resolveSecretReferences(c); err != nil {
+		return err
 	}
 
 	return ValidateConfig(c, loggers)
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	errYAMLExpectedMapping     = errors.New("expected a mapping of keys to values")
+	errYAMLExpectedSingleValue = errors.New("expected a single value, not a list or mapping")
+	errYAMLUnknownKey          = errors.New("unsupported or misspelled key")
+)
+
+func errYAMLAtPath(path string, line int, err error) error {
+	return fmt.Errorf("%s (line %d): %w", path, line, err)
+}
+
+func errYAMLUnsupportedType(t reflect.Type) error {
+	return fmt.Errorf("values of type %s are not supported", t)
+}
+
+func errYAMLInvalidBool(value string) error {
+	return fmt.Errorf("%q is not a valid boolean value", value)
+}
+
+// isStructuredConfigFile returns true if the file extension indicates a YAML or JSON configuration
+// file, rather than the default INI-like format.
+func isStructuredConfigFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// readStructuredConfigFileInto reads a YAML or JSON configuration file into a Config struct. Since
+// JSON is a subset of YAML, the same parser is used for both.
+//
+// The file has the same structure as the INI-like format: each top-level key is a section name, such
+// as "Main" or "Redis", whose value is a mapping of property names to values. The Environment, Filters,
+// and EventDestination sections are mappings of names to those mappings. As in the INI-like format,
+// section and property names are case-insensitive, and values are parsed with the same rules.
+// Properties that can have multiple values, such as allowedOrigin, can be given either as a list or as
+// a comma-delimited string.
+func readStructuredConfigFileInto(c *Config, path string) error {
+	data, err := os.ReadFile(path) //nolint:gosec // the configuration file path is provided by the administrator
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 { // empty file
+		return nil
+	}
+	return decodeYAMLStruct(doc.Content[0], reflect.ValueOf(c).Elem(), "")
+}
+
+func decodeYAMLStruct(node *yaml.Node, target reflect.Value, path string) error {
+	if node.Kind != yaml.MappingNode {
+		return errYAMLAtPath(describeYAMLPath(path), node.Line, errYAMLExpectedMapping)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		field, fieldName, ok := findConfigField(target, keyNode.Value)
+		if !ok {
+			return errYAMLAtPath(joinYAMLPath(path, keyNode.Value), keyNode.Line, errYAMLUnknownKey)
+		}
+		if err := decodeYAMLValue(valueNode, field, joinYAMLPath(path, fieldName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeYAMLValue(node *yaml.Node, target reflect.Value, path string) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	switch {
+	case target.Kind() == reflect.Map:
+		return decodeYAMLMap(node, target, path)
+
+	case target.Type() == reflect.TypeOf(ct.OptStringList{}) && node.Kind == yaml.SequenceNode:
+		values, err := decodeYAMLStringList(node, path)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(ct.NewOptStringList(values)))
+		return nil
+
+	case target.Kind() == reflect.Slice && target.Type().Elem().Kind() == reflect.String:
+		// As in the INI-like format, where a multi-valued property can be repeated, a single value
+		// is appended to any existing values.
+		var values []string
+		if node.Kind == yaml.SequenceNode {
+			var err error
+			if values, err = decodeYAMLStringList(node, path); err != nil {
+				return err
+			}
+		} else if node.Kind == yaml.ScalarNode {
+			values = []string{node.Value}
+		} else {
+			return errYAMLAtPath(path, node.Line, errYAMLExpectedSingleValue)
+		}
+		for _, value := range values {
+			target.Set(reflect.Append(target, reflect.ValueOf(value).Convert(target.Type().Elem())))
+		}
+		return nil
+	}
+
+	if u, ok := target.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if node.Kind != yaml.ScalarNode {
+			return errYAMLAtPath(path, node.Line, errYAMLExpectedSingleValue)
+		}
+		if err := u.UnmarshalText([]byte(node.Value)); err != nil {
+			return errYAMLAtPath(path, node.Line, err)
+		}
+		return nil
+	}
+
+	if target.Kind() == reflect.Struct {
+		return decodeYAMLStruct(node, target, path)
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return errYAMLAtPath(path, node.Line, errYAMLExpectedSingleValue)
+	}
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(node.Value)
+	case reflect.Bool:
+		b, err := parseConfigBool(node.Value)
+		if err != nil {
+			return errYAMLAtPath(path, node.Line, err)
+		}
+		target.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(node.Value, 10, 64)
+		if err != nil {
+			return errYAMLAtPath(path, node.Line, err)
+		}
+		target.SetInt(n)
+	default:
+		return errYAMLAtPath(path, node.Line, errYAMLUnsupportedType(target.Type()))
+	}
+	return nil
+}
+
+// decodeYAMLMap handles the Environment, Filters, and EventDestination sections, which are maps of
+// names to pointers to structs. As in the INI-like format, properties for a name that already exists
+// in the map are merged into the existing struct.
+func decodeYAMLMap(node *yaml.Node, target reflect.Value, path string) error {
+	if node.Kind != yaml.MappingNode {
+		return errYAMLAtPath(path, node.Line, errYAMLExpectedMapping)
+	}
+	if target.IsNil() {
+		target.Set(reflect.MakeMap(target.Type()))
+	}
+	elemType := target.Type().Elem()
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name := reflect.ValueOf(node.Content[i].Value)
+		elem := target.MapIndex(name)
+		if !elem.IsValid() || elem.IsNil() {
+			elem = reflect.New(elemType.Elem())
+		}
+		if err := decodeYAMLStruct(node.Content[i+1], elem.Elem(), fmt.Sprintf("%s[%q]", path, name)); err != nil {
+			return err
+		}
+		target.SetMapIndex(name, elem)
+	}
+	return nil
+}
+
+func decodeYAMLStringList(node *yaml.Node, path string) ([]string, error) {
+	values := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode {
+			return nil, errYAMLAtPath(path, item.Line, errYAMLExpectedSingleValue)
+		}
+		values = append(values, item.Value)
+	}
+	return values, nil
+}
+
+// findConfigField looks for a struct field whose name matches the key case-insensitively, including
+// fields of embedded structs such as MetricsConfig. It returns the field and its canonical name.
+func findConfigField(target reflect.Value, key string) (reflect.Value, string, bool) {
+	for i := 0; i < target.NumField(); i++ {
+		fieldType := target.Type().Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+		if fieldType.Anonymous && fieldType.Type.Kind() == reflect.Struct {
+			if field, name, ok := findConfigField(target.Field(i), key); ok {
+				return field, name, true
+			}
+			continue
+		}
+		if strings.EqualFold(fieldType.Name, key) {
+			return target.Field(i), fieldType.Name, true
+		}
+	}
+	return reflect.Value{}, "", false
+}
+
+// parseConfigBool accepts the same boolean values as the INI-like format.
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, errYAMLInvalidBool(value)
+}
+
+func joinYAMLPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func describeYAMLPath(path string) string {
+	if path == "" {
+		return "top level"
+	}
+	return path
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+)
+
+func TestConfigFromYAMLFile(t *testing.T) {
+	t.Run("all base properties", func(t *testing.T) {
+		testStructuredFileWithValidConfig(t, "relay.yaml", makeValidConfigAllBaseProperties(), `
+Main:
+  port: 8333
+  baseUri: http://base
+  clientSideBaseUri: http://clientbase
+  streamUri: http://stream
+  exitOnError: true
+  exitAlways: yes
+  ignoreConnectionErrors: 1
+  heartbeatInterval: 90s
+  maxClientConnectionTime: 30m
+  maxClientConnectionTimeJitter: 5m
+  maxStreamConnections: 10000
+  maxStreamConnectionsPerEnv: 2000
+  disconnectedStatusTime: 3m
+  tlsEnabled: true
+  tlsCert: cert
+  tlsKey: key
+  tlsMinVersion: "1.2"
+  tlsClientCA: ca
+  logLevel: warn
+  bigSegmentsStaleAsDegraded: true
+  bigSegmentsStaleThreshold: 10m
+  expiredCredentialCleanupInterval: 1m
+  shutdownDrainTimeout: 30s
+  clientSideEvalStreams: true
+  compressServerSideStreams: true
+  webSocketStreams: true
+Events:
+  sendEvents: true
+  eventsUri: http://events
+  flushInterval: 120s
+  capacity: 500
+  inlineUsers: true
+Environment:
+  earth:
+    sdkKey: earth-sdk
+    mobileKey: earth-mob
+    envId: earth-env
+    prefix: earth-
+    tableName: earth-table
+    logLevel: debug
+  krypton:
+    sdkKey: krypton-sdk
+    mobileKey: krypton-mob
+    envId: krypton-env
+    secureMode: true
+    prefix: krypton-
+    tableName: krypton-table
+    allowedOrigin: [https://oa, https://rann]
+    allowedHeader: Timestamp-Valid,Random-Id-Valid
+    allowedClientCert: [client.krypton]
+    ttl: 5m
+`)
+	})
+
+	t.Run("list values in a metrics section", func(t *testing.T) {
+		testStructuredFileWithValidConfig(t, "relay.yml", makeValidConfigDatadogAll(), `
+Datadog:
+  enabled: true
+  prefix: pre-
+  traceAddr: trace
+  statsAddr: stats
+  tag:
+    - tag1:value1
+    - tag2:value2
+`)
+	})
+
+	t.Run("empty values are ignored", func(t *testing.T) {
+		testStructuredFileWithValidConfig(t, "relay.yaml", testDataValidConfig{
+			makeConfig: func(c *Config) { c.Main.Port = mustOptIntGreaterThanZero(8333) },
+		}, `
+Main:
+  port: 8333
+  logLevel:
+Redis:
+`)
+	})
+
+	t.Run("unknown section", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.yaml", `
+Unknown:
+  x: y
+`, "Unknown (line 2): unsupported or misspelled key")
+	})
+
+	t.Run("unknown property", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.yaml", `
+Main:
+  port: 8333
+  prot: 8334
+`, "Main.prot (line 4): unsupported or misspelled key")
+	})
+
+	t.Run("invalid value in an environment", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.yaml", `
+Environment:
+  earth:
+    sdkKey: earth-sdk
+    ttl: forever
+`, `Environment["earth"].TTL (line 5): not a valid duration`)
+	})
+
+	t.Run("invalid boolean", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.yaml", `
+Main:
+  exitOnError: maybe
+`, `Main.ExitOnError (line 3): "maybe" is not a valid boolean value`)
+	})
+
+	t.Run("mapping where a value is expected", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.yaml", `
+Main:
+  port:
+    value: 8333
+`, "Main.Port (line 4): expected a single value")
+	})
+
+	t.Run("malformed YAML", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.yaml", "Main: [", "failed to read configuration file")
+	})
+
+	t.Run("validation is the same as for other formats", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.yaml", `
+Main:
+  tlsEnabled: true
+`, errTLSEnabledWithoutCertOrKey.Error())
+	})
+}
+
+func TestConfigFromJSONFile(t *testing.T) {
+	t.Run("valid properties", func(t *testing.T) {
+		testStructuredFileWithValidConfig(t, "relay.json", testDataValidConfig{
+			makeConfig: func(c *Config) {
+				c.Main.Port = mustOptIntGreaterThanZero(8333)
+				c.Main.HeartbeatInterval = ct.NewOptDuration(90 * time.Second)
+				c.Main.LogLevel = NewOptLogLevel(ldlog.Warn)
+				c.Environment = map[string]*EnvConfig{
+					"earth": {
+						SDKKey:        "earth-sdk",
+						SecureMode:    true,
+						AllowedOrigin: ct.NewOptStringList([]string{"https://oa", "https://rann"}),
+					},
+				}
+			},
+		}, `{
+  "Main": {"Port": 8333, "HeartbeatInterval": "90s", "LogLevel": "warn"},
+  "Environment": {
+    "earth": {"SDKKey": "earth-sdk", "SecureMode": true, "AllowedOrigin": ["https://oa", "https://rann"]}
+  }
+}`)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, "relay.json", `{
+  "Main": {
+    "Port": -1
+  }
+}`, "Main.Port (line 3): value must be greater than zero")
+	})
+}
+
+func TestConfigFromYAMLFileWithEnvironmentVariableOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+Main:
+  port: 8333
+  logLevel: warn
+`), 0600))
+
+	t.Setenv("PORT", "9000")
+
+	var c Config
+	require.NoError(t, LoadConfigFile(&c, path, ldlog.NewDisabledLoggers()))
+	require.NoError(t, LoadConfigFromEnvironment(&c, ldlog.NewDisabledLoggers()))
+	assert.Equal(t, mustOptIntGreaterThanZero(9000), c.Main.Port)
+	assert.Equal(t, NewOptLogLevel(ldlog.Warn), c.Main.LogLevel)
+}
+
+func testStructuredFileWithValidConfig(t *testing.T, fileName string, tdc testDataValidConfig, fileContent string) {
+	path := filepath.Join(t.TempDir(), fileName)
+	require.NoError(t, os.WriteFile(path, []byte(fileContent), 0600))
+
+	var c Config
+	mockLog := ldlogtest.NewMockLog()
+	require.NoError(t, LoadConfigFile(&c, path, mockLog.Loggers))
+	tdc.assertResult(t, c, mockLog)
+}
+
+func testStructuredFileWithInvalidConfig(t *testing.T, fileName string, fileContent string, errMessage string) {
+	path := filepath.Join(t.TempDir(), fileName)
+	require.NoError(t, os.WriteFile(path, []byte(fileContent), 0600))
+
+	var c Config
+	err := LoadConfigFile(&c, path, ldlog.NewDisabledLoggers())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errMessage)
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	ct "github.com/launchdarkly/go-configtypes"
+)
+
+const (
+	// SecretFileReferencePrefix and SecretReferenceSuffix surround a file path in any secret setting, such
+	// as an SDK key or a password, to indicate that the actual value should be read from that file: for
+	// instance, "${file:/run/secrets/sdk-key}".
+	SecretFileReferencePrefix = "${file:"
+
+	// SecretEnvVarReferencePrefix and SecretReferenceSuffix surround an environment variable name in any
+	// secret setting, to indicate that the actual value should be read from that variable: for instance,
+	// "${env:MY_SDK_KEY}".
+	SecretEnvVarReferencePrefix = "${env:"
+
+	// SecretReferenceSuffix ends a secret reference.
+	SecretReferenceSuffix = "}"
+
+	// secretReferenceEscape is removed from the start of a secret setting whose value starts with it, so
+	// that a secret that really does start with "${" can be written as "$${".
+	secretReferenceEscape = "$"
+)
+
+func errSecretFileUnreadable(setting, path string, err error) error {
+	return fmt.Errorf("unable to read %s from file %q: %w", setting, path, err)
+}
+
+func errSecretEnvVarNotSet(setting, name string) error {
+	return fmt.Errorf("%s refers to environment variable %q, which is not set", setting, name)
+}
+
+func errSecretAndSecretFileVars(name, fileVarName string) error {
+	return fmt.Errorf("%s and %s cannot both be set", name, fileVarName)
+}
+
+// secretFileReference returns a secret setting value that refers to the given file.
+func secretFileReference(path string) string {
+	return SecretFileReferencePrefix + path + SecretReferenceSuffix
+}
+
+// parseSecretReference returns the file path or environment variable name that a secret setting value
+// refers to, if it has the form "${file:PATH}" or "${env:NAME}"; only one of those is non-empty.
+func parseSecretReference(value string) (path, varName string) {
+	if !strings.HasSuffix(value, SecretReferenceSuffix) {
+		return "", ""
+	}
+	switch {
+	case strings.HasPrefix(value, SecretFileReferencePrefix):
+		return strings.TrimSuffix(strings.TrimPrefix(value, SecretFileReferencePrefix), SecretReferenceSuffix), ""
+	case strings.HasPrefix(value, SecretEnvVarReferencePrefix):
+		return "", strings.TrimSuffix(strings.TrimPrefix(value, SecretEnvVarReferencePrefix), SecretReferenceSuffix)
+	}
+	return "", ""
+}
+
+// SecretFiles returns the paths of all files that secret settings were read from when this
+// configuration was loaded, so that the caller can watch them for changes.
+func (c Config) SecretFiles() []string {
+	return c.secretFiles
+}
+
+type secretSetting struct {
+	name  string
+	value *string
+}
+
+// getSecretSettings returns pointers to all of the configuration fields that can contain secret
+// references. The ones with named string types, such as SDKKey, are converted to *string so that
+// they can all be updated in the same way.
+func getSecretSettings(c *Config) []secretSetting {
+	ret := []secretSetting{
+		{"AutoConfig.Key", (*string)(&c.AutoConfig.Key)},
+		{"Redis.Password", &c.Redis.Password},
+		{"Consul.Token", &c.Consul.Token},
+		{"Proxy.Password", &c.Proxy.Password},
+	}
+	envNames := make([]string, 0, len(c.Environment))
+	for name := range c.Environment {
+		envNames = append(envNames, name)
+	}
+	sort.Strings(envNames)
+	for _, name := range envNames {
+		ec := c.Environment[name]
+		if ec == nil {
+			continue
+		}
+		ret = append(ret,
+			secretSetting{fmt.Sprintf("Environment[%q].SDKKey", name), (*string)(&ec.SDKKey)},
+			secretSetting{fmt.Sprintf("Environment[%q].MobileKey", name), (*string)(&ec.MobileKey)},
+		)
+	}
+	return ret
+}
+
+// resolveSecretReferences replaces any secret setting whose value is a reference of the form
+// "${file:PATH}" or "${env:NAME}" with the contents of the file or environment variable it refers to.
+// Leading and trailing whitespace is removed from file contents, since secret files often end with a
+// newline. A value that starts with "$${" is not a reference; the first "$" is removed from it.
+//
+// The paths of any files that were read are added to the list returned by SecretFiles.
+func resolveSecretReferences(c *Config) error {
+	for _, s := range getSecretSettings(c) {
+		if strings.HasPrefix(*s.value, secretReferenceEscape+"${") {
+			*s.value = strings.TrimPrefix(*s.value, secretReferenceEscape)
+			continue
+		}
+		path, varName := parseSecretReference(*s.value)
+		switch {
+		case path != "":
+			data, err := os.ReadFile(path) //nolint:gosec // the path is provided by the administrator
+			if err != nil {
+				return errSecretFileUnreadable(s.name, path, err)
+			}
+			*s.value = strings.TrimSpace(string(data))
+			c.addSecretFile(path)
+		case varName != "":
+			value, ok := os.LookupEnv(varName)
+			if !ok {
+				return errSecretEnvVarNotSet(s.name, varName)
+			}
+			*s.value = value
+		}
+	}
+	return nil
+}
+
+func (c *Config) addSecretFile(path string) {
+	for _, p := range c.secretFiles {
+		if p == path {
+			return
+		}
+	}
+	c.secretFiles = append(c.secretFiles, path)
+}
+
+// readSecretFileVar reads a variable that contains the path of a secret file, such as REDIS_PASSWORD_FILE.
+// If it is set, the setting becomes a reference to that file. It is an error to set both that variable
+// and the variable that contains the secret itself.
+func readSecretFileVar(reader *ct.VarReader, varName, fileVarName string, target *string) {
+	var path string
+	reader.Read(fileVarName, &path)
+	if path == "" {
+		return
+	}
+	var value string
+	if reader.Read(varName, &value) && value != "" {
+		reader.AddError(ct.ValidationPath{fileVarName}, errSecretAndSecretFileVars(varName, fileVarName))
+		return
+	}
+	*target = secretFileReference(path)
+}
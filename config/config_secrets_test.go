@@ -0,0 +1,167 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+func makeSecretFile(t *testing.T, name, value string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(value), 0600))
+	return path
+}
+
+func TestSecretReferencesInFile(t *testing.T) {
+	t.Run("file references", func(t *testing.T) {
+		sdkKeyFile := makeSecretFile(t, "sdk-key", "earth-sdk\n")
+		mobileKeyFile := makeSecretFile(t, "mobile-key", "earth-mob")
+		passwordFile := makeSecretFile(t, "password", "  secret\n")
+		configFile := makeSecretFile(t, "relay.conf", `
+[Environment "earth"]
+SDKKey = "${file:`+sdkKeyFile+`}"
+MobileKey = "${file:`+mobileKeyFile+`}"
+
+[Redis]
+Host = "localhost"
+Password = "${file:`+passwordFile+`}"
+
+[Proxy]
+Url = "http://proxy"
+User = "user"
+Password = "${file:`+passwordFile+`}"
+`)
+
+		var c Config
+		require.NoError(t, LoadConfigFile(&c, configFile, ldlog.NewDisabledLoggers()))
+		assert.Equal(t, SDKKey("earth-sdk"), c.Environment["earth"].SDKKey)
+		assert.Equal(t, MobileKey("earth-mob"), c.Environment["earth"].MobileKey)
+		assert.Equal(t, "secret", c.Redis.Password)
+		assert.Equal(t, "secret", c.Proxy.Password)
+		assert.ElementsMatch(t, []string{sdkKeyFile, mobileKeyFile, passwordFile}, c.SecretFiles())
+	})
+
+	t.Run("environment variable references", func(t *testing.T) {
+		t.Setenv("MY_AUTO_CONFIG_KEY", "rel-abc")
+		configFile := makeSecretFile(t, "relay.conf", `
+[AutoConfig]
+Key = "${env:MY_AUTO_CONFIG_KEY}"
+`)
+
+		var c Config
+		require.NoError(t, LoadConfigFile(&c, configFile, ldlog.NewDisabledLoggers()))
+		assert.Equal(t, AutoConfigKey("rel-abc"), c.AutoConfig.Key)
+		assert.Len(t, c.SecretFiles(), 0)
+	})
+
+	t.Run("values that are not references", func(t *testing.T) {
+		configFile := makeSecretFile(t, "relay.conf", `
+[Environment "earth"]
+SDKKey = "file:not-a-reference"
+MobileKey = "$${file:not-a-reference}"
+
+[Redis]
+Host = "localhost"
+Password = "${file:not-a-reference"
+`)
+
+		var c Config
+		require.NoError(t, LoadConfigFile(&c, configFile, ldlog.NewDisabledLoggers()))
+		assert.Equal(t, SDKKey("file:not-a-reference"), c.Environment["earth"].SDKKey)
+		assert.Equal(t, MobileKey("${file:not-a-reference}"), c.Environment["earth"].MobileKey)
+		assert.Equal(t, "${file:not-a-reference", c.Redis.Password)
+		assert.Len(t, c.SecretFiles(), 0)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		configFile := makeSecretFile(t, "relay.conf", `
+[Environment "earth"]
+SDKKey = "${file:/no/such/file}"
+`)
+
+		var c Config
+		err := LoadConfigFile(&c, configFile, ldlog.NewDisabledLoggers())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unable to read Environment["earth"].SDKKey from file "/no/such/file"`)
+	})
+
+	t.Run("missing environment variable", func(t *testing.T) {
+		configFile := makeSecretFile(t, "relay.conf", `
+[Proxy]
+Url = "http://proxy"
+User = "user"
+Password = "${env:NO_SUCH_VARIABLE_FOR_RELAY_TEST}"
+`)
+
+		var c Config
+		err := LoadConfigFile(&c, configFile, ldlog.NewDisabledLoggers())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `Proxy.Password refers to environment variable "NO_SUCH_VARIABLE_FOR_RELAY_TEST"`)
+	})
+}
+
+func TestSecretFileEnvironmentVariables(t *testing.T) {
+	t.Run("_FILE variables", func(t *testing.T) {
+		sdkKeyFile := makeSecretFile(t, "sdk-key", "earth-sdk\n")
+		mobileKeyFile := makeSecretFile(t, "mobile-key", "earth-mob\n")
+		passwordFile := makeSecretFile(t, "password", "secret\n")
+		vars := map[string]string{
+			"LD_FILE_ENV_earth":        sdkKeyFile,
+			"LD_FILE_MOBILE_KEY_earth": mobileKeyFile,
+			"USE_REDIS":                "1",
+			"REDIS_PASSWORD_FILE":      passwordFile,
+			"PROXY_URL":                "http://proxy",
+			"PROXY_AUTH_USER":          "user",
+			"PROXY_AUTH_PASSWORD_FILE": passwordFile,
+		}
+		withEnvironment(vars, func() {
+			var c Config
+			require.NoError(t, LoadConfigFromEnvironment(&c, ldlog.NewDisabledLoggers()))
+			require.Contains(t, c.Environment, "earth")
+			assert.Len(t, c.Environment, 1)
+			assert.Equal(t, SDKKey("earth-sdk"), c.Environment["earth"].SDKKey)
+			assert.Equal(t, MobileKey("earth-mob"), c.Environment["earth"].MobileKey)
+			assert.Equal(t, "secret", c.Redis.Password)
+			assert.Equal(t, "secret", c.Proxy.Password)
+			assert.ElementsMatch(t, []string{sdkKeyFile, mobileKeyFile, passwordFile}, c.SecretFiles())
+		})
+	})
+
+	t.Run("AUTO_CONFIG_KEY_FILE", func(t *testing.T) {
+		keyFile := makeSecretFile(t, "auto-config-key", "rel-abc\n")
+		withEnvironment(map[string]string{"AUTO_CONFIG_KEY_FILE": keyFile}, func() {
+			var c Config
+			require.NoError(t, LoadConfigFromEnvironment(&c, ldlog.NewDisabledLoggers()))
+			assert.Equal(t, AutoConfigKey("rel-abc"), c.AutoConfig.Key)
+		})
+	})
+
+	t.Run("environment name can end in _FILE", func(t *testing.T) {
+		withEnvironment(map[string]string{"LD_ENV_earth_FILE": "earth-sdk"}, func() {
+			var c Config
+			require.NoError(t, LoadConfigFromEnvironment(&c, ldlog.NewDisabledLoggers()))
+			require.Contains(t, c.Environment, "earth_FILE")
+			assert.Equal(t, SDKKey("earth-sdk"), c.Environment["earth_FILE"].SDKKey)
+			assert.Len(t, c.SecretFiles(), 0)
+		})
+	})
+
+	t.Run("variable and file variable cannot both be set", func(t *testing.T) {
+		keyFile := makeSecretFile(t, "sdk-key", "earth-sdk")
+		testInvalidConfigVars(t, map[string]string{
+			"LD_ENV_earth":      "earth-sdk",
+			"LD_FILE_ENV_earth": keyFile,
+		}, "LD_ENV_earth and LD_FILE_ENV_earth cannot both be set")
+		testInvalidConfigVars(t, map[string]string{
+			"PROXY_URL":                "http://proxy",
+			"PROXY_AUTH_USER":          "user",
+			"PROXY_AUTH_PASSWORD":      "secret",
+			"PROXY_AUTH_PASSWORD_FILE": keyFile,
+		}, "PROXY_AUTH_PASSWORD and PROXY_AUTH_PASSWORD_FILE cannot both be set")
+	})
+}
@@ -7,17 +7,29 @@ import (
 
 	ct "github.com/launchdarkly/go-configtypes"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 )
 
 var (
-	errTLSEnabledWithoutCertOrKey      = errors.New("TLS cert and key are required if TLS is enabled")
-	errAutoConfPropertiesWithNoKey     = errors.New("must specify auto-configuration key if other auto-configuration properties are set")
-	errAutoConfWithEnvironments        = errors.New("cannot configure specific environments if auto-configuration is enabled")
-	errFileDataWithAutoConf            = errors.New("cannot specify both auto-configuration key and file data source")
-	errOfflineModePropertiesWithNoFile = errors.New("must specify offline mode filename if other offline mode properties are set")
-	errOfflineModeWithEnvironments     = errors.New("cannot configure specific environments if offline mode is enabled")
-	errMaxInboundPayloadSize           = errors.New("max inbound payload size must be greater than zero")
-	errAutoConfWithoutDBDisambig       = errors.New(`when using auto-configuration with database storage, database prefix (or,` +
+	errTLSEnabledWithoutCertOrKey       = errors.New("TLS cert and key are required if TLS is enabled")
+	errTLSClientCAWithoutTLS            = errors.New("TLS must be enabled if a TLS client CA is specified")
+	errAutoConfPropertiesWithNoKey      = errors.New("must specify auto-configuration key if other auto-configuration properties are set")
+	errAutoConfWithEnvironments         = errors.New("cannot configure specific environments if auto-configuration is enabled")
+	errFileDataWithAutoConf             = errors.New("cannot specify both auto-configuration key and file data source")
+	errOfflineModePropertiesWithNoFile  = errors.New("must specify offline mode filename if other offline mode properties are set")
+	errOfflineModeWithEnvironments      = errors.New("cannot configure specific environments if offline mode is enabled")
+	errMaxInboundPayloadSize            = errors.New("max inbound payload size must be greater than zero")
+	errEventSpoolMaxSize                = errors.New("event spool max size must be greater than zero")
+	errEventsSinkWithoutSendEvents      = errors.New("an events sink is specified, but sending events is not enabled")
+	errEventsSinkFileWithNoPath         = errors.New("events sink path is required if events sink is file")
+	errEventsSinkWebhookWithNoURI       = errors.New("events sink URI is required if events sink is webhook")
+	errEventsSinkMaxFileSize            = errors.New("events sink max file size must be greater than zero")
+	errEventsHighWaterMarkAboveCapacity = errors.New("events high-water mark cannot be greater than events capacity")
+	errEventsMaxMemory                  = errors.New("events max memory must be greater than zero")
+	errEventsOTLPWithoutSendEvents      = errors.New("an OTLP endpoint for events is specified, but sending events is not enabled")
+	errEventsOTLPWithoutEndpoint        = errors.New("OTLP endpoint for events is required if other OTLP properties are set")
+	errEventsOTLPGRPCWithProxy          = errors.New("OTLP protocol for events cannot be grpc if a proxy is configured")
+	errAutoConfWithoutDBDisambig        = errors.New(`when using auto-configuration with database storage, database prefix (or,` +
 		` if using DynamoDB, table name) must be specified and must contain "` + AutoConfigEnvironmentIDPlaceholder + `"`)
 	errRedisURLWithHostAndPort                 = errors.New("please specify Redis URL or host/port, but not both")
 	errRedisBadHostname                        = errors.New("invalid Redis hostname")
@@ -26,12 +38,71 @@ var (
 	errMissingProjKey                          = errors.New("when filters are configured, all environments must specify a 'projKey'")
 	errInvalidFileDataSourceMonitoringInterval = fmt.Errorf("file data source monitoring interval must be >= %s", minimumFileDataSourceMonitoringInterval)
 	errInvalidCredentialCleanupInterval        = fmt.Errorf("expired credential cleanup interval must be >= %s", minimumCredentialCleanupInterval)
+	errAdminPortWithoutToken                   = errors.New("admin API token is required if admin port is set")
+	errAdminPortSameAsMainPort                 = errors.New("admin API port must be different from the main port")
+	errAdminEventDebugWithoutPort              = errors.New("admin API port is required if event debugging is enabled")
+	errReadinessMinPercentRequired             = errors.New("readiness minimum percent is required if readiness policy is minPercent")
+	errReadinessMinPercentTooHigh              = errors.New("readiness minimum percent cannot be greater than 100")
+	errReadinessAutoConfigWithNoKey            = errors.New("readiness policy autoConfigReceived requires an auto-configuration key")
+	errMaxClientConnectionTimeJitter           = errors.New("max client connection time jitter must be less than max client connection time")
 )
 
+func errEnvironmentEventRule(envName string, err error) error {
+	return fmt.Errorf("environment %q: %w", envName, err)
+}
+
+func errCannotRedactContextAttribute(name string) error {
+	return fmt.Errorf("context attribute %q cannot be removed or hashed in events", name)
+}
+
+func errBadDropIdentifyEventsSDKKind(value string) error {
+	return fmt.Errorf(`%q is not a valid SDK kind for dropping identify events; expected "server", "mobile", or "js"`, value)
+}
+
 func errEnvironmentWithNoSDKKey(envName string) error {
 	return fmt.Errorf("SDK key is required for environment %q", envName)
 }
 
+func errAllowedClientCertWithoutClientCA(envName string) error {
+	return fmt.Errorf("environment %q specifies allowed client certificates, but no TLS client CA is specified", envName)
+}
+
+func errEventDestinationWithNoURI(name string) error {
+	return fmt.Errorf("URI is required for event destination %q", name)
+}
+
+func errEventDestination(name string, err error) error {
+	return fmt.Errorf("event destination %q: %w", name, err)
+}
+
+func errBadEventDestinationMode(mode EventDestinationMode) error {
+	return fmt.Errorf(`%q is not a valid event destination mode; expected "summarized" or "raw"`, mode)
+}
+
+func errBadEventsOverloadPolicy(policy EventsOverloadPolicy) error {
+	return fmt.Errorf(`%q is not a valid events overload policy; expected "drop", "reject-429", or "reject-503"`, policy)
+}
+
+func errBadEventsOTLPProtocol(protocol EventsOTLPProtocol) error {
+	return fmt.Errorf(`%q is not a valid OTLP protocol for events; expected "http" or "grpc"`, protocol)
+}
+
+func errBadEventsOTLPSignal(signal EventsOTLPSignal) error {
+	return fmt.Errorf(`%q is not a valid OTLP signal for events; expected "logs" or "traces"`, signal)
+}
+
+func errEventsOTLPHeader(err error) error {
+	return fmt.Errorf("OTLP header for events: %w", err)
+}
+
+func errBadEventsSink(sink EventsSinkKind) error {
+	return fmt.Errorf(`%q is not a valid events sink; expected "file", "stdout", or "webhook"`, sink)
+}
+
+func errBadReadinessPolicy(policy ReadinessPolicy) error {
+	return fmt.Errorf("%q is not a valid readiness policy", policy)
+}
+
 func errMultipleDatabases(databases []string) error {
 	return fmt.Errorf("multiple databases are enabled (%s); only one is allowed", strings.Join(databases, ", "))
 }
@@ -82,6 +153,14 @@ func ValidateConfig(c *Config, loggers ldlog.Loggers) error {
 	validateOfflineMode(&result, c)
 	validateCredentialCleanupInterval(&result, c)
 	validateMaxInboundPayloadSize(&result, c)
+	validateEventSpool(&result, c)
+	validateEventsSink(&result, c)
+	validateEventDestinations(&result, c)
+	validateEventsOverload(&result, c)
+	validateEventsOTLP(&result, c)
+	validateAdmin(&result, c)
+	validateReadiness(&result, c)
+	validateMaxClientConnectionTimeJitter(&result, c)
 
 	return result.GetError()
 }
@@ -115,6 +194,16 @@ func validateConfigTLS(result *ct.ValidationResult, c *Config) {
 	if c.Main.TLSEnabled && (c.Main.TLSCert == "" || c.Main.TLSKey == "") {
 		result.AddError(nil, errTLSEnabledWithoutCertOrKey)
 	}
+	if c.Main.TLSClientCA != "" && !c.Main.TLSEnabled {
+		result.AddError(nil, errTLSClientCAWithoutTLS)
+	}
+	if c.Main.TLSClientCA == "" {
+		for envName, envConfig := range c.Environment {
+			if len(envConfig.AllowedClientCert.Values()) != 0 {
+				result.AddError(nil, errAllowedClientCertWithoutClientCA(envName))
+			}
+		}
+	}
 }
 
 func validateConfigEnvironments(result *ct.ValidationResult, c *Config) {
@@ -144,6 +233,44 @@ func validateConfigEnvironments(result *ct.ValidationResult, c *Config) {
 		if envConfig.SDKKey == "" {
 			result.AddError(nil, errEnvironmentWithNoSDKKey(envName))
 		}
+		validateEnvironmentEventRules(result, envName, envConfig)
+	}
+}
+
+// ValidateEnvConfig checks the properties of an environment that is being added to a running Relay
+// instance, such as with the admin API, in the same way that ValidateConfig checks the environments
+// in the configuration. The main configuration c is used for properties that depend on other sections.
+func ValidateEnvConfig(envName string, envConfig *EnvConfig, c *Config) error {
+	var result ct.ValidationResult
+	if c.Main.TLSClientCA == "" && len(envConfig.AllowedClientCert.Values()) != 0 {
+		result.AddError(nil, errAllowedClientCertWithoutClientCA(envName))
+	}
+	validateEnvironmentEventRules(&result, envName, envConfig)
+	return result.GetError()
+}
+
+func validateEnvironmentEventRules(result *ct.ValidationResult, envName string, envConfig *EnvConfig) {
+	for _, s := range envConfig.SampleCustomEvent.Values() {
+		if _, err := ParseCustomEventSampling(s); err != nil {
+			result.AddError(nil, errEnvironmentEventRule(envName, err))
+		}
+	}
+	for _, name := range envConfig.StripContextAttribute.Values() {
+		if name == "kind" || name == "key" || name == "_meta" {
+			result.AddError(nil, errEnvironmentEventRule(envName, errCannotRedactContextAttribute(name)))
+		}
+	}
+	for _, name := range envConfig.HashContextAttribute.Values() {
+		if name == "kind" || name == "_meta" { // the key can be hashed, but not removed
+			result.AddError(nil, errEnvironmentEventRule(envName, errCannotRedactContextAttribute(name)))
+		}
+	}
+	for _, value := range envConfig.DropIdentifyEvents.Values() {
+		switch basictypes.SDKKind(value) {
+		case basictypes.ServerSDK, basictypes.MobileSDK, basictypes.JSClientSDK:
+		default:
+			result.AddError(nil, errEnvironmentEventRule(envName, errBadDropIdentifyEventsSDKKind(value)))
+		}
 	}
 }
 
@@ -218,6 +345,145 @@ func validateMaxInboundPayloadSize(result *ct.ValidationResult, c *Config) {
 	}
 }
 
+func validateEventSpool(result *ct.ValidationResult, c *Config) {
+	if c.Events.SpoolMaxSize.IsDefined() && c.Events.SpoolMaxSize.GetOrElse(0) <= 0 {
+		result.AddError(nil, errEventSpoolMaxSize)
+	}
+}
+
+func validateEventsSink(result *ct.ValidationResult, c *Config) {
+	switch c.Events.Sink {
+	case "":
+		return
+	case EventsSinkFile:
+		if c.Events.SinkPath == "" {
+			result.AddError(nil, errEventsSinkFileWithNoPath)
+		}
+		if c.Events.SinkMaxFileSize.IsDefined() && c.Events.SinkMaxFileSize.GetOrElse(0) <= 0 {
+			result.AddError(nil, errEventsSinkMaxFileSize)
+		}
+	case EventsSinkStdout:
+	case EventsSinkWebhook:
+		if !c.Events.SinkURI.IsDefined() {
+			result.AddError(nil, errEventsSinkWebhookWithNoURI)
+		}
+	default:
+		result.AddError(nil, errBadEventsSink(c.Events.Sink))
+		return
+	}
+	if !c.Events.SendEvents {
+		result.AddError(nil, errEventsSinkWithoutSendEvents)
+	}
+}
+
+func validateEventsOverload(result *ct.ValidationResult, c *Config) {
+	switch c.Events.OverloadPolicy {
+	case "", EventsOverloadDrop, EventsOverloadReject429, EventsOverloadReject503:
+	default:
+		result.AddError(nil, errBadEventsOverloadPolicy(c.Events.OverloadPolicy))
+	}
+	if c.Events.HighWaterMark.GetOrElse(0) > c.Events.Capacity.GetOrElse(DefaultEventCapacity) {
+		result.AddError(nil, errEventsHighWaterMarkAboveCapacity)
+	}
+	if (c.Events.MaxMemoryPerEnv.IsDefined() && c.Events.MaxMemoryPerEnv.GetOrElse(0) <= 0) ||
+		(c.Events.MaxMemory.IsDefined() && c.Events.MaxMemory.GetOrElse(0) <= 0) {
+		result.AddError(nil, errEventsMaxMemory)
+	}
+}
+
+func validateEventsOTLP(result *ct.ValidationResult, c *Config) {
+	switch c.Events.OTLPProtocol {
+	case "", EventsOTLPHTTP, EventsOTLPGRPC:
+	default:
+		result.AddError(nil, errBadEventsOTLPProtocol(c.Events.OTLPProtocol))
+	}
+	switch c.Events.OTLPSignal {
+	case "", EventsOTLPLogs, EventsOTLPTraces:
+	default:
+		result.AddError(nil, errBadEventsOTLPSignal(c.Events.OTLPSignal))
+	}
+	for _, h := range c.Events.OTLPHeader.Values() {
+		if _, _, err := ParseEventDestinationHeader(h); err != nil {
+			result.AddError(nil, errEventsOTLPHeader(err))
+		}
+	}
+	if !c.Events.OTLPEndpoint.IsDefined() {
+		if c.Events.OTLPProtocol != "" || c.Events.OTLPSignal != "" || len(c.Events.OTLPHeader.Values()) != 0 {
+			result.AddError(nil, errEventsOTLPWithoutEndpoint)
+		}
+		return
+	}
+	if !c.Events.SendEvents {
+		result.AddError(nil, errEventsOTLPWithoutSendEvents)
+	}
+	if c.Events.OTLPProtocol == EventsOTLPGRPC && c.Proxy.URL.IsDefined() {
+		result.AddError(nil, errEventsOTLPGRPCWithProxy) // the gRPC client does not use the proxy settings
+	}
+}
+
+func validateEventDestinations(result *ct.ValidationResult, c *Config) {
+	for name, dest := range c.EventDestination {
+		if !dest.URI.IsDefined() {
+			result.AddError(nil, errEventDestinationWithNoURI(name))
+		}
+		for _, h := range dest.Header.Values() {
+			if _, _, err := ParseEventDestinationHeader(h); err != nil {
+				result.AddError(nil, errEventDestination(name, err))
+			}
+		}
+		switch dest.Mode {
+		case "", EventDestinationSummarized, EventDestinationRaw:
+		default:
+			result.AddError(nil, errEventDestination(name, errBadEventDestinationMode(dest.Mode)))
+		}
+	}
+}
+
+func validateAdmin(result *ct.ValidationResult, c *Config) {
+	if !c.Admin.Port.IsDefined() {
+		if c.Admin.EventDebug {
+			result.AddError(nil, errAdminEventDebugWithoutPort)
+		}
+		return
+	}
+	if c.Admin.Token == "" {
+		result.AddError(nil, errAdminPortWithoutToken)
+	}
+	if c.Admin.Port.GetOrElse(0) == c.Main.Port.GetOrElse(DefaultPort) {
+		result.AddError(nil, errAdminPortSameAsMainPort)
+	}
+}
+
+func validateReadiness(result *ct.ValidationResult, c *Config) {
+	switch c.Main.ReadinessPolicy {
+	case "", ReadinessAllEnvironments:
+	case ReadinessMinPercent:
+		if !c.Main.ReadinessMinPercent.IsDefined() {
+			result.AddError(nil, errReadinessMinPercentRequired)
+		}
+	case ReadinessAutoConfigReceived:
+		if !c.AutoConfig.Key.Defined() {
+			result.AddError(nil, errReadinessAutoConfigWithNoKey)
+		}
+	default:
+		result.AddError(nil, errBadReadinessPolicy(c.Main.ReadinessPolicy))
+	}
+	if c.Main.ReadinessMinPercent.GetOrElse(0) > 100 {
+		result.AddError(nil, errReadinessMinPercentTooHigh)
+	}
+}
+
+func validateMaxClientConnectionTimeJitter(result *ct.ValidationResult, c *Config) {
+	maxConnTime := c.Main.MaxClientConnectionTime.GetOrElse(0)
+	if !c.Main.MaxClientConnectionTimeJitter.IsDefined() || maxConnTime <= 0 {
+		return // the jitter has no effect if connections do not have a maximum time
+	}
+	jitter := c.Main.MaxClientConnectionTimeJitter.GetOrElse(0)
+	if jitter < 0 || jitter >= maxConnTime {
+		result.AddError(nil, errMaxClientConnectionTimeJitter)
+	}
+}
+
 func validateConfigDatabases(result *ct.ValidationResult, c *Config, loggers ldlog.Loggers) {
 	normalizeRedisConfig(result, c)
 
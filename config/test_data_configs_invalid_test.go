@@ -12,12 +12,38 @@ func makeInvalidConfigs() []testDataInvalidConfig {
 	return []testDataInvalidConfig{
 		makeInvalidConfigMissingSDKKey(),
 		makeInvalidConfigCredentialCleanupInterval("0s"),
+		makeInvalidConfigEventSpoolMaxSize(),
+		makeInvalidConfigEventsSinkUnknown(),
+		makeInvalidConfigEventsSinkFileWithNoPath(),
+		makeInvalidConfigEventsSinkWebhookWithNoURI(),
+		makeInvalidConfigEventsSinkWithoutSendEvents(),
+		makeInvalidConfigEventsOverloadPolicy(),
+		makeInvalidConfigEventsHighWaterMark(),
+		makeInvalidConfigEventsMaxMemory(),
+		makeInvalidConfigEventsOTLPProtocol(),
+		makeInvalidConfigEventsOTLPSignal(),
+		makeInvalidConfigEventsOTLPWithoutEndpoint(),
+		makeInvalidConfigEventsOTLPWithoutSendEvents(),
+		makeInvalidConfigEventsOTLPGRPCWithProxy(),
+		makeInvalidConfigEventDestinationWithNoURI(),
+		makeInvalidConfigEventDestinationHeader(),
+		makeInvalidConfigEventDestinationMode(),
+		makeInvalidConfigEventRule("SampleCustomEvent", "LD_SAMPLE_CUSTOM_EVENT_", "page-view",
+			`"page-view" is not a valid custom event sampling rule`),
+		makeInvalidConfigEventRule("StripContextAttribute", "LD_STRIP_CONTEXT_ATTRIBUTE_", "key",
+			`context attribute "key" cannot be removed or hashed in events`),
+		makeInvalidConfigEventRule("HashContextAttribute", "LD_HASH_CONTEXT_ATTRIBUTE_", "kind",
+			`context attribute "kind" cannot be removed or hashed in events`),
+		makeInvalidConfigEventRule("DropIdentifyEvents", "LD_DROP_IDENTIFY_EVENTS_", "php",
+			`"php" is not a valid SDK kind for dropping identify events`),
 		makeInvalidConfigCredentialCleanupInterval("-1s"),
 		makeInvalidConfigCredentialCleanupInterval("99ms"),
 		makeInvalidConfigTLSWithNoCertOrKey(),
 		makeInvalidConfigTLSWithNoCert(),
 		makeInvalidConfigTLSWithNoKey(),
 		makeInvalidConfigTLSVersion(),
+		makeInvalidConfigTLSClientCAWithoutTLS(),
+		makeInvalidConfigAllowedClientCertWithoutClientCA(),
 		makeInvalidConfigAutoConfKeyWithEnvironments(),
 		makeInvalidConfigAutoConfAllowedOriginWithNoKey(),
 		makeInvalidConfigAutoConfAllowedHeaderWithNoKey(),
@@ -43,6 +69,14 @@ func makeInvalidConfigs() []testDataInvalidConfig {
 		makeInvalidConfigDynamoDBNoPrefixOrTableName(),
 		makeInvalidConfigDynamoDBAutoConfNoPrefixOrTableName(),
 		makeInvalidConfigMultipleDatabases(),
+		makeInvalidConfigAdminPortWithoutToken(),
+		makeInvalidConfigAdminPortSameAsMainPort(),
+		makeInvalidConfigAdminEventDebugWithoutPort(),
+		makeInvalidConfigReadinessPolicyUnknown(),
+		makeInvalidConfigReadinessMinPercentMissing(),
+		makeInvalidConfigReadinessMinPercentTooHigh(),
+		makeInvalidConfigReadinessAutoConfigWithNoKey(),
+		makeInvalidConfigMaxClientConnectionTimeJitterTooHigh(),
 	}
 }
 
@@ -103,6 +137,29 @@ TLSMinVersion = x
 	return c
 }
 
+func makeInvalidConfigTLSClientCAWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "TLS client CA without TLS"}
+	c.envVarsError = "TLS must be enabled if a TLS client CA is specified"
+	c.envVars = map[string]string{"TLS_CLIENT_CA": "ca.pem"}
+	c.fileContent = `
+[Main]
+TLSClientCA = ca.pem
+`
+	return c
+}
+
+func makeInvalidConfigAllowedClientCertWithoutClientCA() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "allowed client certs without TLS client CA"}
+	c.envVarsError = `environment "earth" specifies allowed client certificates, but no TLS client CA is specified`
+	c.envVars = map[string]string{"LD_ENV_earth": "earth-sdk", "LD_ALLOWED_CLIENT_CERT_earth": "client.example.com"}
+	c.fileContent = `
+[Environment "earth"]
+SDKKey = earth-sdk
+AllowedClientCert = client.example.com
+`
+	return c
+}
+
 func makeInvalidConfigAutoConfKeyWithEnvironments() testDataInvalidConfig {
 	c := testDataInvalidConfig{name: "auto-conf key with environments"}
 	c.envVarsError = errAutoConfWithEnvironments.Error()
@@ -467,3 +524,323 @@ Enabled = true
 `
 	return c
 }
+
+func makeInvalidConfigAdminPortWithoutToken() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin port without token"}
+	c.envVarsError = "admin API token is required if admin port is set"
+	c.envVars = map[string]string{"ADMIN_PORT": "8032"}
+	c.fileContent = `
+[Admin]
+Port = 8032
+`
+	return c
+}
+
+func makeInvalidConfigAdminPortSameAsMainPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin port same as main port"}
+	c.envVarsError = "admin API port must be different from the main port"
+	c.envVars = map[string]string{"ADMIN_PORT": "8030", "ADMIN_TOKEN": "secret"}
+	c.fileContent = `
+[Admin]
+Port = 8030
+Token = "secret"
+`
+	return c
+}
+
+func makeInvalidConfigAdminEventDebugWithoutPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin event debugging without port"}
+	c.envVarsError = "admin API port is required if event debugging is enabled"
+	c.envVars = map[string]string{"ADMIN_EVENT_DEBUG": "true"}
+	c.fileContent = `
+[Admin]
+EventDebug = true
+`
+	return c
+}
+
+func makeInvalidConfigReadinessPolicyUnknown() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "unknown readiness policy"}
+	c.envVarsError = `"whenever" is not a valid readiness policy`
+	c.envVars = map[string]string{"READINESS_POLICY": "whenever"}
+	c.fileContent = `
+[Main]
+ReadinessPolicy = whenever
+`
+	return c
+}
+
+func makeInvalidConfigReadinessMinPercentMissing() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "readiness policy minPercent without percent"}
+	c.envVarsError = "readiness minimum percent is required if readiness policy is minPercent"
+	c.envVars = map[string]string{"READINESS_POLICY": "minPercent"}
+	c.fileContent = `
+[Main]
+ReadinessPolicy = minPercent
+`
+	return c
+}
+
+func makeInvalidConfigReadinessMinPercentTooHigh() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "readiness minimum percent over 100"}
+	c.envVarsError = "readiness minimum percent cannot be greater than 100"
+	c.envVars = map[string]string{"READINESS_POLICY": "minPercent", "READINESS_MIN_PERCENT": "101"}
+	c.fileContent = `
+[Main]
+ReadinessPolicy = minPercent
+ReadinessMinPercent = 101
+`
+	return c
+}
+
+func makeInvalidConfigReadinessAutoConfigWithNoKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "readiness policy autoConfigReceived without auto-config key"}
+	c.envVarsError = "readiness policy autoConfigReceived requires an auto-configuration key"
+	c.envVars = map[string]string{"READINESS_POLICY": "autoConfigReceived"}
+	c.fileContent = `
+[Main]
+ReadinessPolicy = autoConfigReceived
+`
+	return c
+}
+
+func makeInvalidConfigMaxClientConnectionTimeJitterTooHigh() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "max client connection time jitter not less than max client connection time"}
+	c.envVarsError = errMaxClientConnectionTimeJitter.Error()
+	c.envVars = map[string]string{"MAX_CLIENT_CONNECTION_TIME": "10m", "MAX_CLIENT_CONNECTION_TIME_JITTER": "10m"}
+	c.fileContent = `
+[Main]
+MaxClientConnectionTime = 10m
+MaxClientConnectionTimeJitter = 10m
+`
+	return c
+}
+
+func makeInvalidConfigEventSpoolMaxSize() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event spool max size is zero"}
+	c.envVarsError = errEventSpoolMaxSize.Error()
+	c.envVars = map[string]string{
+		"EVENTS_SPOOL_DIR":      "/var/spool/ld-relay",
+		"EVENTS_SPOOL_MAX_SIZE": "0B",
+	}
+	c.fileContent = `
+[Events]
+SpoolDir = /var/spool/ld-relay
+SpoolMaxSize = 0B
+`
+	return c
+}
+
+func makeInvalidConfigEventsSinkUnknown() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "unknown events sink"}
+	c.envVarsError = `"kafka" is not a valid events sink`
+	c.envVars = map[string]string{"USE_EVENTS": "1", "EVENTS_SINK": "kafka"}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Sink = kafka
+`
+	return c
+}
+
+func makeInvalidConfigEventsSinkFileWithNoPath() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "file events sink without path"}
+	c.envVarsError = errEventsSinkFileWithNoPath.Error()
+	c.envVars = map[string]string{"USE_EVENTS": "1", "EVENTS_SINK": "file"}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Sink = file
+`
+	return c
+}
+
+func makeInvalidConfigEventsSinkWebhookWithNoURI() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "webhook events sink without URI"}
+	c.envVarsError = errEventsSinkWebhookWithNoURI.Error()
+	c.envVars = map[string]string{"USE_EVENTS": "1", "EVENTS_SINK": "webhook"}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Sink = webhook
+`
+	return c
+}
+
+func makeInvalidConfigEventsSinkWithoutSendEvents() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events sink without sending events"}
+	c.envVarsError = errEventsSinkWithoutSendEvents.Error()
+	c.envVars = map[string]string{"EVENTS_SINK": "stdout"}
+	c.fileContent = `
+[Events]
+Sink = stdout
+`
+	return c
+}
+
+func makeInvalidConfigEventsOverloadPolicy() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "unknown events overload policy"}
+	c.envVarsError = `"block" is not a valid events overload policy`
+	c.envVars = map[string]string{"EVENTS_OVERLOAD_POLICY": "block"}
+	c.fileContent = `
+[Events]
+OverloadPolicy = block
+`
+	return c
+}
+
+func makeInvalidConfigEventsHighWaterMark() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events high-water mark above capacity"}
+	c.envVarsError = errEventsHighWaterMarkAboveCapacity.Error()
+	c.envVars = map[string]string{"EVENTS_CAPACITY": "100", "EVENTS_HIGH_WATER_MARK": "101"}
+	c.fileContent = `
+[Events]
+Capacity = 100
+HighWaterMark = 101
+`
+	return c
+}
+
+func makeInvalidConfigEventsMaxMemory() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events max memory is zero"}
+	c.envVarsError = errEventsMaxMemory.Error()
+	c.envVars = map[string]string{"EVENTS_MAX_MEMORY": "0B"}
+	c.fileContent = `
+[Events]
+MaxMemory = 0B
+`
+	return c
+}
+
+func makeInvalidConfigEventsOTLPProtocol() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "unknown events OTLP protocol"}
+	c.envVarsError = `"thrift" is not a valid OTLP protocol for events`
+	c.envVars = map[string]string{
+		"USE_EVENTS":           "1",
+		"EVENTS_OTLP_ENDPOINT": "http://otel-collector:4318",
+		"EVENTS_OTLP_PROTOCOL": "thrift",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+OTLPEndpoint = http://otel-collector:4318
+OTLPProtocol = thrift
+`
+	return c
+}
+
+func makeInvalidConfigEventsOTLPSignal() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "unknown events OTLP signal"}
+	c.envVarsError = `"metrics" is not a valid OTLP signal for events`
+	c.envVars = map[string]string{
+		"USE_EVENTS":           "1",
+		"EVENTS_OTLP_ENDPOINT": "http://otel-collector:4318",
+		"EVENTS_OTLP_SIGNAL":   "metrics",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+OTLPEndpoint = http://otel-collector:4318
+OTLPSignal = metrics
+`
+	return c
+}
+
+func makeInvalidConfigEventsOTLPWithoutEndpoint() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events OTLP properties without endpoint"}
+	c.envVarsError = errEventsOTLPWithoutEndpoint.Error()
+	c.envVars = map[string]string{"USE_EVENTS": "1", "EVENTS_OTLP_PROTOCOL": "grpc"}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+OTLPProtocol = grpc
+`
+	return c
+}
+
+func makeInvalidConfigEventsOTLPWithoutSendEvents() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events OTLP endpoint without sending events"}
+	c.envVarsError = errEventsOTLPWithoutSendEvents.Error()
+	c.envVars = map[string]string{"EVENTS_OTLP_ENDPOINT": "http://otel-collector:4318"}
+	c.fileContent = `
+[Events]
+OTLPEndpoint = http://otel-collector:4318
+`
+	return c
+}
+
+func makeInvalidConfigEventsOTLPGRPCWithProxy() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events OTLP grpc protocol with proxy"}
+	c.envVarsError = errEventsOTLPGRPCWithProxy.Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":           "1",
+		"EVENTS_OTLP_ENDPOINT": "http://otel-collector:4317",
+		"EVENTS_OTLP_PROTOCOL": "grpc",
+		"PROXY_URL":            "http://proxy",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+OTLPEndpoint = http://otel-collector:4317
+OTLPProtocol = grpc
+
+[Proxy]
+URL = http://proxy
+`
+	return c
+}
+
+func makeInvalidConfigEventDestinationWithNoURI() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event destination without URI"}
+	c.fileError = errEventDestinationWithNoURI("collector").Error()
+	c.fileContent = `
+[EventDestination "collector"]
+Mode = raw
+`
+	return c
+}
+
+func makeInvalidConfigEventDestinationHeader() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event destination with invalid header"}
+	c.envVarsError = `event destination "collector": "no-colon" is not a valid event destination header`
+	c.envVars = map[string]string{
+		"EVENT_DESTINATION_URI_collector":    "http://collector",
+		"EVENT_DESTINATION_HEADER_collector": "no-colon",
+	}
+	c.fileContent = `
+[EventDestination "collector"]
+URI = http://collector
+Header = no-colon
+`
+	return c
+}
+
+func makeInvalidConfigEventDestinationMode() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event destination with invalid mode"}
+	c.envVarsError = `event destination "collector": "sampled" is not a valid event destination mode`
+	c.envVars = map[string]string{
+		"EVENT_DESTINATION_URI_collector":  "http://collector",
+		"EVENT_DESTINATION_MODE_collector": "sampled",
+	}
+	c.fileContent = `
+[EventDestination "collector"]
+URI = http://collector
+Mode = sampled
+`
+	return c
+}
+
+func makeInvalidConfigEventRule(property, envVarPrefix, value, errMessage string) testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "invalid event rule " + property + " = " + value}
+	c.envVarsError = `environment "earth": ` + errMessage
+	c.envVars = map[string]string{
+		"LD_ENV_earth":         "earth-sdk",
+		envVarPrefix + "earth": value,
+	}
+	c.fileContent = `
+[Environment "earth"]
+SDKKey = earth-sdk
+` + property + ` = ` + value + `
+`
+	return c
+}
@@ -68,6 +68,14 @@ func makeValidConfigs() []testDataValidConfig {
 		makeValidConfigMaxInboundPayloadSize("50KiB"),
 		makeValidConfigMaxInboundPayloadSize("7MiB"),
 		makeValidConfigMaxInboundPayloadSize("10GiB"),
+		makeValidConfigEventSpool(),
+		makeValidConfigEventRules(),
+		makeValidConfigEventsSinkFile(),
+		makeValidConfigEventsSinkWebhook(),
+		makeValidConfigEventDestinations(),
+		makeValidConfigEventsOverload(),
+		makeValidConfigEventsContextKeys(),
+		makeValidConfigEventsOTLP(),
 		makeValidConfigOfflineModeMinimal(),
 		makeValidConfigOfflineModeWithMonitoringInterval("100ms"),
 		makeValidConfigOfflineModeWithMonitoringInterval("1s"),
@@ -94,6 +102,10 @@ func makeValidConfigs() []testDataValidConfig {
 		makeValidConfigPrometheusMinimal(),
 		makeValidConfigPrometheusAll(),
 		makeValidConfigProxy(),
+		makeValidConfigAdmin(),
+		makeValidConfigAdminEventDebug(),
+		makeValidConfigReadinessMinPercent(),
+		makeValidConfigMaxClientConnectionTimeJitterWithoutTime(),
 	}
 }
 
@@ -110,15 +122,23 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 			IgnoreConnectionErrors:           true,
 			HeartbeatInterval:                ct.NewOptDuration(90 * time.Second),
 			MaxClientConnectionTime:          ct.NewOptDuration(30 * time.Minute),
+			MaxClientConnectionTimeJitter:    ct.NewOptDuration(5 * time.Minute),
+			MaxStreamConnections:             mustOptIntGreaterThanZero(10000),
+			MaxStreamConnectionsPerEnv:       mustOptIntGreaterThanZero(2000),
 			DisconnectedStatusTime:           ct.NewOptDuration(3 * time.Minute),
 			TLSEnabled:                       true,
 			TLSCert:                          "cert",
 			TLSKey:                           "key",
 			TLSMinVersion:                    NewOptTLSVersion(tls.VersionTLS12),
+			TLSClientCA:                      "ca",
 			LogLevel:                         NewOptLogLevel(ldlog.Warn),
 			BigSegmentsStaleAsDegraded:       true,
 			BigSegmentsStaleThreshold:        ct.NewOptDuration(10 * time.Minute),
 			ExpiredCredentialCleanupInterval: ct.NewOptDuration(1 * time.Minute),
+			ShutdownDrainTimeout:             ct.NewOptDuration(30 * time.Second),
+			ClientSideEvalStreams:            true,
+			CompressServerSideStreams:        true,
+			WebSocketStreams:                 true,
 		}
 		c.Events = EventsConfig{
 			SendEvents:            true,
@@ -138,15 +158,16 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 				LogLevel:  NewOptLogLevel(ldlog.Debug),
 			},
 			"krypton": {
-				SDKKey:        "krypton-sdk",
-				MobileKey:     "krypton-mob",
-				EnvID:         "krypton-env",
-				SecureMode:    true,
-				Prefix:        "krypton-",
-				TableName:     "krypton-table",
-				AllowedOrigin: ct.NewOptStringList([]string{"https://oa", "https://rann"}),
-				AllowedHeader: ct.NewOptStringList([]string{"Timestamp-Valid", "Random-Id-Valid"}),
-				TTL:           ct.NewOptDuration(5 * time.Minute),
+				SDKKey:            "krypton-sdk",
+				MobileKey:         "krypton-mob",
+				EnvID:             "krypton-env",
+				SecureMode:        true,
+				Prefix:            "krypton-",
+				TableName:         "krypton-table",
+				AllowedOrigin:     ct.NewOptStringList([]string{"https://oa", "https://rann"}),
+				AllowedHeader:     ct.NewOptStringList([]string{"Timestamp-Valid", "Random-Id-Valid"}),
+				AllowedClientCert: ct.NewOptStringList([]string{"client.krypton"}),
+				TTL:               ct.NewOptDuration(5 * time.Minute),
 			},
 		}
 	}
@@ -160,11 +181,15 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 		"IGNORE_CONNECTION_ERRORS":            "1",
 		"HEARTBEAT_INTERVAL":                  "90s",
 		"MAX_CLIENT_CONNECTION_TIME":          "30m",
+		"MAX_CLIENT_CONNECTION_TIME_JITTER":   "5m",
+		"MAX_STREAM_CONNECTIONS":              "10000",
+		"MAX_STREAM_CONNECTIONS_PER_ENV":      "2000",
 		"DISCONNECTED_STATUS_TIME":            "3m",
 		"TLS_ENABLED":                         "1",
 		"TLS_CERT":                            "cert",
 		"TLS_KEY":                             "key",
 		"TLS_MIN_VERSION":                     "1.2",
+		"TLS_CLIENT_CA":                       "ca",
 		"LOG_LEVEL":                           "warn",
 		"BIG_SEGMENTS_STALE_AS_DEGRADED":      "true",
 		"BIG_SEGMENTS_STALE_THRESHOLD":        "10m",
@@ -187,8 +212,13 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 		"LD_TABLE_NAME_krypton":               "krypton-table",
 		"LD_ALLOWED_ORIGIN_krypton":           "https://oa,https://rann",
 		"LD_ALLOWED_HEADER_krypton":           "Timestamp-Valid,Random-Id-Valid",
+		"LD_ALLOWED_CLIENT_CERT_krypton":      "client.krypton",
 		"LD_TTL_krypton":                      "5m",
 		"EXPIRED_CREDENTIAL_CLEANUP_INTERVAL": "1m",
+		"SHUTDOWN_DRAIN_TIMEOUT":              "30s",
+		"CLIENT_SIDE_EVAL_STREAMS":            "1",
+		"COMPRESS_SERVER_SIDE_STREAMS":        "1",
+		"WEBSOCKET_STREAMS":                   "1",
 	}
 	c.fileContent = `
 [Main]
@@ -201,15 +231,23 @@ ExitAlways = 1
 IgnoreConnectionErrors = 1
 HeartbeatInterval = 90s
 MaxClientConnectionTime = 30m
+MaxClientConnectionTimeJitter = 5m
+MaxStreamConnections = 10000
+MaxStreamConnectionsPerEnv = 2000
 DisconnectedStatusTime = 3m
 TLSEnabled = 1
 TLSCert = "cert"
 TLSKey = "key"
 TLSMinVersion = "1.2"
+TLSClientCA = "ca"
 LogLevel = "warn"
 BigSegmentsStaleAsDegraded = 1
 BigSegmentsStaleThreshold = 10m
 ExpiredCredentialCleanupInterval = 1m
+ShutdownDrainTimeout = 30s
+ClientSideEvalStreams = 1
+CompressServerSideStreams = 1
+WebSocketStreams = 1
 
 [Events]
 SendEvents = 1
@@ -237,6 +275,7 @@ AllowedOrigin = "https://oa"
 AllowedOrigin = "https://rann"
 AllowedHeader = "Timestamp-Valid"
 AllowedHeader = "Random-Id-Valid"
+AllowedClientCert = "client.krypton"
 TTL = 5m
 `
 	return c
@@ -379,6 +418,214 @@ MaxInboundPayloadSize = ` + size
 	return c
 }
 
+func makeValidConfigEventSpool() testDataValidConfig {
+	c := testDataValidConfig{name: "event spool properties"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SpoolDir = "/var/spool/ld-relay"
+		c.Events.SpoolMaxSize = ct.NewOptBase2Bytes(20 * 1024 * 1024)
+	}
+	c.envVars = map[string]string{
+		"EVENTS_SPOOL_DIR":      "/var/spool/ld-relay",
+		"EVENTS_SPOOL_MAX_SIZE": "20MiB",
+	}
+	c.fileContent = `
+[Events]
+SpoolDir = /var/spool/ld-relay
+SpoolMaxSize = 20MiB
+`
+	return c
+}
+
+func makeValidConfigEventsSinkFile() testDataValidConfig {
+	c := testDataValidConfig{name: "events sink file properties"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.Events.Sink = EventsSinkFile
+		c.Events.SinkPath = "/var/log/ld-relay/events.ndjson"
+		c.Events.SinkMaxFileSize = ct.NewOptBase2Bytes(10 * 1024 * 1024)
+		c.Events.SinkMaxFiles = mustOptIntGreaterThanZero(3)
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":                "1",
+		"EVENTS_SINK":               "file",
+		"EVENTS_SINK_PATH":          "/var/log/ld-relay/events.ndjson",
+		"EVENTS_SINK_MAX_FILE_SIZE": "10MiB",
+		"EVENTS_SINK_MAX_FILES":     "3",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Sink = file
+SinkPath = /var/log/ld-relay/events.ndjson
+SinkMaxFileSize = 10MiB
+SinkMaxFiles = 3
+`
+	return c
+}
+
+func makeValidConfigEventsSinkWebhook() testDataValidConfig {
+	c := testDataValidConfig{name: "events sink webhook properties"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.Events.Sink = EventsSinkWebhook
+		c.Events.SinkURI = newOptURLAbsoluteMustBeValid("http://localhost:9000/events")
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":      "1",
+		"EVENTS_SINK":     "webhook",
+		"EVENTS_SINK_URI": "http://localhost:9000/events",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Sink = webhook
+SinkURI = http://localhost:9000/events
+`
+	return c
+}
+
+func makeValidConfigEventsOverload() testDataValidConfig {
+	c := testDataValidConfig{name: "events overload properties"}
+	c.makeConfig = func(c *Config) {
+		c.Events.Capacity = mustOptIntGreaterThanZero(5000)
+		c.Events.OverloadPolicy = EventsOverloadReject429
+		c.Events.HighWaterMark = mustOptIntGreaterThanZero(4000)
+		c.Events.MaxMemoryPerEnv = ct.NewOptBase2Bytes(10 * 1024 * 1024)
+		c.Events.MaxMemory = ct.NewOptBase2Bytes(100 * 1024 * 1024)
+	}
+	c.envVars = map[string]string{
+		"EVENTS_CAPACITY":           "5000",
+		"EVENTS_OVERLOAD_POLICY":    "reject-429",
+		"EVENTS_HIGH_WATER_MARK":    "4000",
+		"EVENTS_MAX_MEMORY_PER_ENV": "10MiB",
+		"EVENTS_MAX_MEMORY":         "100MiB",
+	}
+	c.fileContent = `
+[Events]
+Capacity = 5000
+OverloadPolicy = reject-429
+HighWaterMark = 4000
+MaxMemoryPerEnv = 10MiB
+MaxMemory = 100MiB
+`
+	return c
+}
+
+func makeValidConfigEventsContextKeys() testDataValidConfig {
+	c := testDataValidConfig{name: "events context keys properties"}
+	c.makeConfig = func(c *Config) {
+		c.Events.ContextKeysCapacity = mustOptIntGreaterThanZero(50000)
+		c.Events.ContextKeysFlushInterval = ct.NewOptDuration(30 * time.Minute)
+	}
+	c.envVars = map[string]string{
+		"EVENTS_CONTEXT_KEYS_CAPACITY":       "50000",
+		"EVENTS_CONTEXT_KEYS_FLUSH_INTERVAL": "30m",
+	}
+	c.fileContent = `
+[Events]
+ContextKeysCapacity = 50000
+ContextKeysFlushInterval = 30m
+`
+	return c
+}
+
+func makeValidConfigEventsOTLP() testDataValidConfig {
+	c := testDataValidConfig{name: "events OTLP properties"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.Events.OTLPEndpoint = newOptURLAbsoluteMustBeValid("http://otel-collector:4317")
+		c.Events.OTLPProtocol = EventsOTLPGRPC
+		c.Events.OTLPSignal = EventsOTLPTraces
+		c.Events.OTLPHeader = ct.NewOptStringList([]string{"Authorization: Bearer xyz"})
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":           "1",
+		"EVENTS_OTLP_ENDPOINT": "http://otel-collector:4317",
+		"EVENTS_OTLP_PROTOCOL": "grpc",
+		"EVENTS_OTLP_SIGNAL":   "traces",
+		"EVENTS_OTLP_HEADER":   "Authorization: Bearer xyz",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+OTLPEndpoint = http://otel-collector:4317
+OTLPProtocol = grpc
+OTLPSignal = traces
+OTLPHeader = "Authorization: Bearer xyz"
+`
+	return c
+}
+
+func makeValidConfigEventDestinations() testDataValidConfig {
+	c := testDataValidConfig{name: "event destinations"}
+	c.makeConfig = func(c *Config) {
+		c.EventDestination = map[string]*EventDestinationConfig{
+			"collector": {
+				URI:    newOptURLAbsoluteMustBeValid("http://collector:8080"),
+				Header: ct.NewOptStringList([]string{"Authorization: Bearer xyz", "X-Source: relay"}),
+			},
+			"archive": {
+				URI:  newOptURLAbsoluteMustBeValid("https://archive.example.com/events"),
+				Mode: EventDestinationRaw,
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"EVENT_DESTINATION_URI_collector":    "http://collector:8080",
+		"EVENT_DESTINATION_HEADER_collector": "Authorization: Bearer xyz,X-Source: relay",
+		"EVENT_DESTINATION_URI_archive":      "https://archive.example.com/events",
+		"EVENT_DESTINATION_MODE_archive":     "raw",
+	}
+	c.fileContent = `
+[EventDestination "collector"]
+URI = http://collector:8080
+Header = "Authorization: Bearer xyz"
+Header = "X-Source: relay"
+
+[EventDestination "archive"]
+URI = https://archive.example.com/events
+Mode = raw
+`
+	return c
+}
+
+func makeValidConfigEventRules() testDataValidConfig {
+	c := testDataValidConfig{name: "event rules"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"earth": {
+				SDKKey:                SDKKey("earth-sdk"),
+				DropCustomEvent:       ct.NewOptStringList([]string{"debug-*", "internal"}),
+				SampleCustomEvent:     ct.NewOptStringList([]string{"page-view=0.25"}),
+				StripContextAttribute: ct.NewOptStringList([]string{"email", "phone"}),
+				HashContextAttribute:  ct.NewOptStringList([]string{"key"}),
+				DropIdentifyEvents:    ct.NewOptStringList([]string{"mobile", "js"}),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_earth":                     "earth-sdk",
+		"LD_DROP_CUSTOM_EVENT_earth":       "debug-*,internal",
+		"LD_SAMPLE_CUSTOM_EVENT_earth":     "page-view=0.25",
+		"LD_STRIP_CONTEXT_ATTRIBUTE_earth": "email,phone",
+		"LD_HASH_CONTEXT_ATTRIBUTE_earth":  "key",
+		"LD_DROP_IDENTIFY_EVENTS_earth":    "mobile,js",
+	}
+	c.fileContent = `
+[Environment "earth"]
+SDKKey = earth-sdk
+DropCustomEvent = debug-*
+DropCustomEvent = internal
+SampleCustomEvent = page-view=0.25
+StripContextAttribute = email
+StripContextAttribute = phone
+HashContextAttribute = key
+DropIdentifyEvents = mobile
+DropIdentifyEvents = js
+`
+	return c
+}
+
 func MustOptDurationFromString(duration string) ct.OptDuration {
 	opt, err := ct.NewOptDurationFromString(duration)
 	if err != nil {
@@ -896,3 +1143,83 @@ CaCertFiles = "cert"
 `
 	return c
 }
+
+func makeValidConfigAdmin() testDataValidConfig {
+	c := testDataValidConfig{name: "admin API"}
+	c.makeConfig = func(c *Config) {
+		c.Admin = AdminConfig{
+			Port:  mustOptIntGreaterThanZero(8032),
+			Host:  "0.0.0.0",
+			Token: "secret",
+		}
+	}
+	c.envVars = map[string]string{
+		"ADMIN_PORT":  "8032",
+		"ADMIN_HOST":  "0.0.0.0",
+		"ADMIN_TOKEN": "secret",
+	}
+	c.fileContent = `
+[Admin]
+Port = 8032
+Host = "0.0.0.0"
+Token = "secret"
+`
+	return c
+}
+
+func makeValidConfigAdminEventDebug() testDataValidConfig {
+	c := testDataValidConfig{name: "admin API with event debugging"}
+	c.makeConfig = func(c *Config) {
+		c.Admin = AdminConfig{
+			Port:                    mustOptIntGreaterThanZero(8032),
+			Token:                   "secret",
+			EventDebug:              true,
+			EventDebugSamplingRatio: mustOptIntGreaterThanZero(10),
+		}
+	}
+	c.envVars = map[string]string{
+		"ADMIN_PORT":                       "8032",
+		"ADMIN_TOKEN":                      "secret",
+		"ADMIN_EVENT_DEBUG":                "true",
+		"ADMIN_EVENT_DEBUG_SAMPLING_RATIO": "10",
+	}
+	c.fileContent = `
+[Admin]
+Port = 8032
+Token = "secret"
+EventDebug = true
+EventDebugSamplingRatio = 10
+`
+	return c
+}
+
+func makeValidConfigReadinessMinPercent() testDataValidConfig {
+	c := testDataValidConfig{name: "readiness policy minPercent"}
+	c.makeConfig = func(c *Config) {
+		c.Main.ReadinessPolicy = ReadinessMinPercent
+		c.Main.ReadinessMinPercent = mustOptIntGreaterThanZero(75)
+	}
+	c.envVars = map[string]string{
+		"READINESS_POLICY":      "minPercent",
+		"READINESS_MIN_PERCENT": "75",
+	}
+	c.fileContent = `
+[Main]
+ReadinessPolicy = minPercent
+ReadinessMinPercent = 75
+`
+	return c
+}
+
+func makeValidConfigMaxClientConnectionTimeJitterWithoutTime() testDataValidConfig {
+	c := testDataValidConfig{name: "max client connection time jitter without max client connection time"}
+	c.makeConfig = func(c *Config) {
+		c.Main.MaxClientConnectionTimeJitter = ct.NewOptDuration(time.Minute)
+	}
+	c.envVars = map[string]string{"MAX_CLIENT_CONNECTION_TIME_JITTER": "1m"}
+	c.fileContent = `
+[Main]
+MaxClientConnectionTimeJitter = 1m
+`
+	return c
+}
@@ -0,0 +1,44 @@
+package api
+
+// AdminEnvironmentRep is the per-environment JSON representation returned by the admin API.
+//
+// This is exported for use in integration test code.
+type AdminEnvironmentRep struct {
+	Name           string `json:"name"`
+	SDKKey         string `json:"sdkKey"`
+	EnvID          string `json:"envId,omitempty"`
+	EnvKey         string `json:"envKey,omitempty"`
+	EnvName        string `json:"envName,omitempty"`
+	ProjKey        string `json:"projKey,omitempty"`
+	ProjName       string `json:"projName,omitempty"`
+	MobileKey      string `json:"mobileKey,omitempty"`
+	ExpiringSDKKey string `json:"expiringSdkKey,omitempty"`
+	FilterKey      string `json:"filterKey,omitempty"`
+	Initialized    bool   `json:"initialized"`
+	InitError      string `json:"initError,omitempty"`
+}
+
+// AdminEnvironmentConfigRep is the JSON representation of an environment configuration that is
+// accepted by the admin API when adding an environment. Its properties correspond to the ones in
+// the [Environment "NAME"] section of the configuration file.
+//
+// This is exported for use in integration test code.
+type AdminEnvironmentConfigRep struct {
+	SDKKey                string   `json:"sdkKey"`
+	MobileKey             string   `json:"mobileKey,omitempty"`
+	EnvID                 string   `json:"envId,omitempty"`
+	Prefix                string   `json:"prefix,omitempty"`
+	TableName             string   `json:"tableName,omitempty"`
+	AllowedOrigin         []string `json:"allowedOrigin,omitempty"`
+	AllowedHeader         []string `json:"allowedHeader,omitempty"`
+	AllowedClientCert     []string `json:"allowedClientCert,omitempty"`
+	SecureMode            bool     `json:"secureMode,omitempty"`
+	LogLevel              string   `json:"logLevel,omitempty"`
+	TTL                   string   `json:"ttl,omitempty"`
+	ProjKey               string   `json:"projKey,omitempty"`
+	DropCustomEvent       []string `json:"dropCustomEvent,omitempty"`
+	SampleCustomEvent     []string `json:"sampleCustomEvent,omitempty"`
+	StripContextAttribute []string `json:"stripContextAttribute,omitempty"`
+	HashContextAttribute  []string `json:"hashContextAttribute,omitempty"`
+	DropIdentifyEvents    []string `json:"dropIdentifyEvents,omitempty"`
+}
@@ -9,10 +9,29 @@ import (
 //
 // This is exported for use in integration test code.
 type StatusRep struct {
-	Environments  map[string]EnvironmentStatusRep `json:"environments"`
-	Status        string                          `json:"status"`
-	Version       string                          `json:"version"`
-	ClientVersion string                          `json:"clientVersion"`
+	Environments   map[string]EnvironmentStatusRep `json:"environments"`
+	Status         string                          `json:"status"`
+	Version        string                          `json:"version"`
+	ClientVersion  string                          `json:"clientVersion"`
+	TLSCertificate *TLSCertificateStatusRep        `json:"tlsCertificate,omitempty"`
+}
+
+// TLSCertificateStatusRep describes the TLS certificate that Relay's server is currently using. It is
+// only present in StatusRep if TLS is enabled.
+//
+// This is exported for use in integration test code.
+type TLSCertificateStatusRep struct {
+	Expiry ldtime.UnixMillisecondTime `json:"expiry"`
+}
+
+// ReadinessRep is the JSON representation returned by the readiness endpoint.
+//
+// This is exported for use in integration test code.
+type ReadinessRep struct {
+	Ready                   bool   `json:"ready"`
+	Policy                  string `json:"policy"`
+	InitializedEnvironments int    `json:"initializedEnvironments"`
+	TotalEnvironments       int    `json:"totalEnvironments"`
 }
 
 // EnvironmentStatusRep is the per-environment JSON representation returned by the status endpoint.
@@ -31,6 +50,30 @@ type EnvironmentStatusRep struct {
 	ConnectionStatus ConnectionStatusRep  `json:"connectionStatus"`
 	DataStoreStatus  DataStoreStatusRep   `json:"dataStoreStatus"`
 	BigSegmentStatus *BigSegmentStatusRep `json:"bigSegmentStatus,omitempty"`
+
+	Details *EnvironmentStatusDetailsRep `json:"details,omitempty"`
+}
+
+// EnvironmentStatusDetailsRep is the optional detailed information in EnvironmentStatusRep. It is
+// included in the per-environment status resource, or in the overall status resource if requested.
+//
+// This is exported for use in integration test code.
+type EnvironmentStatusDetailsRep struct {
+	FlagCount             int                        `json:"flagCount"`
+	SegmentCount          int                        `json:"segmentCount"`
+	LastUpdated           ldtime.UnixMillisecondTime `json:"lastUpdated,omitempty"`
+	LatestVersion         int                        `json:"latestVersion"`
+	StreamConnections     map[string]int             `json:"streamConnections"`
+	EventQueueDepth       map[string]int             `json:"eventQueueDepth,omitempty"`
+	DeprecatedCredentials []DeprecatedCredentialRep  `json:"deprecatedCredentials,omitempty"`
+}
+
+// DeprecatedCredentialRep describes a deprecated SDK key that will stop working at the time of Expiry.
+//
+// This is exported for use in integration test code.
+type DeprecatedCredentialRep struct {
+	SDKKey string                     `json:"sdkKey"`
+	Expiry ldtime.UnixMillisecondTime `json:"expiry"`
 }
 
 // BigSegmentStatusRep is the big segment status representation returned by the status endpoint.
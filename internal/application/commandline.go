@@ -16,7 +16,9 @@ type Options struct {
 	ConfigFile       string
 	AllowMissingFile bool
 	UseEnvironment   bool
+	WatchConfigFile  bool
 	PrintVersion     bool
+	Validate         bool
 }
 
 func errConfigFileNotFound(filename string) error {
@@ -50,16 +52,27 @@ func (o Options) DescribeConfigSource() string {
 // 3. If you specify --from-env, it creates a configuration from environment variables as described in README.
 // 4. If you specify both, the file is loaded first, then it applies changes from variables if any.
 // 5. Omitting all options is equivalent to explicitly specifying --config /etc/ld-relay.conf.
+// 6. If you specify --watch-config, relay reloads the configuration whenever the configuration file changes,
+// the same as if it had received a SIGHUP signal.
+// 7. If the first argument is "validate", relay only validates the configuration from the other options and
+// describes it, instead of starting; see RunValidateCommand.
 func ReadOptions(osArgs []string, errorOutput io.Writer) (Options, error) {
 	var o Options
 
+	args := osArgs[1:]
+	if len(args) != 0 && args[0] == ValidateCommand {
+		o.Validate = true
+		args = args[1:]
+	}
+
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
 	fs.SetOutput(errorOutput)
 	fs.StringVar(&o.ConfigFile, "config", "", "configuration file location")
 	fs.BoolVar(&o.AllowMissingFile, "allow-missing-file", false, "suppress error if config file is not found")
 	fs.BoolVar(&o.UseEnvironment, "from-env", false, "read configuration from environment variables")
+	fs.BoolVar(&o.WatchConfigFile, "watch-config", false, "reload configuration whenever the configuration file changes")
 	fs.BoolVar(&o.PrintVersion, "version", false, "print relay's version")
-	err := fs.Parse(osArgs[1:])
+	err := fs.Parse(args)
 	if err != nil {
 		return o, err
 	}
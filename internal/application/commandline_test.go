@@ -59,6 +59,16 @@ func TestReadOptions(t *testing.T) {
 		assert.True(t, opts.PrintVersion)
 	})
 
+	t.Run("validate subcommand", func(t *testing.T) {
+		helpers.WithTempFile(func(filename string) {
+			opts, err := ReadOptions([]string{appName, ValidateCommand, "--config", filename, "--from-env"}, io.Discard)
+			require.NoError(t, err)
+			assert.True(t, opts.Validate)
+			assert.Equal(t, filename, opts.ConfigFile)
+			assert.True(t, opts.UseEnvironment)
+		})
+	})
+
 	t.Run("invalid options", func(t *testing.T) {
 		_, err := ReadOptions([]string{appName, "--unknown"}, io.Discard)
 		assert.Error(t, err)
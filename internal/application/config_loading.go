@@ -0,0 +1,104 @@
+package application
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// DefaultConfigFileWatchInterval is how often the configuration file is checked for changes if
+// Options.WatchConfigFile is set.
+const DefaultConfigFileWatchInterval = time.Second * 5
+
+func errLoadingConfigFile(err error) error {
+	return fmt.Errorf("error loading config file: %w", err)
+}
+
+func errLoadingConfigFromEnvironment(err error) error {
+	return fmt.Errorf("configuration error: %w", err)
+}
+
+// LoadConfig builds the Relay configuration from the configuration file and/or environment variables
+// specified in the command-line options. This is the same logic that is used at startup, so it can also
+// be used to reload the configuration later.
+func LoadConfig(opts Options, loggers ldlog.Loggers) (config.Config, error) {
+	var c config.Config
+	if opts.ConfigFile != "" {
+		if err := config.LoadConfigFile(&c, opts.ConfigFile, loggers); err != nil {
+			return c, errLoadingConfigFile(err)
+		}
+	}
+	if opts.UseEnvironment {
+		if err := config.LoadConfigFromEnvironment(&c, loggers); err != nil {
+			return c, errLoadingConfigFromEnvironment(err)
+		}
+	}
+	return c, nil
+}
+
+// WatchConfigFile starts polling the specified file for changes, and sends a value to the returned
+// channel whenever its size or modification time changes. Polling stops when the closeCh channel is
+// closed; closeCh can be nil if polling should continue for the lifetime of the process.
+//
+// Environment variables cannot be watched this way; to pick up changes to those, the process must
+// receive a SIGHUP signal instead.
+func WatchConfigFile(
+	path string,
+	interval time.Duration,
+	closeCh <-chan struct{},
+	loggers ldlog.Loggers,
+) <-chan struct{} {
+	return WatchFiles([]string{path}, interval, closeCh, loggers)
+}
+
+// WatchFiles is the same as WatchConfigFile, but watches any number of files, such as the files that
+// secret settings were read from (see config.Config.SecretFiles). A single value is sent to the
+// returned channel if one or more of them change.
+func WatchFiles(
+	paths []string,
+	interval time.Duration,
+	closeCh <-chan struct{},
+	loggers ldlog.Loggers,
+) <-chan struct{} {
+	changedCh := make(chan struct{}, 1)
+	prevInfos := make([]os.FileInfo, len(paths))
+	for i, path := range paths {
+		prevInfos[i], _ = os.Stat(path)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-closeCh:
+				return
+			case <-ticker.C:
+				for i, path := range paths {
+					// os.Stat follows symbolic links, so this also detects the kind of update that
+					// Kubernetes makes to a mounted secret, where a link is switched to a new file.
+					nextInfo, err := os.Stat(path)
+					if err != nil {
+						loggers.Warnf("Unable to check file %q for changes: %s", path, err)
+						continue
+					}
+					prevInfo := prevInfos[i]
+					if prevInfo == nil || nextInfo.ModTime() != prevInfo.ModTime() || nextInfo.Size() != prevInfo.Size() {
+						loggers.Infof("File %q has changed", path)
+						select {
+						case changedCh <- struct{}{}:
+						default: // a reload is already pending
+						}
+					}
+					prevInfos[i] = nextInfo
+				}
+			}
+		}
+	}()
+
+	return changedCh
+}
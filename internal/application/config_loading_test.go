@@ -0,0 +1,104 @@
+package application
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("from file", func(t *testing.T) {
+		helpers.WithTempFile(func(filename string) {
+			require.NoError(t, os.WriteFile(filename, []byte(`
+[Environment "env1"]
+SDKKey = "sdk-key"
+`), 0600))
+			c, err := LoadConfig(Options{ConfigFile: filename}, ldlog.NewDisabledLoggers())
+			require.NoError(t, err)
+			require.Contains(t, c.Environment, "env1")
+			assert.Equal(t, config.SDKKey("sdk-key"), c.Environment["env1"].SDKKey)
+		})
+	})
+
+	t.Run("secret from file", func(t *testing.T) {
+		dir := t.TempDir()
+		keyFile, configFile := filepath.Join(dir, "sdk-key"), filepath.Join(dir, "relay.conf")
+		require.NoError(t, os.WriteFile(keyFile, []byte("sdk-key\n"), 0600))
+		require.NoError(t, os.WriteFile(configFile, []byte(`
+[Environment "env1"]
+SDKKey = "${file:`+keyFile+`}"
+`), 0600))
+		c, err := LoadConfig(Options{ConfigFile: configFile}, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		require.Contains(t, c.Environment, "env1")
+		assert.Equal(t, config.SDKKey("sdk-key"), c.Environment["env1"].SDKKey)
+		assert.Equal(t, []string{keyFile}, c.SecretFiles())
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		helpers.WithTempFile(func(filename string) {
+			require.NoError(t, os.WriteFile(filename, []byte(`[Unknown]`), 0600))
+			_, err := LoadConfig(Options{ConfigFile: filename}, ldlog.NewDisabledLoggers())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "error loading config file")
+		})
+	})
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	helpers.WithTempFile(func(filename string) {
+		closeCh := make(chan struct{})
+		defer close(closeCh)
+
+		changedCh := WatchConfigFile(filename, time.Millisecond*10, closeCh, ldlog.NewDisabledLoggers())
+
+		select {
+		case <-changedCh:
+			require.Fail(t, "got unexpected change notification")
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		require.NoError(t, os.WriteFile(filename, []byte("# changed"), 0600))
+
+		select {
+		case <-changedCh:
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for change notification")
+		}
+	})
+}
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	file1, file2 := filepath.Join(dir, "file1"), filepath.Join(dir, "file2")
+	require.NoError(t, os.WriteFile(file1, []byte("1"), 0600))
+	require.NoError(t, os.WriteFile(file2, []byte("2"), 0600))
+
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+
+	changedCh := WatchFiles([]string{file1, file2}, time.Millisecond*10, closeCh, ldlog.NewDisabledLoggers())
+
+	select {
+	case <-changedCh:
+		require.Fail(t, "got unexpected change notification")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	require.NoError(t, os.WriteFile(file2, []byte("changed"), 0600))
+
+	select {
+	case <-changedCh:
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for change notification")
+	}
+}
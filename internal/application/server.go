@@ -13,24 +13,20 @@ import (
 
 // StartHTTPServer starts the server, with or without TLS. It returns immediately, starting the server
 // on a separate goroutine; if the server fails to start up, it sends an error to the error channel.
+//
+// If tlsConfig is not nil, the server uses TLS, and tlsConfig must provide the certificate either in
+// Certificates or with a GetCertificate callback.
 func StartHTTPServer(
 	port int,
 	handler http.Handler,
-	tlsEnabled bool,
-	tlsCertFile, tlsKeyFile string,
-	tlsMinVersion uint16,
+	tlsConfig *tls.Config,
 	loggers ldlog.Loggers,
 ) (*http.Server, <-chan error) {
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", port),
 		Handler:           handler,
 		ReadHeaderTimeout: 10 * time.Second,
-	}
-
-	if tlsEnabled && tlsMinVersion != 0 {
-		srv.TLSConfig = &tls.Config{ //nolint:gosec // linter doesn't want to see MinVersion being set to a variable
-			MinVersion: tlsMinVersion,
-		}
+		TLSConfig:         tlsConfig,
 	}
 
 	errCh := make(chan error)
@@ -38,17 +34,17 @@ func StartHTTPServer(
 	go func() {
 		var err error
 		loggers.Infof("Starting server listening on port %d\n", port)
-		if tlsEnabled {
+		if tlsConfig != nil {
 			message := "TLS enabled for server"
-			if tlsMinVersion != 0 {
-				message += fmt.Sprintf(" (minimum TLS version: %s)", config.NewOptTLSVersion(tlsMinVersion).String())
+			if tlsConfig.MinVersion != 0 {
+				message += fmt.Sprintf(" (minimum TLS version: %s)", config.NewOptTLSVersion(tlsConfig.MinVersion).String())
 			}
 			loggers.Info(message)
-			err = srv.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
+			err = srv.ListenAndServeTLS("", "") // the certificate comes from tlsConfig
 		} else {
 			err = srv.ListenAndServe()
 		}
-		if err != nil {
+		if err != nil && err != http.ErrServerClosed { // ErrServerClosed means Shutdown or Close was called
 			errCh <- err
 		}
 	}()
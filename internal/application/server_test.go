@@ -40,10 +40,19 @@ func withSelfSignedCert(t *testing.T, action func(certFilePath, keyFilePath stri
 	})
 }
 
+func makeServerTLSConfig(t *testing.T, certFilePath, keyFilePath string, minVersion uint16) *tls.Config {
+	cert, err := tls.LoadX509KeyPair(certFilePath, keyFilePath)
+	require.NoError(t, err)
+	return &tls.Config{ //nolint:gosec // MinVersion is deliberately variable in these tests
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+}
+
 func TestStartHTTPServerInsecure(t *testing.T) {
 	port := st.GetAvailablePort(t)
 	mockLog := ldlogtest.NewMockLog()
-	server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK), false, "", "", 0, mockLog.Loggers)
+	server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK), nil, mockLog.Loggers)
 	require.NotNil(t, server)
 	require.NotNil(t, errCh)
 	require.Eventually(t, func() bool {
@@ -61,7 +70,7 @@ func TestStartHTTPServerSecure(t *testing.T) {
 
 	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
 		server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK),
-			true, certFilePath, keyFilePath, 0, mockLog.Loggers)
+			makeServerTLSConfig(t, certFilePath, keyFilePath, 0), mockLog.Loggers)
 		require.NotNil(t, server)
 		require.NotNil(t, errCh)
 
@@ -87,7 +96,7 @@ func TestStartHTTPServerSecureWithMinTLSVersion(t *testing.T) {
 
 	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
 		server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK),
-			true, certFilePath, keyFilePath, tls.VersionTLS12, mockLog.Loggers)
+			makeServerTLSConfig(t, certFilePath, keyFilePath, tls.VersionTLS12), mockLog.Loggers)
 		require.NotNil(t, server)
 		require.NotNil(t, errCh)
 
@@ -112,7 +121,7 @@ func TestStartHTTPServerSecureWithMinTLSVersion(t *testing.T) {
 
 func TestStartHTTPServerPortAlreadyUsed(t *testing.T) {
 	st.WithListenerForAnyPort(t, func(l net.Listener, port int) {
-		_, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(200), false, "", "", 0, ldlog.NewDisabledLoggers())
+		_, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(200), nil, ldlog.NewDisabledLoggers())
 		require.NotNil(t, errCh)
 		err := helpers.RequireValue(t, errCh, time.Second, "timed out waiting for error")
 		assert.NotNil(t, err)
@@ -0,0 +1,234 @@
+package application
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	"github.com/launchdarkly/ld-relay/v8/internal/util"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// ValidateCommand is the command-line subcommand that runs RunValidateCommand instead of starting Relay.
+const ValidateCommand = "validate"
+
+// redactedValue replaces the value of any secret setting in the output of RunValidateCommand. It is the
+// same placeholder that util.RedactURL uses for passwords in URLs.
+const redactedValue = "xxxxx"
+
+// placeholderEnvID is shown in place of an environment ID when describing the data store settings
+// for environments that are not known until Relay is running.
+const placeholderEnvID = "<environment ID>"
+
+// secretSettings are the settings, other than SDK credentials and URLs, whose values must not be shown
+// by RunValidateCommand.
+var secretSettings = map[string]bool{ //nolint:gochecknoglobals
+	"Redis.Password": true,
+	"Consul.Token":   true,
+	"Proxy.Password": true,
+	"Admin.Token":    true,
+}
+
+// headerSettings are the settings whose values are HTTP headers in the format "Name: value". These often
+// carry credentials, so RunValidateCommand shows only the header name.
+var headerSettings = map[string]bool{ //nolint:gochecknoglobals
+	"EventDestination.Header": true,
+	"Events.OTLPHeader":       true,
+}
+
+// redactedHeaderValue replaces the value of a header in the output of RunValidateCommand.
+const redactedHeaderValue = "<redacted>"
+
+// RunValidateCommand implements the "validate" subcommand. It loads the configuration from the same
+// sources, and with the same validation, as it would be loaded at startup. If that fails, it returns the
+// error. Otherwise, it writes the effective configuration to out in the configuration file format, with
+// secrets such as keys and passwords redacted, followed by the data store prefix and table name that
+// each environment will use.
+func RunValidateCommand(opts Options, out io.Writer, loggers ldlog.Loggers) error {
+	c, err := LoadConfig(opts, loggers)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "# Effective configuration from %s\n", opts.DescribeConfigSource())
+	writeEffectiveConfig(out, c)
+	fmt.Fprintln(out)
+	return writeEnvironmentDataStores(out, c)
+}
+
+func writeEffectiveConfig(out io.Writer, c config.Config) {
+	sections := []struct {
+		name  string
+		value interface{}
+	}{
+		{"Main", c.Main},
+		{"AutoConfig", c.AutoConfig},
+		{"OfflineMode", c.OfflineMode},
+		{"Events", c.Events},
+		{"Redis", c.Redis},
+		{"Consul", c.Consul},
+		{"DynamoDB", c.DynamoDB},
+		{"Proxy", c.Proxy},
+		{"Admin", c.Admin},
+		{"Datadog", c.Datadog},
+		{"Stackdriver", c.Stackdriver},
+		{"Prometheus", c.Prometheus},
+	}
+	for _, s := range sections {
+		writeConfigSection(out, s.name, s.name, s.value)
+	}
+	for _, name := range sortedKeys(c.Environment) {
+		writeConfigSection(out, fmt.Sprintf("Environment %q", name), "Environment", *c.Environment[name])
+	}
+	for _, name := range sortedKeys(c.Filters) {
+		writeConfigSection(out, fmt.Sprintf("Filters %q", name), "Filters", *c.Filters[name])
+	}
+	for _, name := range sortedKeys(c.EventDestination) {
+		writeConfigSection(out, fmt.Sprintf("EventDestination %q", name), "EventDestination", *c.EventDestination[name])
+	}
+}
+
+// writeConfigSection writes all of the fields of a configuration struct that are not empty. Sections
+// in which every field is empty are omitted.
+func writeConfigSection(out io.Writer, header, sectionName string, section interface{}) {
+	v := reflect.ValueOf(section)
+	var lines []string
+	for i := 0; i < v.NumField(); i++ {
+		field, fieldValue := v.Type().Field(i), v.Field(i)
+		if fieldValue.IsZero() {
+			continue
+		}
+		if fieldValue.Kind() == reflect.Slice {
+			for j := 0; j < fieldValue.Len(); j++ {
+				lines = append(lines, fmt.Sprintf("%s = %s", field.Name, formatConfigValue(fieldValue.Index(j).Interface())))
+			}
+			continue
+		}
+		if list, ok := fieldValue.Interface().(ct.OptStringList); ok {
+			// Each value gets its own line, since a value could itself contain a comma.
+			for _, value := range list.Values() {
+				if headerSettings[sectionName+"."+field.Name] {
+					value = redactHeader(value)
+				}
+				lines = append(lines, fmt.Sprintf("%s = %s", field.Name, strconv.Quote(value)))
+			}
+			continue
+		}
+		formatted := formatConfigValue(fieldValue.Interface())
+		if secretSettings[sectionName+"."+field.Name] {
+			formatted = strconv.Quote(redactedValue)
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s", field.Name, formatted))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n[%s]\n", header)
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+}
+
+func redactHeader(header string) string {
+	name, _, err := config.ParseEventDestinationHeader(header)
+	if err != nil {
+		return redactedValue
+	}
+	return name + ": " + redactedHeaderValue
+}
+
+func formatConfigValue(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case config.SDKKey:
+		return strconv.Quote(sdks.ObscureKey(string(v)))
+	case config.MobileKey:
+		return strconv.Quote(sdks.ObscureKey(string(v)))
+	case config.AutoConfigKey:
+		return strconv.Quote(sdks.ObscureKey(string(v)))
+	case ct.OptURLAbsolute:
+		return strconv.Quote(util.RedactURL(v.String()))
+	default:
+		return strconv.Quote(fmt.Sprint(v))
+	}
+}
+
+// writeEnvironmentDataStores describes the data store settings for every environment, after applying
+// the same defaults, filters, and environment ID substitutions that Relay will apply at runtime. It
+// returns the first error, if any, that Relay would encounter when configuring those data stores.
+func writeEnvironmentDataStores(out io.Writer, c config.Config) error {
+	var firstErr error
+	describe := func(name string, envConfig config.EnvConfig) {
+		if err := writeEnvironmentDataStore(out, c, name, envConfig); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	switch {
+	case c.AutoConfig.Key != "":
+		fmt.Fprintln(out, "# Environments will be provided by auto-configuration; each one will use:")
+		envConfig := envfactory.NewEnvConfigFactoryForAutoConfig(c.AutoConfig).
+			MakeEnvironmentConfig(envfactory.EnvironmentParams{EnvID: placeholderEnvID})
+		describe("", envConfig)
+	case c.OfflineMode.FileDataSource != "":
+		fmt.Fprintln(out, "# Environments will be provided by the offline mode archive; each one will use:")
+		envConfig := envfactory.NewEnvConfigFactoryForOfflineMode(c.OfflineMode).
+			MakeEnvironmentConfig(envfactory.EnvironmentParams{EnvID: placeholderEnvID})
+		describe("", envConfig)
+	default:
+		fmt.Fprintln(out, "# Environments:")
+		for _, name := range sortedKeys(c.Environment) {
+			envConfig := *c.Environment[name]
+			describe(name, envConfig)
+			// Payload filters create an additional environment for each filter key in the project, in
+			// the same way as in relay.makeFilteredEnvironments.
+			if filters, ok := c.Filters[envConfig.ProjKey]; ok {
+				for _, key := range filters.Keys.Values() {
+					filtered := envConfig
+					filtered.FilterKey = config.FilterKey(key)
+					if filtered.Prefix != "" {
+						filtered.Prefix += "/" + key
+					}
+					describe(name+"/"+key, filtered)
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+func writeEnvironmentDataStore(out io.Writer, c config.Config, name string, envConfig config.EnvConfig) error {
+	label := "#  "
+	if name != "" {
+		label += " " + name + ":"
+	}
+	_, info, err := sdks.ConfigureDataStore(c, envConfig, ldlog.NewDisabledLoggers())
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "%s invalid data store configuration: %s\n", label, err)
+		return err
+	case info.DBType == "":
+		fmt.Fprintf(out, "%s in-memory data store\n", label)
+	case info.DBTable != "":
+		fmt.Fprintf(out, "%s %s data store, prefix %q, table name %q\n", label, info.DBType, info.DBPrefix, info.DBTable)
+	default:
+		fmt.Fprintf(out, "%s %s data store, prefix %q\n", label, info.DBType, info.DBPrefix)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
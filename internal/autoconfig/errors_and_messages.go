@@ -7,6 +7,7 @@ const (
 	logMsgStreamOtherError    = "Unexpected error on auto-configuration stream: %s"
 	logMsgBadKey              = "Invalid auto-configuration key; cannot get environments"
 	logMsgDeliberateReconnect = "Will restart auto-configuration stream to get new data due to a policy change"
+	logMsgReconnecting        = "Reconnecting to auto-configuration stream with new configuration"
 	logMsgPutEvent            = "Received configuration for %d environment(s)"
 	logMsgAddEnv              = "Added %s"
 	logMsgUpdateEnv           = "Properties have changed for %s"
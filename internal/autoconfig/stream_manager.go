@@ -53,6 +53,8 @@ type StreamManager struct {
 	loggers           ldlog.Loggers
 	halt              chan struct{}
 	closeOnce         sync.Once
+	reconnectCh       chan struct{}
+	lock              sync.Mutex
 
 	envReceiver    *MessageReceiver[envfactory.EnvironmentRep]
 	filterReceiver *MessageReceiver[envfactory.FilterRep]
@@ -83,6 +85,7 @@ func NewStreamManager(
 		initialRetryDelay: initialRetryDelay,
 		loggers:           loggers,
 		halt:              make(chan struct{}),
+		reconnectCh:       make(chan struct{}, 1),
 	}
 
 	// Enforces ordering constraints on the SSE messages that are sent from the server, allowing the MessageHandler
@@ -116,11 +119,66 @@ func (s *StreamManager) Close() {
 	})
 }
 
+// Reconnect closes the current stream connection, if any, and connects again using a new key and HTTP
+// configuration. This also works if the stream had stopped because the previous key was rejected.
+//
+// The last known state of the environments and filters is kept, so when the new connection receives its
+// initial "put" event, only the ones that have changed in the meantime are updated.
+func (s *StreamManager) Reconnect(key config.AutoConfigKey, httpConfig httpconfig.HTTPConfig) {
+	s.loggers.Info(logMsgReconnecting)
+	s.lock.Lock()
+	s.key = key
+	s.httpConfig = httpConfig
+	s.lock.Unlock()
+	select {
+	case s.reconnectCh <- struct{}{}:
+	default: // a reconnect is already pending, and it will use the configuration we just set
+	}
+}
+
 func (s *StreamManager) subscribe(readyCh chan<- error) {
 	var readyOnce sync.Once
 	signalReady := func(err error) { readyOnce.Do(func() { readyCh <- err }) }
 
+	for {
+		if stream := s.connect(signalReady); stream != nil {
+			signalReady(nil)
+			if s.consumeStream(stream) {
+				continue
+			}
+		}
+		// Either we've been closed, or the stream has stopped for good (for instance, because the key was
+		// rejected); in the latter case, we can still connect again if Reconnect is called.
+		select {
+		case <-s.reconnectCh:
+		case <-s.halt:
+			return
+		}
+	}
+}
+
+// stopConnecting returns true if the StreamManager has been closed or Reconnect has been called, meaning
+// that any connection attempt in progress should be abandoned.
+func (s *StreamManager) stopConnecting() bool {
+	select {
+	case <-s.halt:
+		return true
+	default:
+		return len(s.reconnectCh) != 0
+	}
+}
+
+// connect tries to open a stream connection with the current key and HTTP configuration. It returns nil if
+// it failed permanently, or if it gave up because stopConnecting returned true.
+func (s *StreamManager) connect(signalReady func(error)) *es.Stream {
+	s.lock.Lock()
+	key, httpConfig := s.key, s.httpConfig
+	s.lock.Unlock()
+
 	errorHandler := func(err error) es.StreamErrorHandlerResult {
+		if s.stopConnecting() {
+			return es.StreamErrorHandlerResult{CloseNow: true}
+		}
 		if se, ok := err.(es.SubscriptionError); ok {
 			if se.Code == 401 || se.Code == 403 {
 				s.loggers.Error(logMsgBadKey)
@@ -144,16 +202,16 @@ func (s *StreamManager) subscribe(readyCh chan<- error) {
 	if err != nil {
 		s.loggers.Errorf(logMsgBadURL, err)
 		signalReady(err)
-		return
+		return nil
 	}
 
 	req, _ := http.NewRequest("GET", rpacEndpoint, nil)
-	req.Header.Set("Authorization", string(s.key))
+	req.Header.Set("Authorization", string(key))
 	s.loggers.Infof(logMsgStreamConnecting, rpacEndpoint)
 
 	// Client.Timeout must be zeroed out for stream connections, since it's not just a connect timeout
 	// but a timeout for the entire response
-	client := s.httpConfig.Client()
+	client := httpConfig.Client()
 	client.Timeout = 0
 
 	stream, err := es.SubscribeWithRequestAndOptions(req,
@@ -169,16 +227,20 @@ func (s *StreamManager) subscribe(readyCh chan<- error) {
 	)
 
 	if err != nil {
+		if s.stopConnecting() {
+			return nil
+		}
 		s.loggers.Errorf(logMsgStreamOtherError, err)
 		signalReady(err)
-		return
+		return nil
 	}
 
-	signalReady(nil)
-	s.consumeStream(stream)
+	return stream
 }
 
-func (s *StreamManager) consumeStream(stream *es.Stream) {
+// consumeStream processes events from the stream until it is closed. It returns true if that was because
+// Reconnect was called.
+func (s *StreamManager) consumeStream(stream *es.Stream) bool {
 	// Consume remaining Events and Errors so we can garbage collect
 	defer func() {
 		for range stream.Events {
@@ -193,12 +255,11 @@ func (s *StreamManager) consumeStream(stream *es.Stream) {
 		select {
 		case event, ok := <-stream.Events:
 			if !ok {
-				// COVERAGE: stream.Events is only closed if the EventSource has been closed. However, that
-				// only happens when we have received from s.halt, in which case we return immediately
-				// after calling stream.Close(), terminating the for loop-- so we should not actually reach
-				// this point. Still, in case the channel is somehow closed unexpectedly, we do want to
-				// terminate the loop.
-				return
+				// stream.Events is only closed if the EventSource has been closed. That happens when we
+				// have received from s.halt or s.reconnectCh, in which case we have already returned, or
+				// when our error handler told the EventSource to stop retrying, for instance because the
+				// key was rejected.
+				return false
 			}
 
 			shouldRestart := false
@@ -295,9 +356,12 @@ func (s *StreamManager) consumeStream(stream *es.Stream) {
 			if shouldRestart {
 				stream.Restart()
 			}
+		case <-s.reconnectCh:
+			stream.Close()
+			return true
 		case <-s.halt:
 			stream.Close()
-			return
+			return false
 		}
 	}
 }
@@ -0,0 +1,68 @@
+package autoconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNewConfigKey config.AutoConfigKey = "new-test-key"
+
+func (p streamManagerTestParams) reconnectWithNewKey() {
+	httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", p.mockLog.Loggers)
+	require.NoError(p.t, err)
+	p.streamManager.Reconnect(testNewConfigKey, httpConfig)
+}
+
+func TestReconnectUsesNewKey(t *testing.T) {
+	event := makeEnvPutEvent(testEnv1)
+	streamManagerTest(t, &event, func(p streamManagerTestParams) {
+		p.startStream()
+		req := helpers.RequireValue(t, p.requestsCh, time.Second, "timed out waiting for request")
+		assert.Equal(t, string(testConfigKey), req.Request.Header.Get("Authorization"))
+		msg := p.requireMessage()
+		require.NotNil(t, msg.add)
+		p.requireReceivedAllMessage()
+
+		p.reconnectWithNewKey()
+
+		req = helpers.RequireValue(t, p.requestsCh, time.Second, "timed out waiting for reconnect")
+		assert.Equal(t, string(testNewConfigKey), req.Request.Header.Get("Authorization"))
+		p.requireReceivedAllMessage() // the environment hasn't changed, so it is not added again
+		p.requireNoMoreMessages()
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Info, "Reconnecting to auto-configuration stream")
+	})
+}
+
+func TestReconnectAfterKeyWasRejected(t *testing.T) {
+	initialEvent := makeEnvPutEvent(testEnv1)
+	streamHandler, stream := httphelpers.SSEHandler(&initialEvent)
+	defer stream.Close()
+	handler := httphelpers.SequentialHandler(
+		httphelpers.HandlerWithStatus(401), // first request will get this
+		streamHandler,                      // request after Reconnect will get this
+	)
+	streamManagerTestWithStreamHandler(t, handler, stream, func(p streamManagerTestParams) {
+		p.startStream()
+		<-p.requestsCh // first request
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Error, "Invalid auto-configuration key")
+
+		p.reconnectWithNewKey()
+
+		req := helpers.RequireValue(t, p.requestsCh, time.Second, "timed out waiting for reconnect")
+		assert.Equal(t, string(testNewConfigKey), req.Request.Header.Get("Authorization"))
+		msg := p.requireMessage()
+		require.NotNil(t, msg.add)
+		assert.Equal(t, testEnv1.ToParams(), *msg.add)
+		p.requireReceivedAllMessage()
+	})
+}
@@ -21,4 +21,14 @@ const (
 	// "ping" events. This is identical to MobilePingStream except that it only handles requests
 	// authenticated with an environment ID.
 	JSClientPingStream StreamKind = "js-ping"
+
+	// MobileEvalStream represents the mobile streaming endpoints when they are configured to send the
+	// evaluation results for the context of each connection, rather than "ping" events.
+	MobileEvalStream StreamKind = "mobile-eval"
+
+	// JSClientEvalStream represents the JS client-side streaming endpoints when they are configured to
+	// send the evaluation results for the context of each connection, rather than "ping" events. This is
+	// identical to MobileEvalStream except that it only handles requests authenticated with an
+	// environment ID.
+	JSClientEvalStream StreamKind = "js-eval"
 )
@@ -122,6 +122,17 @@ func (r *Rotator) DeprecatedCredentials() []SDKCredential {
 	return r.deprecatedCredentials()
 }
 
+// DeprecatedSDKKeyExpiries returns each deprecated SDK key along with the time when it will expire.
+func (r *Rotator) DeprecatedSDKKeyExpiries() map[config.SDKKey]time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ret := make(map[config.SDKKey]time.Time, len(r.deprecatedSdkKeys))
+	for key, expiry := range r.deprecatedSdkKeys {
+		ret[key] = expiry
+	}
+	return ret
+}
+
 // AllCredentials returns the primary and deprecated credentials as one list.
 func (r *Rotator) AllCredentials() []SDKCredential {
 	r.mu.RLock()
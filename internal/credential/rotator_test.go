@@ -169,10 +169,12 @@ func TestSDKKeyDeprecation(t *testing.T) {
 	additions, expirations = rotator.StepTime(deprecationTime)
 	assert.Empty(t, additions)
 	assert.Empty(t, expirations)
+	assert.Equal(t, map[config.SDKKey]time.Time{key1: deprecationTime}, rotator.DeprecatedSDKKeyExpiries())
 
 	additions, expirations = rotator.StepTime(deprecationTime.Add(1 * time.Millisecond))
 	assert.Empty(t, additions)
 	assert.ElementsMatch(t, []SDKCredential{key1}, expirations)
+	assert.Empty(t, rotator.DeprecatedSDKKeyExpiries())
 }
 
 func TestManyConcurrentSDKKeyDeprecation(t *testing.T) {
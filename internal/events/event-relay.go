@@ -1,9 +1,11 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"time"
@@ -33,20 +35,24 @@ const defaultEventQueueCleanupInterval = time.Hour
 type EventDispatcher struct {
 	analyticsEndpoints  map[basictypes.SDKKind]*analyticsEventEndpointDispatcher
 	diagnosticEndpoints map[basictypes.SDKKind]*diagnosticEventEndpointDispatcher
+	destinations        []*EventDestination
+	inspector           *EventInspector
 }
 
 type analyticsEventEndpointDispatcher struct {
-	config                    c.EventsConfig
-	httpClient                *http.Client
-	httpConfig                httpconfig.HTTPConfig
-	authKey                   credential.SDKCredential
-	remotePath                string
-	verbatimRelay             *eventVerbatimRelay
-	summarizingRelay          *eventSummarizingRelay
-	storeAdapter              *store.SSERelayDataStoreAdapter
-	eventQueueCleanupInterval time.Duration
-	loggers                   ldlog.Loggers
-	mu                        sync.Mutex
+	config           c.EventsConfig
+	httpClient       *http.Client
+	httpConfig       httpconfig.HTTPConfig
+	authKey          credential.SDKCredential
+	sdkKind          basictypes.SDKKind
+	remotePath       string
+	overload         *eventOverloadPolicy
+	options          EventDispatcherOptions
+	verbatimRelay    *eventVerbatimRelay
+	summarizingRelay *eventSummarizingRelay
+	storeAdapter     *store.SSERelayDataStoreAdapter
+	loggers          ldlog.Loggers
+	mu               sync.Mutex
 }
 
 type diagnosticEventEndpointDispatcher struct {
@@ -54,6 +60,7 @@ type diagnosticEventEndpointDispatcher struct {
 	httpConfig httpconfig.HTTPConfig
 	baseURI    string
 	uriPath    string
+	discard    bool // true if analytics events are going to an events sink rather than to LaunchDarkly
 	loggers    ldlog.Loggers
 }
 
@@ -72,7 +79,11 @@ func (r *EventDispatcher) GetHandler(sdkKind basictypes.SDKKind, eventsKind ldev
 }
 
 func (r *analyticsEventEndpointDispatcher) dispatch(w http.ResponseWriter, req *http.Request) {
+	if r.overload.reject(w, r.queuedEventCount(), r.loggers) {
+		return
+	}
 	consumeEvents(w, req, r.loggers, func(body []byte) {
+		r.options.Inspector.inspectInbound(r.sdkKind, req, body)
 		evts := make([]json.RawMessage, 0)
 		err := json.Unmarshal(body, &evts)
 		if err != nil {
@@ -83,16 +94,34 @@ func (r *analyticsEventEndpointDispatcher) dispatch(w http.ResponseWriter, req *
 		metadata := GetEventPayloadMetadata(req)
 
 		r.loggers.Debugf("Received %d events (v%d) to be proxied to %s", len(evts), metadata.SchemaVersion, r.remotePath)
+		recordEventsReceived(r.options.MetricsContext, evts)
+		if r.overload.discard(r.loggers) {
+			recordEventsDropped(r.options.MetricsContext, eventsDroppedReasonMemoryLimit, len(evts))
+			return
+		}
+		evts = r.options.Rules.apply(r.sdkKind, evts)
+		if len(evts) == 0 {
+			return
+		}
+		if hasEventDestinations(r.options.Destinations, true) {
+			if data, err := json.Marshal(evts); err == nil {
+				enqueueToEventDestinations(r.options.Destinations, true, r.remotePath, metadata, data, len(evts))
+			}
+		}
+		traceParent := req.Header.Get(otlpTraceParentHeader)
 		if metadata.SchemaVersion < SummaryEventsSchemaVersion {
-			r.getSummarizingRelay().enqueue(metadata, evts)
+			r.getSummarizingRelay().enqueue(metadata, evts, traceParent)
 			return
 		}
 
 		if _, ok := req.Header[http.CanonicalHeaderKey(EventUnsummarizedHeader)]; ok {
-			r.getSummarizingRelay().enqueue(metadata, evts)
+			r.getSummarizingRelay().enqueue(metadata, evts, traceParent)
 			return
 		}
 
+		// Events that are summarized are exported to OpenTelemetry by the summarizing relay, after it
+		// has translated them.
+		r.options.OTLP.exportEvents(r.sdkKind, traceParent, evts)
 		r.getVerbatimRelay().enqueue(metadata, evts)
 	})
 }
@@ -111,6 +140,15 @@ func (r *analyticsEventEndpointDispatcher) replaceCredential(newCredential crede
 	}
 }
 
+func (r *analyticsEventEndpointDispatcher) queuedEventCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.verbatimRelay == nil {
+		return 0
+	}
+	return r.verbatimRelay.publisher.QueuedEventCount()
+}
+
 func (r *analyticsEventEndpointDispatcher) close() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -124,6 +162,9 @@ func (r *analyticsEventEndpointDispatcher) close() {
 
 func (d *diagnosticEventEndpointDispatcher) dispatch(w http.ResponseWriter, req *http.Request) {
 	consumeEvents(w, req, d.loggers, func(body []byte) {
+		if d.discard {
+			return
+		}
 		// We are just operating as a reverse proxy and passing the request on verbatim to LD; we do not
 		// need to parse the JSON.
 		d.loggers.Debugf("Received diagnostic event to be proxied to %s/%s", d.baseURI, d.uriPath)
@@ -170,7 +211,8 @@ func (r *analyticsEventEndpointDispatcher) getVerbatimRelay() *eventVerbatimRela
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if r.verbatimRelay == nil {
-		r.verbatimRelay = newEventVerbatimRelay(r.authKey, r.config, r.httpConfig, r.loggers, r.remotePath)
+		r.verbatimRelay = newEventVerbatimRelay(r.authKey, r.sdkKind, r.config, r.httpConfig, r.loggers, r.remotePath,
+			r.overload.memoryLimits(), r.options)
 	}
 	return r.verbatimRelay
 }
@@ -180,7 +222,7 @@ func (r *analyticsEventEndpointDispatcher) getSummarizingRelay() *eventSummarizi
 	defer r.mu.Unlock()
 	if r.summarizingRelay == nil {
 		r.summarizingRelay = newEventSummarizingRelay(r.config, r.httpConfig, r.authKey, r.storeAdapter,
-			r.loggers, r.remotePath, r.eventQueueCleanupInterval)
+			r.loggers, r.remotePath, r.sdkKind, r.options)
 	}
 	return r.summarizingRelay
 }
@@ -196,7 +238,46 @@ func (r *analyticsEventEndpointDispatcher) flush() { //nolint:unused // used onl
 	}
 }
 
-// NewEventDispatcher creates a handler for relaying events to LaunchDarkly for an environment
+// EventDispatcherOptions contains optional parameters for NewEventDispatcher. The zero value means that
+// none of the optional behavior is enabled.
+type EventDispatcherOptions struct {
+	// MetricsContext is used to record metrics about the events. It is normally the environment's
+	// OpenCensus context; if it is nil, no metrics are recorded.
+	MetricsContext context.Context
+
+	// Rules, if not nil, is applied to analytics events before they are forwarded.
+	Rules *EventRules
+
+	// Sink, if not nil, receives analytics events instead of LaunchDarkly, and diagnostic events are
+	// discarded.
+	Sink *EnvironmentEventSink
+
+	// Destinations are additional places that analytics events are delivered to. The EventDispatcher takes
+	// ownership of them, and closes them when it is closed.
+	Destinations []*EventDestination
+
+	// GlobalMemory, if not nil, is a limit on the size of queued analytics events that is shared by all
+	// environments. The size of this environment's queued events is added to it, as well as to a limit for
+	// this environment if config.MaxMemoryPerEnv is set. What happens when a limit is reached, or when too
+	// many events are queued, depends on config.OverloadPolicy.
+	GlobalMemory *EventMemoryLimit
+
+	// Inspector, if not nil, is notified of the analytics event payloads that are received and delivered.
+	Inspector *EventInspector
+
+	// OTLP, if not nil, receives feature, custom, and identify events to export to OpenTelemetry.
+	OTLP *EnvironmentOTLPExporter
+
+	// SpoolDir is a directory where events that cannot be delivered are stored, in subdirectories for
+	// each kind of SDK, until they can be delivered. If it is empty, undeliverable events are not kept.
+	SpoolDir string
+
+	// EventQueueCleanupInterval is how often to check for unused summarizing event queues. It is normally
+	// zero to use the default; it is overridden in tests.
+	EventQueueCleanupInterval time.Duration
+}
+
+// NewEventDispatcher creates a handler for relaying events to LaunchDarkly for an environment.
 func NewEventDispatcher(
 	sdkKey c.SDKKey,
 	mobileKey c.MobileKey,
@@ -205,36 +286,45 @@ func NewEventDispatcher(
 	config c.EventsConfig,
 	httpConfig httpconfig.HTTPConfig,
 	storeAdapter *store.SSERelayDataStoreAdapter,
-	eventQueueCleanupInterval time.Duration, // normally zero to use the default; overridden in tests
+	options EventDispatcherOptions,
 ) *EventDispatcher {
+	overload := newEventOverloadPolicy(config, options.GlobalMemory)
 	ep := &EventDispatcher{
 		analyticsEndpoints: map[basictypes.SDKKind]*analyticsEventEndpointDispatcher{
-			basictypes.ServerSDK: newAnalyticsEventEndpointDispatcher(sdkKey,
-				config, httpConfig, storeAdapter, loggers, "/bulk", eventQueueCleanupInterval),
+			basictypes.ServerSDK: newAnalyticsEventEndpointDispatcher(sdkKey, basictypes.ServerSDK,
+				config, httpConfig, storeAdapter, loggers, "/bulk", overload, options),
 		},
 		diagnosticEndpoints: map[basictypes.SDKKind]*diagnosticEventEndpointDispatcher{
-			basictypes.ServerSDK: newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers, "/diagnostic"),
+			basictypes.ServerSDK: newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers, "/diagnostic", options.Sink != nil),
 		},
+		destinations: options.Destinations,
+		inspector:    options.Inspector,
 	}
 	if mobileKey.Defined() {
-		ep.analyticsEndpoints[basictypes.MobileSDK] = newAnalyticsEventEndpointDispatcher(mobileKey,
-			config, httpConfig, storeAdapter, loggers, "/mobile", eventQueueCleanupInterval)
-		ep.diagnosticEndpoints[basictypes.MobileSDK] = newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers, "/mobile/events/diagnostic")
+		ep.analyticsEndpoints[basictypes.MobileSDK] = newAnalyticsEventEndpointDispatcher(mobileKey, basictypes.MobileSDK,
+			config, httpConfig, storeAdapter, loggers, "/mobile", overload, options)
+		ep.diagnosticEndpoints[basictypes.MobileSDK] = newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers,
+			"/mobile/events/diagnostic", options.Sink != nil)
 	}
 	if envID.Defined() {
-		ep.analyticsEndpoints[basictypes.JSClientSDK] = newAnalyticsEventEndpointDispatcher(envID, config, httpConfig, storeAdapter, loggers,
-			"/events/bulk/"+string(envID), eventQueueCleanupInterval)
+		ep.analyticsEndpoints[basictypes.JSClientSDK] = newAnalyticsEventEndpointDispatcher(envID, basictypes.JSClientSDK, config, httpConfig,
+			storeAdapter, loggers, "/events/bulk/"+string(envID), overload, options)
 		ep.diagnosticEndpoints[basictypes.JSClientSDK] = newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers,
-			"/events/diagnostic/"+string(envID))
+			"/events/diagnostic/"+string(envID), options.Sink != nil)
 	}
 	return ep
 }
 
-// Close shuts down any goroutines/channels being used by the EventDispatcher.
+// Close delivers any analytics events that are still queued, and then shuts down any goroutines/channels
+// being used by the EventDispatcher. It blocks until the deliveries have finished.
 func (r *EventDispatcher) Close() {
 	for _, e := range r.analyticsEndpoints {
 		e.close()
 	}
+	// The endpoints have now flushed their last events to the destinations.
+	for _, d := range r.destinations {
+		d.close()
+	}
 	// diagnosticEventEndpointDispatcher doesn't currently need to be closed, because it doesn't maintain any
 	// goroutines or channels
 }
@@ -245,6 +335,24 @@ func (r *EventDispatcher) flush() { //nolint:unused // used only in tests
 	}
 }
 
+// GetQueuedEventCounts returns the number of analytics events, for each kind of SDK, that have been
+// received and are waiting to be forwarded verbatim. Events that are being summarized for older SDKs
+// are buffered inside the SDK's event processor, which does not report a count, so they are not
+// included.
+func (r *EventDispatcher) GetQueuedEventCounts() map[basictypes.SDKKind]int {
+	ret := make(map[basictypes.SDKKind]int, len(r.analyticsEndpoints))
+	for sdkKind, e := range r.analyticsEndpoints {
+		ret[sdkKind] = e.queuedEventCount()
+	}
+	return ret
+}
+
+// GetInspector returns the EventInspector that reports this environment's analytics event payloads, or
+// nil if there is none.
+func (r *EventDispatcher) GetInspector() *EventInspector {
+	return r.inspector
+}
+
 // ReplaceCredential changes the authorization credentail that is used when forwarding events to any
 // endpoints that use that type of credential. For instance, if newCredential is a MobileKey, this
 // affects only endpoints that use a mobile key.
@@ -261,6 +369,7 @@ func newDiagnosticEventEndpointDispatcher(
 	httpConfig httpconfig.HTTPConfig,
 	loggers ldlog.Loggers,
 	remotePath string,
+	discard bool,
 ) *diagnosticEventEndpointDispatcher {
 	eventsURI := getEventsURI(config)
 	return &diagnosticEventEndpointDispatcher{
@@ -268,37 +377,59 @@ func newDiagnosticEventEndpointDispatcher(
 		httpConfig: httpConfig,
 		baseURI:    eventsURI,
 		uriPath:    remotePath,
+		discard:    discard,
 		loggers:    loggers,
 	}
 }
 
+// newAnalyticsEventEndpointDispatcher creates the dispatcher for one kind of SDK. The options are the
+// ones that were passed to NewEventDispatcher; the endpoint's copy of them is adjusted so that SpoolDir
+// refers to the subdirectory for this kind of SDK, and MetricsContext has the platformCategory tag for it.
 func newAnalyticsEventEndpointDispatcher(
 	authKey credential.SDKCredential,
+	sdkKind basictypes.SDKKind,
 	config c.EventsConfig,
 	httpConfig httpconfig.HTTPConfig,
 	storeAdapter *store.SSERelayDataStoreAdapter,
 	loggers ldlog.Loggers,
 	remotePath string,
-	eventQueueCleanupInterval time.Duration,
+	overload *eventOverloadPolicy,
+	options EventDispatcherOptions,
 ) *analyticsEventEndpointDispatcher {
-	return &analyticsEventEndpointDispatcher{
-		authKey:                   authKey,
-		config:                    config,
-		httpClient:                httpConfig.Client(),
-		httpConfig:                httpConfig,
-		storeAdapter:              storeAdapter,
-		loggers:                   loggers,
-		remotePath:                remotePath,
-		eventQueueCleanupInterval: eventQueueCleanupInterval,
+	if options.SpoolDir != "" {
+		options.SpoolDir = filepath.Join(options.SpoolDir, string(sdkKind))
+	}
+	options.MetricsContext = makeEndpointMetricsContext(options.MetricsContext, sdkKind)
+	r := &analyticsEventEndpointDispatcher{
+		authKey:      authKey,
+		sdkKind:      sdkKind,
+		config:       config,
+		httpClient:   httpConfig.Client(),
+		httpConfig:   httpConfig,
+		storeAdapter: storeAdapter,
+		loggers:      loggers,
+		remotePath:   remotePath,
+		overload:     overload,
+		options:      options,
+	}
+	if options.SpoolDir != "" && spoolDirHasData(options.SpoolDir) {
+		// Normally the publisher isn't created until we receive some events, but if events from a previous
+		// run are waiting in the spool, we want to start delivering them right away.
+		r.verbatimRelay = newEventVerbatimRelay(authKey, sdkKind, config, httpConfig, loggers, remotePath,
+			overload.memoryLimits(), options)
 	}
+	return r
 }
 
 func newEventVerbatimRelay(
 	authKey credential.SDKCredential,
+	sdkKind basictypes.SDKKind,
 	config c.EventsConfig,
 	httpConfig httpconfig.HTTPConfig,
 	loggers ldlog.Loggers,
 	remotePath string,
+	memoryLimits []*EventMemoryLimit,
+	options EventDispatcherOptions,
 ) *eventVerbatimRelay {
 	eventsURI := getEventsURI(config)
 	opts := []OptionType{
@@ -306,6 +437,27 @@ func newEventVerbatimRelay(
 		OptionBaseURI(eventsURI),
 		OptionURIPath(remotePath),
 	}
+	if options.Sink != nil {
+		opts = append(opts, OptionSink{Sink: options.Sink, SDKKind: sdkKind})
+	}
+	if hasEventDestinations(options.Destinations, false) {
+		opts = append(opts, OptionDestinations(options.Destinations))
+	}
+	if options.MetricsContext != nil {
+		opts = append(opts, OptionMetricsContext{Context: options.MetricsContext})
+	}
+	if len(memoryLimits) != 0 {
+		opts = append(opts, OptionMemoryLimits(memoryLimits))
+	}
+	if options.Inspector != nil {
+		opts = append(opts, OptionInspector{Inspector: options.Inspector, SDKKind: sdkKind})
+	}
+	if options.SpoolDir != "" {
+		opts = append(opts, OptionSpool{
+			Dir:     options.SpoolDir,
+			MaxSize: int64(config.SpoolMaxSize.GetOrElse(c.DefaultEventsSpoolMaxSize)),
+		})
+	}
 
 	opts = append(opts, OptionFlushInterval(config.FlushInterval.GetOrElse(c.DefaultEventsFlushInterval)))
 
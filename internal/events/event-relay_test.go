@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"sort"
@@ -68,6 +69,13 @@ var allTestEndpoints = []testEndpointInfo{testServerEndpointInfo, testMobileEndp
 
 type eventRelayTestOptions struct {
 	eventQueueCleanupInterval time.Duration
+	rules                     *EventRules
+	sink                      *EnvironmentEventSink
+	destinations              []*EventDestination
+	metricsCtx                context.Context
+	globalMemory              *EventMemoryLimit
+	inspector                 *EventInspector
+	otlp                      *EnvironmentOTLPExporter
 }
 
 type eventRelayTestParams struct {
@@ -118,7 +126,16 @@ func eventRelayTestWithOptions(
 			eventsConfig,
 			httpConfig,
 			makeStoreAdapterWithExistingStore(store),
-			opts.eventQueueCleanupInterval,
+			EventDispatcherOptions{
+				MetricsContext:            opts.metricsCtx,
+				Rules:                     opts.rules,
+				Sink:                      opts.sink,
+				Destinations:              opts.destinations,
+				GlobalMemory:              opts.globalMemory,
+				Inspector:                 opts.inspector,
+				OTLP:                      opts.otlp,
+				EventQueueCleanupInterval: opts.eventQueueCleanupInterval,
+			},
 		)
 		defer dispatcher.Close()
 
@@ -350,6 +367,182 @@ func TestEventDispatcherReplaceCredential(t *testing.T) {
 	})
 }
 
+func TestEventDispatcherQueuedEventCounts(t *testing.T) {
+	eventRelayTest(t, st.EnvWithAllCredentials, config.EventsConfig{}, func(p eventRelayTestParams) {
+		assert.Equal(t, map[basictypes.SDKKind]int{basictypes.ServerSDK: 0, basictypes.MobileSDK: 0, basictypes.JSClientSDK: 0},
+			p.dispatcher.GetQueuedEventCounts())
+
+		req := st.BuildRequest("POST", "/", []byte(eventPayloadForVerbatimOnly), headersWithEventSchema(CurrentEventsSchemaVersion))
+		handler := p.dispatcher.GetHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind)
+		handler(httptest.NewRecorder(), req)
+
+		assert.Eventually(t, func() bool {
+			return p.dispatcher.GetQueuedEventCounts()[basictypes.MobileSDK] == 3
+		}, time.Second, time.Millisecond*10)
+		assert.Equal(t, 0, p.dispatcher.GetQueuedEventCounts()[basictypes.ServerSDK])
+
+		p.dispatcher.flush()
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+		assert.Equal(t, 0, p.dispatcher.GetQueuedEventCounts()[basictypes.MobileSDK])
+	})
+}
+
+func TestEventDispatcherAppliesEventRules(t *testing.T) {
+	rules := NewEventRules(config.EnvConfig{
+		DropCustomEvent:    configtypes.NewOptStringList([]string{"noisy-*"}),
+		DropIdentifyEvents: configtypes.NewOptStringList([]string{"mobile"}),
+	})
+	opts := eventRelayTestOptions{rules: rules}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		body := `[{"kind":"identify","key":"a"},{"kind":"custom","key":"noisy-1"},{"kind":"custom","key":"useful"}]`
+		for _, sdkKind := range []basictypes.SDKKind{basictypes.ServerSDK, basictypes.MobileSDK} {
+			req := st.BuildRequest("POST", "/", []byte(body), headersWithEventSchema(CurrentEventsSchemaVersion))
+			p.dispatcher.GetHandler(sdkKind, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+		}
+
+		assert.Eventually(t, func() bool {
+			counts := p.dispatcher.GetQueuedEventCounts()
+			return counts[basictypes.ServerSDK] == 2 && counts[basictypes.MobileSDK] == 1
+		}, time.Second, time.Millisecond*10)
+	})
+}
+
+func TestEventDispatcherDeliversToEventSink(t *testing.T) {
+	sink := newTestEventSink()
+	opts := eventRelayTestOptions{sink: NewEnvironmentEventSink(sink, "my env")}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		t.Run("verbatim", func(t *testing.T) {
+			req := st.BuildRequest("POST", "/", []byte(eventPayloadForVerbatimOnly), headersWithEventSchema(CurrentEventsSchemaVersion))
+			p.dispatcher.GetHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+			p.dispatcher.flush()
+
+			d := helpers.RequireValue(t, sink.deliveries, time.Second)
+			assert.Equal(t, EventSinkSource{
+				EnvName:  "my env",
+				SDKKind:  basictypes.MobileSDK,
+				Metadata: EventPayloadMetadata{SchemaVersion: CurrentEventsSchemaVersion},
+			}, d.source)
+			assert.Equal(t, eventPayloadForVerbatimOnly, d.payload)
+			assert.Equal(t, 3, d.count)
+		})
+
+		t.Run("summarized", func(t *testing.T) {
+			summarizeEventsParams := makeBasicSummarizeEventsParams()
+			req := st.BuildRequest("POST", "/", []byte(summarizeEventsParams.inputEventsJSON),
+				headersWithEventSchema(summarizeEventsParams.schemaVersion))
+			p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+			p.dispatcher.flush()
+
+			d := helpers.RequireValue(t, sink.deliveries, time.Second)
+			assert.Equal(t, basictypes.ServerSDK, d.source.SDKKind)
+			assert.Equal(t, CurrentEventsSchemaVersion, d.source.Metadata.SchemaVersion)
+			m.In(t).Assert(d.payload, m.JSONStrEqual(summarizeEventsParams.expectedEventsJSON))
+		})
+
+		t.Run("diagnostic events are discarded", func(t *testing.T) {
+			req := st.BuildRequest("POST", "/", []byte(`{"kind":"diagnostic"}`), nil)
+			w := httptest.NewRecorder()
+			p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.DiagnosticEventDataKind)(w, req)
+			assert.Equal(t, http.StatusAccepted, w.Result().StatusCode)
+		})
+
+		helpers.AssertNoMoreValues(t, sink.deliveries, time.Millisecond*50)
+		helpers.AssertNoMoreValues(t, p.requestsCh, time.Millisecond*50)
+	})
+}
+
+func TestEventDispatcherDeliversToEventDestinations(t *testing.T) {
+	handler, destRequestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(destServer *httptest.Server) {
+		httpConfig, _ := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", ldlog.NewDisabledLoggers())
+		summarizedURI, _ := configtypes.NewOptURLAbsoluteFromString(destServer.URL + "/summarized")
+		rawURI, _ := configtypes.NewOptURLAbsoluteFromString(destServer.URL + "/raw")
+		destinations := NewEventDestinations(map[string]*config.EventDestinationConfig{
+			"a": {URI: summarizedURI},
+			"b": {URI: rawURI, Mode: config.EventDestinationRaw},
+		}, "my env", httpConfig, ldlog.NewDisabledLoggers())
+		opts := eventRelayTestOptions{destinations: destinations}
+
+		// requests to the two destinations are made on separate goroutines, so they can arrive in either order
+		requireDestinationRequests := func(t *testing.T) map[string]httphelpers.HTTPRequestInfo {
+			ret := make(map[string]httphelpers.HTTPRequestInfo)
+			for i := 0; i < 2; i++ {
+				r := helpers.RequireValue(t, destRequestsCh, time.Second)
+				ret[r.Request.URL.Path] = r
+			}
+			return ret
+		}
+
+		eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+			t.Run("verbatim", func(t *testing.T) {
+				req := st.BuildRequest("POST", "/", []byte(eventPayloadForVerbatimOnly), headersWithEventSchema(CurrentEventsSchemaVersion))
+				p.dispatcher.GetHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+				p.dispatcher.flush()
+
+				r := helpers.RequireValue(t, p.requestsCh, time.Second)
+				uncompressed, err := util.DecompressGzipData(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, eventPayloadForVerbatimOnly, string(uncompressed))
+
+				destRequests := requireDestinationRequests(t)
+				require.Contains(t, destRequests, "/summarized/mobile")
+				require.Contains(t, destRequests, "/raw/mobile")
+				for _, dr := range destRequests {
+					assert.Equal(t, eventPayloadForVerbatimOnly, string(dr.Body))
+					assert.Equal(t, "my env", dr.Request.Header.Get(EventSinkEnvironmentHeader))
+					assert.Equal(t, "", dr.Request.Header.Get("Authorization"))
+				}
+			})
+
+			t.Run("summarized", func(t *testing.T) {
+				summarizeEventsParams := makeBasicSummarizeEventsParams()
+				req := st.BuildRequest("POST", "/", []byte(summarizeEventsParams.inputEventsJSON),
+					headersWithEventSchema(summarizeEventsParams.schemaVersion))
+				p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+				p.dispatcher.flush()
+
+				r := helpers.RequireValue(t, p.requestsCh, time.Second)
+				uncompressed, err := util.DecompressGzipData(r.Body)
+				require.NoError(t, err)
+				m.In(t).Assert(uncompressed, m.JSONStrEqual(summarizeEventsParams.expectedEventsJSON))
+
+				destRequests := requireDestinationRequests(t)
+				require.Contains(t, destRequests, "/summarized/bulk")
+				require.Contains(t, destRequests, "/raw/bulk")
+				m.In(t).Assert(destRequests["/summarized/bulk"].Body, m.JSONStrEqual(summarizeEventsParams.expectedEventsJSON))
+				m.In(t).Assert(destRequests["/raw/bulk"].Body, m.JSONStrEqual(summarizeEventsParams.inputEventsJSON))
+				assert.Equal(t, strconv.Itoa(CurrentEventsSchemaVersion),
+					destRequests["/summarized/bulk"].Request.Header.Get(EventSchemaHeader))
+				assert.Equal(t, "1", destRequests["/raw/bulk"].Request.Header.Get(EventSchemaHeader)) // unspecified means 1
+			})
+		})
+		helpers.AssertNoMoreValues(t, destRequestsCh, time.Millisecond*50)
+	})
+}
+
+func TestEventDispatcherDeliversToPrimaryWhenEventDestinationFails(t *testing.T) {
+	httphelpers.WithServer(httphelpers.HandlerWithStatus(503), func(destServer *httptest.Server) {
+		httpConfig, _ := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", ldlog.NewDisabledLoggers())
+		uri, _ := configtypes.NewOptURLAbsoluteFromString(destServer.URL)
+		destinations := NewEventDestinations(map[string]*config.EventDestinationConfig{"a": {URI: uri}},
+			"my env", httpConfig, ldlog.NewDisabledLoggers())
+		opts := eventRelayTestOptions{destinations: destinations}
+
+		eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+			for i := 0; i < 3; i++ {
+				req := st.BuildRequest("POST", "/", []byte(eventPayloadForVerbatimOnly), headersWithEventSchema(CurrentEventsSchemaVersion))
+				p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+				p.dispatcher.flush()
+
+				r := helpers.RequireValue(t, p.requestsCh, time.Second)
+				uncompressed, err := util.DecompressGzipData(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, eventPayloadForVerbatimOnly, string(uncompressed))
+			}
+		})
+	})
+}
+
 func TestEventHandlersRejectMalformedJSON(t *testing.T) {
 	malformedInput := `[{"no`
 	eventRelayTest(t, st.EnvWithAllCredentials, config.EventsConfig{}, func(p eventRelayTestParams) {
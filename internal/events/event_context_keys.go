@@ -0,0 +1,210 @@
+package events
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/events/oldevents"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+)
+
+// contextKeyCache is a set of fully-qualified context keys with a maximum size, which discards the least
+// recently used key when it is full, and is cleared at a regular interval. This is the same behavior as
+// the context key cache inside each ldevents.EventProcessor, which decides when to generate an index
+// event; the difference is that this one is shared by all of the event processors of an
+// eventSummarizingRelay, so that a context that is seen in events with different metadata does not get
+// an index event in each payload.
+type contextKeyCache struct {
+	capacity      int
+	flushInterval time.Duration
+	lastFlush     time.Time
+	keys          map[string]*list.Element
+	order         *list.List // most recently used key is at the front
+	lock          sync.Mutex
+}
+
+func newContextKeyCache(capacity int, flushInterval time.Duration) *contextKeyCache {
+	return &contextKeyCache{
+		capacity:      capacity,
+		flushInterval: flushInterval,
+		lastFlush:     time.Now(),
+		keys:          make(map[string]*list.Element),
+		order:         list.New(),
+	}
+}
+
+// add adds a key to the cache, and returns true if it was not already there.
+func (c *contextKeyCache) add(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.clearIfFlushIntervalElapsed()
+	if e, ok := c.keys[key]; ok {
+		c.order.MoveToFront(e)
+		return false
+	}
+	c.keys[key] = c.order.PushFront(key)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.keys, oldest.Value.(string))
+	}
+	return true
+}
+
+// addAll adds each of the keys to the cache.
+func (c *contextKeyCache) addAll(keys []string) {
+	for _, key := range keys {
+		c.add(key)
+	}
+}
+
+// contains returns true if the key is in the cache.
+func (c *contextKeyCache) contains(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.clearIfFlushIntervalElapsed()
+	_, ok := c.keys[key]
+	return ok
+}
+
+func (c *contextKeyCache) clearIfFlushIntervalElapsed() {
+	if time.Since(c.lastFlush) >= c.flushInterval {
+		c.keys = make(map[string]*list.Element)
+		c.order.Init()
+		c.lastFlush = time.Now()
+	}
+}
+
+// removeDuplicateIndexEvents removes any index events for contexts that are already in the cache, or
+// that an earlier index event in the same data was for, from a JSON array of output events. It returns
+// the original data if nothing was removed. It does not change the cache; instead, it also returns the
+// keys of the contexts of the remaining index and identify events, which the caller should add to the
+// cache with addAll once the events have been delivered.
+func (c *contextKeyCache) removeDuplicateIndexEvents(data []byte, count int) ([]byte, int, []string) {
+	var evts []json.RawMessage
+	if err := json.Unmarshal(data, &evts); err != nil {
+		return data, count, nil
+	}
+	kept := make([]json.RawMessage, 0, len(evts))
+	var keys []string
+	seen := make(map[string]bool)
+	for _, e := range evts {
+		var fields struct {
+			Kind    string          `json:"kind"`
+			Context json.RawMessage `json:"context"`
+		}
+		if json.Unmarshal(e, &fields) == nil && (fields.Kind == "index" || fields.Kind == "identify") {
+			var context ldcontext.Context
+			if json.Unmarshal(fields.Context, &context) == nil && context.Err() == nil {
+				key := context.FullyQualifiedKey()
+				if fields.Kind == "index" && (seen[key] || c.contains(key)) {
+					continue
+				}
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+		kept = append(kept, e)
+	}
+	if len(kept) == len(evts) {
+		return data, count, keys
+	}
+	if len(kept) == 0 {
+		return nil, 0, nil
+	}
+	ret, err := json.Marshal(kept)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return data, count, keys
+	}
+	return ret, len(kept), keys
+}
+
+// indexedContextCache remembers the contexts of the index events that SDKs have sent, by fully-qualified
+// key, with a maximum size like contextKeyCache. A current-schema event that refers to its context only
+// by "contextKeys" is given to the event processor with the full context from here, if there is one.
+// Otherwise, once the key had been cleared from contextKeyCache and from the event processor's own cache,
+// the event processor would generate an index event that has only the context keys.
+//
+// Unlike contextKeyCache, this is not cleared at an interval, because the event processor can need a
+// context again whenever its own cache is cleared.
+type indexedContextCache struct {
+	capacity int
+	contexts map[string]*list.Element
+	order    *list.List // values are indexedContext; most recently used is at the front
+	lock     sync.Mutex
+}
+
+type indexedContext struct {
+	key     string
+	context oldevents.ReceivedEventContext
+}
+
+func newIndexedContextCache(capacity int) *indexedContextCache {
+	return &indexedContextCache{
+		capacity: capacity,
+		contexts: make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// put adds or replaces the context for a key.
+func (c *indexedContextCache) put(key string, context oldevents.ReceivedEventContext) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.contexts[key]; ok {
+		e.Value = indexedContext{key: key, context: context}
+		c.order.MoveToFront(e)
+		return
+	}
+	c.contexts[key] = c.order.PushFront(indexedContext{key: key, context: context})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.contexts, oldest.Value.(indexedContext).key)
+	}
+}
+
+// get returns the context for a key, if any. An empty key is never found.
+func (c *indexedContextCache) get(key string) (oldevents.ReceivedEventContext, bool) {
+	if key == "" {
+		return oldevents.ReceivedEventContext{}, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.contexts[key]
+	if !ok {
+		return oldevents.ReceivedEventContext{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(indexedContext).context, true
+}
+
+// indexDeduplicatingEventSender is an ldevents.EventSender that removes index events for contexts that
+// another event processor of the same eventSummarizingRelay has already reported. A context only counts
+// as reported once the payload containing its index event has been delivered, so that if a delivery
+// fails, the next payload for that context still has an index event.
+type indexDeduplicatingEventSender struct {
+	wrapped ldevents.EventSender
+	keys    *contextKeyCache
+}
+
+func (s *indexDeduplicatingEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	if kind != ldevents.AnalyticsEventDataKind {
+		return s.wrapped.SendEventData(kind, data, count)
+	}
+	data, count, keys := s.keys.removeDuplicateIndexEvents(data, count)
+	if count == 0 {
+		return ldevents.EventSenderResult{Success: true}
+	}
+	result := s.wrapped.SendEventData(kind, data, count)
+	if result.Success {
+		s.keys.addAll(keys)
+	}
+	return result
+}
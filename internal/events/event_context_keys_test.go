@@ -0,0 +1,132 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/events/oldevents"
+
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextKeyCacheDiscardsLeastRecentlyUsedKey(t *testing.T) {
+	cache := newContextKeyCache(2, time.Hour)
+	assert.True(t, cache.add("a"))
+	assert.True(t, cache.add("b"))
+	assert.False(t, cache.add("a"))
+	assert.True(t, cache.add("c")) // discards "b"
+	assert.False(t, cache.add("a"))
+	assert.True(t, cache.add("b"))
+}
+
+func TestContextKeyCacheIsClearedAfterFlushInterval(t *testing.T) {
+	cache := newContextKeyCache(10, time.Hour)
+	assert.True(t, cache.add("a"))
+	cache.lastFlush = time.Now().Add(-time.Hour)
+	assert.True(t, cache.add("a"))
+	assert.False(t, cache.add("a"))
+}
+
+func TestRemoveDuplicateIndexEvents(t *testing.T) {
+	cache := newContextKeyCache(10, time.Hour)
+	cache.add("org:o1")
+
+	data, count, keys := cache.removeDuplicateIndexEvents([]byte(`[
+		{"kind": "index", "context": {"kind": "org", "key": "o1"}},
+		{"kind": "index", "context": {"kind": "user", "key": "u1"}},
+		{"kind": "identify", "context": {"kind": "user", "key": "u2"}},
+		{"kind": "index", "context": {"kind": "user", "key": "u1"}},
+		{"kind": "custom", "key": "e"}
+	]`), 5)
+	assert.Equal(t, 3, count)
+	assert.JSONEq(t, `[
+		{"kind": "index", "context": {"kind": "user", "key": "u1"}},
+		{"kind": "identify", "context": {"kind": "user", "key": "u2"}},
+		{"kind": "custom", "key": "e"}
+	]`, string(data))
+	assert.Equal(t, []string{"u1", "u2"}, keys)
+	assert.False(t, cache.contains("u1"))
+
+	cache.addAll(keys)
+	data, count, keys = cache.removeDuplicateIndexEvents([]byte(`[
+		{"kind": "index", "context": {"kind": "user", "key": "u1"}},
+		{"kind": "index", "context": {"kind": "user", "key": "u2"}}
+	]`), 2)
+	assert.Equal(t, 0, count)
+	assert.Nil(t, data)
+	assert.Nil(t, keys)
+}
+
+func TestRemoveDuplicateIndexEventsReturnsSameDataIfNothingIsRemoved(t *testing.T) {
+	cache := newContextKeyCache(10, time.Hour)
+	input := []byte(`[{"kind": "index", "context": {"kind": "user", "key": "u1"}}, "not an object"]`)
+	data, count, keys := cache.removeDuplicateIndexEvents(input, 2)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, string(input), string(data))
+	assert.Equal(t, []string{"u1"}, keys)
+}
+
+type fakeEventSender struct {
+	success  bool
+	payloads []string
+}
+
+func (s *fakeEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	s.payloads = append(s.payloads, string(data))
+	return ldevents.EventSenderResult{Success: s.success}
+}
+
+func TestIndexDeduplicatingEventSenderRecordsContextsOnlyAfterSuccessfulDelivery(t *testing.T) {
+	payload := []byte(`[{"kind": "index", "context": {"kind": "user", "key": "u1"}}, {"kind": "custom", "key": "e"}]`)
+	wrapped := &fakeEventSender{success: false}
+	sender := &indexDeduplicatingEventSender{wrapped: wrapped, keys: newContextKeyCache(10, time.Hour)}
+
+	result := sender.SendEventData(ldevents.AnalyticsEventDataKind, payload, 2)
+	assert.False(t, result.Success)
+	assert.False(t, sender.keys.contains("u1"))
+
+	// The failed payload did not count as reporting the context, so the next one keeps its index event.
+	wrapped.success = true
+	result = sender.SendEventData(ldevents.AnalyticsEventDataKind, payload, 2)
+	assert.True(t, result.Success)
+	assert.True(t, sender.keys.contains("u1"))
+
+	result = sender.SendEventData(ldevents.AnalyticsEventDataKind, payload, 2)
+	assert.True(t, result.Success)
+	require.Len(t, wrapped.payloads, 3)
+	assert.Equal(t, string(payload), wrapped.payloads[0])
+	assert.Equal(t, string(payload), wrapped.payloads[1])
+	assert.JSONEq(t, `[{"kind": "custom", "key": "e"}]`, wrapped.payloads[2])
+}
+
+func TestIndexedContextCacheDiscardsLeastRecentlyUsedContext(t *testing.T) {
+	makeContext := func(contextJSON string) oldevents.ReceivedEventContext {
+		var c oldevents.ReceivedEventContext
+		require.NoError(t, json.Unmarshal([]byte(contextJSON), &c))
+		return c
+	}
+	a1, a2 := makeContext(`{"key": "a", "name": "1"}`), makeContext(`{"key": "a", "name": "2"}`)
+	b, c := makeContext(`{"key": "b"}`), makeContext(`{"key": "c"}`)
+
+	cache := newIndexedContextCache(2)
+	cache.put("user:a", a1)
+	cache.put("user:b", b)
+	cache.put("user:a", a2) // replaces a1
+	got, ok := cache.get("user:a")
+	assert.True(t, ok)
+	assert.Equal(t, a2, got)
+
+	cache.put("user:c", c) // discards "b"
+	_, ok = cache.get("user:b")
+	assert.False(t, ok)
+	got, ok = cache.get("user:c")
+	assert.True(t, ok)
+	assert.Equal(t, c, got)
+
+	_, ok = cache.get("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+)
+
+const (
+	eventDestinationQueueSize    = 100
+	eventDestinationCloseTimeout = 5 * time.Second
+)
+
+// EventDestination is an additional destination for analytics events, as configured by an
+// [EventDestination "NAME"] section. It receives events at the same time as the primary destination,
+// which is LaunchDarkly or the events sink.
+//
+// Each EventDestination has its own queue and goroutine, so a destination that is slow or unreachable
+// never delays delivery to the primary destination or to other destinations. If its queue is full,
+// further payloads for that destination are discarded until it catches up. Payloads are posted to the
+// destination's URI plus the same path that is used for LaunchDarkly, such as "/bulk", with the
+// configured headers; the SDK credential is not sent.
+type EventDestination struct {
+	name        string
+	raw         bool
+	client      *http.Client
+	baseURI     string
+	baseHeaders http.Header
+	queue       chan eventDestinationPayload
+	done        chan struct{}
+	overflowed  atomic.Bool // used to avoid logging the same warning repeatedly
+	closed      bool
+	loggers     ldlog.Loggers
+	lock        sync.RWMutex
+}
+
+type eventDestinationPayload struct {
+	remotePath string
+	metadata   EventPayloadMetadata
+	data       []byte
+	count      int
+}
+
+// NewEventDestinations creates an EventDestination for each configured destination, for the events of
+// one environment. The envName is sent to each destination in the EventSinkEnvironmentHeader header.
+// Invalid headers are ignored, since they have already been reported by config.ValidateConfig.
+func NewEventDestinations(
+	configs map[string]*c.EventDestinationConfig,
+	envName string,
+	httpConfig httpconfig.HTTPConfig,
+	loggers ldlog.Loggers,
+) []*EventDestination {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ret := make([]*EventDestination, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, newEventDestination(name, *configs[name], envName, httpConfig, loggers))
+	}
+	return ret
+}
+
+func newEventDestination(
+	name string,
+	config c.EventDestinationConfig,
+	envName string,
+	httpConfig httpconfig.HTTPConfig,
+	loggers ldlog.Loggers,
+) *EventDestination {
+	baseHeaders := make(http.Header)
+	for k, v := range httpConfig.SDKHTTPConfig.DefaultHeaders {
+		baseHeaders[k] = v
+	}
+	baseHeaders.Del("Authorization") // this is the SDK key; if the destination needs authorization, it is in config.Header
+	baseHeaders.Set(EventSinkEnvironmentHeader, envName)
+	for _, h := range config.Header.Values() {
+		if name, value, err := c.ParseEventDestinationHeader(h); err == nil {
+			baseHeaders.Set(name, value)
+		}
+	}
+	d := &EventDestination{
+		name:        name,
+		raw:         config.Mode == c.EventDestinationRaw,
+		client:      httpConfig.Client(),
+		baseURI:     strings.TrimSuffix(config.URI.String(), "/"),
+		baseHeaders: baseHeaders,
+		queue:       make(chan eventDestinationPayload, eventDestinationQueueSize),
+		done:        make(chan struct{}),
+		loggers:     loggers,
+	}
+	go d.run()
+	return d
+}
+
+// enqueue adds a payload to the destination's queue without blocking.
+func (d *EventDestination) enqueue(remotePath string, metadata EventPayloadMetadata, data []byte, count int) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.queue <- eventDestinationPayload{remotePath: remotePath, metadata: metadata, data: data, count: count}:
+		d.overflowed.Store(false)
+	default:
+		if d.overflowed.CompareAndSwap(false, true) {
+			d.loggers.Warnf("Event destination %q is not keeping up with the events it receives; some events will not be delivered to it",
+				d.name)
+		}
+	}
+}
+
+func (d *EventDestination) run() {
+	for p := range d.queue {
+		headers := make(http.Header)
+		for k, v := range d.baseHeaders {
+			headers[k] = v
+		}
+		if p.metadata.Tags != "" {
+			headers.Set(TagsHeader, p.metadata.Tags)
+		}
+		sendConfig := ldevents.EventSenderConfiguration{
+			Client:        d.client,
+			BaseURI:       d.baseURI,
+			BaseHeaders:   func() http.Header { return headers },
+			SchemaVersion: p.metadata.SchemaVersion,
+			Loggers:       d.loggers,
+		}
+		result := ldevents.SendEventDataWithRetry(sendConfig, ldevents.AnalyticsEventDataKind, p.remotePath, p.data, p.count)
+		if !result.Success {
+			d.loggers.Warnf("Unable to deliver %d events to event destination %q", p.count, d.name)
+		}
+	}
+	close(d.done)
+}
+
+// close stops accepting payloads, and waits a limited time for queued payloads to be delivered.
+func (d *EventDestination) close() {
+	d.lock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.lock.Unlock()
+	select {
+	case <-d.done:
+	case <-time.After(eventDestinationCloseTimeout):
+		d.loggers.Warnf("Timed out waiting for events to be delivered to event destination %q", d.name)
+	}
+}
+
+// hasEventDestinations returns true if any of the destinations want raw payloads, if raw is true, or
+// summarized payloads, if raw is false.
+func hasEventDestinations(destinations []*EventDestination, raw bool) bool {
+	for _, d := range destinations {
+		if d.raw == raw {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueToEventDestinations adds a payload to the queue of each destination that wants raw payloads,
+// if raw is true, or summarized payloads, if raw is false.
+func enqueueToEventDestinations(
+	destinations []*EventDestination,
+	raw bool,
+	remotePath string,
+	metadata EventPayloadMetadata,
+	data []byte,
+	count int,
+) {
+	for _, d := range destinations {
+		if d.raw == raw {
+			d.enqueue(remotePath, metadata, data, count)
+		}
+	}
+}
+
+// fanOutEventSender is an ldevents.EventSender that adds each payload to the queues of the summarized
+// destinations, and then delivers it to the primary destination.
+type fanOutEventSender struct {
+	primary      ldevents.EventSender
+	destinations []*EventDestination
+	remotePath   string
+	metadata     EventPayloadMetadata
+}
+
+func (f *fanOutEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	if kind == ldevents.AnalyticsEventDataKind {
+		enqueueToEventDestinations(f.destinations, false, f.remotePath, f.metadata, data, count)
+	}
+	return f.primary.SendEventData(kind, data, count)
+}
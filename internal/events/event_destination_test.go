@@ -0,0 +1,128 @@
+package events
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+
+	"github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeTestEventDestination(t *testing.T, uri string, headers ...string) *EventDestination {
+	httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{}, config.SDKKey("sdk-key"), "", ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	destURI, err := configtypes.NewOptURLAbsoluteFromString(uri)
+	require.NoError(t, err)
+	destinations := NewEventDestinations(map[string]*config.EventDestinationConfig{
+		"test": {URI: destURI, Header: configtypes.NewOptStringList(headers)},
+	}, "my env", httpConfig, ldlog.NewDisabledLoggers())
+	require.Len(t, destinations, 1)
+	return destinations[0]
+}
+
+func TestNewEventDestinationsSortsByName(t *testing.T) {
+	httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	uri, _ := configtypes.NewOptURLAbsoluteFromString("http://localhost")
+	destinations := NewEventDestinations(map[string]*config.EventDestinationConfig{
+		"c": {URI: uri}, "a": {URI: uri}, "b": {URI: uri, Mode: config.EventDestinationRaw},
+	}, "", httpConfig, ldlog.NewDisabledLoggers())
+	require.Len(t, destinations, 3)
+	for i, name := range []string{"a", "b", "c"} {
+		assert.Equal(t, name, destinations[i].name)
+		destinations[i].close()
+	}
+	assert.True(t, hasEventDestinations(destinations, true))
+	assert.True(t, hasEventDestinations(destinations, false))
+	assert.False(t, hasEventDestinations(destinations[0:1], true))
+}
+
+func TestEventDestinationPostsPayloads(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		d := makeTestEventDestination(t, server.URL+"/collector/", "Authorization: Bearer xyz", "X-Custom: a")
+		defer d.close()
+
+		d.enqueue("/bulk", EventPayloadMetadata{SchemaVersion: 4, Tags: "application-id/x"}, []byte(`["a"]`), 1)
+
+		r := helpers.RequireValue(t, requestsCh, time.Second)
+		assert.Equal(t, "POST", r.Request.Method)
+		assert.Equal(t, "/collector/bulk", r.Request.URL.Path)
+		assert.Equal(t, "Bearer xyz", r.Request.Header.Get("Authorization"))
+		assert.Equal(t, "a", r.Request.Header.Get("X-Custom"))
+		assert.Equal(t, "my env", r.Request.Header.Get(EventSinkEnvironmentHeader))
+		assert.Equal(t, "4", r.Request.Header.Get(EventSchemaHeader))
+		assert.Equal(t, "application-id/x", r.Request.Header.Get(TagsHeader))
+		assert.Equal(t, `["a"]`, string(r.Body))
+	})
+}
+
+func TestEventDestinationDoesNotSendSDKKey(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		d := makeTestEventDestination(t, server.URL)
+		defer d.close()
+
+		d.enqueue("/bulk", EventPayloadMetadata{SchemaVersion: 4}, []byte(`["a"]`), 1)
+
+		r := helpers.RequireValue(t, requestsCh, time.Second)
+		assert.Equal(t, "", r.Request.Header.Get("Authorization"))
+	})
+}
+
+func TestEventDestinationDiscardsPayloadsWhenQueueIsFull(t *testing.T) {
+	unblock := make(chan struct{})
+	handler, requestsCh := httphelpers.RecordingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		w.WriteHeader(202)
+	}))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		d := makeTestEventDestination(t, server.URL)
+
+		total := eventDestinationQueueSize + 10
+		enqueued := make(chan struct{})
+		go func() {
+			for i := 0; i < total; i++ {
+				d.enqueue("/bulk", EventPayloadMetadata{SchemaVersion: 4}, []byte(`["a"]`), 1)
+			}
+			close(enqueued)
+		}()
+		select {
+		case <-enqueued:
+		case <-time.After(time.Second):
+			require.Fail(t, "enqueue blocked while the destination was unavailable")
+		}
+
+		close(unblock)
+		d.close()
+		received := 0
+		for len(requestsCh) > 0 {
+			<-requestsCh
+			received++
+		}
+		assert.Less(t, received, total)
+		assert.GreaterOrEqual(t, received, eventDestinationQueueSize)
+	})
+}
+
+func TestEventDestinationIgnoresPayloadsAfterClose(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		d := makeTestEventDestination(t, server.URL)
+		d.close()
+
+		d.enqueue("/bulk", EventPayloadMetadata{SchemaVersion: 4}, []byte(`["a"]`), 1)
+
+		helpers.AssertNoMoreValues(t, requestsCh, time.Millisecond*50)
+	})
+}
@@ -0,0 +1,197 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/credential"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+)
+
+const eventInspectorBufferSize = 100
+
+// These are the values of EventInspectorRecord.Direction.
+const (
+	// EventInspectorInbound means the payload was received from an SDK.
+	EventInspectorInbound = "inbound"
+
+	// EventInspectorOutbound means the payload was produced by Relay to be delivered to LaunchDarkly,
+	// or to the events sink if there is one.
+	EventInspectorOutbound = "outbound"
+)
+
+// EventInspector lets an administrator watch the analytics event payloads of one environment, for
+// debugging. It reports each payload as it is received from an SDK, and each payload as it is delivered,
+// to any subscribers.
+//
+// To limit the overhead, nothing is done if there are no subscribers, and if the sampling ratio is N,
+// only one out of every N payloads in each direction is reported. A subscriber that does not keep up
+// misses some payloads, rather than delaying event processing.
+//
+// A nil *EventInspector is valid and does nothing.
+type EventInspector struct {
+	samplingRatio   uint64
+	inboundCount    atomic.Uint64
+	outboundCount   atomic.Uint64
+	subscriberCount atomic.Int32
+	subscribers     map[chan EventInspectorRecord]struct{}
+	lock            sync.Mutex
+}
+
+// EventInspectorRecord describes one payload that was reported by an EventInspector.
+type EventInspectorRecord struct {
+	Direction     string             `json:"direction"`
+	Timestamp     int64              `json:"timestamp"` // Unix milliseconds
+	SDKKind       basictypes.SDKKind `json:"sdkKind"`
+	Path          string             `json:"path"`
+	Credential    string             `json:"credential,omitempty"` // obscured in the same way as in the status resource
+	SchemaVersion int                `json:"schemaVersion"`
+	Tags          string             `json:"tags,omitempty"`
+	Count         int                `json:"count"`
+	Payload       json.RawMessage    `json:"payload"` // a JSON string, if the SDK sent something that was not JSON
+}
+
+// NewEventInspector creates an EventInspector that reports one out of every samplingRatio payloads.
+func NewEventInspector(samplingRatio int) *EventInspector {
+	if samplingRatio < 1 {
+		samplingRatio = 1
+	}
+	return &EventInspector{
+		samplingRatio: uint64(samplingRatio),
+		subscribers:   make(map[chan EventInspectorRecord]struct{}),
+	}
+}
+
+// Subscribe starts reporting payloads on a channel. The caller must call the returned function when it
+// no longer wants to receive them.
+func (i *EventInspector) Subscribe() (<-chan EventInspectorRecord, func()) {
+	ch := make(chan EventInspectorRecord, eventInspectorBufferSize)
+	i.lock.Lock()
+	i.subscribers[ch] = struct{}{}
+	i.subscriberCount.Add(1)
+	i.lock.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			i.lock.Lock()
+			delete(i.subscribers, ch)
+			i.subscriberCount.Add(-1)
+			i.lock.Unlock()
+		})
+	}
+}
+
+// sample returns true if a payload in this direction should be reported.
+func (i *EventInspector) sample(direction string) bool {
+	if i == nil || i.subscriberCount.Load() == 0 {
+		return false
+	}
+	counter := &i.outboundCount
+	if direction == EventInspectorInbound {
+		counter = &i.inboundCount
+	}
+	return (counter.Add(1)-1)%i.samplingRatio == 0
+}
+
+func (i *EventInspector) publish(r EventInspectorRecord) {
+	r.Timestamp = time.Now().UnixMilli()
+	if !json.Valid(r.Payload) {
+		r.Payload, _ = json.Marshal(string(r.Payload))
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for ch := range i.subscribers {
+		select {
+		case ch <- r:
+		default: // this subscriber is not keeping up
+		}
+	}
+}
+
+// inspectInbound reports an event payload that was received from an SDK.
+func (i *EventInspector) inspectInbound(sdkKind basictypes.SDKKind, req *http.Request, body []byte) {
+	if !i.sample(EventInspectorInbound) {
+		return
+	}
+	metadata := GetEventPayloadMetadata(req)
+	count := 0
+	var evts []json.RawMessage
+	if json.Unmarshal(body, &evts) == nil {
+		count = len(evts)
+	}
+	i.publish(EventInspectorRecord{
+		Direction:     EventInspectorInbound,
+		SDKKind:       sdkKind,
+		Path:          req.URL.Path,
+		Credential:    obscureAuthorizationHeader(req.Header.Get("Authorization")),
+		SchemaVersion: metadata.SchemaVersion,
+		Tags:          metadata.Tags,
+		Count:         count,
+		Payload:       body,
+	})
+}
+
+// inspectOutbound reports an event payload that Relay is about to deliver.
+func (i *EventInspector) inspectOutbound(
+	sdkKind basictypes.SDKKind,
+	remotePath string,
+	authKey credential.SDKCredential,
+	metadata EventPayloadMetadata,
+	payload []byte,
+	count int,
+) {
+	if !i.sample(EventInspectorOutbound) {
+		return
+	}
+	var credential string
+	if authKey != nil {
+		credential = obscureAuthorizationHeader(authKey.GetAuthorizationHeaderValue())
+	}
+	i.publish(EventInspectorRecord{
+		Direction:     EventInspectorOutbound,
+		SDKKind:       sdkKind,
+		Path:          remotePath,
+		Credential:    credential,
+		SchemaVersion: metadata.SchemaVersion,
+		Tags:          metadata.Tags,
+		Count:         count,
+		Payload:       payload,
+	})
+}
+
+// obscureAuthorizationHeader obscures the credential in an Authorization header value, which may or may
+// not have a scheme such as "api_key" before the credential.
+func obscureAuthorizationHeader(value string) string {
+	if value == "" {
+		return ""
+	}
+	if scheme, key, found := strings.Cut(value, " "); found {
+		return scheme + " " + sdks.ObscureKey(key)
+	}
+	return sdks.ObscureKey(value)
+}
+
+// inspectingEventSender is an ldevents.EventSender that reports each analytics event payload to an
+// EventInspector before delivering it.
+type inspectingEventSender struct {
+	wrapped    ldevents.EventSender
+	inspector  *EventInspector
+	sdkKind    basictypes.SDKKind
+	remotePath string
+	authKey    credential.SDKCredential
+	metadata   EventPayloadMetadata
+}
+
+func (s *inspectingEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	if kind == ldevents.AnalyticsEventDataKind {
+		s.inspector.inspectOutbound(s.sdkKind, s.remotePath, s.authKey, s.metadata, data, count)
+	}
+	return s.wrapped.SendEventData(kind, data, count)
+}
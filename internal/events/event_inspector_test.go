@@ -0,0 +1,94 @@
+package events
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventInspectorDoesNothingWithoutSubscribers(t *testing.T) {
+	inspector := NewEventInspector(1)
+	assert.False(t, inspector.sample(EventInspectorInbound))
+
+	_, unsubscribe := inspector.Subscribe()
+	assert.True(t, inspector.sample(EventInspectorInbound))
+	unsubscribe()
+	assert.False(t, inspector.sample(EventInspectorInbound))
+
+	var nilInspector *EventInspector
+	assert.False(t, nilInspector.sample(EventInspectorInbound))
+}
+
+func TestEventInspectorSamplesEachDirectionSeparately(t *testing.T) {
+	inspector := NewEventInspector(3)
+	_, unsubscribe := inspector.Subscribe()
+	defer unsubscribe()
+
+	var inbound, outbound []bool
+	for i := 0; i < 6; i++ {
+		inbound = append(inbound, inspector.sample(EventInspectorInbound))
+		outbound = append(outbound, inspector.sample(EventInspectorOutbound))
+	}
+	assert.Equal(t, []bool{true, false, false, true, false, false}, inbound)
+	assert.Equal(t, inbound, outbound)
+}
+
+func TestObscureAuthorizationHeader(t *testing.T) {
+	key := string(st.EnvMobile.Config.MobileKey)
+	assert.Equal(t, sdks.ObscureKey(key), obscureAuthorizationHeader(key))
+	assert.Equal(t, "api_key "+sdks.ObscureKey(key), obscureAuthorizationHeader("api_key "+key))
+	assert.Equal(t, "", obscureAuthorizationHeader(""))
+}
+
+func TestEventDispatcherReportsSummarizedPayloadsToInspector(t *testing.T) {
+	opts := eventRelayTestOptions{inspector: NewEventInspector(1)}
+	records, unsubscribe := opts.inspector.Subscribe()
+	defer unsubscribe()
+
+	eventRelayTestWithOptions(t, st.EnvMain, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		params := makeBasicSummarizeEventsParams()
+		headers := headersWithEventSchema(params.schemaVersion)
+		headers.Set("Authorization", string(st.EnvMain.Config.SDKKey))
+		req := st.BuildRequest("POST", "/bulk", []byte(params.inputEventsJSON), headers)
+		p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+
+		inbound := helpers.RequireValue(t, records, time.Second)
+		assert.Equal(t, EventInspectorInbound, inbound.Direction)
+		assert.Equal(t, basictypes.ServerSDK, inbound.SDKKind)
+		assert.Equal(t, "/bulk", inbound.Path)
+		assert.Equal(t, sdks.ObscureKey(string(st.EnvMain.Config.SDKKey)), inbound.Credential)
+		assert.JSONEq(t, params.inputEventsJSON, string(inbound.Payload))
+
+		p.dispatcher.flush()
+		outbound := helpers.RequireValue(t, records, time.Second)
+		assert.Equal(t, EventInspectorOutbound, outbound.Direction)
+		assert.Equal(t, CurrentEventsSchemaVersion, outbound.SchemaVersion)
+		assert.Equal(t, sdks.ObscureKey(string(st.EnvMain.Config.SDKKey)), outbound.Credential)
+		assert.JSONEq(t, params.expectedEventsJSON, string(outbound.Payload))
+		require.NotContains(t, string(outbound.Payload), string(st.EnvMain.Config.SDKKey))
+	})
+}
+
+func TestEventInspectorReportsInvalidJSONAsString(t *testing.T) {
+	inspector := NewEventInspector(1)
+	records, unsubscribe := inspector.Subscribe()
+	defer unsubscribe()
+
+	req := st.BuildRequest("POST", "/bulk", []byte("not JSON"), nil)
+	inspector.inspectInbound(basictypes.ServerSDK, req, []byte("not JSON"))
+
+	record := helpers.RequireValue(t, records, time.Second)
+	assert.Equal(t, `"not JSON"`, string(record.Payload))
+	assert.Equal(t, 0, record.Count)
+}
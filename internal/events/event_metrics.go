@@ -0,0 +1,219 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// The event pipeline metrics are defined here rather than in the metrics package, because that package
+// depends on this one. The metrics package registers the views from GetMetricsViews along with its own,
+// so they are exported in the same way.
+//
+// Metrics are recorded with the environment's OpenCensus context, which has the "env" tag. A component
+// whose metrics context is nil does not record anything.
+//
+// As in the metrics package, the measures are kept in global variables so that the same instances are
+// always used; the gochecknoglobals linter is excluded for this file in .golangci.yml.
+
+const (
+	eventsReceivedMeasureName         = "events_received"
+	eventsDroppedMeasureName          = "events_dropped"
+	eventParseFailuresMeasureName     = "event_parse_failures"
+	eventDeliveryAttemptsMeasureName  = "event_delivery_attempts"
+	eventDeliveryFailuresMeasureName  = "event_delivery_failures"
+	eventFlushDurationMeasureName     = "event_flush_duration_ms"
+	eventSummarizingQueuesMeasureName = "event_summarizing_queues"
+
+	eventsDroppedReasonOverflow = "overflow"
+
+	deliveryStatusNetworkError = "network_error"
+	unknownEventKindTagValue   = "unknown"
+)
+
+var (
+	envNameTagKey, _          = tag.NewKey("env") // the same key that the metrics package uses
+	platformCategoryTagKey, _ = tag.NewKey("platformCategory")
+	eventKindTagKey, _        = tag.NewKey("eventKind")
+	statusTagKey, _           = tag.NewKey("status")
+	reasonTagKey, _           = tag.NewKey("reason")
+
+	eventsReceivedMeasure = stats.Int64(eventsReceivedMeasureName,
+		"number of analytics events received from SDKs", stats.UnitDimensionless)
+	eventsDroppedMeasure = stats.Int64(eventsDroppedMeasureName,
+		"number of analytics events discarded before delivery", stats.UnitDimensionless)
+	eventParseFailuresMeasure = stats.Int64(eventParseFailuresMeasureName,
+		"number of analytics events that could not be parsed for summarizing", stats.UnitDimensionless)
+	eventDeliveryAttemptsMeasure = stats.Int64(eventDeliveryAttemptsMeasureName,
+		"number of HTTP requests made to deliver analytics events", stats.UnitDimensionless)
+	eventDeliveryFailuresMeasure = stats.Int64(eventDeliveryFailuresMeasureName,
+		"number of HTTP requests to deliver analytics events that did not succeed", stats.UnitDimensionless)
+	eventFlushDurationMeasure = stats.Float64(eventFlushDurationMeasureName,
+		"time taken to deliver a payload of analytics events, including retries", stats.UnitMilliseconds)
+	eventSummarizingQueuesMeasure = stats.Int64(eventSummarizingQueuesMeasureName,
+		"current number of event processors that are summarizing events from older SDKs", stats.UnitDimensionless)
+
+	eventMetricsViews = []*view.View{
+		{
+			Measure:     eventsReceivedMeasure,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey, eventKindTagKey},
+		},
+		{
+			Measure:     eventsDroppedMeasure,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey, reasonTagKey},
+		},
+		{
+			Measure:     eventParseFailuresMeasure,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey},
+		},
+		{
+			Measure:     eventDeliveryAttemptsMeasure,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey, statusTagKey},
+		},
+		{
+			Measure:     eventDeliveryFailuresMeasure,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey, statusTagKey},
+		},
+		{
+			Measure:     eventFlushDurationMeasure,
+			Aggregation: view.Distribution(10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000),
+			TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey},
+		},
+		{
+			Measure:     eventSummarizingQueuesMeasure,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey},
+		},
+	}
+
+	// These are the event kinds that are used as values of the eventKind tag; anything else is reported
+	// as "unknown", so that a misbehaving client can't create an unbounded number of tag values.
+	knownEventKinds = map[string]bool{
+		"feature": true, "custom": true, "identify": true, "index": true, "summary": true,
+		"debug": true, "alias": true, "migration_op": true,
+	}
+)
+
+// GetMetricsViews returns the OpenCensus views for the event pipeline metrics.
+func GetMetricsViews() []*view.View {
+	return eventMetricsViews
+}
+
+// makeEndpointMetricsContext adds the platformCategory tag for an SDK kind to the environment's metrics
+// context. It returns nil if envMetricsCtx is nil.
+func makeEndpointMetricsContext(envMetricsCtx context.Context, sdkKind basictypes.SDKKind) context.Context {
+	if envMetricsCtx == nil {
+		return nil
+	}
+	category := string(sdkKind)
+	if sdkKind == basictypes.JSClientSDK {
+		category = "browser" // for consistency with the other metrics
+	}
+	ctx, _ := tag.New(envMetricsCtx, tag.Upsert(platformCategoryTagKey, category))
+	return ctx
+}
+
+func recordEventMetric(metricsCtx context.Context, m stats.Measurement, tags ...tag.Mutator) {
+	if metricsCtx == nil {
+		return
+	}
+	if len(tags) > 0 {
+		metricsCtx, _ = tag.New(metricsCtx, tags...) // the tag values are known to be valid
+	}
+	stats.Record(metricsCtx, m)
+}
+
+// recordEventsReceived counts the events in a payload from an SDK by event kind.
+func recordEventsReceived(metricsCtx context.Context, evts []json.RawMessage) {
+	if metricsCtx == nil {
+		return
+	}
+	counts := make(map[string]int64)
+	for _, e := range evts {
+		var fields struct {
+			Kind string `json:"kind"`
+		}
+		_ = json.Unmarshal(e, &fields)
+		kind := fields.Kind
+		if !knownEventKinds[kind] {
+			kind = unknownEventKindTagValue
+		}
+		counts[kind]++
+	}
+	for kind, n := range counts {
+		recordEventMetric(metricsCtx, eventsReceivedMeasure.M(n), tag.Upsert(eventKindTagKey, kind))
+	}
+}
+
+func recordEventsDropped(metricsCtx context.Context, reason string, count int) {
+	recordEventMetric(metricsCtx, eventsDroppedMeasure.M(int64(count)), tag.Upsert(reasonTagKey, reason))
+}
+
+func recordFlushDuration(metricsCtx context.Context, startTime time.Time) {
+	recordEventMetric(metricsCtx, eventFlushDurationMeasure.M(float64(time.Since(startTime))/float64(time.Millisecond)))
+}
+
+// withDeliveryMetrics returns a copy of an HTTP client that records each request that it makes, or the
+// same client if metricsCtx is nil. Since retries are done by making another request, each attempt is
+// counted separately.
+func withDeliveryMetrics(metricsCtx context.Context, client *http.Client) *http.Client {
+	if metricsCtx == nil {
+		return client
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	ret := *client
+	ret.Transport = &deliveryMetricsTransport{wrapped: transport, metricsCtx: metricsCtx}
+	return &ret
+}
+
+type deliveryMetricsTransport struct {
+	wrapped    http.RoundTripper
+	metricsCtx context.Context
+}
+
+func (t *deliveryMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.wrapped.RoundTrip(req)
+	status := deliveryStatusNetworkError
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	statusTag := tag.Upsert(statusTagKey, status)
+	recordEventMetric(t.metricsCtx, eventDeliveryAttemptsMeasure.M(1), statusTag)
+	if err != nil || resp.StatusCode >= 300 {
+		recordEventMetric(t.metricsCtx, eventDeliveryFailuresMeasure.M(1), statusTag)
+	}
+	return resp, err
+}
+
+// flushMetricsEventSender is an ldevents.EventSender that records how long each delivery takes.
+type flushMetricsEventSender struct {
+	wrapped    ldevents.EventSender
+	metricsCtx context.Context
+}
+
+func (f *flushMetricsEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	startTime := time.Now()
+	result := f.wrapped.SendEventData(kind, data, count)
+	if kind == ldevents.AnalyticsEventDataKind {
+		recordFlushDuration(f.metricsCtx, startTime)
+	}
+	return result
+}
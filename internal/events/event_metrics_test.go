@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// makeTestMetricsContext registers the event metrics views, and returns a metrics context whose env tag
+// is the test name, so that each test only sees its own data.
+func makeTestMetricsContext(t *testing.T) context.Context {
+	require.NoError(t, view.Register(GetMetricsViews()...))
+	ctx, err := tag.New(context.Background(), tag.Insert(envNameTagKey, t.Name()))
+	require.NoError(t, err)
+	return ctx
+}
+
+// getMetricValue returns the sum of a measure for the rows that have the test's env tag and all of the
+// specified tag values.
+func getMetricValue(t *testing.T, measureName string, tags map[tag.Key]string) float64 {
+	rows, err := view.RetrieveData(measureName)
+	require.NoError(t, err)
+	var total float64
+	for _, row := range rows {
+		matched := 0
+		for _, rowTag := range row.Tags {
+			if rowTag.Key == envNameTagKey && rowTag.Value == t.Name() {
+				matched++
+			} else if value, ok := tags[rowTag.Key]; ok && value == rowTag.Value {
+				matched++
+			}
+		}
+		if matched != len(tags)+1 {
+			continue
+		}
+		switch data := row.Data.(type) {
+		case *view.SumData:
+			total += data.Value
+		case *view.DistributionData:
+			total += float64(data.Count)
+		}
+	}
+	return total
+}
+
+func TestRecordEventsReceivedCountsByEventKind(t *testing.T) {
+	ctx := makeTestMetricsContext(t)
+	evts := []json.RawMessage{
+		json.RawMessage(`{"kind":"custom"}`),
+		json.RawMessage(`{"kind":"custom"}`),
+		json.RawMessage(`{"kind":"identify"}`),
+		json.RawMessage(`{"kind":"made-up"}`),
+		json.RawMessage(`"not an object"`),
+	}
+	recordEventsReceived(ctx, evts)
+
+	assert.Equal(t, float64(2), getMetricValue(t, eventsReceivedMeasureName, map[tag.Key]string{eventKindTagKey: "custom"}))
+	assert.Equal(t, float64(1), getMetricValue(t, eventsReceivedMeasureName, map[tag.Key]string{eventKindTagKey: "identify"}))
+	assert.Equal(t, float64(2), getMetricValue(t, eventsReceivedMeasureName,
+		map[tag.Key]string{eventKindTagKey: unknownEventKindTagValue}))
+}
+
+func TestNilMetricsContextDisablesMetrics(t *testing.T) {
+	client := &http.Client{}
+	assert.Same(t, client, withDeliveryMetrics(nil, client))
+	assert.Nil(t, makeEndpointMetricsContext(nil, basictypes.ServerSDK))
+	recordEventsReceived(nil, []json.RawMessage{json.RawMessage(`{"kind":"custom"}`)}) // just verifying it doesn't panic
+}
+
+func TestDeliveryMetricsCountAttemptsAndFailuresByStatus(t *testing.T) {
+	ctx := makeTestMetricsContext(t)
+	handler := httphelpers.SequentialHandler(httphelpers.HandlerWithStatus(503), httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		client := withDeliveryMetrics(ctx, http.DefaultClient)
+		for i := 0; i < 2; i++ {
+			resp, err := client.Post(server.URL, "application/json", nil)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+		}
+	})
+
+	status := func(s string) map[tag.Key]string { return map[tag.Key]string{statusTagKey: s} }
+	assert.Equal(t, float64(1), getMetricValue(t, eventDeliveryAttemptsMeasureName, status("503")))
+	assert.Equal(t, float64(1), getMetricValue(t, eventDeliveryAttemptsMeasureName, status("202")))
+	assert.Equal(t, float64(1), getMetricValue(t, eventDeliveryFailuresMeasureName, status("503")))
+	assert.Equal(t, float64(0), getMetricValue(t, eventDeliveryFailuresMeasureName, status("202")))
+}
+
+func TestEventDispatcherRecordsMetrics(t *testing.T) {
+	opts := eventRelayTestOptions{metricsCtx: makeTestMetricsContext(t)}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		body := `[{"kind":"custom","key":"a"},{"kind":"index"}]`
+		req := st.BuildRequest("POST", "/", []byte(body), headersWithEventSchema(CurrentEventsSchemaVersion))
+		p.dispatcher.GetHandler(basictypes.JSClientSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+		p.dispatcher.flush()
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+
+		summarizeEventsParams := makeBasicSummarizeEventsParams()
+		req = st.BuildRequest("POST", "/", []byte(summarizeEventsParams.inputEventsJSON),
+			headersWithEventSchema(summarizeEventsParams.schemaVersion))
+		p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+		p.dispatcher.flush()
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+	})
+	// the dispatcher has been closed, so all deliveries have finished
+
+	browser := map[tag.Key]string{platformCategoryTagKey: "browser"}
+	server := map[tag.Key]string{platformCategoryTagKey: "server"}
+	assert.Equal(t, float64(1), getMetricValue(t, eventsReceivedMeasureName,
+		map[tag.Key]string{platformCategoryTagKey: "browser", eventKindTagKey: "custom"}))
+	assert.Equal(t, float64(1), getMetricValue(t, eventsReceivedMeasureName,
+		map[tag.Key]string{platformCategoryTagKey: "browser", eventKindTagKey: "index"}))
+	assert.Equal(t, float64(1), getMetricValue(t, eventsReceivedMeasureName,
+		map[tag.Key]string{platformCategoryTagKey: "server", eventKindTagKey: "feature"}))
+	assert.Equal(t, float64(1), getMetricValue(t, eventDeliveryAttemptsMeasureName,
+		map[tag.Key]string{platformCategoryTagKey: "browser", statusTagKey: "202"}))
+	assert.Equal(t, float64(1), getMetricValue(t, eventDeliveryAttemptsMeasureName,
+		map[tag.Key]string{platformCategoryTagKey: "server", statusTagKey: "202"}))
+	assert.Equal(t, float64(1), getMetricValue(t, eventFlushDurationMeasureName, browser))
+	assert.Equal(t, float64(1), getMetricValue(t, eventFlushDurationMeasureName, server))
+	assert.Equal(t, float64(0), getMetricValue(t, eventSummarizingQueuesMeasureName, server))
+	assert.Equal(t, float64(0), getMetricValue(t, eventParseFailuresMeasureName, server))
+}
+
+func TestEventPublisherRecordsOverflowDrops(t *testing.T) {
+	ctx := makeTestMetricsContext(t)
+	publisher, err := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), ldlog.NewDisabledLoggers(),
+		OptionCapacity(2), OptionMetricsContext{Context: ctx})
+	require.NoError(t, err)
+	publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"a"`), json.RawMessage(`"b"`), json.RawMessage(`"c"`))
+	require.Eventually(t, func() bool { return publisher.QueuedEventCount() == 2 }, time.Second, time.Millisecond*10)
+
+	assert.Equal(t, float64(1), getMetricValue(t, eventsDroppedMeasureName,
+		map[tag.Key]string{reasonTagKey: eventsDroppedReasonOverflow}))
+	publisher.disableQueue <- struct{}{} // don't try to deliver the events when closing
+	publisher.Close()
+}
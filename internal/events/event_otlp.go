@@ -0,0 +1,364 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+)
+
+const (
+	otlpExporterQueueSize    = 100
+	otlpExporterCloseTimeout = 5 * time.Second
+
+	// otlpTraceParentHeader is the W3C Trace Context header that identifies the trace and span of the
+	// request that delivered the events, if the SDK's application propagates trace context.
+	otlpTraceParentHeader = "traceparent"
+
+	otlpFeatureEventName  = "feature_flag.evaluation"
+	otlpCustomEventName   = "launchdarkly.custom"
+	otlpIdentifyEventName = "launchdarkly.identify"
+)
+
+// OTLPEventExporter sends flag evaluation, custom, and identify events to an OpenTelemetry collector,
+// as configured by EventsConfig.OTLPEndpoint, so that they can be correlated with the traces of the
+// requests that produced them. This is in addition to delivering the events to LaunchDarkly.
+//
+// Depending on EventsConfig.OTLPSignal, each event becomes either a log record, or an event on a span
+// that represents the request that delivered it to Relay. If that request had a W3C traceparent header,
+// the log records or span belong to that trace.
+//
+// A single OTLPEventExporter is shared by all environments. Like EventDestination, it has its own queue
+// and goroutine, so a collector that is slow or unreachable never delays event delivery; if its queue
+// is full, further events are not exported until it catches up.
+type OTLPEventExporter struct {
+	signal     c.EventsOTLPSignal
+	client     otlpClient
+	queue      chan otlpEventBatch
+	done       chan struct{}
+	overflowed atomic.Bool // used to avoid logging the same warning repeatedly
+	closed     bool
+	loggers    ldlog.Loggers
+	lock       sync.RWMutex
+}
+
+// otlpEventBatch is the events from one request that are to be exported together.
+type otlpEventBatch struct {
+	envName  string
+	sdkKind  basictypes.SDKKind
+	trace    otlpTraceContext
+	received time.Time
+	records  []otlpEventRecord
+}
+
+// otlpEventRecord is the information that is exported for one event.
+type otlpEventRecord struct {
+	name         string
+	creationDate ldtime.UnixMillisecondTime
+	contextKey   string // fully-qualified key, or "" if the event had no context
+	flagKey      string
+	flagVersion  ldvalue.OptionalInt
+	variation    ldvalue.OptionalInt
+	value        ldvalue.Value
+	defaultValue ldvalue.Value
+	reason       ldreason.EvaluationReason
+	eventKey     string
+	data         ldvalue.Value
+	metricValue  *float64
+}
+
+// otlpTraceContext is the trace that exported events belong to.
+type otlpTraceContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	flags   byte
+	valid   bool
+}
+
+// NewOTLPEventExporter creates an OTLPEventExporter, or returns nil if config.OTLPEndpoint is not set.
+func NewOTLPEventExporter(
+	config c.EventsConfig,
+	httpConfig httpconfig.HTTPConfig,
+	loggers ldlog.Loggers,
+) (*OTLPEventExporter, error) {
+	if !config.OTLPEndpoint.IsDefined() {
+		return nil, nil
+	}
+	var client otlpClient
+	if config.OTLPProtocol == c.EventsOTLPGRPC {
+		grpcClient, err := newOTLPGRPCClient(*config.OTLPEndpoint.Get(), config.OTLPHeader.Values(), httpConfig)
+		if err != nil {
+			return nil, err
+		}
+		client = grpcClient
+	} else {
+		client = newOTLPHTTPClient(*config.OTLPEndpoint.Get(), config.OTLPHeader.Values(), httpConfig)
+	}
+	signal := config.OTLPSignal
+	if signal == "" {
+		signal = c.EventsOTLPLogs
+	}
+	e := &OTLPEventExporter{
+		signal:  signal,
+		client:  client,
+		queue:   make(chan otlpEventBatch, otlpExporterQueueSize),
+		done:    make(chan struct{}),
+		loggers: loggers,
+	}
+	go e.run()
+	return e, nil
+}
+
+// enqueue adds a batch of events to the exporter's queue without blocking.
+func (e *OTLPEventExporter) enqueue(batch otlpEventBatch) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.queue <- batch:
+		e.overflowed.Store(false)
+	default:
+		if e.overflowed.CompareAndSwap(false, true) {
+			e.loggers.Warn("The OpenTelemetry collector is not keeping up with the events it receives; some events will not be exported")
+		}
+	}
+}
+
+func (e *OTLPEventExporter) run() {
+	for batch := range e.queue {
+		var err error
+		if e.signal == c.EventsOTLPTraces {
+			err = e.client.exportTraces(makeOTLPTraceRequest(batch))
+		} else {
+			err = e.client.exportLogs(makeOTLPLogsRequest(batch))
+		}
+		if err != nil {
+			e.loggers.Warnf("Unable to export %d events to the OpenTelemetry collector: %s", len(batch.records), err)
+		}
+	}
+	close(e.done)
+}
+
+// Close stops accepting events, waits a limited time for queued events to be exported, and then
+// releases the connection to the collector.
+func (e *OTLPEventExporter) Close() {
+	e.lock.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.lock.Unlock()
+	select {
+	case <-e.done:
+	case <-time.After(otlpExporterCloseTimeout):
+		e.loggers.Warn("Timed out waiting for events to be exported to the OpenTelemetry collector")
+	}
+	e.client.close()
+}
+
+// EnvironmentOTLPExporter is an OTLPEventExporter together with the name of the environment whose
+// events it receives. A nil *EnvironmentOTLPExporter is valid and does nothing.
+type EnvironmentOTLPExporter struct {
+	exporter *OTLPEventExporter
+	envName  string
+}
+
+// NewEnvironmentOTLPExporter creates an EnvironmentOTLPExporter, or returns nil if exporter is nil.
+func NewEnvironmentOTLPExporter(exporter *OTLPEventExporter, envName string) *EnvironmentOTLPExporter {
+	if exporter == nil {
+		return nil
+	}
+	return &EnvironmentOTLPExporter{exporter: exporter, envName: envName}
+}
+
+// exportEvents exports the feature, custom, and identify events in a list of events in the current
+// schema, which an SDK sent in a request with the specified traceparent header.
+func (x *EnvironmentOTLPExporter) exportEvents(sdkKind basictypes.SDKKind, traceParent string, evts []json.RawMessage) {
+	if x == nil {
+		return
+	}
+	records := make([]otlpEventRecord, 0, len(evts))
+	for _, e := range evts {
+		if r, ok := otlpRecordFromEvent(e); ok {
+			records = append(records, r)
+		}
+	}
+	x.exportRecords(sdkKind, traceParent, records)
+}
+
+// exportRecords exports events that have already been converted.
+func (x *EnvironmentOTLPExporter) exportRecords(sdkKind basictypes.SDKKind, traceParent string, records []otlpEventRecord) {
+	if x == nil || len(records) == 0 {
+		return
+	}
+	x.exporter.enqueue(otlpEventBatch{
+		envName:  x.envName,
+		sdkKind:  sdkKind,
+		trace:    parseOTLPTraceParent(traceParent),
+		received: time.Now(),
+		records:  records,
+	})
+}
+
+// otlpRecordFromEvent converts an event in either the current schema or an older one, returning false
+// if it is not a feature, custom, or identify event.
+func otlpRecordFromEvent(rawEvent []byte) (otlpEventRecord, bool) {
+	var fields struct {
+		Kind         string                     `json:"kind"`
+		CreationDate ldtime.UnixMillisecondTime `json:"creationDate"`
+		Key          string                     `json:"key"`
+		Version      ldvalue.OptionalInt        `json:"version"`
+		Variation    ldvalue.OptionalInt        `json:"variation"`
+		Value        ldvalue.Value              `json:"value"`
+		Default      ldvalue.Value              `json:"default"`
+		Reason       ldreason.EvaluationReason  `json:"reason"`
+		Data         ldvalue.Value              `json:"data"`
+		MetricValue  *float64                   `json:"metricValue"`
+		Context      json.RawMessage            `json:"context"`
+		User         json.RawMessage            `json:"user"` // older schemas
+		ContextKeys  map[string]string          `json:"contextKeys"`
+	}
+	if err := json.Unmarshal(rawEvent, &fields); err != nil {
+		return otlpEventRecord{}, false
+	}
+	r := otlpEventRecord{
+		creationDate: fields.CreationDate,
+		contextKey:   otlpContextKey(fields.Context, fields.User, fields.ContextKeys),
+	}
+	switch fields.Kind {
+	case "feature":
+		r.name = otlpFeatureEventName
+		r.flagKey = fields.Key
+		r.flagVersion = fields.Version
+		r.variation = fields.Variation
+		r.value = fields.Value
+		r.defaultValue = fields.Default
+		r.reason = fields.Reason
+	case "custom":
+		r.name = otlpCustomEventName
+		r.eventKey = fields.Key
+		r.data = fields.Data
+		r.metricValue = fields.MetricValue
+	case "identify":
+		r.name = otlpIdentifyEventName
+	default:
+		return otlpEventRecord{}, false
+	}
+	return r, true
+}
+
+// otlpRecordFromEvaluation converts a feature event from an older SDK, after it has been translated by
+// oldevents.TranslateFeatureEvent; the translated properties take precedence over the original ones,
+// since the variation may have been filled in from the flag data.
+func otlpRecordFromEvaluation(evalData ldevents.EvaluationData, rawEvent []byte) otlpEventRecord {
+	r, _ := otlpRecordFromEvent(rawEvent)
+	r.name = otlpFeatureEventName
+	r.creationDate = evalData.CreationDate
+	r.flagKey = evalData.Key
+	r.flagVersion = evalData.Version
+	r.variation = evalData.Variation
+	r.value = evalData.Value
+	r.defaultValue = evalData.Default
+	r.reason = evalData.Reason
+	return r
+}
+
+// otlpRecordFromCustomEvent converts a custom event from an older SDK, after it has been translated by
+// oldevents.TranslateCustomEvent.
+func otlpRecordFromCustomEvent(customData ldevents.CustomEventData, rawEvent []byte) otlpEventRecord {
+	r, _ := otlpRecordFromEvent(rawEvent)
+	r.name = otlpCustomEventName
+	r.creationDate = customData.CreationDate
+	r.eventKey = customData.Key
+	r.data = customData.Data
+	r.metricValue = nil
+	if customData.HasMetric {
+		metricValue := customData.MetricValue
+		r.metricValue = &metricValue
+	}
+	return r
+}
+
+// otlpRecordFromIdentifyEvent converts an identify event from an older SDK, after it has been
+// translated by oldevents.TranslateIdentifyEvent.
+func otlpRecordFromIdentifyEvent(identifyData ldevents.IdentifyEventData, rawEvent []byte) otlpEventRecord {
+	r, _ := otlpRecordFromEvent(rawEvent)
+	r.name = otlpIdentifyEventName
+	r.creationDate = identifyData.CreationDate
+	return r
+}
+
+// otlpContextKey returns the fully-qualified key of an event's context, which may be a full context, an
+// old-style user, or a map of context kinds to keys.
+func otlpContextKey(contextJSON, userJSON json.RawMessage, contextKeys map[string]string) string {
+	for _, data := range []json.RawMessage{contextJSON, userJSON} {
+		if len(data) == 0 {
+			continue
+		}
+		var context ldcontext.Context
+		if json.Unmarshal(data, &context) == nil && context.Err() == nil {
+			return context.FullyQualifiedKey()
+		}
+	}
+	if len(contextKeys) == 0 {
+		return ""
+	}
+	builder := ldcontext.NewMultiBuilder()
+	for kind, key := range contextKeys {
+		builder.Add(ldcontext.NewWithKind(ldcontext.Kind(kind), key))
+	}
+	if context := builder.Build(); context.Err() == nil {
+		return context.FullyQualifiedKey()
+	}
+	return ""
+}
+
+// parseOTLPTraceParent parses a W3C traceparent header, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". It returns an invalid otlpTraceContext if
+// the header is missing or malformed.
+func parseOTLPTraceParent(value string) otlpTraceContext {
+	var ret otlpTraceContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 2*len(ret.traceID) || len(parts[2]) != 2*len(ret.spanID) || len(parts[3]) != 2 {
+		return otlpTraceContext{}
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(ret.traceID[:], []byte(parts[1])); err != nil {
+		return otlpTraceContext{}
+	}
+	if _, err := hex.Decode(ret.spanID[:], []byte(parts[2])); err != nil {
+		return otlpTraceContext{}
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return otlpTraceContext{}
+	}
+	if ret.traceID == [16]byte{} || ret.spanID == [8]byte{} {
+		return otlpTraceContext{} // all-zero IDs are invalid
+	}
+	ret.flags = flags[0]
+	ret.valid = true
+	return ret
+}
+
+func newOTLPRandomID(length int) []byte {
+	ret := make([]byte, length)
+	_, _ = rand.Read(ret)
+	return ret
+}
@@ -0,0 +1,369 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+	"github.com/launchdarkly/ld-relay/v8/relay/version"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// This file builds OTLP export requests with the generated OpenTelemetry protobuf types, and sends them
+// with OTLP/HTTP or OTLP/gRPC.
+
+const (
+	otlpExportTimeout     = 10 * time.Second
+	otlpScopeName         = "github.com/launchdarkly/ld-relay"
+	otlpServiceName       = "ld-relay"
+	otlpSpanName          = "ld-relay.events"
+	otlpFeatureFlagSource = "LaunchDarkly"
+
+	otlpHTTPLogsPath   = "/v1/logs"
+	otlpHTTPTracesPath = "/v1/traces"
+
+	otlpGRPCDefaultPort    = "4317"
+	otlpGRPCDefaultTLSPort = "443"
+)
+
+type otlpAttribute struct {
+	key   string
+	value ldvalue.Value
+}
+
+func makeOTLPLogsRequest(batch otlpEventBatch) *collogspb.ExportLogsServiceRequest {
+	records := make([]*logspb.LogRecord, 0, len(batch.records))
+	for _, r := range batch.records {
+		records = append(records, makeOTLPLogRecord(batch, r))
+	}
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource:  makeOTLPResource(),
+			ScopeLogs: []*logspb.ScopeLogs{{Scope: makeOTLPScope(), LogRecords: records}},
+		}},
+	}
+}
+
+func makeOTLPLogRecord(batch otlpEventBatch, r otlpEventRecord) *logspb.LogRecord {
+	attrs := append(otlpBatchAttributes(batch), otlpAttribute{"event.name", ldvalue.String(r.name)})
+	record := &logspb.LogRecord{
+		TimeUnixNano:         otlpEventTime(batch, r),
+		ObservedTimeUnixNano: uint64(batch.received.UnixNano()),
+		SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:         "INFO",
+		Body:                 makeOTLPAnyValue(ldvalue.String(r.name)),
+		Attributes:           makeOTLPKeyValues(append(attrs, otlpEventAttributes(r)...)),
+	}
+	if batch.trace.valid {
+		record.Flags = uint32(batch.trace.flags)
+		record.TraceId = batch.trace.traceID[:]
+		record.SpanId = batch.trace.spanID[:]
+	}
+	return record
+}
+
+func makeOTLPTraceRequest(batch otlpEventBatch) *coltracepb.ExportTraceServiceRequest {
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource:   makeOTLPResource(),
+			ScopeSpans: []*tracepb.ScopeSpans{{Scope: makeOTLPScope(), Spans: []*tracepb.Span{makeOTLPSpan(batch)}}},
+		}},
+	}
+}
+
+// makeOTLPSpan returns a span that represents the request that delivered a batch of events to Relay.
+// It is a child of the span in the request's traceparent header, if there was one.
+func makeOTLPSpan(batch otlpEventBatch) *tracepb.Span {
+	span := &tracepb.Span{
+		TraceId:           newOTLPRandomID(16),
+		SpanId:            newOTLPRandomID(8),
+		Name:              otlpSpanName,
+		Kind:              tracepb.Span_SPAN_KIND_INTERNAL,
+		StartTimeUnixNano: uint64(batch.received.UnixNano()),
+		EndTimeUnixNano:   uint64(batch.received.UnixNano()),
+		Attributes:        makeOTLPKeyValues(otlpBatchAttributes(batch)),
+	}
+	if batch.trace.valid {
+		span.TraceId = batch.trace.traceID[:]
+		span.ParentSpanId = batch.trace.spanID[:]
+	}
+	for _, r := range batch.records {
+		span.Events = append(span.Events, &tracepb.Span_Event{
+			TimeUnixNano: otlpEventTime(batch, r),
+			Name:         r.name,
+			Attributes:   makeOTLPKeyValues(otlpEventAttributes(r)),
+		})
+	}
+	return span
+}
+
+func makeOTLPResource() *resourcepb.Resource {
+	return &resourcepb.Resource{
+		Attributes: makeOTLPKeyValues([]otlpAttribute{
+			{"service.name", ldvalue.String(otlpServiceName)},
+			{"service.version", ldvalue.String(version.Version)},
+		}),
+	}
+}
+
+func makeOTLPScope() *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{Name: otlpScopeName, Version: version.Version}
+}
+
+func otlpBatchAttributes(batch otlpEventBatch) []otlpAttribute {
+	return []otlpAttribute{
+		{"launchdarkly.environment", ldvalue.String(batch.envName)},
+		{"launchdarkly.sdk_kind", ldvalue.String(string(batch.sdkKind))},
+	}
+}
+
+// otlpEventAttributes returns the attributes of one event. The attributes of flag evaluations follow
+// the OpenTelemetry semantic conventions for feature flags where possible.
+func otlpEventAttributes(r otlpEventRecord) []otlpAttribute {
+	var ret []otlpAttribute
+	if r.contextKey != "" {
+		ret = append(ret, otlpAttribute{"launchdarkly.context_key", ldvalue.String(r.contextKey)})
+	}
+	switch r.name {
+	case otlpFeatureEventName:
+		ret = append(ret,
+			otlpAttribute{"feature_flag.key", ldvalue.String(r.flagKey)},
+			otlpAttribute{"feature_flag.provider_name", ldvalue.String(otlpFeatureFlagSource)},
+		)
+		if r.variation.IsDefined() {
+			ret = append(ret, otlpAttribute{"feature_flag.variant", ldvalue.String(strconv.Itoa(r.variation.IntValue()))})
+		}
+		if r.flagVersion.IsDefined() {
+			ret = append(ret, otlpAttribute{"feature_flag.version", ldvalue.Int(r.flagVersion.IntValue())})
+		}
+		ret = append(ret,
+			otlpAttribute{"launchdarkly.value", r.value},
+			otlpAttribute{"launchdarkly.default", r.defaultValue},
+		)
+		if r.reason.GetKind() != "" {
+			ret = append(ret, otlpAttribute{"launchdarkly.reason", ldvalue.String(string(r.reason.GetKind()))})
+		}
+	case otlpCustomEventName:
+		ret = append(ret,
+			otlpAttribute{"launchdarkly.event_key", ldvalue.String(r.eventKey)},
+			otlpAttribute{"launchdarkly.data", r.data},
+		)
+		if r.metricValue != nil {
+			ret = append(ret, otlpAttribute{"launchdarkly.metric_value", ldvalue.Float64(*r.metricValue)})
+		}
+	}
+	return ret
+}
+
+// otlpEventTime returns the creation time of an event in Unix nanoseconds, or the time that it was
+// received if the SDK did not provide a creation time.
+func otlpEventTime(batch otlpEventBatch, r otlpEventRecord) uint64 {
+	if r.creationDate == 0 {
+		return uint64(batch.received.UnixNano())
+	}
+	return uint64(r.creationDate) * uint64(time.Millisecond)
+}
+
+// makeOTLPKeyValues converts attributes to OTLP key-value pairs, omitting any whose value is null.
+func makeOTLPKeyValues(attrs []otlpAttribute) []*commonpb.KeyValue {
+	ret := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		if !a.value.IsNull() {
+			ret = append(ret, &commonpb.KeyValue{Key: a.key, Value: makeOTLPAnyValue(a.value)})
+		}
+	}
+	return ret
+}
+
+// makeOTLPAnyValue converts a value to an AnyValue. Arrays and objects are encoded as JSON strings,
+// which is easier to query in most backends than the equivalent OTLP structures.
+func makeOTLPAnyValue(v ldvalue.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case ldvalue.BoolType:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.BoolValue()}}
+	case ldvalue.NumberType:
+		if v.IsInt() {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v.IntValue())}}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.Float64Value()}}
+	case ldvalue.StringType:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.StringValue()}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.JSONString()}}
+	}
+}
+
+// otlpClient sends export requests to a collector.
+type otlpClient interface {
+	exportLogs(request *collogspb.ExportLogsServiceRequest) error
+	exportTraces(request *coltracepb.ExportTraceServiceRequest) error
+	close()
+}
+
+// otlpHTTPClient sends export requests with OTLP/HTTP, in binary protobuf encoding. A failed request
+// is retried once, as for other event deliveries.
+type otlpHTTPClient struct {
+	client      *http.Client
+	baseURI     string
+	baseHeaders http.Header
+}
+
+func newOTLPHTTPClient(endpoint url.URL, headers []string, httpConfig httpconfig.HTTPConfig) *otlpHTTPClient {
+	baseHeaders := make(http.Header)
+	for k, v := range httpConfig.SDKHTTPConfig.DefaultHeaders {
+		baseHeaders[k] = v
+	}
+	baseHeaders.Del("Authorization") // this is the SDK key; if the collector needs authorization, it is in headers
+	for _, h := range headers {
+		if name, value, err := c.ParseEventDestinationHeader(h); err == nil {
+			baseHeaders.Set(name, value)
+		}
+	}
+	baseHeaders.Set("Content-Type", "application/x-protobuf")
+	return &otlpHTTPClient{
+		client:      httpConfig.Client(),
+		baseURI:     strings.TrimSuffix(endpoint.String(), "/"),
+		baseHeaders: baseHeaders,
+	}
+}
+
+func (h *otlpHTTPClient) exportLogs(request *collogspb.ExportLogsServiceRequest) error {
+	return h.export(otlpHTTPLogsPath, request)
+}
+
+func (h *otlpHTTPClient) exportTraces(request *coltracepb.ExportTraceServiceRequest) error {
+	return h.export(otlpHTTPTracesPath, request)
+}
+
+func (h *otlpHTTPClient) export(path string, request proto.Message) error {
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		var retryable bool
+		if retryable, err = h.post(h.baseURI+path, body); err == nil || !retryable {
+			break
+		}
+	}
+	return err
+}
+
+func (h *otlpHTTPClient) post(uri string, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range h.baseHeaders {
+		req.Header[k] = v
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+	defer cancel()
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			retryable = true
+		}
+		return retryable, fmt.Errorf("HTTP status %d from %s", resp.StatusCode, uri)
+	}
+	return false, nil
+}
+
+func (h *otlpHTTPClient) close() {}
+
+// otlpGRPCClient sends export requests with OTLP/gRPC. The connection uses TLS if the endpoint URL's
+// scheme is "https".
+type otlpGRPCClient struct {
+	conn     *grpc.ClientConn
+	logs     collogspb.LogsServiceClient
+	traces   coltracepb.TraceServiceClient
+	metadata metadata.MD
+}
+
+// newOTLPGRPCClient creates a gRPC connection to the collector. If the endpoint URL has no port, the
+// standard OTLP/gRPC port is used, or 443 for https. The TLS connection trusts the same CA certificates as
+// the HTTP client from httpConfig; the proxy settings are not used, which config validation enforces.
+func newOTLPGRPCClient(endpoint url.URL, headers []string, httpConfig httpconfig.HTTPConfig) (*otlpGRPCClient, error) {
+	target := endpoint.Host
+	creds := insecure.NewCredentials()
+	if endpoint.Scheme == "https" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if transport, ok := httpConfig.Client().Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+			tlsConfig.RootCAs = transport.TLSClientConfig.RootCAs
+		}
+		creds = credentials.NewTLS(tlsConfig)
+		if endpoint.Port() == "" {
+			target = net.JoinHostPort(endpoint.Hostname(), otlpGRPCDefaultTLSPort)
+		}
+	} else if endpoint.Port() == "" {
+		target = net.JoinHostPort(endpoint.Hostname(), otlpGRPCDefaultPort)
+	}
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	md := metadata.MD{}
+	for _, h := range headers {
+		if name, value, err := c.ParseEventDestinationHeader(h); err == nil {
+			md.Append(name, value)
+		}
+	}
+	return &otlpGRPCClient{
+		conn:     conn,
+		logs:     collogspb.NewLogsServiceClient(conn),
+		traces:   coltracepb.NewTraceServiceClient(conn),
+		metadata: md,
+	}, nil
+}
+
+func (g *otlpGRPCClient) exportLogs(request *collogspb.ExportLogsServiceRequest) error {
+	ctx, cancel := g.exportContext()
+	defer cancel()
+	_, err := g.logs.Export(ctx, request)
+	return err
+}
+
+func (g *otlpGRPCClient) exportTraces(request *coltracepb.ExportTraceServiceRequest) error {
+	ctx, cancel := g.exportContext()
+	defer cancel()
+	_, err := g.traces.Export(ctx, request)
+	return err
+}
+
+func (g *otlpGRPCClient) exportContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(metadata.NewOutgoingContext(context.Background(), g.metadata), otlpExportTimeout)
+}
+
+func (g *otlpGRPCClient) close() {
+	_ = g.conn.Close()
+}
@@ -0,0 +1,354 @@
+package events
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	"github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceID     = "\x4b\xf9\x2f\x35\x77\xb3\x4d\xa6\xa3\xce\x92\x9d\x0e\x0e\x47\x36"
+	testSpanID      = "\x00\xf0\x67\xaa\x0b\xa9\x02\xb7"
+)
+
+// otlpTestAttributes converts OTLP attributes to a map of ldvalue.Value.
+func otlpTestAttributes(attrs []*commonpb.KeyValue) map[string]ldvalue.Value {
+	ret := make(map[string]ldvalue.Value)
+	for _, kv := range attrs {
+		switch v := kv.Value.Value.(type) {
+		case *commonpb.AnyValue_StringValue:
+			ret[kv.Key] = ldvalue.String(v.StringValue)
+		case *commonpb.AnyValue_BoolValue:
+			ret[kv.Key] = ldvalue.Bool(v.BoolValue)
+		case *commonpb.AnyValue_IntValue:
+			ret[kv.Key] = ldvalue.Int(int(v.IntValue))
+		case *commonpb.AnyValue_DoubleValue:
+			ret[kv.Key] = ldvalue.Float64(v.DoubleValue)
+		}
+	}
+	return ret
+}
+
+// otlpTestLogRecords decodes an ExportLogsServiceRequest and returns its log records.
+func otlpTestLogRecords(t *testing.T, body []byte) []*logspb.LogRecord {
+	var request collogspb.ExportLogsServiceRequest
+	require.NoError(t, proto.Unmarshal(body, &request))
+	require.Len(t, request.ResourceLogs, 1)
+	resourceLogs := request.ResourceLogs[0]
+	assert.Equal(t, "ld-relay", otlpTestAttributes(resourceLogs.Resource.Attributes)["service.name"].StringValue())
+	require.Len(t, resourceLogs.ScopeLogs, 1)
+	return resourceLogs.ScopeLogs[0].LogRecords
+}
+
+type otlpTestGRPCRequest struct {
+	request  proto.Message
+	metadata metadata.MD
+}
+
+// otlpTestGRPCRecorder records the requests that a test gRPC server receives.
+type otlpTestGRPCRecorder chan otlpTestGRPCRequest
+
+func (r otlpTestGRPCRecorder) record(ctx context.Context, request proto.Message) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r <- otlpTestGRPCRequest{request: request, metadata: md}
+}
+
+type otlpTestLogsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	otlpTestGRPCRecorder
+}
+
+func (s otlpTestLogsServer) Export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (
+	*collogspb.ExportLogsServiceResponse, error) {
+	s.record(ctx, request)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+type otlpTestTraceServer struct {
+	coltracepb.UnimplementedTraceServiceServer
+	otlpTestGRPCRecorder
+}
+
+func (s otlpTestTraceServer) Export(ctx context.Context, request *coltracepb.ExportTraceServiceRequest) (
+	*coltracepb.ExportTraceServiceResponse, error) {
+	s.record(ctx, request)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// startOTLPTestGRPCServer starts a gRPC server that implements the OTLP logs and trace services.
+func startOTLPTestGRPCServer(t *testing.T, address string, opts ...grpc.ServerOption) (
+	net.Addr, <-chan otlpTestGRPCRequest) {
+	requestsCh := make(otlpTestGRPCRecorder, 10)
+	server := grpc.NewServer(opts...)
+	collogspb.RegisterLogsServiceServer(server, otlpTestLogsServer{otlpTestGRPCRecorder: requestsCh})
+	coltracepb.RegisterTraceServiceServer(server, otlpTestTraceServer{otlpTestGRPCRecorder: requestsCh})
+	listener, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return listener.Addr(), requestsCh
+}
+
+func makeOTLPTestExporter(t *testing.T, eventsConfig config.EventsConfig) *OTLPEventExporter {
+	exporter, err := NewOTLPEventExporter(eventsConfig, defaultHTTPConfig(), ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.NotNil(t, exporter)
+	t.Cleanup(exporter.Close)
+	return exporter
+}
+
+func postEventsWithTraceParent(p eventRelayTestParams, schemaVersion int, body string) {
+	headers := headersWithEventSchema(schemaVersion)
+	headers.Set(otlpTraceParentHeader, testTraceParent)
+	req := st.BuildRequest("POST", "/", []byte(body), headers)
+	p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+}
+
+func TestOTLPExporterSendsLogRecordsOverHTTP(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(200))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		endpoint, _ := configtypes.NewOptURLAbsoluteFromString(server.URL)
+		exporter := makeOTLPTestExporter(t, config.EventsConfig{
+			OTLPEndpoint: endpoint,
+			OTLPHeader:   configtypes.NewOptStringList([]string{"X-Collector-Token: xyz"}),
+		})
+		opts := eventRelayTestOptions{otlp: NewEnvironmentOTLPExporter(exporter, "my environment")}
+		eventRelayTestWithOptions(t, st.EnvMain, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+			postEventsWithTraceParent(p, CurrentEventsSchemaVersion, `[
+				{"kind": "feature", "creationDate": 1000, "key": "flagkey", "version": 11, "variation": 1,
+					"value": "b", "default": "c", "context": {"kind": "user", "key": "userkey"}},
+				{"kind": "custom", "creationDate": 2000, "key": "clicked", "contextKeys": {"user": "userkey"},
+					"metricValue": 2.5},
+				{"kind": "summary", "startDate": 1000, "endDate": 2000, "features": {}}
+			]`)
+
+			r := helpers.RequireValue(t, requestsCh, time.Second)
+			assert.Equal(t, otlpHTTPLogsPath, r.Request.URL.Path)
+			assert.Equal(t, "application/x-protobuf", r.Request.Header.Get("Content-Type"))
+			assert.Equal(t, "xyz", r.Request.Header.Get("X-Collector-Token"))
+			assert.Equal(t, "", r.Request.Header.Get("Authorization"))
+
+			records := otlpTestLogRecords(t, r.Body)
+			require.Len(t, records, 2)
+
+			feature := records[0]
+			assert.Equal(t, uint64(1000*time.Millisecond), feature.TimeUnixNano)
+			assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_INFO, feature.SeverityNumber)
+			assert.Equal(t, "feature_flag.evaluation", feature.Body.GetStringValue())
+			assert.Equal(t, testTraceID, string(feature.TraceId))
+			assert.Equal(t, testSpanID, string(feature.SpanId))
+			assert.Equal(t, uint32(1), feature.Flags)
+			assert.Equal(t, map[string]ldvalue.Value{
+				"event.name":                 ldvalue.String("feature_flag.evaluation"),
+				"launchdarkly.environment":   ldvalue.String("my environment"),
+				"launchdarkly.sdk_kind":      ldvalue.String("server"),
+				"launchdarkly.context_key":   ldvalue.String("userkey"),
+				"feature_flag.key":           ldvalue.String("flagkey"),
+				"feature_flag.provider_name": ldvalue.String("LaunchDarkly"),
+				"feature_flag.variant":       ldvalue.String("1"),
+				"feature_flag.version":       ldvalue.Int(11),
+				"launchdarkly.value":         ldvalue.String("b"),
+				"launchdarkly.default":       ldvalue.String("c"),
+			}, otlpTestAttributes(feature.Attributes))
+
+			custom := records[1]
+			attrs := otlpTestAttributes(custom.Attributes)
+			assert.Equal(t, "launchdarkly.custom", attrs["event.name"].StringValue())
+			assert.Equal(t, "clicked", attrs["launchdarkly.event_key"].StringValue())
+			assert.Equal(t, 2.5, attrs["launchdarkly.metric_value"].Float64Value())
+			assert.Equal(t, "userkey", attrs["launchdarkly.context_key"].StringValue())
+		})
+	})
+}
+
+func TestOTLPExporterExportsEventsFromOlderSDKsAfterTranslation(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(200))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		endpoint, _ := configtypes.NewOptURLAbsoluteFromString(server.URL)
+		exporter := makeOTLPTestExporter(t, config.EventsConfig{OTLPEndpoint: endpoint})
+		opts := eventRelayTestOptions{otlp: NewEnvironmentOTLPExporter(exporter, "my environment")}
+		eventRelayTestWithOptions(t, st.EnvMain, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+			_, _ = st.UpsertFlag(p.dataStore, makeTestFlag(false, 0))
+
+			// In schema 1, the variation is not provided, so it is determined from the flag data.
+			postEventsWithTraceParent(p, 1, `[
+				{"kind": "feature", "creationDate": 1000, "key": "flagkey", "version": 11, "value": "b",
+					"default": "c", "user": {"key": "userkey"}},
+				{"kind": "identify", "creationDate": 2000, "user": {"key": "userkey"}}
+			]`)
+
+			r := helpers.RequireValue(t, requestsCh, time.Second)
+			records := otlpTestLogRecords(t, r.Body)
+			require.Len(t, records, 2)
+
+			attrs := otlpTestAttributes(records[0].Attributes)
+			assert.Equal(t, "flagkey", attrs["feature_flag.key"].StringValue())
+			assert.Equal(t, "1", attrs["feature_flag.variant"].StringValue())
+			assert.Equal(t, "userkey", attrs["launchdarkly.context_key"].StringValue())
+			assert.Equal(t, testTraceID, string(records[0].TraceId))
+
+			attrs = otlpTestAttributes(records[1].Attributes)
+			assert.Equal(t, "launchdarkly.identify", attrs["event.name"].StringValue())
+			assert.Equal(t, "userkey", attrs["launchdarkly.context_key"].StringValue())
+		})
+	})
+}
+
+func TestOTLPExporterSendsSpanEventsOverGRPC(t *testing.T) {
+	addr, requestsCh := startOTLPTestGRPCServer(t, "localhost:0")
+
+	endpoint, _ := configtypes.NewOptURLAbsoluteFromString("http://" + addr.String())
+	exporter := makeOTLPTestExporter(t, config.EventsConfig{
+		OTLPEndpoint: endpoint,
+		OTLPProtocol: config.EventsOTLPGRPC,
+		OTLPSignal:   config.EventsOTLPTraces,
+		OTLPHeader:   configtypes.NewOptStringList([]string{"X-Collector-Token: xyz"}),
+	})
+	opts := eventRelayTestOptions{otlp: NewEnvironmentOTLPExporter(exporter, "my environment")}
+	eventRelayTestWithOptions(t, st.EnvMain, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		postEventsWithTraceParent(p, CurrentEventsSchemaVersion, `[
+			{"kind": "feature", "creationDate": 1000, "key": "flagkey", "version": 11, "variation": 1,
+				"value": "b", "default": "c", "context": {"kind": "user", "key": "userkey"}},
+			{"kind": "identify", "creationDate": 2000, "context": {"kind": "user", "key": "userkey"}}
+		]`)
+
+		r := helpers.RequireValue(t, requestsCh, time.Second*5)
+		assert.Equal(t, []string{"xyz"}, r.metadata.Get("x-collector-token"))
+
+		require.IsType(t, &coltracepb.ExportTraceServiceRequest{}, r.request)
+		request := r.request.(*coltracepb.ExportTraceServiceRequest)
+		require.Len(t, request.ResourceSpans, 1)
+		require.Len(t, request.ResourceSpans[0].ScopeSpans, 1)
+		require.Len(t, request.ResourceSpans[0].ScopeSpans[0].Spans, 1)
+		span := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+		assert.Equal(t, testTraceID, string(span.TraceId))
+		assert.Len(t, span.SpanId, 8)
+		assert.NotEqual(t, testSpanID, string(span.SpanId))
+		assert.Equal(t, testSpanID, string(span.ParentSpanId))
+		assert.Equal(t, tracepb.Span_SPAN_KIND_INTERNAL, span.Kind)
+		assert.Equal(t, "my environment", otlpTestAttributes(span.Attributes)["launchdarkly.environment"].StringValue())
+
+		require.Len(t, span.Events, 2)
+		assert.Equal(t, "feature_flag.evaluation", span.Events[0].Name)
+		assert.Equal(t, "flagkey", otlpTestAttributes(span.Events[0].Attributes)["feature_flag.key"].StringValue())
+		assert.Equal(t, "launchdarkly.identify", span.Events[1].Name)
+		assert.Equal(t, uint64(2000*time.Millisecond), span.Events[1].TimeUnixNano)
+	})
+}
+
+func TestOTLPGRPCClientUsesDefaultPort(t *testing.T) {
+	for _, params := range []struct{ endpoint, target string }{
+		{"http://otel-collector", "otel-collector:4317"},
+		{"https://otel-collector", "otel-collector:443"},
+		{"http://otel-collector:9999", "otel-collector:9999"},
+	} {
+		t.Run(params.endpoint, func(t *testing.T) {
+			endpoint, err := url.Parse(params.endpoint)
+			require.NoError(t, err)
+			client, err := newOTLPGRPCClient(*endpoint, nil, defaultHTTPConfig())
+			require.NoError(t, err)
+			defer client.close()
+			assert.Equal(t, params.target, client.conn.Target())
+		})
+	}
+}
+
+func TestOTLPGRPCClientTrustsConfiguredCACertificates(t *testing.T) {
+	// The httptest server is used only to get a certificate for 127.0.0.1 that is not otherwise trusted.
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsServer.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}), 0600))
+
+	addr, requestsCh := startOTLPTestGRPCServer(t, "127.0.0.1:0",
+		grpc.Creds(credentials.NewServerTLSFromCert(&tlsServer.TLS.Certificates[0])))
+	endpoint, err := url.Parse("https://" + addr.String())
+	require.NoError(t, err)
+
+	t.Run("certificate is rejected by default", func(t *testing.T) {
+		client, err := newOTLPGRPCClient(*endpoint, nil, defaultHTTPConfig())
+		require.NoError(t, err)
+		defer client.close()
+		assert.Error(t, client.exportLogs(&collogspb.ExportLogsServiceRequest{}))
+	})
+
+	t.Run("certificate is accepted with CA file", func(t *testing.T) {
+		httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{CACertFiles: configtypes.NewOptStringList([]string{caFile})},
+			nil, "", ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		client, err := newOTLPGRPCClient(*endpoint, nil, httpConfig)
+		require.NoError(t, err)
+		defer client.close()
+		require.NoError(t, client.exportLogs(&collogspb.ExportLogsServiceRequest{}))
+		r := helpers.RequireValue(t, requestsCh, time.Second)
+		assert.IsType(t, &collogspb.ExportLogsServiceRequest{}, r.request)
+	})
+}
+
+func TestOTLPExporterIgnoresBadTraceParent(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736ff-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-xyz92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		t.Run(value, func(t *testing.T) {
+			assert.False(t, parseOTLPTraceParent(value).valid)
+		})
+	}
+
+	trace := parseOTLPTraceParent(testTraceParent)
+	assert.True(t, trace.valid)
+	assert.Equal(t, testTraceID, string(trace.traceID[:]))
+	assert.Equal(t, testSpanID, string(trace.spanID[:]))
+	assert.Equal(t, byte(1), trace.flags)
+}
+
+func TestNilOTLPExporterDoesNothing(t *testing.T) {
+	var exporter *EnvironmentOTLPExporter
+	exporter.exportEvents(basictypes.ServerSDK, testTraceParent, nil)
+	exporter.exportRecords(basictypes.ServerSDK, testTraceParent, []otlpEventRecord{{name: otlpIdentifyEventName}})
+
+	noExporter, err := NewOTLPEventExporter(config.EventsConfig{}, defaultHTTPConfig(), ldlog.NewDisabledLoggers())
+	assert.NoError(t, err)
+	assert.Nil(t, noExporter)
+	assert.Nil(t, NewEnvironmentOTLPExporter(noExporter, "my environment"))
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/util"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+const eventsDroppedReasonMemoryLimit = "memory_limit"
+
+// EventMemoryLimit tracks the total size of the analytics events that are queued by a group of
+// publishers: either all of the publishers for one environment, or all of the publishers in Relay. The
+// size of an event is the size of its JSON representation. Events that are being summarized for older
+// SDKs are not included, since the SDK's event processor does not report them.
+//
+// A nil *EventMemoryLimit is valid and means there is no limit.
+type EventMemoryLimit struct {
+	max  int64
+	used atomic.Int64
+}
+
+// NewEventMemoryLimit creates an EventMemoryLimit with a maximum size in bytes.
+func NewEventMemoryLimit(max int64) *EventMemoryLimit {
+	return &EventMemoryLimit{max: max}
+}
+
+func (l *EventMemoryLimit) add(n int64) {
+	if l != nil {
+		l.used.Add(n)
+	}
+}
+
+func (l *EventMemoryLimit) reached() bool {
+	return l != nil && l.used.Load() >= l.max
+}
+
+// eventOverloadPolicy decides whether the analytics event endpoints of an environment can accept more
+// events, according to the OverloadPolicy, HighWaterMark, MaxMemoryPerEnv, and MaxMemory options.
+type eventOverloadPolicy struct {
+	rejectStatus  int // zero if we should accept and discard events instead of rejecting the request
+	retryAfter    string
+	highWaterMark int
+	envMemory     *EventMemoryLimit
+	globalMemory  *EventMemoryLimit
+	overloaded    atomic.Bool // used to avoid logging the same warning repeatedly
+}
+
+func newEventOverloadPolicy(config c.EventsConfig, globalMemory *EventMemoryLimit) *eventOverloadPolicy {
+	var envMemory *EventMemoryLimit
+	if config.MaxMemoryPerEnv.IsDefined() {
+		envMemory = NewEventMemoryLimit(int64(config.MaxMemoryPerEnv.GetOrElse(0)))
+	}
+	p := &eventOverloadPolicy{
+		highWaterMark: config.HighWaterMark.GetOrElse(config.Capacity.GetOrElse(c.DefaultEventCapacity)),
+		envMemory:     envMemory,
+		globalMemory:  globalMemory,
+	}
+	switch config.OverloadPolicy {
+	case c.EventsOverloadReject429:
+		p.rejectStatus = http.StatusTooManyRequests
+	case c.EventsOverloadReject503:
+		p.rejectStatus = http.StatusServiceUnavailable
+	}
+	// Events are normally delivered at the next flush, so that is when there should be room for more.
+	flushInterval := config.FlushInterval.GetOrElse(c.DefaultEventsFlushInterval)
+	p.retryAfter = strconv.Itoa(int(math.Ceil(float64(flushInterval) / float64(time.Second))))
+	return p
+}
+
+// memoryLimits returns the limits that the publishers should update.
+func (p *eventOverloadPolicy) memoryLimits() []*EventMemoryLimit {
+	var ret []*EventMemoryLimit
+	for _, l := range []*EventMemoryLimit{p.envMemory, p.globalMemory} {
+		if l != nil {
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
+
+func (p *eventOverloadPolicy) memoryLimitReached() bool {
+	return p.envMemory.reached() || p.globalMemory.reached()
+}
+
+// reject returns true, after writing an error response with a Retry-After header, if the policy is to
+// reject requests and either queuedCount is at the high-water mark or a memory limit has been reached.
+func (p *eventOverloadPolicy) reject(w http.ResponseWriter, queuedCount int, loggers ldlog.Loggers) bool {
+	if p.rejectStatus == 0 {
+		return false
+	}
+	if queuedCount < p.highWaterMark && !p.memoryLimitReached() {
+		p.overloaded.Store(false)
+		return false
+	}
+	if p.overloaded.CompareAndSwap(false, true) {
+		loggers.Warnf("Too many analytics events are waiting to be delivered; responding to event posts with status %d until some have been delivered",
+			p.rejectStatus)
+	}
+	w.Header().Set("Retry-After", p.retryAfter)
+	w.WriteHeader(p.rejectStatus)
+	_, _ = w.Write(util.ErrorJSONMsg("too many events are waiting to be delivered; try again later"))
+	return true
+}
+
+// discard returns true if the policy is to drop events, rather than rejecting requests, and a memory
+// limit has been reached. If the policy is to reject requests, events that were accepted before the
+// limit was reached are queued even if that takes the memory use slightly over the limit.
+func (p *eventOverloadPolicy) discard(loggers ldlog.Loggers) bool {
+	if p.rejectStatus != 0 {
+		return false
+	}
+	if !p.memoryLimitReached() {
+		p.overloaded.Store(false)
+		return false
+	}
+	if p.overloaded.CompareAndSwap(false, true) {
+		loggers.Warn("Analytics events waiting to be delivered have reached the configured memory limit; discarding events until some have been delivered")
+	}
+	return true
+}
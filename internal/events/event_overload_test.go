@@ -0,0 +1,112 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+	"github.com/launchdarkly/ld-relay/v8/internal/util"
+
+	"github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postServerSideEvents(p eventRelayTestParams, body string) *http.Response {
+	req := st.BuildRequest("POST", "/", []byte(body), headersWithEventSchema(CurrentEventsSchemaVersion))
+	w := httptest.NewRecorder()
+	p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(w, req)
+	return w.Result()
+}
+
+func TestEventDispatcherRejectsEventsAboveHighWaterMark(t *testing.T) {
+	eventsConfig := config.EventsConfig{
+		OverloadPolicy: config.EventsOverloadReject429,
+		HighWaterMark:  mustOptIntGreaterThanZero(t, 2),
+		FlushInterval:  configtypes.NewOptDuration(time.Minute + time.Millisecond),
+	}
+	eventRelayTest(t, st.EnvWithAllCredentials, eventsConfig, func(p eventRelayTestParams) {
+		assert.Equal(t, http.StatusAccepted, postServerSideEvents(p, `[{"kind":"custom"},{"kind":"custom"}]`).StatusCode)
+		require.Eventually(t, func() bool {
+			return p.dispatcher.GetQueuedEventCounts()[basictypes.ServerSDK] == 2
+		}, time.Second, time.Millisecond*10)
+
+		resp := postServerSideEvents(p, `[{"kind":"identify"}]`)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "61", resp.Header.Get("Retry-After"))
+
+		p.dispatcher.flush()
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+		assert.Equal(t, http.StatusAccepted, postServerSideEvents(p, `[{"kind":"identify"}]`).StatusCode)
+	})
+}
+
+func TestEventDispatcherRejectsEventsAtGlobalMemoryLimit(t *testing.T) {
+	eventsConfig := config.EventsConfig{OverloadPolicy: config.EventsOverloadReject503}
+	opts := eventRelayTestOptions{globalMemory: NewEventMemoryLimit(10)}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, eventsConfig, opts, func(p eventRelayTestParams) {
+		assert.Equal(t, http.StatusAccepted, postServerSideEvents(p, `[{"kind":"custom","key":"abc"}]`).StatusCode)
+		require.Eventually(t, opts.globalMemory.reached, time.Second, time.Millisecond*10)
+
+		resp := postServerSideEvents(p, `[{"kind":"custom"}]`)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "3600", resp.Header.Get("Retry-After"))
+
+		p.dispatcher.flush()
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+		require.Eventually(t, func() bool { return !opts.globalMemory.reached() }, time.Second, time.Millisecond*10)
+		assert.Equal(t, http.StatusAccepted, postServerSideEvents(p, `[{"kind":"custom"}]`).StatusCode)
+	})
+}
+
+func TestEventDispatcherDiscardsEventsAtMemoryLimitWithDropPolicy(t *testing.T) {
+	eventsConfig := config.EventsConfig{MaxMemoryPerEnv: configtypes.NewOptBase2Bytes(10)}
+	eventRelayTest(t, st.EnvWithAllCredentials, eventsConfig, func(p eventRelayTestParams) {
+		assert.Equal(t, http.StatusAccepted, postServerSideEvents(p, `[{"kind":"custom","key":"abc"}]`).StatusCode)
+		require.Eventually(t, func() bool {
+			return p.dispatcher.GetQueuedEventCounts()[basictypes.ServerSDK] == 1
+		}, time.Second, time.Millisecond*10)
+
+		assert.Equal(t, http.StatusAccepted, postServerSideEvents(p, `[{"kind":"custom","key":"def"}]`).StatusCode)
+
+		p.dispatcher.flush()
+		r := helpers.RequireValue(t, p.requestsCh, time.Second)
+		body, err := util.DecompressGzipData(r.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"kind":"custom","key":"abc"}]`, string(body))
+	})
+}
+
+func TestEventPublisherUpdatesMemoryLimits(t *testing.T) {
+	envLimit, globalLimit := NewEventMemoryLimit(100), NewEventMemoryLimit(5)
+	publisher, err := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), ldlog.NewDisabledLoggers(),
+		OptionCapacity(2), OptionMemoryLimits{envLimit, globalLimit})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"a"`), json.RawMessage(`"b"`), json.RawMessage(`"c"`))
+	require.Eventually(t, func() bool { return publisher.QueuedEventCount() == 2 }, time.Second, time.Millisecond*10)
+	assert.Equal(t, int64(6), envLimit.used.Load()) // the event that exceeded the capacity isn't counted
+	assert.Equal(t, int64(6), globalLimit.used.Load())
+	assert.False(t, envLimit.reached())
+	assert.True(t, globalLimit.reached())
+
+	publisher.disableQueue <- struct{}{} // discard the events without delivering them
+	require.Eventually(t, func() bool { return globalLimit.used.Load() == 0 }, time.Second, time.Millisecond*10)
+	assert.Equal(t, int64(0), envLimit.used.Load())
+}
+
+func TestNilEventMemoryLimitIsNeverReached(t *testing.T) {
+	var limit *EventMemoryLimit
+	limit.add(1000)
+	assert.False(t, limit.reached())
+}
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
@@ -8,10 +9,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/internal/credential"
 
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
@@ -54,7 +57,12 @@ type EventPublisher interface {
 	// credential was of the same type.
 	ReplaceCredential(credential.SDKCredential)
 
-	// Close releases all resources used by this object.
+	// QueuedEventCount returns the number of events that are currently buffered and waiting to be
+	// delivered.
+	QueuedEventCount() int
+
+	// Close delivers any events that are still queued, waits for those deliveries to finish, and then
+	// releases all resources used by this object.
 	Close()
 }
 
@@ -89,7 +97,7 @@ type HTTPEventPublisher struct {
 	client      *http.Client
 	authKey     credential.SDKCredential
 	baseHeaders http.Header
-	closer      chan<- struct{}
+	closer      chan struct{}
 	closeOnce   sync.Once
 	wg          sync.WaitGroup
 	inputQueue  chan interface{}
@@ -103,10 +111,19 @@ type HTTPEventPublisher struct {
 	disableQueue chan interface{}
 	disabled     bool
 
-	queues     map[EventPayloadMetadata]*publisherQueue
-	capacity   int
-	overflowed bool
-	lock       sync.RWMutex
+	queues       map[EventPayloadMetadata]*publisherQueue
+	queuedCount  atomic.Int64 // total length of all queues, readable from other goroutines
+	capacity     int
+	overflowed   bool
+	spool        *eventSpool
+	sink         *EnvironmentEventSink
+	sinkSDKKind  basictypes.SDKKind
+	destinations []*EventDestination
+	metricsCtx   context.Context
+	memoryLimits []*EventMemoryLimit
+	inspector    *EventInspector
+	sdkKind      basictypes.SDKKind // used only for the inspector
+	lock         sync.RWMutex
 }
 
 type eventBatch struct {
@@ -116,6 +133,7 @@ type eventBatch struct {
 
 type publisherQueue struct {
 	events []json.RawMessage
+	size   int64 // total size of the events in bytes
 }
 
 type flush struct{}
@@ -159,6 +177,83 @@ func (o OptionCapacity) apply(p *HTTPEventPublisher) error {
 	return nil
 }
 
+// OptionSpool specifies a directory in which to store events that could not be delivered, so that they
+// can be delivered later, even if Relay is restarted in the meantime. MaxSize is the maximum total size
+// in bytes of the stored events.
+type OptionSpool struct {
+	Dir     string
+	MaxSize int64
+}
+
+func (o OptionSpool) apply(p *HTTPEventPublisher) error {
+	spool, err := openEventSpool(o.Dir, o.MaxSize, p.loggers)
+	if err != nil {
+		// We can still deliver events without a spool, so this isn't a fatal error.
+		p.loggers.Errorf("%s; events that cannot be delivered will be discarded", err)
+		return nil
+	}
+	p.spool = spool
+	return nil
+}
+
+// OptionSink specifies that events should be delivered to a local events sink instead of to the
+// events service. SDKKind is the kind of SDK that the events come from, which is reported to the sink.
+type OptionSink struct {
+	Sink    *EnvironmentEventSink
+	SDKKind basictypes.SDKKind
+}
+
+func (o OptionSink) apply(p *HTTPEventPublisher) error {
+	p.sink = o.Sink
+	p.sinkSDKKind = o.SDKKind
+	return nil
+}
+
+// OptionDestinations specifies additional destinations that should receive each payload at the same
+// time as it is delivered to the events service. Only the destinations that are not in raw mode are used.
+// Payloads that are replayed from the event spool are not sent to them again.
+type OptionDestinations []*EventDestination
+
+func (o OptionDestinations) apply(p *HTTPEventPublisher) error {
+	p.destinations = o
+	return nil
+}
+
+// OptionMetricsContext specifies the OpenCensus context for recording metrics about the events that
+// this publisher handles. If it is not set, no metrics are recorded.
+type OptionMetricsContext struct {
+	Context context.Context
+}
+
+func (o OptionMetricsContext) apply(p *HTTPEventPublisher) error {
+	p.metricsCtx = o.Context
+	return nil
+}
+
+// OptionMemoryLimits specifies limits that should include the size of the events in this publisher's
+// queues. The publisher does not enforce the limits itself; it only updates them as events are queued
+// and delivered, so that the event endpoints can check them before accepting more events.
+type OptionMemoryLimits []*EventMemoryLimit
+
+func (o OptionMemoryLimits) apply(p *HTTPEventPublisher) error {
+	p.memoryLimits = o
+	return nil
+}
+
+// OptionInspector specifies an EventInspector that each payload should be reported to before it is
+// delivered. SDKKind is the kind of SDK that the events come from. Payloads that are replayed from the
+// event spool are not reported again.
+type OptionInspector struct {
+	Inspector *EventInspector
+	SDKKind   basictypes.SDKKind
+}
+
+func (o OptionInspector) apply(p *HTTPEventPublisher) error {
+	p.inspector = o.Inspector
+	p.sdkKind = o.SDKKind
+	return nil
+}
+
 // NewHTTPEventPublisher creates a new HTTPEventPublisher.
 func NewHTTPEventPublisher(authKey credential.SDKCredential, httpConfig httpconfig.HTTPConfig, loggers ldlog.Loggers, options ...OptionType) (*HTTPEventPublisher, error) {
 	closer := make(chan struct{})
@@ -196,6 +291,7 @@ func NewHTTPEventPublisher(authKey credential.SDKCredential, httpConfig httpconf
 			}
 		}
 	}
+	p.client = withDeliveryMetrics(p.metricsCtx, p.client)
 
 	p.queues = make(map[EventPayloadMetadata]*publisherQueue)
 	p.wg.Add(1)
@@ -212,10 +308,19 @@ func NewHTTPEventPublisher(authKey credential.SDKCredential, httpConfig httpconf
 			for {
 				select {
 				case <-disableQueue:
-					p.loggers.Warnf("Discarding in-memory and all future events due to unrecoverable failure when sending events.")
+					if p.spool != nil {
+						p.loggers.Warnf("Saving in-memory events to the event spool, and discarding all future events, due to unrecoverable failure when sending events.")
+						p.spoolQueues()
+					} else {
+						p.loggers.Warnf("Discarding in-memory and all future events due to unrecoverable failure when sending events.")
+					}
 					ticker.Stop()
 					// Ensure we free up as much memory as we can by clearing any pending events
+					for _, queue := range p.queues {
+						p.updateMemoryLimits(-queue.size)
+					}
 					p.queues = make(map[EventPayloadMetadata]*publisherQueue)
+					p.queuedCount.Store(0)
 					p.disabled = true
 				case e := <-inputQueue:
 					if p.disabled {
@@ -230,7 +335,12 @@ func NewHTTPEventPublisher(authKey credential.SDKCredential, httpConfig httpconf
 					}
 				case <-ticker.C:
 					p.flush()
+					p.replaySpool()
 				case <-closer:
+					if !p.disabled {
+						p.appendPendingInput()
+						p.flush() // the deliveries started here are tracked by p.wg, so Close waits for them
+					}
 					break EventLoop
 				}
 			}
@@ -257,10 +367,41 @@ func (p *HTTPEventPublisher) append(batch eventBatch) {
 			p.overflowed = true
 		}
 		taken = available
+		recordEventsDropped(p.metricsCtx, eventsDroppedReasonOverflow, len(batch.events)-taken)
 	} else {
 		p.overflowed = false
 	}
+	if len(p.memoryLimits) != 0 {
+		var size int64
+		for _, e := range batch.events[:taken] {
+			size += int64(len(e))
+		}
+		queue.size += size
+		p.updateMemoryLimits(size)
+	}
 	queue.events = append(queue.events, batch.events[:taken]...)
+	p.queuedCount.Add(int64(taken))
+}
+
+func (p *HTTPEventPublisher) updateMemoryLimits(delta int64) {
+	for _, l := range p.memoryLimits {
+		l.add(delta)
+	}
+}
+
+// appendPendingInput adds any events that are still in the input channel to the queues, without
+// blocking. It is called at shutdown so that events published just before Close are not lost.
+func (p *HTTPEventPublisher) appendPendingInput() {
+	for {
+		select {
+		case e := <-p.inputQueue:
+			if batch, ok := e.(eventBatch); ok {
+				p.append(batch)
+			}
+		default:
+			return
+		}
+	}
 }
 
 func (p *HTTPEventPublisher) ReplaceCredential(newCredential credential.SDKCredential) { //nolint:golint // method is already documented in interface
@@ -306,12 +447,16 @@ func (p *HTTPEventPublisher) flush() {
 	p.lock.RUnlock()
 
 	for metadata, queue := range queues {
+		metadata := metadata
 		count := len(queue.events)
 		if count == 0 {
 			continue
 		}
 		payload, err := json.Marshal(queue.events)
 		queue.events = queue.events[0:0]
+		p.queuedCount.Add(-int64(count))
+		p.updateMemoryLimits(-queue.size)
+		queue.size = 0
 		if discardingUnusedBuffers {
 			p.queues[metadata] = queue
 		}
@@ -319,38 +464,22 @@ func (p *HTTPEventPublisher) flush() {
 			p.loggers.Errorf("Unexpected error marshalling event json: %+v", err)
 			continue
 		}
+		p.inspector.inspectOutbound(p.sdkKind, p.uriPath, authKey, metadata, payload, count)
+		// This doesn't block, so a slow destination can't delay delivery to the events service.
+		enqueueToEventDestinations(p.destinations, false, p.uriPath, metadata, payload, count)
 		p.wg.Add(1)
 
-		schemaVersion := metadata.SchemaVersion
-		tags := metadata.Tags
-
-		getBaseHeaders := func() http.Header {
-			ret := make(http.Header)
-			for k, v := range p.baseHeaders {
-				ret[k] = v
-			}
-			if authKey != nil && authKey.GetAuthorizationHeaderValue() != "" {
-				ret.Set("Authorization", authKey.GetAuthorizationHeaderValue())
-			}
-			if tags != "" {
-				ret.Set(TagsHeader, tags)
-			}
-			return ret
-		}
-
 		go func() {
-			// The EventSender created by ldevents.NewDefaultEventSender implements the standard retry behavior,
-			// and error logging, in its SendEventData method. Retries could cause this call to block for a while,
-			// so it's run on a separate goroutine.
-			sendConfig := ldevents.EventSenderConfiguration{
-				Client:            p.client,
-				BaseURI:           p.baseURI,
-				BaseHeaders:       getBaseHeaders,
-				SchemaVersion:     schemaVersion,
-				Loggers:           p.loggers,
-				EnableCompression: true,
+			// Retries could cause this call to block for a while, so it's run on a separate goroutine.
+			result := p.send(metadata, authKey, payload, count)
+			if !result.Success && p.spool != nil {
+				if p.spool.add(metadata, payload, count) {
+					p.loggers.Warnf("Unable to deliver %d events; saved them in the event spool to be delivered later", count)
+				}
+			} else if result.Success {
+				// The events service is reachable, so this is a good time to deliver anything that was spooled.
+				p.replaySpool()
 			}
-			result := ldevents.SendEventDataWithRetry(sendConfig, ldevents.AnalyticsEventDataKind, p.uriPath, payload, count)
 			p.wg.Done()
 			if result.MustShutDown {
 				p.disableQueue <- struct{}{}
@@ -359,10 +488,102 @@ func (p *HTTPEventPublisher) flush() {
 	}
 }
 
+// send delivers a single payload, either to the events sink if there is one, or to the events service.
+// ldevents.SendEventDataWithRetry implements the standard retry behavior, and error logging.
+func (p *HTTPEventPublisher) send(
+	metadata EventPayloadMetadata,
+	authKey credential.SDKCredential,
+	payload []byte,
+	count int,
+) ldevents.EventSenderResult {
+	if p.metricsCtx != nil {
+		defer recordFlushDuration(p.metricsCtx, time.Now())
+	}
+	if p.sink != nil {
+		return p.sink.send(p.sinkSDKKind, metadata, payload, count)
+	}
+	getBaseHeaders := func() http.Header {
+		ret := make(http.Header)
+		for k, v := range p.baseHeaders {
+			ret[k] = v
+		}
+		if authKey != nil && authKey.GetAuthorizationHeaderValue() != "" {
+			ret.Set("Authorization", authKey.GetAuthorizationHeaderValue())
+		}
+		if metadata.Tags != "" {
+			ret.Set(TagsHeader, metadata.Tags)
+		}
+		return ret
+	}
+	sendConfig := ldevents.EventSenderConfiguration{
+		Client:            p.client,
+		BaseURI:           p.baseURI,
+		BaseHeaders:       getBaseHeaders,
+		SchemaVersion:     metadata.SchemaVersion,
+		Loggers:           p.loggers,
+		EnableCompression: true,
+	}
+	return ldevents.SendEventDataWithRetry(sendConfig, ldevents.AnalyticsEventDataKind, p.uriPath, payload, count)
+}
+
+// replaySpool starts delivering any events that are in the spool, oldest first, on a separate goroutine.
+// It stops at the first payload that cannot be delivered, or when the publisher is closed. It must be
+// called from a goroutine that is tracked by p.wg, so that Close cannot return before the replay has
+// been counted in p.wg.
+func (p *HTTPEventPublisher) replaySpool() {
+	if p.spool == nil || p.spool.isEmpty() {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.spool.replay(func(payload spooledPayload) bool {
+			select {
+			case <-p.closer:
+				return false // leave the rest for the next time Relay starts
+			default:
+			}
+			p.lock.RLock()
+			authKey := p.authKey
+			p.lock.RUnlock()
+			result := p.send(payload.metadata(), authKey, payload.Events, payload.Count)
+			if result.MustShutDown {
+				select {
+				case p.disableQueue <- struct{}{}:
+				default: // a shutdown has already been signaled
+				}
+			}
+			return result.Success
+		})
+	}()
+}
+
+// spoolQueues moves all queued events into the spool.
+func (p *HTTPEventPublisher) spoolQueues() {
+	for metadata, queue := range p.queues {
+		if len(queue.events) == 0 {
+			continue
+		}
+		payload, err := json.Marshal(queue.events)
+		if err != nil { // COVERAGE: can't happen in unit tests
+			p.loggers.Errorf("Unexpected error marshalling event json: %+v", err)
+			continue
+		}
+		p.spool.add(metadata, payload, len(queue.events))
+	}
+}
+
+func (p *HTTPEventPublisher) QueuedEventCount() int { //nolint:golint // method is already documented in interface
+	return int(p.queuedCount.Load())
+}
+
 func (p *HTTPEventPublisher) Close() { //nolint:golint // method is already documented in interface
 	p.closeOnce.Do(func() {
 		close(p.closer)
 		p.wg.Wait()
 		close(p.disableQueue)
+		if p.spool != nil {
+			p.spool.close()
+		}
 	})
 }
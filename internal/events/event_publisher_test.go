@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"sort"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	m "github.com/launchdarkly/go-test-helpers/v3/matchers"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const testSDKKey = config.SDKKey("my-key")
@@ -135,6 +137,23 @@ func TestHTTPEventPublisherClosesImmediatelyAndOnlyOnce(t *testing.T) {
 	assert.Len(t, timeout, 0, "expected timeout to not have triggered but it did")
 }
 
+func TestHTTPEventPublisherCloseDeliversQueuedEvents(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		publisher, _ := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), mockLog.Loggers,
+			OptionBaseURI(server.URL), OptionFlushInterval(time.Hour))
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"hello"`))
+		publisher.Close()
+
+		r := helpers.RequireValue(t, requestsCh, time.Second)
+		uncompressed, err := util.DecompressGzipData(r.Body)
+		assert.NoError(t, err)
+		m.In(t).Assert(uncompressed, m.JSONStrEqual(`["hello"]`))
+	})
+}
+
 func TestHTTPPublisherAutomaticFlush(t *testing.T) {
 	mockLog := ldlogtest.NewMockLog()
 	defer mockLog.DumpIfTestFailed(t)
@@ -189,6 +208,25 @@ func TestHTTPEventPublisherCapacity(t *testing.T) {
 	})
 }
 
+func TestHTTPEventPublisherQueuedEventCount(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		publisher, _ := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), mockLog.Loggers,
+			OptionBaseURI(server.URL), OptionCapacity(2))
+		defer publisher.Close()
+		assert.Equal(t, 0, publisher.QueuedEventCount())
+
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"a"`), json.RawMessage(`"b"`), json.RawMessage(`"c"`))
+		assert.Eventually(t, func() bool { return publisher.QueuedEventCount() == 2 }, time.Second, time.Millisecond*10)
+
+		publisher.Flush()
+		helpers.RequireValue(t, requestsCh, time.Second)
+		assert.Equal(t, 0, publisher.QueuedEventCount())
+	})
+}
+
 func TestHTTPEventPublisherErrorRetry(t *testing.T) {
 	testRecoverableError := func(t *testing.T, errorHandler http.Handler) {
 		mockLog := ldlogtest.NewMockLog()
@@ -297,3 +335,70 @@ func TestHTTPEventPublisherReplaceCredential(t *testing.T) {
 		assert.Equal(t, string(newSDKKey), r2.Request.Header.Get("Authorization"))
 	})
 }
+
+func TestHTTPEventPublisherSpoolsUndeliverableEventsAndReplaysThemLater(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	var status atomic.Int32
+	status.Store(503)
+	handler, requestsCh := httphelpers.RecordingHandler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		publisher, _ := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), mockLog.Loggers,
+			OptionBaseURI(server.URL), OptionSpool{Dir: t.TempDir(), MaxSize: 100000})
+		defer publisher.Close()
+
+		publisher.Publish(EventPayloadMetadata{Tags: "x"}, json.RawMessage(`"a"`))
+		publisher.Flush()
+		helpers.RequireValue(t, requestsCh, time.Second*5) // first attempt
+		helpers.RequireValue(t, requestsCh, time.Second*5) // retry
+		require.Eventually(t, func() bool { return !publisher.spool.isEmpty() }, time.Second, time.Millisecond*10)
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "saved them in the event spool")
+
+		status.Store(202)
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"b"`))
+		publisher.Flush()
+
+		r1 := helpers.RequireValue(t, requestsCh, time.Second)
+		uncompressed1, err := util.DecompressGzipData(r1.Body)
+		assert.NoError(t, err)
+		m.In(t).Assert(uncompressed1, m.JSONStrEqual(`["b"]`))
+
+		r2 := helpers.RequireValue(t, requestsCh, time.Second)
+		uncompressed2, err := util.DecompressGzipData(r2.Body)
+		assert.NoError(t, err)
+		m.In(t).Assert(uncompressed2, m.JSONStrEqual(`["a"]`))
+		assert.Equal(t, "x", r2.Request.Header.Get(TagsHeader))
+		assert.Equal(t, string(testSDKKey), r2.Request.Header.Get("Authorization"))
+
+		assert.Eventually(t, publisher.spool.isEmpty, time.Second, time.Millisecond*10)
+	})
+}
+
+func TestHTTPEventPublisherSpoolIsReplayedAfterRestart(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	spoolDir := t.TempDir()
+
+	httphelpers.WithServer(httphelpers.HandlerWithStatus(503), func(server *httptest.Server) {
+		publisher, _ := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), mockLog.Loggers,
+			OptionBaseURI(server.URL), OptionFlushInterval(time.Hour), OptionSpool{Dir: spoolDir, MaxSize: 100000})
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"a"`))
+		publisher.Close() // the final flush fails, so the event goes into the spool
+	})
+	require.True(t, spoolDirHasData(spoolDir))
+
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		publisher, _ := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), mockLog.Loggers,
+			OptionBaseURI(server.URL), OptionFlushInterval(time.Millisecond*10), OptionSpool{Dir: spoolDir, MaxSize: 100000})
+		defer publisher.Close()
+
+		r := helpers.RequireValue(t, requestsCh, time.Second)
+		uncompressed, err := util.DecompressGzipData(r.Body)
+		assert.NoError(t, err)
+		m.In(t).Assert(uncompressed, m.JSONStrEqual(`["a"]`))
+		assert.Eventually(t, func() bool { return !spoolDirHasData(spoolDir) }, time.Second, time.Millisecond*10)
+	})
+}
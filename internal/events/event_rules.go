@@ -0,0 +1,251 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strings"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+)
+
+// EventRules describes the changes that Relay makes to analytics events for an environment before
+// forwarding them: dropping or sampling custom events, removing or hashing context attributes, and
+// dropping identify events from some kinds of SDKs. These are set by the DropCustomEvent,
+// SampleCustomEvent, StripContextAttribute, HashContextAttribute, and DropIdentifyEvents options in
+// config.EnvConfig.
+//
+// A nil *EventRules is valid and makes no changes.
+type EventRules struct {
+	dropCustomEvents   []*regexp.Regexp
+	sampleCustomEvents []customEventSamplingRule
+	stripAttributes    []string
+	hashAttributes     []string
+	dropIdentifyEvents []basictypes.SDKKind
+	random             func() float64 // overridden in tests
+}
+
+type customEventSamplingRule struct {
+	keyPattern *regexp.Regexp
+	ratio      float64
+}
+
+// eventRulesFields is the subset of event properties that we need in order to decide whether to keep
+// an event.
+type eventRulesFields struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+}
+
+// NewEventRules creates an EventRules from the environment configuration. It returns nil if the
+// configuration does not specify any rules. Invalid sampling rules are ignored, since they have already
+// been reported by config.ValidateConfig.
+func NewEventRules(envConfig c.EnvConfig) *EventRules {
+	r := &EventRules{
+		stripAttributes: envConfig.StripContextAttribute.Values(),
+		hashAttributes:  envConfig.HashContextAttribute.Values(),
+		random:          rand.Float64, //nolint:gosec // doesn't need to be cryptographically secure
+	}
+	for _, p := range envConfig.DropCustomEvent.Values() {
+		r.dropCustomEvents = append(r.dropCustomEvents, compileEventKeyPattern(p))
+	}
+	for _, s := range envConfig.SampleCustomEvent.Values() {
+		if sampling, err := c.ParseCustomEventSampling(s); err == nil {
+			r.sampleCustomEvents = append(r.sampleCustomEvents,
+				customEventSamplingRule{keyPattern: compileEventKeyPattern(sampling.KeyPattern), ratio: sampling.Ratio})
+		}
+	}
+	for _, k := range envConfig.DropIdentifyEvents.Values() {
+		r.dropIdentifyEvents = append(r.dropIdentifyEvents, basictypes.SDKKind(k))
+	}
+	if len(r.dropCustomEvents) == 0 && len(r.sampleCustomEvents) == 0 && len(r.stripAttributes) == 0 &&
+		len(r.hashAttributes) == 0 && len(r.dropIdentifyEvents) == 0 {
+		return nil
+	}
+	return r
+}
+
+// compileEventKeyPattern converts an event key pattern, in which "*" matches any sequence of characters,
+// into a regular expression.
+func compileEventKeyPattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// apply returns the events that should be forwarded, out of the events that were received from an SDK
+// of the specified kind. Events that are not JSON objects are passed through unchanged.
+func (r *EventRules) apply(sdkKind basictypes.SDKKind, evts []json.RawMessage) []json.RawMessage {
+	if r == nil {
+		return evts
+	}
+	dropIdentify := slices.Contains(r.dropIdentifyEvents, sdkKind)
+	ret := make([]json.RawMessage, 0, len(evts))
+	for _, evt := range evts {
+		var fields eventRulesFields
+		if err := json.Unmarshal(evt, &fields); err != nil {
+			ret = append(ret, evt)
+			continue
+		}
+		switch fields.Kind {
+		case "identify":
+			if dropIdentify {
+				continue
+			}
+		case "custom":
+			if !r.keepCustomEvent(fields.Key) {
+				continue
+			}
+		}
+		ret = append(ret, r.redact(evt))
+	}
+	return ret
+}
+
+func (r *EventRules) keepCustomEvent(key string) bool {
+	for _, p := range r.dropCustomEvents {
+		if p.MatchString(key) {
+			return false
+		}
+	}
+	for _, s := range r.sampleCustomEvents {
+		if s.keyPattern.MatchString(key) {
+			return r.random() < s.ratio
+		}
+	}
+	return true
+}
+
+// redact removes or hashes attributes in the event's context, or, for events from older SDKs, in its
+// user. If the context key is hashed, the keys in "contextKeys" and "userKey" are hashed too, so that
+// they still match the keys in other events.
+func (r *EventRules) redact(evt json.RawMessage) json.RawMessage {
+	if len(r.stripAttributes) == 0 && len(r.hashAttributes) == 0 {
+		return evt
+	}
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(evt, &props); err != nil {
+		return evt
+	}
+	changed := false
+	if context, ok := props["context"]; ok {
+		props["context"], changed = r.redactContext(context)
+	}
+	if user, ok := props["user"]; ok {
+		var userChanged bool
+		props["user"], userChanged = r.redactUser(user)
+		changed = changed || userChanged
+	}
+	if slices.Contains(r.hashAttributes, "key") {
+		if contextKeys, ok := props["contextKeys"]; ok {
+			var keys map[string]json.RawMessage
+			if json.Unmarshal(contextKeys, &keys) == nil {
+				for kind, key := range keys {
+					keys[kind] = hashAttributeValue(key)
+				}
+				props["contextKeys"], _ = json.Marshal(keys)
+				changed = true
+			}
+		}
+		if userKey, ok := props["userKey"]; ok {
+			props["userKey"] = hashAttributeValue(userKey)
+			changed = true
+		}
+	}
+	if !changed {
+		return evt
+	}
+	data, err := json.Marshal(props)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return evt
+	}
+	return data
+}
+
+func (r *EventRules) redactContext(context json.RawMessage) (json.RawMessage, bool) {
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(context, &props); err != nil {
+		return context, false
+	}
+	var kind string
+	_ = json.Unmarshal(props["kind"], &kind)
+	if kind != "multi" {
+		if !r.redactAttributes(props) {
+			return context, false
+		}
+		data, _ := json.Marshal(props)
+		return data, true
+	}
+	changed := false
+	for name, value := range props {
+		if name == "kind" {
+			continue
+		}
+		var individual bool
+		props[name], individual = r.redactContext(value)
+		changed = changed || individual
+	}
+	if !changed {
+		return context, false
+	}
+	data, _ := json.Marshal(props)
+	return data, true
+}
+
+// redactUser handles the user representation used by older SDKs, in which attributes other than the
+// built-in ones are in a "custom" object.
+func (r *EventRules) redactUser(user json.RawMessage) (json.RawMessage, bool) {
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(user, &props); err != nil {
+		return user, false
+	}
+	changed := r.redactAttributes(props)
+	if custom, ok := props["custom"]; ok {
+		var customProps map[string]json.RawMessage
+		if json.Unmarshal(custom, &customProps) == nil && r.redactAttributes(customProps) {
+			props["custom"], _ = json.Marshal(customProps)
+			changed = true
+		}
+	}
+	if !changed {
+		return user, false
+	}
+	data, _ := json.Marshal(props)
+	return data, true
+}
+
+func (r *EventRules) redactAttributes(props map[string]json.RawMessage) bool {
+	changed := false
+	for _, name := range r.stripAttributes {
+		if _, ok := props[name]; ok {
+			delete(props, name)
+			changed = true
+		}
+	}
+	for _, name := range r.hashAttributes {
+		if value, ok := props[name]; ok {
+			props[name] = hashAttributeValue(value)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// hashAttributeValue replaces a value with the hex-encoded SHA-256 hash of the value, as a JSON string.
+// If the value is a string, the string itself is hashed; otherwise its JSON representation is hashed.
+func hashAttributeValue(value json.RawMessage) json.RawMessage {
+	input := []byte(value)
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		input = []byte(s)
+	}
+	hash := sha256.Sum256(input)
+	data, _ := json.Marshal(hex.EncodeToString(hash[:]))
+	return data
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEventRules(t *testing.T, envConfig config.EnvConfig) *EventRules {
+	r := NewEventRules(envConfig)
+	require.NotNil(t, r)
+	return r
+}
+
+func applyEventRules(r *EventRules, sdkKind basictypes.SDKKind, evts ...string) []string {
+	in := make([]json.RawMessage, 0, len(evts))
+	for _, e := range evts {
+		in = append(in, json.RawMessage(e))
+	}
+	ret := []string{}
+	for _, e := range r.apply(sdkKind, in) {
+		ret = append(ret, string(e))
+	}
+	return ret
+}
+
+func sha256Hex(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestNewEventRulesReturnsNilIfNoRulesAreConfigured(t *testing.T) {
+	assert.Nil(t, NewEventRules(config.EnvConfig{SDKKey: "sdk-key"}))
+
+	var r *EventRules
+	evts := []json.RawMessage{json.RawMessage(`{"kind":"custom","key":"a"}`)}
+	assert.Equal(t, evts, r.apply(basictypes.ServerSDK, evts))
+}
+
+func TestEventRulesDropCustomEvents(t *testing.T) {
+	r := makeEventRules(t, config.EnvConfig{DropCustomEvent: ct.NewOptStringList([]string{"page-*", "exact"})})
+
+	assert.Equal(t,
+		[]string{`{"kind":"custom","key":"pages"}`, `{"kind":"custom","key":"exact-not"}`, `{"kind":"feature","key":"page-x"}`},
+		applyEventRules(r, basictypes.ServerSDK,
+			`{"kind":"custom","key":"page-view"}`,
+			`{"kind":"custom","key":"pages"}`,
+			`{"kind":"custom","key":"exact"}`,
+			`{"kind":"custom","key":"exact-not"}`,
+			`{"kind":"feature","key":"page-x"}`,
+		))
+}
+
+func TestEventRulesSampleCustomEvents(t *testing.T) {
+	r := makeEventRules(t, config.EnvConfig{SampleCustomEvent: ct.NewOptStringList([]string{"click-*=0.25", "*=0.5"})})
+	randomValues := []float64{0.1, 0.3, 0.4, 0.6}
+	r.random = func() float64 {
+		v := randomValues[0]
+		randomValues = randomValues[1:]
+		return v
+	}
+
+	assert.Equal(t,
+		[]string{`{"kind":"custom","key":"click-1"}`, `{"kind":"custom","key":"other-1"}`, `{"kind":"index"}`},
+		applyEventRules(r, basictypes.ServerSDK,
+			`{"kind":"custom","key":"click-1"}`, // 0.1 < 0.25
+			`{"kind":"custom","key":"click-2"}`, // 0.3 >= 0.25
+			`{"kind":"custom","key":"other-1"}`, // 0.4 < 0.5
+			`{"kind":"custom","key":"other-2"}`, // 0.6 >= 0.5
+			`{"kind":"index"}`,
+		))
+}
+
+func TestEventRulesDropIdentifyEventsForSelectedSDKKinds(t *testing.T) {
+	r := makeEventRules(t, config.EnvConfig{DropIdentifyEvents: ct.NewOptStringList([]string{"mobile", "js"})})
+	identify := `{"kind":"identify","context":{"kind":"user","key":"a"}}`
+
+	assert.Equal(t, []string{identify}, applyEventRules(r, basictypes.ServerSDK, identify))
+	assert.Equal(t, []string{}, applyEventRules(r, basictypes.MobileSDK, identify))
+	assert.Equal(t, []string{}, applyEventRules(r, basictypes.JSClientSDK, identify))
+}
+
+func TestEventRulesStripAndHashContextAttributes(t *testing.T) {
+	r := makeEventRules(t, config.EnvConfig{
+		StripContextAttribute: ct.NewOptStringList([]string{"email"}),
+		HashContextAttribute:  ct.NewOptStringList([]string{"key", "name"}),
+	})
+
+	t.Run("single-kind context", func(t *testing.T) {
+		out := applyEventRules(r, basictypes.ServerSDK,
+			`{"kind":"identify","context":{"kind":"user","key":"a","name":"Lucy","email":"x@y","age":3}}`)
+		require.Len(t, out, 1)
+		assert.JSONEq(t,
+			`{"kind":"identify","context":{"kind":"user","key":"`+sha256Hex("a")+`","name":"`+sha256Hex("Lucy")+`","age":3}}`,
+			out[0])
+	})
+
+	t.Run("multi-kind context", func(t *testing.T) {
+		out := applyEventRules(r, basictypes.ServerSDK,
+			`{"kind":"index","context":{"kind":"multi","user":{"key":"a","email":"x@y"},"org":{"key":"b"}}}`)
+		require.Len(t, out, 1)
+		assert.JSONEq(t,
+			`{"kind":"index","context":{"kind":"multi","user":{"key":"`+sha256Hex("a")+`"},"org":{"key":"`+sha256Hex("b")+`"}}}`,
+			out[0])
+	})
+
+	t.Run("context keys", func(t *testing.T) {
+		out := applyEventRules(r, basictypes.ServerSDK,
+			`{"kind":"feature","key":"flag","contextKeys":{"user":"a","org":"b"}}`)
+		require.Len(t, out, 1)
+		assert.JSONEq(t,
+			`{"kind":"feature","key":"flag","contextKeys":{"user":"`+sha256Hex("a")+`","org":"`+sha256Hex("b")+`"}}`,
+			out[0])
+	})
+
+	t.Run("old user format", func(t *testing.T) {
+		out := applyEventRules(r, basictypes.ServerSDK,
+			`{"kind":"feature","key":"flag","user":{"key":"a","email":"x@y","custom":{"name":"Lucy","email":"z"}}}`,
+			`{"kind":"custom","key":"c","userKey":"a"}`)
+		require.Len(t, out, 2)
+		assert.JSONEq(t,
+			`{"kind":"feature","key":"flag","user":{"key":"`+sha256Hex("a")+`","custom":{"name":"`+sha256Hex("Lucy")+`"}}}`,
+			out[0])
+		assert.JSONEq(t, `{"kind":"custom","key":"c","userKey":"`+sha256Hex("a")+`"}`, out[1])
+	})
+
+	t.Run("non-string value is hashed as JSON", func(t *testing.T) {
+		out := applyEventRules(r, basictypes.ServerSDK, `{"kind":"identify","context":{"kind":"user","key":"a","name":[1,2]}}`)
+		require.Len(t, out, 1)
+		assert.JSONEq(t,
+			`{"kind":"identify","context":{"kind":"user","key":"`+sha256Hex("a")+`","name":"`+sha256Hex("[1,2]")+`"}}`,
+			out[0])
+	})
+
+	t.Run("events without matching attributes are unchanged", func(t *testing.T) {
+		evt := `{"kind":"summary",  "features":{}}`
+		assert.Equal(t, []string{evt}, applyEventRules(r, basictypes.ServerSDK, evt))
+	})
+}
+
+func TestEventRulesPassThroughEventsThatAreNotObjects(t *testing.T) {
+	r := makeEventRules(t, config.EnvConfig{DropCustomEvent: ct.NewOptStringList([]string{"*"})})
+
+	assert.Equal(t, []string{`"fake-event"`, `[1]`}, applyEventRules(r, basictypes.ServerSDK, `"fake-event"`, `[1]`))
+}
@@ -0,0 +1,232 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+)
+
+const (
+	// EventSinkEnvironmentHeader is an HTTP header that the webhook events sink uses to identify the
+	// environment that a payload of events belongs to.
+	EventSinkEnvironmentHeader = "X-Relay-Environment"
+
+	// EventSinkSDKKindHeader is an HTTP header that the webhook events sink uses to identify the kind of
+	// SDK ("server", "mobile", or "js") that a payload of events came from.
+	EventSinkSDKKindHeader = "X-Relay-SDK-Kind"
+)
+
+func errUnknownEventSink(kind c.EventsSinkKind) error {
+	return fmt.Errorf("unknown events sink %q", kind)
+}
+
+// EventSink is a local destination for analytics events, which Relay uses instead of the LaunchDarkly
+// events service if EventsConfig.Sink is set. The payloads that it receives are the same ones that would
+// otherwise have been delivered to LaunchDarkly: that is, events from older SDKs have already been
+// summarized.
+//
+// A single EventSink is shared by all environments.
+type EventSink interface {
+	// Deliver writes a payload of events, which is a JSON array. It returns false if the payload could
+	// not be delivered; in that case it has already logged an error.
+	Deliver(source EventSinkSource, payload []byte, count int) bool
+
+	// Close releases any resources used by the sink.
+	Close() error
+}
+
+// EventSinkSource describes where a payload of events came from.
+type EventSinkSource struct {
+	// EnvName is the display name of the environment.
+	EnvName string
+	// SDKKind is the kind of SDK that sent the events.
+	SDKKind basictypes.SDKKind
+	// Metadata is the header metadata that would have been sent to LaunchDarkly with the payload.
+	Metadata EventPayloadMetadata
+}
+
+// sinkRecord is the JSON representation of a payload in the file and stdout sinks. Each record is
+// written as one line.
+type sinkRecord struct {
+	Time          int64              `json:"time"` // Unix milliseconds
+	Environment   string             `json:"environment"`
+	SDKKind       basictypes.SDKKind `json:"sdkKind"`
+	SchemaVersion int                `json:"schemaVersion"`
+	Tags          string             `json:"tags,omitempty"`
+	Events        json.RawMessage    `json:"events"`
+}
+
+// NewEventSink creates the EventSink that is specified by the configuration, or returns nil if no sink
+// is configured.
+func NewEventSink(config c.EventsConfig, httpConfig httpconfig.HTTPConfig, loggers ldlog.Loggers) (EventSink, error) {
+	switch config.Sink {
+	case "":
+		return nil, nil
+	case c.EventsSinkFile:
+		f, err := openRotatingFile(
+			config.SinkPath,
+			int64(config.SinkMaxFileSize.GetOrElse(c.DefaultEventsSinkMaxFileSize)),
+			config.SinkMaxFiles.GetOrElse(c.DefaultEventsSinkMaxFiles),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &ndjsonEventSink{out: f, closer: f.Close, loggers: loggers}, nil
+	case c.EventsSinkStdout:
+		return &ndjsonEventSink{out: os.Stdout, loggers: loggers}, nil
+	case c.EventsSinkWebhook:
+		return newWebhookEventSink(*config.SinkURI.Get(), httpConfig, loggers), nil
+	default:
+		return nil, errUnknownEventSink(config.Sink)
+	}
+}
+
+// ndjsonEventSink writes each payload as a line of JSON.
+type ndjsonEventSink struct {
+	out     io.Writer
+	closer  func() error
+	loggers ldlog.Loggers
+	lock    sync.Mutex
+}
+
+func (s *ndjsonEventSink) Deliver(source EventSinkSource, payload []byte, count int) bool { //nolint:golint // method is already documented in interface
+	line, err := json.Marshal(sinkRecord{
+		Time:          time.Now().UnixMilli(),
+		Environment:   source.EnvName,
+		SDKKind:       source.SDKKind,
+		SchemaVersion: source.Metadata.SchemaVersion,
+		Tags:          source.Metadata.Tags,
+		Events:        payload,
+	})
+	if err != nil {
+		s.loggers.Errorf("Unable to write %d events to events sink: %s", count, err)
+		return false
+	}
+	line = append(line, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.out.Write(line); err != nil {
+		s.loggers.Errorf("Unable to write %d events to events sink: %s", count, err)
+		return false
+	}
+	return true
+}
+
+func (s *ndjsonEventSink) Close() error { //nolint:golint // method is already documented in interface
+	if s.closer == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closer()
+}
+
+// webhookEventSink posts each payload to a URL, with the same retry behavior that is used for posting
+// events to LaunchDarkly. The credential that the events were received with is not sent.
+type webhookEventSink struct {
+	client      *http.Client
+	baseURI     string
+	path        string
+	baseHeaders http.Header
+	loggers     ldlog.Loggers
+}
+
+func newWebhookEventSink(uri url.URL, httpConfig httpconfig.HTTPConfig, loggers ldlog.Loggers) *webhookEventSink {
+	baseHeaders := make(http.Header)
+	for k, v := range httpConfig.SDKHTTPConfig.DefaultHeaders {
+		baseHeaders[k] = v
+	}
+	baseHeaders.Del("Authorization")
+	// ldevents.SendEventDataWithRetry takes a base URI and a path separately
+	path := uri.RequestURI()
+	uri.Path, uri.RawPath, uri.RawQuery = "", "", ""
+	return &webhookEventSink{
+		client:      httpConfig.Client(),
+		baseURI:     strings.TrimSuffix(uri.String(), "/"),
+		path:        path,
+		baseHeaders: baseHeaders,
+		loggers:     loggers,
+	}
+}
+
+func (s *webhookEventSink) Deliver(source EventSinkSource, payload []byte, count int) bool { //nolint:golint // method is already documented in interface
+	headers := make(http.Header)
+	for k, v := range s.baseHeaders {
+		headers[k] = v
+	}
+	headers.Set(EventSinkEnvironmentHeader, source.EnvName)
+	headers.Set(EventSinkSDKKindHeader, string(source.SDKKind))
+	if source.Metadata.Tags != "" {
+		headers.Set(TagsHeader, source.Metadata.Tags)
+	}
+	sendConfig := ldevents.EventSenderConfiguration{
+		Client:        s.client,
+		BaseURI:       s.baseURI,
+		BaseHeaders:   func() http.Header { return headers },
+		SchemaVersion: source.Metadata.SchemaVersion,
+		Loggers:       s.loggers,
+	}
+	return ldevents.SendEventDataWithRetry(sendConfig, ldevents.AnalyticsEventDataKind, s.path, payload, count).Success
+}
+
+func (s *webhookEventSink) Close() error { //nolint:golint // method is already documented in interface
+	return nil
+}
+
+// EnvironmentEventSink is an EventSink together with the name of the environment whose events it
+// receives. A nil *EnvironmentEventSink means that events are delivered to LaunchDarkly.
+type EnvironmentEventSink struct {
+	sink    EventSink
+	envName string
+}
+
+// NewEnvironmentEventSink creates an EnvironmentEventSink, or returns nil if sink is nil.
+func NewEnvironmentEventSink(sink EventSink, envName string) *EnvironmentEventSink {
+	if sink == nil {
+		return nil
+	}
+	return &EnvironmentEventSink{sink: sink, envName: envName}
+}
+
+func (s *EnvironmentEventSink) send(
+	sdkKind basictypes.SDKKind,
+	metadata EventPayloadMetadata,
+	payload []byte,
+	count int,
+) ldevents.EventSenderResult {
+	ok := s.sink.Deliver(EventSinkSource{EnvName: s.envName, SDKKind: sdkKind, Metadata: metadata}, payload, count)
+	return ldevents.EventSenderResult{Success: ok}
+}
+
+// newSender returns an ldevents.EventSender that delivers payloads to the sink, for use by an
+// ldevents.EventProcessor.
+func (s *EnvironmentEventSink) newSender(sdkKind basictypes.SDKKind, metadata EventPayloadMetadata) ldevents.EventSender {
+	return &eventSinkSender{sink: s, sdkKind: sdkKind, metadata: metadata}
+}
+
+type eventSinkSender struct {
+	sink     *EnvironmentEventSink
+	sdkKind  basictypes.SDKKind
+	metadata EventPayloadMetadata
+}
+
+func (e *eventSinkSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	if kind != ldevents.AnalyticsEventDataKind { // COVERAGE: Relay doesn't configure diagnostic events for its event processors
+		return ldevents.EventSenderResult{Success: true}
+	}
+	return e.sink.send(e.sdkKind, e.metadata, data, count)
+}
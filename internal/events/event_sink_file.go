@@ -0,0 +1,96 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func errOpeningEventSinkFile(path string, err error) error {
+	return fmt.Errorf("unable to open events sink file %q: %w", path, err)
+}
+
+// rotatingFile is an io.Writer that appends to a file, and renames the file when it would exceed a
+// maximum size, in the same way as logrotate: the current file becomes path.1, the previous path.1
+// becomes path.2, and so on, up to maxFiles old files. It is not safe for concurrent use.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errOpeningEventSinkFile(path, err)
+	}
+	f := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errOpeningEventSinkFile(f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil { // COVERAGE: can't make this happen in unit tests
+		_ = file.Close()
+		return errOpeningEventSinkFile(f.path, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends data to the file. The data is never split between two files, so a file may exceed the
+// maximum size if a single write is bigger than that.
+func (f *rotatingFile) Write(data []byte) (int, error) {
+	if f.file == nil {
+		// a previous rotation failed to reopen the file; try again
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil { // COVERAGE: can't make this happen in unit tests
+		return err
+	}
+	f.file = nil
+	_ = os.Remove(f.backupPath(f.maxFiles))
+	for i := f.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(f.backupPath(i), f.backupPath(i+1)) // the file might not exist yet
+	}
+	if err := os.Rename(f.path, f.backupPath(1)); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+// Close closes the current file.
+func (f *rotatingFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
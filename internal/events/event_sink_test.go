@@ -0,0 +1,177 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+
+	"github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sinkDelivery struct {
+	source  EventSinkSource
+	payload string
+	count   int
+}
+
+// testEventSink is an EventSink that records its deliveries.
+type testEventSink struct {
+	deliveries chan sinkDelivery
+}
+
+func newTestEventSink() *testEventSink {
+	return &testEventSink{deliveries: make(chan sinkDelivery, 10)}
+}
+
+func (s *testEventSink) Deliver(source EventSinkSource, payload []byte, count int) bool {
+	s.deliveries <- sinkDelivery{source: source, payload: string(payload), count: count}
+	return true
+}
+
+func (s *testEventSink) Close() error { return nil }
+
+func readSinkRecords(t *testing.T, data []byte) []sinkRecord {
+	var ret []sinkRecord
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var r sinkRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func TestNDJSONEventSinkWritesOneLinePerPayload(t *testing.T) {
+	var buf bytes.Buffer
+	sink := &ndjsonEventSink{out: &buf, loggers: ldlog.NewDisabledLoggers()}
+	source := EventSinkSource{
+		EnvName:  "my env",
+		SDKKind:  basictypes.MobileSDK,
+		Metadata: EventPayloadMetadata{SchemaVersion: 4, Tags: "application-id/x"},
+	}
+	require.True(t, sink.Deliver(source, []byte("[\n{\"kind\":\"custom\"}\n]"), 1))
+	require.True(t, sink.Deliver(EventSinkSource{EnvName: "other", SDKKind: basictypes.ServerSDK}, []byte(`[1,2]`), 2))
+	require.NoError(t, sink.Close())
+
+	records := readSinkRecords(t, buf.Bytes())
+	require.Len(t, records, 2)
+	assert.NotZero(t, records[0].Time)
+	assert.Equal(t, "my env", records[0].Environment)
+	assert.Equal(t, basictypes.MobileSDK, records[0].SDKKind)
+	assert.Equal(t, 4, records[0].SchemaVersion)
+	assert.Equal(t, "application-id/x", records[0].Tags)
+	assert.Equal(t, `[{"kind":"custom"}]`, string(records[0].Events))
+	assert.Equal(t, "other", records[1].Environment)
+	assert.Equal(t, `[1,2]`, string(records[1].Events))
+}
+
+func TestFileEventSinkRotatesFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "events.ndjson")
+	sink, err := NewEventSink(config.EventsConfig{
+		Sink:            config.EventsSinkFile,
+		SinkPath:        path,
+		SinkMaxFileSize: configtypes.NewOptBase2Bytes(150),
+		SinkMaxFiles:    mustOptIntGreaterThanZero(t, 2),
+	}, httpconfig.HTTPConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+
+	for _, e := range []string{`["a"]`, `["b"]`, `["c"]`, `["d"]`} {
+		require.True(t, sink.Deliver(EventSinkSource{EnvName: "env"}, []byte(e), 1))
+	}
+	require.NoError(t, sink.Close())
+
+	// each record is about 90 bytes, so each file holds one record
+	contents := func(p string) string {
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		records := readSinkRecords(t, data)
+		require.Len(t, records, 1)
+		return string(records[0].Events)
+	}
+	assert.Equal(t, `["d"]`, contents(path))
+	assert.Equal(t, `["c"]`, contents(path+".1"))
+	assert.Equal(t, `["b"]`, contents(path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestFileEventSinkAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(`{"events":["old"]}`+"\n"), 0600))
+
+	sink, err := NewEventSink(config.EventsConfig{Sink: config.EventsSinkFile, SinkPath: path},
+		httpconfig.HTTPConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.True(t, sink.Deliver(EventSinkSource{EnvName: "env"}, []byte(`["new"]`), 1))
+	require.NoError(t, sink.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	records := readSinkRecords(t, data)
+	require.Len(t, records, 2)
+	assert.Equal(t, `["old"]`, string(records[0].Events))
+	assert.Equal(t, `["new"]`, string(records[1].Events))
+}
+
+func TestWebhookEventSinkPostsPayloads(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{}, config.SDKKey("sdk-key"), "", ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		sinkURI, _ := configtypes.NewOptURLAbsoluteFromString(server.URL + "/hooks/events?source=relay")
+		sink, err := NewEventSink(config.EventsConfig{Sink: config.EventsSinkWebhook, SinkURI: sinkURI},
+			httpConfig, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer sink.Close()
+
+		source := EventSinkSource{
+			EnvName:  "my env",
+			SDKKind:  basictypes.JSClientSDK,
+			Metadata: EventPayloadMetadata{SchemaVersion: 4, Tags: "application-id/x"},
+		}
+		require.True(t, sink.Deliver(source, []byte(`["a"]`), 1))
+
+		r := helpers.RequireValue(t, requestsCh, time.Second)
+		assert.Equal(t, "POST", r.Request.Method)
+		assert.Equal(t, "/hooks/events", r.Request.URL.Path)
+		assert.Equal(t, "source=relay", r.Request.URL.RawQuery)
+		assert.Equal(t, "my env", r.Request.Header.Get(EventSinkEnvironmentHeader))
+		assert.Equal(t, "js", r.Request.Header.Get(EventSinkSDKKindHeader))
+		assert.Equal(t, "4", r.Request.Header.Get(EventSchemaHeader))
+		assert.Equal(t, "application-id/x", r.Request.Header.Get(TagsHeader))
+		assert.Equal(t, "", r.Request.Header.Get("Authorization"))
+		assert.Equal(t, `["a"]`, string(r.Body))
+	})
+}
+
+func TestWebhookEventSinkReportsFailure(t *testing.T) {
+	httphelpers.WithServer(httphelpers.HandlerWithStatus(500), func(server *httptest.Server) {
+		httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		sinkURI, _ := configtypes.NewOptURLAbsoluteFromString(server.URL)
+		sink, err := NewEventSink(config.EventsConfig{Sink: config.EventsSinkWebhook, SinkURI: sinkURI},
+			httpConfig, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+
+		assert.False(t, sink.Deliver(EventSinkSource{}, []byte(`["a"]`), 1))
+	})
+}
+
+func mustOptIntGreaterThanZero(t *testing.T, n int) configtypes.OptIntGreaterThanZero {
+	o, err := configtypes.NewOptIntGreaterThanZero(n)
+	require.NoError(t, err)
+	return o
+}
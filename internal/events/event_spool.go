@@ -0,0 +1,303 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+const (
+	spoolSegmentPrefix      = "segment-"
+	spoolSegmentSuffix      = ".ndjson"
+	spoolTempSuffix         = ".tmp"
+	maxSpoolSegmentSize     = 1024 * 1024
+	spoolSegmentsPerMaxSize = 8
+)
+
+func errOpeningSpool(dir string, err error) error {
+	return fmt.Errorf("unable to open event spool directory %q: %w", dir, err)
+}
+
+// eventSpool is a bounded queue of event payloads, stored on disk, for payloads that could not be
+// delivered. It is used by HTTPEventPublisher so that events are not lost if the events service is
+// unreachable for longer than the publisher's retry period, or if Relay is restarted before they can
+// be delivered.
+//
+// Each payload is stored as one line of JSON in a segment file. Segment files are named with an
+// increasing sequence number, so they can be replayed in the order they were written; a segment is
+// deleted once all of its payloads have been delivered. New payloads are always appended to the newest
+// segment, and a replay always starts by closing that segment for writing, so that writing and replaying
+// never touch the same file at the same time.
+//
+// If adding a payload would make the total size of the segment files exceed the configured maximum, the
+// payload is discarded.
+type eventSpool struct {
+	dir         string
+	maxSize     int64
+	segmentSize int64
+	segments    []spoolSegment // oldest first
+	totalSize   int64
+	writer      *os.File // the newest segment, if it is still open for appending
+	nextSeq     uint64
+	full        bool // used to avoid logging the same warning repeatedly
+	replaying   atomic.Bool
+	loggers     ldlog.Loggers
+	lock        sync.Mutex
+}
+
+type spoolSegment struct {
+	seq  uint64
+	size int64
+}
+
+// spooledPayload is the JSON representation of a payload in a segment file.
+type spooledPayload struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Tags          string          `json:"tags,omitempty"`
+	Count         int             `json:"count"`
+	Events        json.RawMessage `json:"events"`
+}
+
+func (p spooledPayload) metadata() EventPayloadMetadata {
+	return EventPayloadMetadata{SchemaVersion: p.SchemaVersion, Tags: p.Tags}
+}
+
+// openEventSpool creates the spool directory if necessary, and picks up any segment files that were
+// left there by a previous Relay process.
+func openEventSpool(dir string, maxSize int64, loggers ldlog.Loggers) (*eventSpool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errOpeningSpool(dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errOpeningSpool(dir, err)
+	}
+	s := &eventSpool{
+		dir:         dir,
+		maxSize:     maxSize,
+		segmentSize: min(maxSize/spoolSegmentsPerMaxSize+1, maxSpoolSegmentSize),
+		loggers:     loggers,
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, spoolTempSuffix) {
+			// left over from a replay that was interrupted; the original segment is still there
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		var seq uint64
+		if _, err := fmt.Sscanf(name, spoolSegmentPrefix+"%d"+spoolSegmentSuffix, &seq); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, errOpeningSpool(dir, err)
+		}
+		s.segments = append(s.segments, spoolSegment{seq: seq, size: info.Size()})
+		s.totalSize += info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+	if len(s.segments) != 0 {
+		s.nextSeq = s.segments[len(s.segments)-1].seq + 1
+		loggers.Infof("Found %d bytes of undelivered events in %q; they will be delivered when possible",
+			s.totalSize, dir)
+	}
+	return s, nil
+}
+
+// spoolDirHasData returns true if the directory contains any segment files.
+func spoolDirHasData(dir string) bool {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), spoolSegmentPrefix) && strings.HasSuffix(entry.Name(), spoolSegmentSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *eventSpool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%020d%s", spoolSegmentPrefix, seq, spoolSegmentSuffix))
+}
+
+// isEmpty returns true if there are no payloads waiting to be replayed.
+func (s *eventSpool) isEmpty() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.segments) == 0
+}
+
+// add stores a payload at the end of the spool. It returns false if the payload could not be stored.
+func (s *eventSpool) add(metadata EventPayloadMetadata, events json.RawMessage, count int) bool {
+	line, err := json.Marshal(spooledPayload{
+		SchemaVersion: metadata.SchemaVersion,
+		Tags:          metadata.Tags,
+		Count:         count,
+		Events:        events,
+	})
+	if err != nil { // COVERAGE: can't happen in unit tests
+		s.loggers.Errorf("Unexpected error marshalling spooled events: %s", err)
+		return false
+	}
+	line = append(line, '\n')
+	size := int64(len(line))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.totalSize+size > s.maxSize {
+		if !s.full {
+			s.loggers.Warnf("Event spool in %q has reached its maximum size of %d bytes; undeliverable events will be discarded",
+				s.dir, s.maxSize)
+			s.full = true
+		}
+		return false
+	}
+	s.full = false
+
+	if s.writer == nil || s.segments[len(s.segments)-1].size+size > s.segmentSize {
+		if err := s.startSegment(); err != nil {
+			s.loggers.Errorf("Unable to create event spool file: %s", err)
+			return false
+		}
+	}
+	_, err = s.writer.Write(line)
+	if err == nil {
+		err = s.writer.Sync()
+	}
+	if err != nil {
+		s.loggers.Errorf("Unable to write to event spool file %q: %s", s.writer.Name(), err)
+		// The file may now end with a partial line, which replay will skip; don't write to it any more.
+		s.closeWriter()
+		return false
+	}
+	s.segments[len(s.segments)-1].size += size
+	s.totalSize += size
+	return true
+}
+
+func (s *eventSpool) startSegment() error {
+	s.closeWriter()
+	f, err := os.OpenFile(s.segmentPath(s.nextSeq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.writer = f
+	s.segments = append(s.segments, spoolSegment{seq: s.nextSeq})
+	s.nextSeq++
+	return nil
+}
+
+func (s *eventSpool) closeWriter() {
+	if s.writer != nil {
+		_ = s.writer.Close()
+		s.writer = nil
+	}
+}
+
+// replay calls deliver for each stored payload, oldest first, and removes the payloads that were
+// delivered. It stops at the first payload for which deliver returns false, leaving that payload and
+// all later ones in the spool. If a replay is already in progress, it returns immediately.
+func (s *eventSpool) replay(deliver func(spooledPayload) bool) {
+	if !s.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.replaying.Store(false)
+
+	for {
+		s.lock.Lock()
+		if len(s.segments) == 0 {
+			s.lock.Unlock()
+			return
+		}
+		seg := s.segments[0]
+		if len(s.segments) == 1 {
+			s.closeWriter() // new payloads will go into a new segment
+		}
+		s.lock.Unlock()
+
+		remaining, err := s.replaySegment(seg, deliver)
+		if err != nil {
+			s.loggers.Errorf("Unable to replay event spool file %q: %s", s.segmentPath(seg.seq), err)
+			return
+		}
+		if remaining == nil {
+			s.removeFirstSegment(seg)
+			continue
+		}
+		s.replaceFirstSegment(seg, remaining)
+		return
+	}
+}
+
+// replaySegment delivers the payloads in a segment. If they are all delivered, it returns nil; otherwise
+// it returns the lines that still need to be delivered.
+func (s *eventSpool) replaySegment(seg spoolSegment, deliver func(spooledPayload) bool) ([][]byte, error) {
+	data, err := os.ReadFile(s.segmentPath(seg.seq))
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) != 0 {
+			lines = append(lines, line)
+		}
+	}
+	for i, line := range lines {
+		var payload spooledPayload
+		if err := json.Unmarshal(line, &payload); err != nil {
+			s.loggers.Warnf("Discarding malformed data in event spool file %q: %s", s.segmentPath(seg.seq), err)
+			continue
+		}
+		if !deliver(payload) {
+			return lines[i:], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *eventSpool) removeFirstSegment(seg spoolSegment) {
+	if err := os.Remove(s.segmentPath(seg.seq)); err != nil && !os.IsNotExist(err) {
+		s.loggers.Errorf("Unable to remove event spool file: %s", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.segments = s.segments[1:]
+	s.totalSize -= seg.size
+}
+
+func (s *eventSpool) replaceFirstSegment(seg spoolSegment, remaining [][]byte) {
+	data := append(bytes.Join(remaining, []byte{'\n'}), '\n')
+	path := s.segmentPath(seg.seq)
+	tempPath := path + spoolTempSuffix
+	err := os.WriteFile(tempPath, data, 0600)
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		// The segment is unchanged, so payloads that were already delivered will be delivered again.
+		s.loggers.Errorf("Unable to update event spool file %q: %s", path, err)
+		_ = os.Remove(tempPath)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.segments[0].size = int64(len(data))
+	s.totalSize += int64(len(data)) - seg.size
+}
+
+// close releases the open segment file, if any. Payloads that are still in the spool will be found by
+// openEventSpool the next time Relay starts.
+func (s *eventSpool) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closeWriter()
+}
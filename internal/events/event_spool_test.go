@@ -0,0 +1,106 @@
+package events
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestSpool(t *testing.T, dir string, maxSize int64) *eventSpool {
+	s, err := openEventSpool(dir, maxSize, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	t.Cleanup(s.close)
+	return s
+}
+
+func replayAll(s *eventSpool) []spooledPayload {
+	var ret []spooledPayload
+	s.replay(func(p spooledPayload) bool {
+		ret = append(ret, p)
+		return true
+	})
+	return ret
+}
+
+func TestEventSpoolReplaysPayloadsInOrder(t *testing.T) {
+	s := openTestSpool(t, t.TempDir(), 1000000)
+	s.segmentSize = 50 // so that the payloads are spread across several segments
+	for i := 0; i < 5; i++ {
+		require.True(t, s.add(EventPayloadMetadata{SchemaVersion: 4, Tags: "t"}, json.RawMessage(`["`+string(rune('a'+i))+`"]`), 1))
+	}
+	assert.Greater(t, len(s.segments), 1)
+
+	replayed := replayAll(s)
+	require.Len(t, replayed, 5)
+	for i, p := range replayed {
+		assert.Equal(t, `["`+string(rune('a'+i))+`"]`, string(p.Events))
+		assert.Equal(t, EventPayloadMetadata{SchemaVersion: 4, Tags: "t"}, p.metadata())
+		assert.Equal(t, 1, p.Count)
+	}
+	assert.True(t, s.isEmpty())
+	assert.Equal(t, int64(0), s.totalSize)
+
+	files, _ := os.ReadDir(s.dir)
+	assert.Len(t, files, 0)
+}
+
+func TestEventSpoolKeepsPayloadsThatWereNotDelivered(t *testing.T) {
+	s := openTestSpool(t, t.TempDir(), 1000000)
+	for _, e := range []string{`["a"]`, `["b"]`, `["c"]`} {
+		require.True(t, s.add(EventPayloadMetadata{}, json.RawMessage(e), 1))
+	}
+
+	var attempted []string
+	s.replay(func(p spooledPayload) bool {
+		attempted = append(attempted, string(p.Events))
+		return string(p.Events) != `["b"]`
+	})
+	assert.Equal(t, []string{`["a"]`, `["b"]`}, attempted)
+	assert.False(t, s.isEmpty())
+
+	require.True(t, s.add(EventPayloadMetadata{}, json.RawMessage(`["d"]`), 1))
+
+	var remaining []string
+	for _, p := range replayAll(s) {
+		remaining = append(remaining, string(p.Events))
+	}
+	assert.Equal(t, []string{`["b"]`, `["c"]`, `["d"]`}, remaining)
+}
+
+func TestEventSpoolIsPreservedWhenReopened(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	s1 := openTestSpool(t, dir, 1000000)
+	require.True(t, s1.add(EventPayloadMetadata{}, json.RawMessage(`["a"]`), 1))
+	s1.close()
+	assert.True(t, spoolDirHasData(dir))
+
+	s2 := openTestSpool(t, dir, 1000000)
+	require.True(t, s2.add(EventPayloadMetadata{}, json.RawMessage(`["b"]`), 1))
+
+	replayed := replayAll(s2)
+	require.Len(t, replayed, 2)
+	assert.Equal(t, `["a"]`, string(replayed[0].Events))
+	assert.Equal(t, `["b"]`, string(replayed[1].Events))
+	assert.False(t, spoolDirHasData(dir))
+}
+
+func TestEventSpoolDiscardsPayloadsWhenFull(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	s, err := openEventSpool(t.TempDir(), 100, mockLog.Loggers)
+	require.NoError(t, err)
+	defer s.close()
+
+	require.True(t, s.add(EventPayloadMetadata{}, json.RawMessage(`["a"]`), 1))
+	assert.False(t, s.add(EventPayloadMetadata{}, json.RawMessage(`["`+strings.Repeat("x", 100)+`"]`), 1))
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "reached its maximum size of 100 bytes")
+
+	assert.Len(t, replayAll(s), 1)
+}
@@ -0,0 +1,148 @@
+package oldevents
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+)
+
+// This file contains the types and logic for events in the current schema, which SDKs that support
+// contexts send if they are configured not to summarize events. Those SDKs send "feature",
+// "custom", and "identify" events in the same form as the older schemas (except as described
+// below), so UnmarshalEvent parses them the same way; the event kinds that only exist in the
+// current schema are parsed here.
+//
+// Translating events that have "contextKeys"
+//
+// A current-schema "feature" or "custom" event may have a "contextKeys" property, a map of context
+// kinds to keys, instead of an inline context. We build a context with only those kinds and keys to
+// pass to the event processor. Its other properties are only used in index events; if the SDK has
+// sent an index event for the context, the summarizing logic in the parent package substitutes the
+// context from that event, so that an index event that the event processor generates later still
+// has them.
+//
+// Translating "index" events
+//
+// An index event from the SDK is the only source of the full properties of a context that the SDK's
+// other events refer to by key, so it is passed through unchanged. The event processor may also
+// generate an index event for the same context, containing only the context keys; the summarizing
+// logic in the parent package removes that one as a duplicate, since the SDK's index event precedes
+// it in the output.
+//
+// Translating "summary" and "migration_op" events
+//
+// These cannot be fed into the event processor, which only produces summary events from
+// evaluations, and would not change migration_op events in any way. So they are passed through
+// unchanged, except that a migration_op event is dropped if it does not have the context keys and
+// flag key that LaunchDarkly requires.
+
+var errMigrationEventHadNoFlagKey = errors.New("received a migration_op event with no evaluation key")
+
+const (
+	indexKind       = "index"
+	summaryKind     = "summary"
+	migrationOpKind = "migration_op"
+)
+
+// IndexEvent represents an "index" event in current-schema event data, which an SDK that does not
+// summarize events sends to provide the full properties of a context that other events refer to by
+// key.
+//
+// See package comments in package_info.go for more details on how this is processed.
+type IndexEvent struct {
+	actualContext ldevents.EventInputContext
+	CreationDate  ldtime.UnixMillisecondTime `json:"creationDate"`
+	Context       *ReceivedEventContext      `json:"context"`
+}
+
+func (e IndexEvent) Kind() string { return indexKind } //nolint:golint
+
+// ContextKey returns the fully-qualified key of the event's context.
+func (e IndexEvent) ContextKey() string { return e.Context.fullyQualifiedKey }
+
+// SummaryEvent represents a "summary" event in current-schema event data.
+//
+// See package comments in package_info.go for more details on how this is processed.
+type SummaryEvent struct {
+	StartDate ldtime.UnixMillisecondTime `json:"startDate"`
+	EndDate   ldtime.UnixMillisecondTime `json:"endDate"`
+	Features  map[string]ldvalue.Value   `json:"features"`
+}
+
+func (e SummaryEvent) Kind() string { return summaryKind } //nolint:golint
+
+// MigrationOpEvent represents a "migration_op" event in current-schema event data, which describes
+// one operation performed by an SDK's migration support. Only the properties that Relay needs to
+// know about are parsed.
+//
+// See package comments in package_info.go for more details on how this is processed.
+type MigrationOpEvent struct {
+	actualContext ldevents.EventInputContext
+	CreationDate  ldtime.UnixMillisecondTime `json:"creationDate"`
+	ContextKeys   map[string]string          `json:"contextKeys"`
+	Operation     string                     `json:"operation"`
+	Evaluation    struct {
+		Key     string              `json:"key"`
+		Version ldvalue.OptionalInt `json:"version"`
+	} `json:"evaluation"`
+}
+
+func (e MigrationOpEvent) Kind() string { return migrationOpKind } //nolint:golint
+
+// unmarshalCurrentSchemaEvent is called by UnmarshalEvent for event kinds that only exist in the
+// current schema. It returns false if the kind is not one of those.
+func unmarshalCurrentSchemaEvent(kind string, rawEvent []byte) (OldEvent, bool, error) {
+	var err error
+	switch kind {
+	case indexKind:
+		var e IndexEvent
+		err = json.Unmarshal(rawEvent, &e)
+		if err == nil {
+			if e.Context == nil {
+				err = errEventHadNoUserOrContext
+			} else {
+				e.actualContext = e.Context.EventInputContext
+			}
+		}
+		return e, true, err
+
+	case summaryKind:
+		var e SummaryEvent
+		err = json.Unmarshal(rawEvent, &e)
+		return e, true, err
+
+	case migrationOpKind:
+		var e MigrationOpEvent
+		err = json.Unmarshal(rawEvent, &e)
+		if err == nil {
+			if len(e.ContextKeys) == 0 {
+				err = errEventHadNoUserOrContext
+			} else {
+				var context ldcontext.Context
+				context, err = contextFromKeys(e.ContextKeys)
+				e.actualContext = ldevents.Context(context)
+			}
+		}
+		if err == nil && e.Evaluation.Key == "" {
+			err = errMigrationEventHadNoFlagKey
+		}
+		return e, true, err
+	}
+	return nil, false, nil
+}
+
+// contextFromKeys builds a context that has only kinds and keys, for a current-schema event that
+// refers to its context by the "contextKeys" property. The full properties of the context, if the
+// SDK reported them, are in an index event.
+func contextFromKeys(contextKeys map[string]string) (ldcontext.Context, error) {
+	builder := ldcontext.NewMultiBuilder()
+	for kind, key := range contextKeys {
+		builder.Add(ldcontext.NewWithKind(ldcontext.Kind(kind), key))
+	}
+	context := builder.Build()
+	return context, context.Err()
+}
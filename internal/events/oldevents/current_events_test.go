@@ -0,0 +1,86 @@
+package oldevents
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalIndexEvent(t *testing.T) {
+	t.Run("with context", func(t *testing.T) {
+		contextJSON := `{"kind": "x", "key": "a", "name": "b"}`
+		e, err := UnmarshalEvent([]byte(`{"kind": "index", "creationDate": 1000, "context": ` + contextJSON + `}`))
+		require.NoError(t, err)
+		require.IsType(t, IndexEvent{}, e)
+		ie := e.(IndexEvent)
+		assert.Equal(t, indexKind, ie.Kind())
+		assert.Equal(t, fakeTime, ie.CreationDate)
+		assert.Equal(t, ldevents.PreserializedContext(ldcontext.NewWithKind("x", "a"), []byte(contextJSON)), ie.actualContext)
+	})
+
+	t.Run("no context", func(t *testing.T) {
+		_, err := UnmarshalEvent([]byte(`{"kind": "index", "creationDate": 1000}`))
+		assert.Equal(t, errEventHadNoUserOrContext, err)
+	})
+}
+
+func TestUnmarshalSummaryEvent(t *testing.T) {
+	eventJSON := `{
+		"kind": "summary",
+		"startDate": 1000,
+		"endDate": 2000,
+		"features": {
+			"flagkey": {"default": "c", "counters": [{"variation": 1, "version": 11, "value": "b", "count": 1}]}
+		}
+	}`
+	e, err := UnmarshalEvent([]byte(eventJSON))
+	require.NoError(t, err)
+	require.IsType(t, SummaryEvent{}, e)
+	se := e.(SummaryEvent)
+	assert.Equal(t, summaryKind, se.Kind())
+	assert.Equal(t, fakeTime, se.StartDate)
+	assert.Equal(t, ldtime.UnixMillisecondTime(2000), se.EndDate)
+	assert.Len(t, se.Features, 1)
+	assert.Equal(t, ldvalue.String("c"), se.Features["flagkey"].GetByKey("default"))
+}
+
+func TestUnmarshalMigrationOpEvent(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		eventJSON := `{
+			"kind": "migration_op",
+			"creationDate": 1000,
+			"contextKeys": {"user": "a"},
+			"operation": "read",
+			"evaluation": {"key": "flagkey", "value": "off", "default": "off", "version": 3, "reason": {"kind": "FALLTHROUGH"}},
+			"measurements": [{"key": "invoked", "values": {"old": true}}]
+		}`
+		e, err := UnmarshalEvent([]byte(eventJSON))
+		require.NoError(t, err)
+		require.IsType(t, MigrationOpEvent{}, e)
+		me := e.(MigrationOpEvent)
+		assert.Equal(t, migrationOpKind, me.Kind())
+		assert.Equal(t, fakeTime, me.CreationDate)
+		assert.Equal(t, ldevents.Context(ldcontext.New("a")), me.actualContext)
+		assert.Equal(t, "read", me.Operation)
+		assert.Equal(t, "flagkey", me.Evaluation.Key)
+		assert.Equal(t, ldvalue.NewOptionalInt(3), me.Evaluation.Version)
+	})
+
+	t.Run("no context keys", func(t *testing.T) {
+		eventJSON := `{"kind": "migration_op", "creationDate": 1000, "operation": "read", "evaluation": {"key": "flagkey"}}`
+		_, err := UnmarshalEvent([]byte(eventJSON))
+		assert.Equal(t, errEventHadNoUserOrContext, err)
+	})
+
+	t.Run("no flag key", func(t *testing.T) {
+		eventJSON := `{"kind": "migration_op", "creationDate": 1000, "contextKeys": {"user": "a"}, "operation": "read"}`
+		_, err := UnmarshalEvent([]byte(eventJSON))
+		assert.Equal(t, errMigrationEventHadNoFlagKey, err)
+	})
+}
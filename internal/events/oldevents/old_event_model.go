@@ -11,7 +11,7 @@ import (
 	ldevents "github.com/launchdarkly/go-sdk-events/v3"
 )
 
-var errEventHadNoUserOrContext = errors.New("received an event with no user or context property")
+var errEventHadNoUserOrContext = errors.New("received an event with no user, context, or contextKeys property")
 
 const (
 	featureKind  = "feature"
@@ -25,6 +25,7 @@ const (
 // removed. So, we use the special "preserialized" mode that tells ldevents to treat this as a raw blob.
 type ReceivedEventContext struct {
 	ldevents.EventInputContext
+	fullyQualifiedKey string
 }
 
 // UnmarshalJSON captures the original JSON user data and treats it as a context.
@@ -35,6 +36,7 @@ func (r *ReceivedEventContext) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	r.EventInputContext = ldevents.PreserializedContext(minimalContext, data)
+	r.fullyQualifiedKey = minimalContext.FullyQualifiedKey()
 	return nil
 }
 
@@ -43,15 +45,17 @@ type OldEvent interface {
 	Kind() string
 }
 
-// FeatureEvent represents a "feature" (evaluation) event in old event data.
+// FeatureEvent represents a "feature" (evaluation) event in old or current-schema event data.
 //
 // See package comments in package_info.go for more details on how this differs from the new model.
 type FeatureEvent struct {
 	actualContext        ldevents.EventInputContext
+	keysOnlyContextKey   string
 	CreationDate         ldtime.UnixMillisecondTime `json:"creationDate"`
 	Key                  string                     `json:"key"`
 	User                 *ReceivedEventContext      `json:"user"`
 	Context              *ReceivedEventContext      `json:"context"`
+	ContextKeys          map[string]string          `json:"contextKeys"`
 	Version              ldvalue.OptionalInt        `json:"version"`
 	Variation            ldvalue.OptionalInt        `json:"variation"`
 	Value                ldvalue.Value              `json:"value"`
@@ -65,6 +69,18 @@ type FeatureEvent struct {
 
 func (e FeatureEvent) Kind() string { return featureKind } //nolint:golint
 
+// KeysOnlyContextKey returns the fully-qualified key of the event's context if the event referred to it
+// only with a "contextKeys" property, or "" otherwise.
+func (e FeatureEvent) KeysOnlyContextKey() string { return e.keysOnlyContextKey }
+
+// WithContext returns a copy of the event that refers to the specified context instead of the one from
+// its own properties. This is used for the full properties of a context that the event referred to only
+// by its keys.
+func (e FeatureEvent) WithContext(context ReceivedEventContext) FeatureEvent {
+	e.actualContext = context.EventInputContext
+	return e
+}
+
 // IdentifyEvent represents an "identify" event in old event data.
 //
 // See package comments in package_info.go for more details on how this differs from the new model.
@@ -77,21 +93,32 @@ type IdentifyEvent struct {
 
 func (e IdentifyEvent) Kind() string { return identifyKind } //nolint:golint
 
-// CustomEvent represents a "custom" event in old event data.
+// CustomEvent represents a "custom" event in old or current-schema event data.
 //
 // See package comments in package_info.go for more details on how this differs from the new model.
 type CustomEvent struct {
-	actualContext ldevents.EventInputContext
-	CreationDate  ldtime.UnixMillisecondTime `json:"creationDate"`
-	Key           string                     `json:"key"`
-	User          *ReceivedEventContext      `json:"user"`
-	Context       *ReceivedEventContext      `json:"context"`
-	Data          ldvalue.Value              `json:"data"`
-	MetricValue   *float64                   `json:"metricValue"`
+	actualContext      ldevents.EventInputContext
+	keysOnlyContextKey string
+	CreationDate       ldtime.UnixMillisecondTime `json:"creationDate"`
+	Key                string                     `json:"key"`
+	User               *ReceivedEventContext      `json:"user"`
+	Context            *ReceivedEventContext      `json:"context"`
+	ContextKeys        map[string]string          `json:"contextKeys"`
+	Data               ldvalue.Value              `json:"data"`
+	MetricValue        *float64                   `json:"metricValue"`
 }
 
 func (e CustomEvent) Kind() string { return customKind } //nolint:golint
 
+// KeysOnlyContextKey is the same as FeatureEvent.KeysOnlyContextKey.
+func (e CustomEvent) KeysOnlyContextKey() string { return e.keysOnlyContextKey }
+
+// WithContext is the same as FeatureEvent.WithContext.
+func (e CustomEvent) WithContext(context ReceivedEventContext) CustomEvent {
+	e.actualContext = context.EventInputContext
+	return e
+}
+
 // UntranslatedEvent represents an event that we do not implement any special processing for.
 type UntranslatedEvent struct {
 	RawEvent []byte
@@ -1,7 +1,8 @@
 // Package oldevents contains types and logic for processing event data from older SDKs that use
-// obsolete event schemas. The "summarizing" logic in the parent package uses this code to transform
-// the event data, converting it into parameters that are then fed into the same event processor
-// that is used by the Go SDK.
+// obsolete event schemas, and from newer SDKs that use the current schema but send each event as it
+// happens rather than summarizing them. The "summarizing" logic in the parent package uses this
+// code to transform the event data, converting it into parameters that are then fed into the same
+// event processor that is used by the Go SDK.
 //
 // The schema variants we need to handle are:
 //
@@ -11,6 +12,10 @@
 //
 // 2. Version 2 - from PHP SDK 3.1.0 and later. This includes a bit more information.
 //
+// 3. The current schema - from SDKs that support contexts and are configured to send unsummarized
+// events. Processing of the event kinds and properties that only exist in this schema is described
+// in current_events.go.
+//
 // Regardless of the schema version, one constant is that if an event has a "user" property, we take
 // the user properties and do not transform them in any way-- because we assume the SDK has already
 // done so, based on its own configuration (e.g. if there were private attributes to be removed,
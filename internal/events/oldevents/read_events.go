@@ -25,13 +25,26 @@ func UnmarshalEvent(rawEvent []byte) (OldEvent, error) {
 
 	var err error
 	var ret OldEvent
-	contextOrUser := func(context *ReceivedEventContext, user *ReceivedEventContext) (ldevents.EventInputContext, error) {
+	var keysOnlyContextKey string // set if contextOrUser used the "contextKeys" property
+	contextOrUser := func(
+		context *ReceivedEventContext,
+		user *ReceivedEventContext,
+		contextKeys map[string]string,
+	) (ldevents.EventInputContext, error) {
 		if context != nil {
 			return context.EventInputContext, nil
 		}
 		if user != nil {
 			return user.EventInputContext, nil
 		}
+		if len(contextKeys) != 0 {
+			context, err := contextFromKeys(contextKeys)
+			if err != nil {
+				return ldevents.EventInputContext{}, err
+			}
+			keysOnlyContextKey = context.FullyQualifiedKey()
+			return ldevents.Context(context), nil
+		}
 		return ldevents.EventInputContext{}, errEventHadNoUserOrContext
 	}
 
@@ -40,7 +53,8 @@ func UnmarshalEvent(rawEvent []byte) (OldEvent, error) {
 		var e FeatureEvent
 		err = json.Unmarshal(rawEvent, &e)
 		if err == nil {
-			e.actualContext, err = contextOrUser(e.Context, e.User)
+			e.actualContext, err = contextOrUser(e.Context, e.User, e.ContextKeys)
+			e.keysOnlyContextKey = keysOnlyContextKey
 		}
 		ret = e
 
@@ -48,7 +62,8 @@ func UnmarshalEvent(rawEvent []byte) (OldEvent, error) {
 		var e CustomEvent
 		err = json.Unmarshal(rawEvent, &e)
 		if err == nil {
-			e.actualContext, err = contextOrUser(e.Context, e.User)
+			e.actualContext, err = contextOrUser(e.Context, e.User, e.ContextKeys)
+			e.keysOnlyContextKey = keysOnlyContextKey
 		}
 		ret = e
 
@@ -56,12 +71,15 @@ func UnmarshalEvent(rawEvent []byte) (OldEvent, error) {
 		var e IdentifyEvent
 		err = json.Unmarshal(rawEvent, &e)
 		if err == nil {
-			e.actualContext, err = contextOrUser(e.Context, e.User)
+			e.actualContext, err = contextOrUser(e.Context, e.User, nil)
 		}
 		ret = e
 
 	default:
-		ret = UntranslatedEvent{kind: kindFieldOnly.Kind, RawEvent: rawEvent}
+		var ok bool
+		if ret, ok, err = unmarshalCurrentSchemaEvent(kindFieldOnly.Kind, rawEvent); !ok {
+			ret = UntranslatedEvent{kind: kindFieldOnly.Kind, RawEvent: rawEvent}
+		}
 	}
 
 	return ret, err
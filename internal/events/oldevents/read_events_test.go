@@ -84,6 +84,26 @@ func TestUnmarshalFeatureEvent(t *testing.T) {
 		_, err := UnmarshalEvent([]byte(eventJSON))
 		assert.Equal(t, errEventHadNoUserOrContext, err)
 	})
+
+	t.Run("context keys", func(t *testing.T) {
+		eventJSON := `{
+			"kind": "feature",
+			"creationDate": 1000,
+			"key": "flagkey",
+			"contextKeys": {"user": "a", "org": "b"},
+			"value": "v",
+			"samplingRatio": 2,
+			"excludeFromSummaries": true
+		}`
+		e, err := UnmarshalEvent([]byte(eventJSON))
+		require.NoError(t, err)
+		require.IsType(t, FeatureEvent{}, e)
+		fe := e.(FeatureEvent)
+		assert.Equal(t, ldevents.Context(ldcontext.NewMulti(ldcontext.New("a"), ldcontext.NewWithKind("org", "b"))),
+			fe.actualContext)
+		assert.Equal(t, ldvalue.NewOptionalInt(2), fe.SamplingRatio)
+		assert.Equal(t, ldvalue.NewOptionalBool(true), fe.ExcludeFromSummaries)
+	})
 }
 
 func TestUnmarshalIdentifyEvent(t *testing.T) {
@@ -168,6 +188,32 @@ func TestUnmarshalCustomEvent(t *testing.T) {
 		_, err := UnmarshalEvent([]byte(eventJSON))
 		assert.Equal(t, errEventHadNoUserOrContext, err)
 	})
+
+	t.Run("context keys", func(t *testing.T) {
+		eventJSON := `{
+			"kind": "custom",
+			"creationDate": 1000,
+			"key": "eventkey",
+			"contextKeys": {"user": "a"}
+		}`
+		e, err := UnmarshalEvent([]byte(eventJSON))
+		require.NoError(t, err)
+		require.IsType(t, CustomEvent{}, e)
+		ce := e.(CustomEvent)
+		assert.Equal(t, "eventkey", ce.Key)
+		assert.Equal(t, ldevents.Context(ldcontext.New("a")), ce.actualContext)
+	})
+
+	t.Run("invalid context keys", func(t *testing.T) {
+		eventJSON := `{
+			"kind": "custom",
+			"creationDate": 1000,
+			"key": "eventkey",
+			"contextKeys": {"kind": "a"}
+		}`
+		_, err := UnmarshalEvent([]byte(eventJSON))
+		assert.Error(t, err)
+	})
 }
 
 func TestUnmarshalOtherEvent(t *testing.T) {
@@ -214,6 +214,72 @@ func makeAllSummarizeEventsParams() []summarizeEventsParams {
 				}
 			]`, // unchanged
 		},
+		{
+			name:          "current-schema events that refer to a context by key",
+			schemaVersion: 4,
+			inputEventsJSON: `
+			[
+				{ "kind": "index", "creationDate": 1000, "context": {"kind": "user", "key": "userkey", "name": "Lucy"} },
+				{ "kind": "feature", "creationDate": 1000, "key": "flagkey", "contextKeys": {"user": "userkey"},
+				"value": "b", "default": "c", "version": 11, "variation": 1, "trackEvents": false },
+				{ "kind": "custom", "creationDate": 1000, "key": "eventkey1", "contextKeys": {"user": "userkey"} }
+			]`,
+			expectedEventsJSON: `
+			[
+				{ "kind": "index", "creationDate": 1000, "context": {"kind": "user", "key": "userkey", "name": "Lucy"} },
+				{ "kind": "custom", "creationDate": 1000, "key": "eventkey1", "contextKeys": {"user": "userkey"} },
+				{
+					"kind": "summary", "startDate": 1000, "endDate": 1000,
+					"features": {
+						"flagkey": {
+							"default": "c", "contextKinds": ["user"],
+							"counters": [{"variation": 1, "version": 11, "value": "b", "count": 1}]
+						}
+					}
+				}
+			]`, // the index event generated by the event processor for "userkey" is removed as a duplicate
+		},
+		{
+			name:          "current-schema summary and migration_op events",
+			schemaVersion: 4,
+			inputEventsJSON: `
+			[
+				{
+					"kind": "summary", "startDate": 1000, "endDate": 2000,
+					"features": {
+						"otherflag": {
+							"default": "x", "contextKinds": ["user"],
+							"counters": [{"variation": 0, "version": 1, "value": "y", "count": 3}]
+						}
+					}
+				},
+				{
+					"kind": "migration_op", "creationDate": 1000, "contextKeys": {"user": "userkey"}, "operation": "read",
+					"evaluation": {"key": "migrationflag", "value": "off", "default": "off", "reason": {"kind": "FALLTHROUGH"}},
+					"measurements": [{"key": "invoked", "values": {"old": true}}]
+				},
+				{
+					"kind": "migration_op", "creationDate": 1000, "operation": "read", "evaluation": {"key": "migrationflag"}
+				}
+			]`,
+			expectedEventsJSON: `
+			[
+				{
+					"kind": "summary", "startDate": 1000, "endDate": 2000,
+					"features": {
+						"otherflag": {
+							"default": "x", "contextKinds": ["user"],
+							"counters": [{"variation": 0, "version": 1, "value": "y", "count": 3}]
+						}
+					}
+				},
+				{
+					"kind": "migration_op", "creationDate": 1000, "contextKeys": {"user": "userkey"}, "operation": "read",
+					"evaluation": {"key": "migrationflag", "value": "off", "default": "off", "reason": {"kind": "FALLTHROUGH"}},
+					"measurements": [{"key": "invoked", "values": {"old": true}}]
+				}
+			]`, // unchanged, except that the migration_op event with no context keys is removed
+		},
 		{
 			name: "unparseable events are removed",
 			inputEventsJSON: `
@@ -1,16 +1,17 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"reflect"
 	"sync"
 	"time"
 
-	"github.com/launchdarkly/go-server-sdk/v7/ldcomponents"
 	"github.com/launchdarkly/ld-relay/v8/internal/credential"
 
 	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/events/oldevents"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
 	"github.com/launchdarkly/ld-relay/v8/internal/store"
@@ -29,7 +30,9 @@ import (
 //
 // Like HTTPEventPublisher, this supports proxying events in separate payloads if we received them
 // with different request metadata (e.g. tags). To do this, we have to maintain a separate
-// EventProcessor instance for each unique metadata set we've seen.
+// EventProcessor instance for each unique metadata set we've seen. Each EventProcessor generates index
+// events for the contexts it has not seen recently, so the EventProcessors share a contextKeyCache that
+// removes index events for contexts that another one has already reported.
 type eventSummarizingRelay struct {
 	queues       map[EventPayloadMetadata]*eventSummarizingRelayQueue
 	authKey      credential.SDKCredential
@@ -37,10 +40,19 @@ type eventSummarizingRelay struct {
 	baseHeaders  http.Header
 	storeAdapter *store.SSERelayDataStoreAdapter
 	eventsConfig ldevents.EventsConfiguration
+	contextKeys  *contextKeyCache
+	indexed      *indexedContextCache
 	baseURI      string
 	remotePath   string
+	sink         *EnvironmentEventSink
+	destinations []*EventDestination
+	inspector    *EventInspector
+	otlp         *EnvironmentOTLPExporter
+	sdkKind      basictypes.SDKKind
 	loggers      ldlog.Loggers
+	metricsCtx   context.Context
 	closer       chan struct{}
+	closed       chan struct{}
 	lock         sync.Mutex
 	closeOnce    sync.Once
 }
@@ -64,14 +76,15 @@ func newEventSummarizingRelay(
 	storeAdapter *store.SSERelayDataStoreAdapter,
 	loggers ldlog.Loggers,
 	remotePath string,
-	eventQueueCleanupInterval time.Duration,
+	sdkKind basictypes.SDKKind,
+	options EventDispatcherOptions,
 ) *eventSummarizingRelay {
 	eventsConfig := ldevents.EventsConfiguration{
 		Capacity:              config.Capacity.GetOrElse(c.DefaultEventCapacity),
 		FlushInterval:         config.FlushInterval.GetOrElse(c.DefaultEventsFlushInterval),
 		Loggers:               loggers,
-		UserKeysCapacity:      ldcomponents.DefaultContextKeysCapacity,
-		UserKeysFlushInterval: ldcomponents.DefaultContextKeysFlushInterval,
+		UserKeysCapacity:      config.ContextKeysCapacity.GetOrElse(c.DefaultEventsContextKeysCapacity),
+		UserKeysFlushInterval: config.ContextKeysFlushInterval.GetOrElse(c.DefaultEventsContextKeysFlushInterval),
 	}
 	baseHeaders := make(http.Header)
 	for k, v := range httpConfig.SDKHTTPConfig.DefaultHeaders {
@@ -81,20 +94,31 @@ func newEventSummarizingRelay(
 	er := &eventSummarizingRelay{
 		queues:       make(map[EventPayloadMetadata]*eventSummarizingRelayQueue),
 		authKey:      credential,
-		httpClient:   httpConfig.SDKHTTPConfig.CreateHTTPClient(),
+		httpClient:   withDeliveryMetrics(options.MetricsContext, httpConfig.SDKHTTPConfig.CreateHTTPClient()),
 		baseHeaders:  baseHeaders,
 		storeAdapter: storeAdapter,
 		eventsConfig: eventsConfig,
+		contextKeys:  newContextKeyCache(eventsConfig.UserKeysCapacity, eventsConfig.UserKeysFlushInterval),
+		indexed:      newIndexedContextCache(eventsConfig.UserKeysCapacity),
 		baseURI:      getEventsURI(config),
 		remotePath:   remotePath,
+		sink:         options.Sink,
+		destinations: options.Destinations,
+		inspector:    options.Inspector,
+		otlp:         options.OTLP,
+		sdkKind:      sdkKind,
 		loggers:      loggers,
+		metricsCtx:   options.MetricsContext,
 		closer:       make(chan struct{}),
+		closed:       make(chan struct{}),
 	}
-	go er.runPeriodicCleanupTaskUntilClosed(eventQueueCleanupInterval)
+	go er.runPeriodicCleanupTaskUntilClosed(options.EventQueueCleanupInterval)
 	return er
 }
 
-func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvents []json.RawMessage) {
+// enqueue processes events that an SDK sent in a request with the specified traceparent header. If there
+// is an OpenTelemetry exporter, the events are exported to it after they are translated.
+func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvents []json.RawMessage, traceParent string) {
 	er.lock.Lock()
 	if er.queues == nil {
 		// this instance has been shut down
@@ -104,7 +128,7 @@ func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvent
 	queue := er.queues[metadata]
 	if queue == nil {
 		sender := &delegatingEventSender{
-			wrapped: makeEventSender(er.httpClient, er.baseURI, er.remotePath, er.baseHeaders, er.authKey, metadata, er.loggers),
+			wrapped: er.newEventSender(er.authKey, metadata),
 		}
 		eventsConfig := er.eventsConfig
 		eventsConfig.EventSender = sender
@@ -114,18 +138,25 @@ func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvent
 			eventProcessor: ldevents.NewDefaultEventProcessor(eventsConfig),
 		}
 		er.queues[metadata] = queue
+		recordEventMetric(er.metricsCtx, eventSummarizingQueuesMeasure.M(1))
 	}
 	queue.active = true // see runPeriodicCleanupTaskUntilClosed()
 	er.lock.Unlock()
 
+	var otlpRecords []otlpEventRecord
+	if er.otlp != nil {
+		otlpRecords = make([]otlpEventRecord, 0, len(rawEvents))
+	}
 	for _, rawEvent := range rawEvents {
 		oldEvent, err := oldevents.UnmarshalEvent(rawEvent)
 		if err != nil {
 			er.loggers.Errorf("Error in event processing, event was discarded: %s", err)
+			recordEventMetric(er.metricsCtx, eventParseFailuresMeasure.M(1))
 			continue
 		}
-		_ = er.dispatchEvent(queue.eventProcessor, oldEvent, rawEvent, metadata.SchemaVersion)
+		_ = er.dispatchEvent(queue.eventProcessor, oldEvent, rawEvent, metadata.SchemaVersion, &otlpRecords)
 	}
+	er.otlp.exportRecords(er.sdkKind, traceParent, otlpRecords)
 }
 
 func (er *eventSummarizingRelay) flush() { //nolint:unused // used only in tests
@@ -146,33 +177,85 @@ func (er *eventSummarizingRelay) replaceCredential(newCredential credential.SDKC
 		er.authKey = newCredential
 		for metadata, queue := range er.queues {
 			// See comment on makeEventSender() about why we create a new one in this situation.
-			sender := makeEventSender(er.httpClient, er.baseURI, er.remotePath, er.baseHeaders, newCredential, metadata, er.loggers)
-			queue.eventSender.setWrapped(sender)
+			queue.eventSender.setWrapped(er.newEventSender(newCredential, metadata))
 		}
 	}
 	er.lock.Unlock()
 }
 
+// newEventSender creates the EventSender for the event processor that handles events with the specified
+// metadata. This delivers to the events sink if there is one, or else to the events service, and also to
+// any summarized event destinations, after removing any index events that are redundant because of
+// events with other metadata.
+func (er *eventSummarizingRelay) newEventSender(
+	authKey credential.SDKCredential,
+	metadata EventPayloadMetadata,
+) ldevents.EventSender {
+	// The event processor produces events in the current schema, regardless of what it received.
+	outputMetadata := EventPayloadMetadata{SchemaVersion: CurrentEventsSchemaVersion, Tags: metadata.Tags}
+	var sender ldevents.EventSender
+	if er.sink != nil {
+		sender = er.sink.newSender(er.sdkKind, outputMetadata)
+	} else {
+		sender = makeEventSender(er.httpClient, er.baseURI, er.remotePath, er.baseHeaders, authKey, metadata, er.loggers)
+	}
+	if er.metricsCtx != nil {
+		sender = &flushMetricsEventSender{wrapped: sender, metricsCtx: er.metricsCtx}
+	}
+	if hasEventDestinations(er.destinations, false) {
+		sender = &fanOutEventSender{
+			primary:      sender,
+			destinations: er.destinations,
+			remotePath:   er.remotePath,
+			metadata:     outputMetadata,
+		}
+	}
+	if er.inspector != nil {
+		sender = &inspectingEventSender{
+			wrapped:    sender,
+			inspector:  er.inspector,
+			sdkKind:    er.sdkKind,
+			remotePath: er.remotePath,
+			authKey:    authKey,
+			metadata:   outputMetadata,
+		}
+	}
+	return &indexDeduplicatingEventSender{wrapped: sender, keys: er.contextKeys}
+}
+
 func (er *eventSummarizingRelay) dispatchEvent(
 	ep ldevents.EventProcessor,
 	oldEvent oldevents.OldEvent,
 	rawEvent []byte,
 	schemaVersion int,
+	otlpRecords *[]otlpEventRecord, // nil slice if there is no OpenTelemetry exporter
 ) error {
 	switch e := oldEvent.(type) {
 	case oldevents.FeatureEvent:
+		if context, ok := er.indexed.get(e.KeysOnlyContextKey()); ok {
+			e = e.WithContext(context)
+		}
 		evalData, err := oldevents.TranslateFeatureEvent(e, schemaVersion, er.storeAdapter.GetStore())
 		if err != nil {
 			return err
 		}
 		ep.RecordEvaluation(evalData)
+		if *otlpRecords != nil {
+			*otlpRecords = append(*otlpRecords, otlpRecordFromEvaluation(evalData, rawEvent))
+		}
 
 	case oldevents.CustomEvent:
+		if context, ok := er.indexed.get(e.KeysOnlyContextKey()); ok {
+			e = e.WithContext(context)
+		}
 		customData, err := oldevents.TranslateCustomEvent(e)
 		if err != nil {
 			return err
 		}
 		ep.RecordCustomEvent(customData)
+		if *otlpRecords != nil {
+			*otlpRecords = append(*otlpRecords, otlpRecordFromCustomEvent(customData, rawEvent))
+		}
 
 	case oldevents.IdentifyEvent:
 		identifyData, err := oldevents.TranslateIdentifyEvent(e)
@@ -180,6 +263,24 @@ func (er *eventSummarizingRelay) dispatchEvent(
 			return err
 		}
 		ep.RecordIdentifyEvent(identifyData)
+		if *otlpRecords != nil {
+			*otlpRecords = append(*otlpRecords, otlpRecordFromIdentifyEvent(identifyData, rawEvent))
+		}
+
+	case oldevents.IndexEvent:
+		// The SDK's index event goes into the output as-is. We also remember its context, so that feature
+		// and custom events that refer to the context only by key are processed with the full context;
+		// then any index event that the event processor generates for it also has the full properties,
+		// even if that happens after the context key caches have been cleared. If the event processor
+		// generates one in the same payload as the SDK's index event, or while the context is still in
+		// contextKeyCache, indexDeduplicatingEventSender removes it.
+		er.indexed.put(e.ContextKey(), *e.Context)
+		ep.RecordRawEvent(rawEvent)
+
+	case oldevents.SummaryEvent, oldevents.MigrationOpEvent:
+		// These are current-schema events that the event processor cannot generate from its inputs in
+		// the same form, so they go into the output as-is.
+		ep.RecordRawEvent(rawEvent)
 
 	case oldevents.UntranslatedEvent:
 		// We use this for alias events, and anything else we don't recognize. We can't do any kind of
@@ -214,8 +315,10 @@ func (er *eventSummarizingRelay) runPeriodicCleanupTaskUntilClosed(cleanupInterv
 			er.queues = nil
 			er.lock.Unlock()
 			for _, queue := range queues {
-				_ = queue.eventProcessor.Close()
+				_ = queue.eventProcessor.Close() // this flushes any pending events before returning
 			}
+			recordEventMetric(er.metricsCtx, eventSummarizingQueuesMeasure.M(-int64(len(queues))))
+			close(er.closed)
 			return
 
 		case <-ticker.C:
@@ -243,14 +346,17 @@ func (er *eventSummarizingRelay) runPeriodicCleanupTaskUntilClosed(cleanupInterv
 				er.loggers.Debugf("Shutting down inactive summarizing relay for %+v", queue.metadata)
 				_ = queue.eventProcessor.Close()
 			}
+			recordEventMetric(er.metricsCtx, eventSummarizingQueuesMeasure.M(-int64(len(unused))))
 		}
 	}
 }
 
+// close shuts down all of the event processors, after they have delivered any pending events.
 func (er *eventSummarizingRelay) close() {
 	er.closeOnce.Do(func() {
 		er.closer <- struct{}{}
 	})
+	<-er.closed
 }
 
 func (d *delegatingEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
@@ -13,6 +13,7 @@ import (
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 	"github.com/launchdarkly/ld-relay/v8/internal/util"
 
+	"github.com/launchdarkly/go-configtypes"
 	ldevents "github.com/launchdarkly/go-sdk-events/v3"
 	helpers "github.com/launchdarkly/go-test-helpers/v3"
 	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
@@ -66,6 +67,13 @@ func TestSummarizeEvents(t *testing.T) {
 	}
 }
 
+// waitForReportedContext waits until the summarizing relay has recorded that a context was reported in
+// an index event, which happens after the response to the payload containing that event was received.
+func waitForReportedContext(t *testing.T, dispatcher *EventDispatcher, key string) {
+	relay := dispatcher.analyticsEndpoints[basictypes.ServerSDK].getSummarizingRelay()
+	require.Eventually(t, func() bool { return relay.contextKeys.contains(key) }, time.Second, time.Millisecond)
+}
+
 func TestSummarizingRelayProcessesEventsSeparatelyForDifferentTags(t *testing.T) {
 	customEventData1a := `{
 		"kind": "custom", "creationDate": 1000, "key": "eventkey1a", "user": { "key": "userkey" }
@@ -87,16 +95,21 @@ func TestSummarizingRelayProcessesEventsSeparatelyForDifferentTags(t *testing.T)
 		req1a := st.BuildRequest("POST", "/", []byte(payload1a), headers1)
 		req1b := st.BuildRequest("POST", "/", []byte(payload1b), headers1)
 		req2 := st.BuildRequest("POST", "/", []byte(payload2), headers2)
-		for _, req := range []*http.Request{req1a, req2, req1b} {
+		for _, req := range []*http.Request{req1a, req1b} {
 			p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
 		}
 		p.dispatcher.flush()
 
 		request1 := expectSummarizedPayloadRequest(t, p.requestsCh)
+
+		// The queues share a context key cache, so we wait until the first queue has delivered its
+		// events before posting to the second one; otherwise, which of them reported the context would
+		// depend on which one was flushed first.
+		waitForReportedContext(t, p.dispatcher, "userkey")
+		p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req2)
+		p.dispatcher.flush()
+
 		request2 := expectSummarizedPayloadRequest(t, p.requestsCh)
-		if request2.Request.Header.Get(TagsHeader) == "tags1" {
-			request1, request2 = request2, request1
-		}
 
 		assert.Equal(t, "tags1", request1.Request.Header.Get(TagsHeader))
 		assert.Equal(t, "tags2", request2.Request.Header.Get(TagsHeader))
@@ -113,7 +126,6 @@ func TestSummarizingRelayProcessesEventsSeparatelyForDifferentTags(t *testing.T)
 			m.MapIncluding(m.KV("kind", m.Equal("custom")), m.KV("key", m.Equal("eventkey1b"))),
 		)))
 		m.In(t).Assert(json.RawMessage(decompressedBody2), m.JSONArray().Should(m.ItemsInAnyOrder(
-			m.MapIncluding(m.KV("kind", m.Equal("index"))),
 			m.MapIncluding(m.KV("kind", m.Equal("custom")), m.KV("key", m.Equal("eventkey2"))),
 		)))
 	})
@@ -141,20 +153,21 @@ func TestSummarizingRelayPeriodicallyClosesInactiveEventProcessors(t *testing.T)
 	options := eventRelayTestOptions{eventQueueCleanupInterval: time.Millisecond * 10}
 
 	eventRelayTestWithOptions(t, st.EnvMain, config.EventsConfig{}, options, func(p eventRelayTestParams) {
+		// The queues share a context key cache, so we flush the first queue and wait for its events
+		// before posting to the second one; otherwise, which of them reported the context would depend
+		// on which one was flushed first. The cleanup task only runs while there is more than one queue.
 		req1a := st.BuildRequest("POST", "/", []byte(payload1a), headers1)
-		req2 := st.BuildRequest("POST", "/", []byte(payload2), headers2)
-		for _, req := range []*http.Request{req1a, req2} {
-			p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
-		}
+		p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req1a)
+		p.dispatcher.flush()
+		request1a := expectSummarizedPayloadRequest(t, p.requestsCh)
+		waitForReportedContext(t, p.dispatcher, "userkey")
 
 		// Don't bother doing an explicit flush here - we expect a flush to happen automatically
-		// when the two EventProcessor instances are shut down, so once we get these requests, we
+		// when the two EventProcessor instances are shut down, so once we get this request, we
 		// know that that has happened.
-		request1a := expectSummarizedPayloadRequest(t, p.requestsCh)
+		req2 := st.BuildRequest("POST", "/", []byte(payload2), headers2)
+		p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req2)
 		request2 := expectSummarizedPayloadRequest(t, p.requestsCh)
-		if request2.Request.Header.Get(TagsHeader) == "tags1" {
-			request1a, request2 = request2, request1a
-		}
 
 		assert.Equal(t, "tags1", request1a.Request.Header.Get(TagsHeader))
 		assert.Equal(t, "tags2", request2.Request.Header.Get(TagsHeader))
@@ -170,7 +183,6 @@ func TestSummarizingRelayPeriodicallyClosesInactiveEventProcessors(t *testing.T)
 			m.MapIncluding(m.KV("kind", m.Equal("custom")), m.KV("key", m.Equal("eventkey1a"))),
 		)))
 		m.In(t).Assert(json.RawMessage(uncompressedBody2), m.JSONArray().Should(m.ItemsInAnyOrder(
-			m.MapIncluding(m.KV("kind", m.Equal("index"))),
 			m.MapIncluding(m.KV("kind", m.Equal("custom")), m.KV("key", m.Equal("eventkey2"))),
 		)))
 
@@ -185,10 +197,86 @@ func TestSummarizingRelayPeriodicallyClosesInactiveEventProcessors(t *testing.T)
 		uncompressedBody1b, err := util.DecompressGzipData(request1b.Body)
 		assert.NoError(t, err)
 
+		// The context key cache outlives the EventProcessors, so the context is not reported again.
 		assert.Equal(t, "tags1", request1b.Request.Header.Get(TagsHeader))
 		m.In(t).Assert(json.RawMessage(uncompressedBody1b), m.JSONArray().Should(m.ItemsInAnyOrder(
-			m.MapIncluding(m.KV("kind", m.Equal("index"))),
 			m.MapIncluding(m.KV("kind", m.Equal("custom")), m.KV("key", m.Equal("eventkey1b"))),
 		)))
 	})
 }
+
+func TestSummarizingRelayDeduplicatesIndexEventsAcrossTags(t *testing.T) {
+	payload := `[{"kind": "custom", "creationDate": 1000, "key": "eventkey", "user": { "key": "userkey" }}]`
+	headers1, headers2 := headersWithEventSchema(0), headersWithEventSchema(0)
+	headers1.Set(TagsHeader, "tags1")
+	headers2.Set(TagsHeader, "tags2")
+
+	eventRelayTest(t, st.EnvMain, config.EventsConfig{}, func(p eventRelayTestParams) {
+		postAndFlush := func(headers http.Header) httphelpers.HTTPRequestInfo {
+			req := st.BuildRequest("POST", "/", []byte(payload), headers)
+			p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+			p.dispatcher.flush()
+			return expectSummarizedPayloadRequest(t, p.requestsCh)
+		}
+
+		request1 := postAndFlush(headers1)
+		waitForReportedContext(t, p.dispatcher, "userkey")
+		assert.Equal(t, "tags1", request1.Request.Header.Get(TagsHeader))
+		body1, err := util.DecompressGzipData(request1.Body)
+		require.NoError(t, err)
+		m.In(t).Assert(json.RawMessage(body1), m.JSONArray().Should(m.ItemsInAnyOrder(
+			m.MapIncluding(m.KV("kind", m.Equal("index")), m.KV("context", m.MapIncluding(m.KV("key", m.Equal("userkey"))))),
+			m.MapIncluding(m.KV("kind", m.Equal("custom"))),
+		)))
+
+		request2 := postAndFlush(headers2)
+		assert.Equal(t, "tags2", request2.Request.Header.Get(TagsHeader))
+		body2, err := util.DecompressGzipData(request2.Body)
+		require.NoError(t, err)
+		m.In(t).Assert(json.RawMessage(body2), m.JSONArray().Should(m.ItemsInAnyOrder(
+			m.MapIncluding(m.KV("kind", m.Equal("custom"))),
+		)))
+	})
+}
+
+func TestSummarizingRelayKeepsFullContextFromSDKIndexEventAfterContextKeysAreFlushed(t *testing.T) {
+	payload1 := `[
+		{ "kind": "index", "creationDate": 1000, "context": {"kind": "user", "key": "userkey", "name": "Lucy"} },
+		{ "kind": "custom", "creationDate": 1000, "key": "eventkey1", "contextKeys": {"user": "userkey"} }
+	]`
+	payload2 := `[
+		{ "kind": "custom", "creationDate": 2000, "key": "eventkey2", "contextKeys": {"user": "userkey"} }
+	]`
+	headers := headersWithEventSchema(CurrentEventsSchemaVersion)
+	headers.Set(EventUnsummarizedHeader, "true")
+	fullIndexEvent := m.MapIncluding(
+		m.KV("kind", m.Equal("index")),
+		m.KV("context", m.MapIncluding(m.KV("key", m.Equal("userkey")), m.KV("name", m.Equal("Lucy")))),
+	)
+
+	eventsConfig := config.EventsConfig{ContextKeysFlushInterval: configtypes.NewOptDuration(time.Millisecond * 10)}
+	eventRelayTest(t, st.EnvMain, eventsConfig, func(p eventRelayTestParams) {
+		postAndFlush := func(payload string) []byte {
+			req := st.BuildRequest("POST", "/", []byte(payload), headers)
+			p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+			p.dispatcher.flush()
+			body, err := util.DecompressGzipData(expectSummarizedPayloadRequest(t, p.requestsCh).Body)
+			require.NoError(t, err)
+			return body
+		}
+
+		m.In(t).Assert(json.RawMessage(postAndFlush(payload1)), m.JSONArray().Should(m.ItemsInAnyOrder(
+			fullIndexEvent,
+			m.MapIncluding(m.KV("kind", m.Equal("custom")), m.KV("key", m.Equal("eventkey1"))),
+		)))
+
+		// After both the shared context key cache and the event processor's own cache have been cleared,
+		// the event processor generates a new index event, which must still have the full context.
+		time.Sleep(time.Millisecond * 50)
+
+		m.In(t).Assert(json.RawMessage(postAndFlush(payload2)), m.JSONArray().Should(m.ItemsInAnyOrder(
+			fullIndexEvent,
+			m.MapIncluding(m.KV("kind", m.Equal("custom")), m.KV("key", m.Equal("eventkey2"))),
+		)))
+	})
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// DynamicLevelLoggers produces Loggers whose minimum level can be changed at any time.
+//
+// The minimum level of an ldlog.Loggers is part of its value, so calling SetMinLevel on it has no
+// effect on copies that have already been given to other components, such as the SDK client or the
+// event publisher. The Loggers returned by DynamicLevelLoggers.Loggers instead check the level in
+// this object for every message, so changing it applies to all of those copies.
+type DynamicLevelLoggers struct {
+	output  ldlog.Loggers
+	level   atomic.Int32
+	loggers ldlog.Loggers
+}
+
+// dynamicLevelLogger is the ldlog.BaseLogger for one level of the Loggers returned by
+// DynamicLevelLoggers.Loggers.
+type dynamicLevelLogger struct {
+	owner       *DynamicLevelLoggers
+	level       ldlog.LogLevel
+	levelPrefix string
+	output      ldlog.BaseLogger
+}
+
+// NewDynamicLevelLoggers creates a DynamicLevelLoggers that writes to the same destinations as output,
+// with the same prefix, but with the specified minimum level instead of the minimum level of output.
+func NewDynamicLevelLoggers(output ldlog.Loggers, minLevel ldlog.LogLevel) *DynamicLevelLoggers {
+	output.SetMinLevel(ldlog.Debug)
+	d := &DynamicLevelLoggers{output: output}
+	d.level.Store(int32(minLevel))
+	d.loggers = ldlog.NewDefaultLoggers()
+	d.loggers.SetMinLevel(ldlog.Debug) // messages are filtered by dynamicLevelLogger instead
+	for _, level := range []ldlog.LogLevel{ldlog.Debug, ldlog.Info, ldlog.Warn, ldlog.Error} {
+		d.loggers.SetBaseLoggerForLevel(level, dynamicLevelLogger{
+			owner:       d,
+			level:       level,
+			levelPrefix: strings.ToUpper(level.Name()) + ":",
+			output:      output.ForLevel(level),
+		})
+	}
+	return d
+}
+
+// Loggers returns a Loggers instance whose minimum level is controlled by this object. The caller
+// can add a prefix to it with SetPrefix, but should not call SetMinLevel on it.
+//
+// Because ldlog.Loggers filters messages by its own minimum level before they reach us, that level
+// has to be Debug, so the GetMinLevel and IsDebugEnabled methods of the returned Loggers always
+// report Debug. Code that needs to know the current level should call GetMinLevel or IsDebugEnabled
+// on the DynamicLevelLoggers instead.
+func (d *DynamicLevelLoggers) Loggers() ldlog.Loggers {
+	return d.loggers
+}
+
+// GetMinLevel returns the current minimum level.
+func (d *DynamicLevelLoggers) GetMinLevel() ldlog.LogLevel {
+	return ldlog.LogLevel(d.level.Load())
+}
+
+// IsDebugEnabled returns true if the current minimum level is Debug.
+func (d *DynamicLevelLoggers) IsDebugEnabled() bool {
+	return d.GetMinLevel() == ldlog.Debug
+}
+
+// SetMinLevel changes the minimum level for all Loggers that were obtained from this object.
+func (d *DynamicLevelLoggers) SetMinLevel(minLevel ldlog.LogLevel) {
+	d.level.Store(int32(minLevel))
+}
+
+// WithMinLevel creates a new DynamicLevelLoggers that writes to the same destinations as this one, but
+// whose minimum level is independent of this one.
+func (d *DynamicLevelLoggers) WithMinLevel(minLevel ldlog.LogLevel) *DynamicLevelLoggers {
+	return NewDynamicLevelLoggers(d.output, minLevel)
+}
+
+// Println and Printf receive messages that ldlog.Loggers has already prefixed with the level name, such
+// as "INFO:", followed by the prefix from SetPrefix if any. The output Loggers adds its own level name,
+// so we remove the first one.
+
+func (l dynamicLevelLogger) Println(values ...interface{}) {
+	if l.level < l.owner.GetMinLevel() {
+		return
+	}
+	if len(values) != 0 {
+		if s, ok := values[0].(string); ok && strings.HasPrefix(s, l.levelPrefix) {
+			rest := strings.TrimPrefix(strings.TrimPrefix(s, l.levelPrefix), " ")
+			if rest == "" {
+				values = values[1:]
+			} else {
+				values = append([]interface{}{rest}, values[1:]...)
+			}
+		}
+	}
+	l.output.Println(values...)
+}
+
+func (l dynamicLevelLogger) Printf(format string, values ...interface{}) {
+	if l.level < l.owner.GetMinLevel() {
+		return
+	}
+	l.output.Printf(strings.TrimPrefix(strings.TrimPrefix(format, l.levelPrefix), " "), values...)
+}
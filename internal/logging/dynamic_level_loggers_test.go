@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamicLevelLoggers(t *testing.T) {
+	t.Run("writes to output with same format", func(t *testing.T) {
+		mockLog := ldlogtest.NewMockLog()
+		d := NewDynamicLevelLoggers(mockLog.Loggers, ldlog.Debug)
+		loggers := d.Loggers()
+		loggers.SetPrefix("[env: x]")
+		loggers.Debug("a")
+		loggers.Infof("b %d", 1)
+		loggers.Warn("c", "d")
+		loggers.Error("e")
+
+		assert.Equal(t, []string{"[env: x] a"}, mockLog.GetOutput(ldlog.Debug))
+		assert.Equal(t, []string{"[env: x] b 1"}, mockLog.GetOutput(ldlog.Info))
+		assert.Equal(t, []string{"[env: x] c d"}, mockLog.GetOutput(ldlog.Warn))
+		assert.Equal(t, []string{"[env: x] e"}, mockLog.GetOutput(ldlog.Error))
+	})
+
+	t.Run("level change applies to existing copies", func(t *testing.T) {
+		mockLog := ldlogtest.NewMockLog()
+		d := NewDynamicLevelLoggers(mockLog.Loggers, ldlog.Warn)
+		loggers := d.Loggers()
+		loggers.Info("a")
+		d.SetMinLevel(ldlog.Info)
+		loggers.Info("b")
+		loggers.Debug("c")
+		assert.Equal(t, ldlog.Info, d.GetMinLevel())
+		assert.False(t, d.IsDebugEnabled())
+		assert.Equal(t, []string{"b"}, mockLog.GetOutput(ldlog.Info))
+		assert.Len(t, mockLog.GetOutput(ldlog.Debug), 0)
+	})
+
+	t.Run("IsDebugEnabled reports current level", func(t *testing.T) {
+		d := NewDynamicLevelLoggers(ldlogtest.NewMockLog().Loggers, ldlog.Info)
+		assert.False(t, d.IsDebugEnabled())
+		d.SetMinLevel(ldlog.Debug)
+		assert.True(t, d.IsDebugEnabled())
+		assert.Equal(t, ldlog.Debug, d.GetMinLevel())
+	})
+
+	t.Run("ignores minimum level of output", func(t *testing.T) {
+		mockLog := ldlogtest.NewMockLog()
+		output := mockLog.Loggers
+		output.SetMinLevel(ldlog.Error)
+		NewDynamicLevelLoggers(output, ldlog.Debug).Loggers().Debug("a")
+		assert.Equal(t, []string{"a"}, mockLog.GetOutput(ldlog.Debug))
+	})
+
+	t.Run("WithMinLevel has independent level", func(t *testing.T) {
+		mockLog := ldlogtest.NewMockLog()
+		d1 := NewDynamicLevelLoggers(mockLog.Loggers, ldlog.Info)
+		d2 := d1.WithMinLevel(ldlog.Debug)
+		d1.Loggers().Debug("a")
+		d2.Loggers().Debug("b")
+		d2.SetMinLevel(ldlog.Error)
+		d1.Loggers().Info("c")
+		d2.Loggers().Info("d")
+		assert.Equal(t, []string{"b"}, mockLog.GetOutput(ldlog.Debug))
+		assert.Equal(t, []string{"c"}, mockLog.GetOutput(ldlog.Info))
+	})
+}
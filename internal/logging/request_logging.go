@@ -1,6 +1,9 @@
 package logging
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 	"strings"
 
@@ -18,6 +21,23 @@ func RequestLoggerMiddleware(loggers ldlog.Loggers) func(http.Handler) http.Hand
 	}
 }
 
+// DynamicRequestLoggerMiddleware is the same as RequestLoggerMiddleware, except that requests are only
+// logged while the current level of levels is Debug, so it responds to changes in that level. The
+// loggers should be obtained from levels.
+func DynamicRequestLoggerMiddleware(loggers ldlog.Loggers, levels *DynamicLevelLoggers) func(http.Handler) http.Handler {
+	requestLogger := RequestLoggerMiddleware(loggers)
+	return func(next http.Handler) http.Handler {
+		loggingHandler := requestLogger(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if levels.IsDebugEnabled() {
+				loggingHandler.ServeHTTP(w, req)
+			} else {
+				next.ServeHTTP(w, req)
+			}
+		})
+	}
+}
+
 type loggingHTTPResponseWriter struct {
 	loggers      ldlog.Loggers
 	writer       http.ResponseWriter
@@ -93,3 +113,15 @@ func (w *loggingHTTPResponseWriter) Flush() {
 		f.Flush()
 	}
 }
+
+// A WebSocket handler takes over the connection from the HTTP server, so loggingHTTPResponseWriter must
+// also implement http.Hijacker if the underlying ResponseWriter does.
+
+func (w *loggingHTTPResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.writer.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying ResponseWriter does not support hijacking")
+	}
+	w.statusCode = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
@@ -1,11 +1,14 @@
 package logging
 
 import (
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
@@ -69,3 +72,56 @@ func TestRequestLoggerMiddlewareAuth(t *testing.T) {
 	mockLog.AssertMessageMatch(t, true, ldlog.Debug, "Request: method=GET url=/url auth=\\*fghij status=200 bytes=3")
 	mockLog.AssertMessageMatch(t, true, ldlog.Debug, "Request: method=GET url=/url auth=abcd status=200 bytes=3")
 }
+
+func TestDynamicRequestLoggerMiddleware(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	levels := NewDynamicLevelLoggers(mockLog.Loggers, ldlog.Info)
+	handler := DynamicRequestLoggerMiddleware(levels.Loggers(), levels)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("abc"))
+	}))
+
+	req1, _ := http.NewRequest("GET", "/url1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req1)
+	levels.SetMinLevel(ldlog.Debug)
+	req2, _ := http.NewRequest("GET", "/url2", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req2)
+
+	assert.Equal(t, []string{"Request: method=GET url=/url2 auth=n/a status=200 bytes=3"}, mockLog.GetOutput(ldlog.Debug))
+}
+
+func TestRequestLoggerMiddlewareHijack(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	mockLog.Loggers.SetMinLevel(ldlog.Debug)
+	handler := RequestLoggerMiddleware(mockLog.Loggers)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\nabc")
+		buf.Flush()
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /url HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	data, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n\r\nabc", string(data))
+
+	server.Close() // waits for the handler to return
+	mockLog.AssertMessageMatch(t, true, ldlog.Debug, "Request: method=GET url=/url auth=n/a status=101 bytes=0")
+}
+
+func TestRequestLoggerMiddlewareHijackNotSupported(t *testing.T) {
+	handler := RequestLoggerMiddleware(ldlog.NewDisabledLoggers())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.Error(t, err)
+	}))
+	req, _ := http.NewRequest("GET", "/url", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
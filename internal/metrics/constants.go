@@ -23,6 +23,12 @@ const (
 
 	requestMeasureName = "requests"
 
+	tlsCertExpiryMeasureName = "tls_certificate_expiry_seconds"
+
+	streamConnsRejectedMeasureName     = "stream_connections_rejected"
+	streamUncompressedBytesMeasureName = "stream_uncompressed_bytes"
+	streamCompressedBytesMeasureName   = "stream_compressed_bytes"
+
 	defaultFlushInterval = time.Minute
 )
 
@@ -33,6 +39,8 @@ var (
 	routeTagKey, _            = tag.NewKey("route")            //nolint:gochecknoglobals
 	methodTagKey, _           = tag.NewKey("method")           //nolint:gochecknoglobals
 	envNameTagKey, _          = tag.NewKey("env")              //nolint:gochecknoglobals
+	streamKindTagKey, _       = tag.NewKey("streamKind")       //nolint:gochecknoglobals
+	reasonTagKey, _           = tag.NewKey("reason")           //nolint:gochecknoglobals
 
 	publicTags  = []tag.Key{platformCategoryTagKey, userAgentTagKey, envNameTagKey}                //nolint:gochecknoglobals
 	privateTags = []tag.Key{platformCategoryTagKey, userAgentTagKey, relayIDTagKey, envNameTagKey} //nolint:gochecknoglobals
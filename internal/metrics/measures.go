@@ -23,6 +23,12 @@ var (
 	newConnMeasure = stats.Int64(newConnMeasureName, "total number of connections", stats.UnitDimensionless)
 	requestMeasure = stats.Int64(requestMeasureName, "Number of hits to a route", stats.UnitDimensionless)
 
+	tlsCertExpiryMeasure = stats.Int64(tlsCertExpiryMeasureName, "expiry time of the TLS certificate, in seconds since the Unix epoch", stats.UnitSeconds)
+
+	streamConnsRejectedMeasure     = stats.Int64(streamConnsRejectedMeasureName, "number of stream connections rejected because of a connection limit", stats.UnitDimensionless)
+	streamUncompressedBytesMeasure = stats.Int64(streamUncompressedBytesMeasureName, "size of stream data before compression", stats.UnitBytes)
+	streamCompressedBytesMeasure   = stats.Int64(streamCompressedBytesMeasureName, "size of stream data after compression", stats.UnitBytes)
+
 	// For internal event exporter
 	privateConnMeasure            = stats.Int64(privateConnMeasureName, "current number of connections", stats.UnitDimensionless)
 	privateNewConnMeasure         = stats.Int64(privateNewConnMeasureName, "total number of connections", stats.UnitDimensionless)
@@ -107,6 +113,24 @@ func WithCount(ctx context.Context, userAgent string, f func(), measure Measure)
 	f()
 }
 
+// RecordStreamConnectionRejected records that a stream connection was rejected because of a connection
+// limit. The streamKind is the basictypes.StreamKind of the stream, and the reason indicates which limit
+// was reached.
+func RecordStreamConnectionRejected(ctx context.Context, streamKind, reason string) {
+	ctx, err := tag.New(ctx, tag.Upsert(streamKindTagKey, streamKind), tag.Upsert(reasonTagKey, reason))
+	if err != nil { // COVERAGE: can't make this happen in unit tests
+		logging.GetGlobalContextLoggers(ctx).Errorf(`Failed to create tags: %s`, err)
+		return
+	}
+	stats.Record(ctx, streamConnsRejectedMeasure.M(1))
+}
+
+// RecordStreamCompression records the size of some data that was written to a compressed stream, before
+// and after compression. The ratio of the totals is the compression ratio.
+func RecordStreamCompression(ctx context.Context, uncompressedBytes, compressedBytes int64) {
+	stats.Record(ctx, streamUncompressedBytesMeasure.M(uncompressedBytes), streamCompressedBytesMeasure.M(compressedBytes))
+}
+
 // WithRouteCount records a route hit and starts a trace. For stream connections, the duration of the stream connection is recorded
 func WithRouteCount(ctx context.Context, userAgent, route, method string, f func(), measure Measure) {
 	tagCtx, err := tag.New(ctx, tag.Insert(routeTagKey, sanitizeTagValue(route)), tag.Insert(methodTagKey, sanitizeTagValue(method)))
@@ -14,6 +14,7 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 
 	"github.com/pborman/uuid"
+	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
 )
@@ -107,6 +108,11 @@ func (m *Manager) Close() {
 	})
 }
 
+// RecordTLSCertificateExpiry updates the gauge for the expiry time of Relay's TLS certificate.
+func (m *Manager) RecordTLSCertificateExpiry(expiry time.Time) {
+	stats.Record(m.openCensusCtx, tlsCertExpiryMeasure.M(expiry.Unix()))
+}
+
 // AddEnvironment creates a new EnvironmentManager with its own OpenCensus context that includes
 // a tag for the environment name, and registers its exporter.
 func (m *Manager) AddEnvironment(envName string, publisher events.EventPublisher) (*EnvironmentManager, error) {
@@ -191,3 +191,47 @@ func TestSanitizeTagValue(t *testing.T) {
 	assert.Equal(t, "abc", sanitizeTagValue("abc"))
 	assert.Equal(t, "_", sanitizeTagValue(""))
 }
+
+func TestRecordTLSCertificateExpiry(t *testing.T) {
+	manager, err := NewManager(config.MetricsConfig{}, 0, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer manager.Close()
+
+	expiry := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	manager.RecordTLSCertificateExpiry(expiry)
+
+	rows, err := view.RetrieveData(tlsCertExpiryMeasureName)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(expiry.Unix()), rows[0].Data.(*view.LastValueData).Value)
+}
+
+func TestRecordStreamConnectionRejected(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordStreamConnectionRejected(p.env.GetOpenCensusContext(), "server", "env_limit")
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(streamConnsRejectedView.Name, st.TestMetricsRow{
+				Tags: map[string]string{
+					"env":        p.envName,
+					"reason":     "env_limit",
+					"streamKind": "server",
+				},
+				Sum: 1,
+			})
+		})
+	})
+}
+
+func TestRecordStreamCompression(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordStreamCompression(p.env.GetOpenCensusContext(), 1000, 100)
+		RecordStreamCompression(p.env.GetOpenCensusContext(), 500, 50)
+
+		expectedTags := map[string]string{"env": p.envName}
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(streamUncompressedBytesView.Name, st.TestMetricsRow{Tags: expectedTags, Sum: 1500}) &&
+				d.HasRow(streamCompressedBytesView.Name, st.TestMetricsRow{Tags: expectedTags, Sum: 150})
+		})
+	})
+}
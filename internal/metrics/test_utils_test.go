@@ -128,6 +128,7 @@ func (p *testEventsPublisher) Publish(context events.EventPayloadMetadata, event
 func (p *testEventsPublisher) Flush()                                     {}
 func (p *testEventsPublisher) Close()                                     {}
 func (p *testEventsPublisher) ReplaceCredential(credential.SDKCredential) {}
+func (p *testEventsPublisher) QueuedEventCount() int                      { return len(p.events) }
 
 func (p *testEventsPublisher) expectMetricsEvent(t *testing.T, timeout time.Duration) relayMetricsEvent {
 	if ret, ok := p.maybeReceiveMetricsEvent(t, timeout); ok {
@@ -3,7 +3,10 @@ package metrics
 import (
 	"sync"
 
+	"github.com/launchdarkly/ld-relay/v8/internal/events"
+
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 var (
@@ -22,6 +25,25 @@ var (
 		Aggregation: view.Count(),
 		TagKeys:     append(publicTags, routeTagKey, methodTagKey),
 	}
+	tlsCertExpiryView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     tlsCertExpiryMeasure,
+		Aggregation: view.LastValue(),
+	}
+	streamConnsRejectedView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     streamConnsRejectedMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey, streamKindTagKey, reasonTagKey},
+	}
+	streamUncompressedBytesView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     streamUncompressedBytesMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	streamCompressedBytesView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     streamCompressedBytesMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
 	privateConnView *view.View = &view.View{ //nolint:gochecknoglobals
 		Measure:     privateConnMeasure,
 		Aggregation: view.Sum(),
@@ -43,7 +65,10 @@ var (
 )
 
 func getPublicViews() []*view.View {
-	return []*view.View{publicConnView, publicNewConnView, requestView}
+	views := []*view.View{publicConnView, publicNewConnView, requestView, tlsCertExpiryView, streamConnsRejectedView,
+		streamUncompressedBytesView, streamCompressedBytesView}
+	// The event pipeline metrics are defined in the events package, since that package can't import this one.
+	return append(views, events.GetMetricsViews()...)
 }
 
 func getPrivateViews() []*view.View {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	ct "github.com/launchdarkly/go-configtypes"
@@ -33,6 +34,7 @@ const (
 	httpStatusMessagePayloadFilterNotFound = "Relay Proxy recognizes the provided credential, but the payload filter was not found"
 	httpStatusMessageMissingEnvURLParam    = "URL did not contain an environment ID"
 	httpStatusMessageSDKClientNotInited    = "client was not initialized"
+	httpStatusMessageClientCertNotAllowed  = "Relay Proxy recognizes the client credential, but the TLS client certificate is not allowed for this environment"
 )
 
 var (
@@ -77,6 +79,10 @@ func Chain(middlewares ...mux.MiddlewareFunc) mux.MiddlewareFunc {
 // SelectEnvironmentByAuthorizationKey creates a middleware function that attempts to authenticate the request
 // using the appropriate kind of credential for the basictypes.SDKKind. If successful, it updates the request context
 // so GetEnvContextInfo will return environment information. If not successful, it returns an error response.
+//
+// For basictypes.JSClientSDK, the credential is the environment ID in the URL rather than an Authorization header;
+// this is the selector for every route with an {envId} variable. In every case, the environment's allowed TLS
+// client certificates are checked after the environment has been found.
 func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayEnvironments) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -116,6 +122,12 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 				return
 			}
 
+			if !isClientCertificateAllowed(req, clientCtx.GetAllowedClientCertificates()) {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(httpStatusMessageClientCertNotAllowed))
+				return
+			}
+
 			if clientCtx.GetClient() == nil {
 				w.WriteHeader(http.StatusServiceUnavailable)
 				_, _ = w.Write([]byte(httpStatusMessageSDKClientNotInited))
@@ -135,6 +147,32 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 	}
 }
 
+// isClientCertificateAllowed returns true if the environment does not restrict which TLS client certificates
+// may be used, or if the certificate presented by the client has a subject common name, DNS name, email
+// address, or URI that is in the allowed list. The certificate chain itself has already been verified by
+// the TLS server at this point.
+func isClientCertificateAllowed(req *http.Request, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	cert := req.TLS.PeerCertificates[0]
+	identities := []string{cert.Subject.CommonName}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+	for _, id := range identities {
+		if id != "" && slices.Contains(allowed, id) {
+			return true
+		}
+	}
+	return false
+}
+
 // CORS is a middleware function that sets the appropriate CORS headers on a browser response
 // (not counting Access-Control-Allow-Methods, which is set by gorilla/mux's CORS middleware
 // based on the route handlers we've defined).
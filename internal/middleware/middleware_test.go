@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"errors"
 	"net/http"
@@ -373,6 +376,92 @@ func TestSelectEnvironmentByAuthorizationKey(t *testing.T) {
 		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
 	})
 
+	t.Run("allowed client certificates", func(t *testing.T) {
+		restrictedEnv := testenv.NewTestEnvContext("restricted", false, nil)
+		restrictedEnv.SetAllowedClientCertificates([]string{"client-a", "spiffe://example/client-b"})
+		envs := testEnvironments{
+			envs: map[sdkauth.ScopedCredential]relayenv.EnvContext{
+				sdkauth.New(st.EnvMain.Config.SDKKey):   restrictedEnv,
+				sdkauth.New(st.EnvMobile.Config.SDKKey): env2,
+			},
+		}
+		selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+
+		withClientCert := func(req *http.Request, cert *x509.Certificate) *http.Request {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+			return req
+		}
+
+		t.Run("accepts certificate with allowed common name", func(t *testing.T) {
+			envCh := make(chan relayenv.EnvContext, 1)
+			req := withClientCert(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "client-a"}})
+			resp, _ := st.DoRequest(req, selector(handlerThatDetectsEnvironment(envCh)))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, restrictedEnv, <-envCh)
+		})
+
+		t.Run("accepts certificate with allowed URI", func(t *testing.T) {
+			envCh := make(chan relayenv.EnvContext, 1)
+			uri, _ := url.Parse("spiffe://example/client-b")
+			req := withClientCert(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "other"}, URIs: []*url.URL{uri}})
+			resp, _ := st.DoRequest(req, selector(handlerThatDetectsEnvironment(envCh)))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, restrictedEnv, <-envCh)
+		})
+
+		t.Run("rejects certificate that is not allowed", func(t *testing.T) {
+			req := withClientCert(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "client-c"}, DNSNames: []string{"client-c"}})
+			resp, body := st.DoRequest(req, selector(nullHandler()))
+
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+			assert.Equal(t, httpStatusMessageClientCertNotAllowed, string(body))
+		})
+
+		t.Run("rejects request without certificate", func(t *testing.T) {
+			req := buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey)
+			resp, _ := st.DoRequest(req, selector(nullHandler()))
+
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+
+		t.Run("checks environment selected by environment ID in URL", func(t *testing.T) {
+			envsByID := testEnvironments{
+				envs: map[sdkauth.ScopedCredential]relayenv.EnvContext{
+					sdkauth.New(st.EnvClientSide.Config.EnvID): restrictedEnv,
+				},
+			}
+			jsClientSelector := SelectEnvironmentByAuthorizationKey(basictypes.JSClientSDK, envsByID)
+			vars := map[string]string{"envId": string(st.EnvClientSide.Config.EnvID)}
+
+			req1 := withClientCert(buildPreRoutedRequest("GET", nil, nil, vars, nil),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "client-c"}})
+			resp1, _ := st.DoRequest(req1, jsClientSelector(nullHandler()))
+			assert.Equal(t, http.StatusForbidden, resp1.StatusCode)
+
+			envCh := make(chan relayenv.EnvContext, 1)
+			req2 := withClientCert(buildPreRoutedRequest("GET", nil, nil, vars, nil),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "client-a"}})
+			resp2, _ := st.DoRequest(req2, jsClientSelector(handlerThatDetectsEnvironment(envCh)))
+			assert.Equal(t, http.StatusOK, resp2.StatusCode)
+			assert.Equal(t, restrictedEnv, <-envCh)
+		})
+
+		t.Run("does not restrict other environments", func(t *testing.T) {
+			envCh := make(chan relayenv.EnvContext, 1)
+			req := withClientCert(buildPreRoutedRequestWithAuth(st.EnvMobile.Config.SDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "client-c"}})
+			resp, _ := st.DoRequest(req, selector(handlerThatDetectsEnvironment(envCh)))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, env2, <-envCh)
+		})
+	})
+
 	t.Run("returns 503 if Relay has not been initialized", func(t *testing.T) {
 		envs := testEnvironments{notInited: true}
 		selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
+)
+
+// CompressedStreaming is a middleware function for SSE stream endpoints that compresses the response with
+// gzip, if the request has an "Accept-Encoding" header that includes gzip. The compressed data is flushed
+// whenever the stream handler flushes the response, which it does after each event, so compression does
+// not delay the delivery of events. The size of the data before and after compression is recorded in the
+// environment's metrics.
+//
+// Only successful responses are compressed; an error response is passed through unchanged.
+func CompressedStreaming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		flusher, canFlush := w.(http.Flusher)
+		if !canFlush || !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, req)
+			return
+		}
+		gw := &gzipStreamWriter{
+			ResponseWriter: w,
+			flusher:        flusher,
+			compressed:     &countingWriter{target: w},
+			metricsCtx:     GetEnvContextInfo(req.Context()).Env.GetMetricsContext(),
+		}
+		defer gw.close()
+		next.ServeHTTP(gw, req)
+	})
+}
+
+type gzipStreamWriter struct {
+	http.ResponseWriter
+	flusher      http.Flusher
+	gz           *gzip.Writer // nil until the response status is known, or if it is not a success status
+	compressed   *countingWriter
+	uncompressed int64
+	wroteHeader  bool
+	metricsCtx   context.Context
+}
+
+// countingWriter counts the bytes that are written to the response after compression.
+type countingWriter struct {
+	target http.ResponseWriter
+	count  int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.target.Write(data)
+	c.count += int64(n)
+	return n, err
+}
+
+func (w *gzipStreamWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if statusCode == http.StatusOK {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.compressed)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *gzipStreamWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	w.uncompressed += int64(len(data))
+	return w.gz.Write(data)
+}
+
+func (w *gzipStreamWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+		w.recordMetrics()
+	}
+	w.flusher.Flush()
+}
+
+func (w *gzipStreamWriter) close() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+		w.recordMetrics()
+		w.flusher.Flush()
+	}
+}
+
+func (w *gzipStreamWriter) recordMetrics() {
+	if w.uncompressed == 0 && w.compressed.count == 0 {
+		return
+	}
+	metrics.RecordStreamCompression(w.metricsCtx, w.uncompressed, w.compressed.count)
+	w.uncompressed = 0
+	w.compressed.count = 0
+}
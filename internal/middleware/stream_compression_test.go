@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedStreaming(t *testing.T) {
+	eventData := "event: put\ndata: {\"" + strings.Repeat("flag-data ", 100) + "\"}\n\n"
+
+	makeRequest := func(p metricsMiddlewareTestParams, acceptEncoding string) *http.Request {
+		req, _ := http.NewRequest("GET", "", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		return req.WithContext(WithEnvContextInfo(req.Context(), EnvContextInfo{Env: p.env}))
+	}
+
+	t.Run("compresses response and flushes each event", func(t *testing.T) {
+		metricsMiddlewareTest(t, func(p metricsMiddlewareTestParams) {
+			rr := httptest.NewRecorder()
+			CompressedStreaming(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(eventData))
+				w.(http.Flusher).Flush()
+
+				// Everything written so far can be decompressed without waiting for the stream to end.
+				gz, err := gzip.NewReader(bytes.NewReader(rr.Body.Bytes()))
+				require.NoError(t, err)
+				buf := make([]byte, len(eventData))
+				_, err = io.ReadFull(gz, buf)
+				require.NoError(t, err)
+				assert.Equal(t, eventData, string(buf))
+			})).ServeHTTP(rr, makeRequest(p, "gzip, deflate"))
+
+			assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			gz, err := gzip.NewReader(rr.Body)
+			require.NoError(t, err)
+			body, err := io.ReadAll(gz)
+			require.NoError(t, err)
+			assert.Equal(t, eventData, string(body))
+
+			p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+				return d.HasRow("stream_uncompressed_bytes", st.TestMetricsRow{
+					Tags: map[string]string{"env": p.envName},
+					Sum:  float64(len(eventData)),
+				})
+			})
+		})
+	})
+
+	t.Run("does not compress if client does not accept gzip", func(t *testing.T) {
+		metricsMiddlewareTest(t, func(p metricsMiddlewareTestParams) {
+			rr := httptest.NewRecorder()
+			CompressedStreaming(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(eventData))
+			})).ServeHTTP(rr, makeRequest(p, ""))
+
+			assert.Equal(t, "", rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			assert.Equal(t, eventData, rr.Body.String())
+		})
+	})
+
+	t.Run("does not compress error response", func(t *testing.T) {
+		metricsMiddlewareTest(t, func(p metricsMiddlewareTestParams) {
+			rr := httptest.NewRecorder()
+			CompressedStreaming(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte("try again later"))
+			})).ServeHTTP(rr, makeRequest(p, "gzip"))
+
+			assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+			assert.Equal(t, "", rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "try again later", rr.Body.String())
+		})
+	})
+}
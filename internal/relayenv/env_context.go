@@ -11,6 +11,7 @@ import (
 
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
 	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/bigsegments"
 	"github.com/launchdarkly/ld-relay/v8/internal/events"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
@@ -57,6 +58,19 @@ func (c *CredentialUpdate) WithTime(t time.Time) *CredentialUpdate {
 	return c
 }
 
+// EnvDataStatus summarizes the flag and segment data that an environment has received from LaunchDarkly
+// (or from a data file in offline mode).
+type EnvDataStatus struct {
+	// FlagCount is the number of flags that currently exist, not counting deleted ones.
+	FlagCount int
+	// SegmentCount is the number of segments that currently exist, not counting deleted ones.
+	SegmentCount int
+	// LastUpdated is the time of the most recent update of any kind, or zero if there has been none.
+	LastUpdated time.Time
+	// LatestVersion is the highest flag or segment version number that has been received.
+	LatestVersion int
+}
+
 // EnvContext is the interface for all Relay operations that are specific to one configured LD environment.
 //
 // The EnvContext is normally associated with an LDClient instance from the Go SDK, and allows direct access
@@ -86,6 +100,9 @@ type EnvContext interface {
 	// GetDeprecatedCredentials returns all deprecated and not-yet-removed credentials for the environment.
 	GetDeprecatedCredentials() []credential.SDKCredential
 
+	// GetDeprecatedSDKKeyExpiries returns each deprecated SDK key along with the time when it will expire.
+	GetDeprecatedSDKKeyExpiries() map[config.SDKKey]time.Time
+
 	// GetClient returns the SDK client instance for this environment. This is nil if initialization is not yet
 	// complete. Rather than providing the full client object, we use the simpler sdks.LDClientContext which
 	// includes only the operations Relay needs to do.
@@ -107,16 +124,37 @@ type EnvContext interface {
 	// have its own prefix string and, optionally, its own log level.
 	GetLoggers() ldlog.Loggers
 
+	// SetLogLevel changes the minimum log level for messages logged by this environment.
+	SetLogLevel(ldlog.LogLevel)
+
 	// GetStreamHandler returns the HTTP handler for the specified kind of stream requests and credential for this
 	// environment. If there is none, it returns a handler for a 404 status (not nil).
 	GetStreamHandler(streams.StreamProvider, credential.SDKCredential) http.Handler
 
+	// GetStreamConnectionCounts returns the number of currently open stream connections to this environment
+	// for each kind of stream.
+	GetStreamConnectionCounts() map[basictypes.StreamKind]int
+
+	// GetDataStatus returns a summary of the flag and segment data that this environment has received.
+	GetDataStatus() EnvDataStatus
+
+	// SendShutdownNotice tells all of the environment's connected stream clients that Relay is
+	// shutting down. It does not close their connections; see DisconnectStreams.
+	SendShutdownNotice()
+
+	// DisconnectStreams closes all of the environment's stream connections, so that the SDK clients
+	// will reconnect, without otherwise shutting down the environment.
+	DisconnectStreams()
+
 	// GetEventDispatcher returns the object that proxies events for this environment.
 	GetEventDispatcher() *events.EventDispatcher
 
 	// GetJSClientContext returns the JSClientContext that is used for browser endpoints.
 	GetJSClientContext() JSClientContext
 
+	// SetJSClientCORS changes the allowed origins and headers for CORS requests from browser clients.
+	SetJSClientCORS(origins, headers []string)
+
 	// GetMetricsContext returns the Context that should be used for OpenCensus operations related to this
 	// environment.
 	GetMetricsContext() context.Context
@@ -137,6 +175,13 @@ type EnvContext interface {
 	// SetSecureMode changes the secure mode setting.
 	SetSecureMode(bool)
 
+	// GetAllowedClientCertificates returns the identities of the TLS client certificates that may be used
+	// to connect to this environment, or nil if any certificate trusted by the TLS client CA may be used.
+	GetAllowedClientCertificates() []string
+
+	// SetAllowedClientCertificates changes the allowed client certificate identities.
+	SetAllowedClientCertificates([]string)
+
 	// GetCreationTime returns the time that this EnvContext was created.
 	GetCreationTime() time.Time
 
@@ -2,8 +2,12 @@ package relayenv
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,9 +16,11 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/internal/credential"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/bigsegments"
 	"github.com/launchdarkly/ld-relay/v8/internal/events"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
 	"github.com/launchdarkly/ld-relay/v8/internal/store"
@@ -83,8 +89,13 @@ type EnvContextImplParams struct {
 	UserAgent                        string
 	LogNameMode                      LogNameMode
 	Loggers                          ldlog.Loggers
+	LogLevels                        *logging.DynamicLevelLoggers // if set, Loggers must be LogLevels.Loggers()
 	ConnectionMapper                 ConnectionMapper
 	ExpiredCredentialCleanupInterval time.Duration
+	EventSink                        events.EventSink          // nil unless EventsConfig.Sink is set
+	EventMemoryLimit                 *events.EventMemoryLimit  // nil unless EventsConfig.MaxMemory is set
+	OTLPEventExporter                *events.OTLPEventExporter // nil unless EventsConfig.OTLPEndpoint is set
+	StreamConnectionLimit            *StreamConnectionLimit    // nil unless MainConfig.MaxStreamConnections is set
 }
 
 type envContextImpl struct {
@@ -92,11 +103,17 @@ type envContextImpl struct {
 	clients                   map[config.SDKKey]sdks.LDClientContext
 	storeAdapter              *store.SSERelayDataStoreAdapter
 	loggers                   ldlog.Loggers
+	logLevels                 *logging.DynamicLevelLoggers
 	identifiers               EnvIdentifiers
 	secureMode                bool
+	allowedClientCerts        []string
 	envStreams                *streams.EnvStreams
 	streamProviders           []streams.StreamProvider
 	handlers                  map[streams.StreamProvider]map[credential.SDKCredential]http.Handler
+	streamConns               map[basictypes.StreamKind]int
+	streamConnsLock           sync.Mutex
+	streamConnPolicy          *streamConnectionPolicy
+	dataStatus                envDataStatusTracker
 	jsContext                 JSClientContext
 	evaluator                 ldeval.Evaluator
 	eventDispatcher           *events.EventDispatcher
@@ -136,6 +153,15 @@ type envContextStreamUpdates struct {
 	context *envContextImpl
 }
 
+// envDataStatusTracker accumulates the information returned by GetDataStatus. It is updated by
+// envContextStreamUpdates, so it sees exactly the same updates that are broadcast to the streams.
+type envDataStatusTracker struct {
+	itemKeys      map[string]map[string]struct{} // data kind name -> keys of non-deleted items
+	lastUpdated   time.Time
+	latestVersion int
+	lock          sync.Mutex
+}
+
 // NewEnvContext creates the internal implementation of EnvContext.
 //
 // It immediately begins trying to initialize the SDK client for this environment. Since that might
@@ -158,14 +184,18 @@ func NewEnvContext(
 	envConfig := params.EnvConfig
 	allConfig := params.AllConfig
 
-	envLoggers := params.Loggers
+	// The environment's log level is independent of the global one, and SetLogLevel can change it for all
+	// of the components that are created below.
+	envLogLevel := envConfig.LogLevel.GetOrElse(allConfig.Main.LogLevel.GetOrElse(ldlog.Info))
+	var envLogLevels *logging.DynamicLevelLoggers
+	if params.LogLevels != nil {
+		envLogLevels = params.LogLevels.WithMinLevel(envLogLevel)
+	} else {
+		envLogLevels = logging.NewDynamicLevelLoggers(params.Loggers, envLogLevel)
+	}
+	envLoggers := envLogLevels.Loggers()
 	logPrefix := makeLogPrefix(params.LogNameMode, envConfig.SDKKey, envConfig.EnvID)
 	envLoggers.SetPrefix(logPrefix)
-	envLoggers.SetMinLevel(
-		envConfig.LogLevel.GetOrElse(
-			allConfig.Main.LogLevel.GetOrElse(ldlog.Info),
-		),
-	)
 
 	httpConfig, err := httpconfig.NewHTTPConfig(allConfig.Proxy, envConfig.SDKKey, params.UserAgent, params.Loggers)
 	if err != nil {
@@ -176,9 +206,13 @@ func NewEnvContext(
 		identifiers:               params.Identifiers,
 		clients:                   make(map[config.SDKKey]sdks.LDClientContext),
 		loggers:                   envLoggers,
+		logLevels:                 envLogLevels,
 		secureMode:                envConfig.SecureMode,
+		allowedClientCerts:        envConfig.AllowedClientCert.Values(),
 		streamProviders:           params.StreamProviders,
 		handlers:                  make(map[streams.StreamProvider]map[credential.SDKCredential]http.Handler),
+		streamConns:               make(map[basictypes.StreamKind]int),
+		streamConnPolicy:          newStreamConnectionPolicy(allConfig.Main, params.StreamConnectionLimit),
 		jsContext:                 params.JSClientContext,
 		sdkClientFactory:          params.ClientFactory,
 		sdkInitTimeout:            allConfig.Main.InitTimeout.GetOrElse(config.DefaultInitTimeout),
@@ -270,7 +304,7 @@ func NewEnvContext(
 		for _, c := range allCreds {
 			h := sp.Handler(sdkauth.NewScoped(envContext.filterKey, c))
 			if h != nil {
-				handlers[c] = h
+				handlers[c] = envContext.countStreamConnections(sp.Kind(), h)
 			}
 		}
 		envContext.handlers[sp] = handlers
@@ -283,28 +317,6 @@ func NewEnvContext(
 	storeAdapter := store.NewSSERelayDataStoreAdapter(dataStoreFactory, envStreamUpdates)
 	envContext.storeAdapter = storeAdapter
 
-	var eventDispatcher *events.EventDispatcher
-	if allConfig.Events.SendEvents {
-		if offlineMode {
-			envLoggers.Info("Events will be accepted for this environment, but will be discarded, since offline mode is enabled")
-		} else {
-			envLoggers.Info("Proxying events for this environment")
-			eventLoggers := envLoggers
-			eventLoggers.SetPrefix(logPrefix + " (event proxy)")
-			eventDispatcher = events.NewEventDispatcher(
-				envConfig.SDKKey,
-				envConfig.MobileKey,
-				envConfig.EnvID,
-				envLoggers,
-				allConfig.Events,
-				httpConfig,
-				storeAdapter,
-				0, // 0 here means "use the default interval for any periodic cleanup task you may need to run"
-			)
-		}
-	}
-	envContext.eventDispatcher = eventDispatcher
-
 	streamURI := allConfig.Main.StreamURI.String()   // config.ValidateConfig has ensured that this has a value
 	eventsURI := allConfig.Events.EventsURI.String() // ditto
 
@@ -334,6 +346,51 @@ func NewEnvContext(
 	}
 	envContext.metricsEnv = em
 
+	var eventMetricsCtx context.Context // nil if there are no metrics
+	if em != nil {
+		eventMetricsCtx = em.GetOpenCensusContext()
+	}
+	var eventDispatcher *events.EventDispatcher
+	if allConfig.Events.SendEvents {
+		if offlineMode && params.EventSink == nil {
+			envLoggers.Info("Events will be accepted for this environment, but will be discarded, since offline mode is enabled")
+		} else {
+			if params.EventSink != nil {
+				envLoggers.Infof("Writing events for this environment to the %s events sink", allConfig.Events.Sink)
+			} else {
+				envLoggers.Info("Proxying events for this environment")
+			}
+			eventLoggers := envLoggers
+			eventLoggers.SetPrefix(logPrefix + " (event proxy)")
+			eventOptions := events.EventDispatcherOptions{
+				MetricsContext: eventMetricsCtx,
+				Rules:          events.NewEventRules(envConfig),
+				Sink:           events.NewEnvironmentEventSink(params.EventSink, params.Identifiers.GetDisplayName()),
+				Destinations: events.NewEventDestinations(allConfig.EventDestination, params.Identifiers.GetDisplayName(),
+					httpConfig, envLoggers),
+				GlobalMemory: params.EventMemoryLimit,
+				OTLP:         events.NewEnvironmentOTLPExporter(params.OTLPEventExporter, params.Identifiers.GetDisplayName()),
+			}
+			if allConfig.Events.SpoolDir != "" {
+				eventOptions.SpoolDir = filepath.Join(allConfig.Events.SpoolDir, eventSpoolDirName(params.Identifiers))
+			}
+			if allConfig.Admin.EventDebug {
+				eventOptions.Inspector = events.NewEventInspector(allConfig.Admin.EventDebugSamplingRatio.GetOrElse(1))
+			}
+			eventDispatcher = events.NewEventDispatcher(
+				envConfig.SDKKey,
+				envConfig.MobileKey,
+				envConfig.EnvID,
+				envLoggers,
+				allConfig.Events,
+				httpConfig,
+				storeAdapter,
+				eventOptions,
+			)
+		}
+	}
+	envContext.eventDispatcher = eventDispatcher
+
 	disconnectedStatusTime := allConfig.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime)
 
 	dataSource := ldcomponents.StreamingDataSource()
@@ -427,7 +484,7 @@ func (c *envContextImpl) addCredential(newCredential credential.SDKCredential) {
 	c.envStreams.AddCredential(newCredential)
 	for streamProvider, handlers := range c.handlers {
 		if h := streamProvider.Handler(sdkauth.NewScoped(c.filterKey, newCredential)); h != nil {
-			handlers[newCredential] = h
+			handlers[newCredential] = c.countStreamConnections(streamProvider.Kind(), h)
 		}
 	}
 
@@ -572,6 +629,10 @@ func (c *envContextImpl) GetDeprecatedCredentials() []credential.SDKCredential {
 	return c.keyRotator.DeprecatedCredentials()
 }
 
+func (c *envContextImpl) GetDeprecatedSDKKeyExpiries() map[config.SDKKey]time.Time {
+	return c.keyRotator.DeprecatedSDKKeyExpiries()
+}
+
 func (c *envContextImpl) GetClient() sdks.LDClientContext {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -611,9 +672,16 @@ func (c *envContextImpl) GetBigSegmentStore() bigsegments.BigSegmentStore {
 }
 
 func (c *envContextImpl) GetLoggers() ldlog.Loggers {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	return c.loggers
 }
 
+func (c *envContextImpl) SetLogLevel(level ldlog.LogLevel) {
+	c.logLevels.SetMinLevel(level)
+}
+
 func (c *envContextImpl) GetStreamHandler(streamProvider streams.StreamProvider, credential credential.SDKCredential) http.Handler {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -628,14 +696,81 @@ func invalidStreamHandler(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 }
 
+// countStreamConnections wraps a stream handler so that the connection stays counted in
+// GetStreamConnectionCounts for as long as the handler is serving it. If the environment's limit for
+// this kind of stream, or the global limit, has been reached, the request is rejected instead.
+func (c *envContextImpl) countStreamConnections(kind basictypes.StreamKind, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		policy := c.streamConnPolicy
+		c.streamConnsLock.Lock()
+		if policy.maxPerKind > 0 && c.streamConns[kind] >= policy.maxPerKind {
+			c.streamConnsLock.Unlock()
+			c.rejectStreamConnection(w, kind, streamConnsRejectedReasonEnvLimit)
+			return
+		}
+		if !policy.globalLimit.acquire() {
+			c.streamConnsLock.Unlock()
+			c.rejectStreamConnection(w, kind, streamConnsRejectedReasonGlobalLimit)
+			return
+		}
+		c.streamConns[kind]++
+		c.streamConnsLock.Unlock()
+		defer func() {
+			c.streamConnsLock.Lock()
+			c.streamConns[kind]--
+			c.streamConnsLock.Unlock()
+			policy.globalLimit.release()
+		}()
+		if connTime := policy.connectionTime(); connTime > 0 {
+			ctx, cancel := context.WithTimeout(req.Context(), connTime)
+			defer cancel()
+			req = req.WithContext(ctx)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (c *envContextImpl) GetStreamConnectionCounts() map[basictypes.StreamKind]int {
+	c.streamConnsLock.Lock()
+	defer c.streamConnsLock.Unlock()
+	ret := make(map[basictypes.StreamKind]int, len(c.streamProviders))
+	for _, sp := range c.streamProviders {
+		ret[sp.Kind()] = c.streamConns[sp.Kind()]
+	}
+	return ret
+}
+
+func (c *envContextImpl) GetDataStatus() EnvDataStatus {
+	return c.dataStatus.get()
+}
+
+func (c *envContextImpl) SendShutdownNotice() {
+	c.envStreams.SendShutdownNotice()
+}
+
+func (c *envContextImpl) DisconnectStreams() {
+	c.envStreams.DisconnectAll()
+}
+
 func (c *envContextImpl) GetEventDispatcher() *events.EventDispatcher {
 	return c.eventDispatcher
 }
 
 func (c *envContextImpl) GetJSClientContext() JSClientContext {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	return c.jsContext
 }
 
+func (c *envContextImpl) SetJSClientCORS(origins, headers []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.jsContext.Origins = origins
+	c.jsContext.Headers = headers
+}
+
 func (c *envContextImpl) GetMetricsContext() context.Context {
 	if c.metricsEnv == nil {
 		return context.Background()
@@ -685,6 +820,20 @@ func (c *envContextImpl) SetSecureMode(secureMode bool) {
 	c.secureMode = secureMode
 }
 
+func (c *envContextImpl) GetAllowedClientCertificates() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.allowedClientCerts
+}
+
+func (c *envContextImpl) SetAllowedClientCertificates(allowed []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.allowedClientCerts = allowed
+}
+
 func (c *envContextImpl) GetDataStoreInfo() sdks.DataStoreEnvironmentInfo {
 	return c.dataStoreInfo
 }
@@ -767,8 +916,10 @@ func (q envContextStoreQueries) GetAll(kind ldstoretypes.DataKind) ([]ldstoretyp
 
 func (u *envContextStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
 	// We use this delegator, rather than sending updates directory to context.envStreams, so that we
-	// can detect the presence of a big segment and turn on the big segment synchronizer as needed.
+	// can detect the presence of a big segment and turn on the big segment synchronizer as needed, and
+	// keep track of what data we have for the status resource.
 	u.context.envStreams.SendAllDataUpdate(allData)
+	u.context.dataStatus.allDataUpdated(allData)
 	if u.context.bigSegmentSync == nil {
 		return
 	}
@@ -792,6 +943,7 @@ func (u *envContextStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Colle
 func (u *envContextStreamUpdates) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
 	// See comments in SendAllDataUpdate.
 	u.context.envStreams.SendSingleItemUpdate(kind, key, item)
+	u.context.dataStatus.itemUpdated(kind, key, item)
 	if u.context.bigSegmentSync == nil {
 		return
 	}
@@ -810,6 +962,55 @@ func (u *envContextStreamUpdates) InvalidateClientSideState() {
 	u.context.envStreams.InvalidateClientSideState()
 }
 
+func (d *envDataStatusTracker) allDataUpdated(allData []ldstoretypes.Collection) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.itemKeys = make(map[string]map[string]struct{})
+	for _, coll := range allData {
+		for _, keyedItem := range coll.Items {
+			d.setItem(coll.Kind, keyedItem.Key, keyedItem.Item)
+		}
+	}
+	d.lastUpdated = time.Now()
+}
+
+func (d *envDataStatusTracker) itemUpdated(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.itemKeys == nil {
+		d.itemKeys = make(map[string]map[string]struct{})
+	}
+	d.setItem(kind, key, item)
+	d.lastUpdated = time.Now()
+}
+
+func (d *envDataStatusTracker) setItem(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	keys := d.itemKeys[kind.GetName()]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		d.itemKeys[kind.GetName()] = keys
+	}
+	if item.Item == nil {
+		delete(keys, key) // a deleted item is represented by a tombstone that has only a version number
+	} else {
+		keys[key] = struct{}{}
+	}
+	if item.Version > d.latestVersion {
+		d.latestVersion = item.Version
+	}
+}
+
+func (d *envDataStatusTracker) get() EnvDataStatus {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return EnvDataStatus{
+		FlagCount:     len(d.itemKeys[ldstoreimpl.Features().GetName()]),
+		SegmentCount:  len(d.itemKeys[ldstoreimpl.Segments().GetName()]),
+		LastUpdated:   d.lastUpdated,
+		LatestVersion: d.latestVersion,
+	}
+}
+
 func makeLogPrefix(logNameMode LogNameMode, sdkKey config.SDKKey, envID config.EnvironmentID) string {
 	name := string(sdkKey)
 	if logNameMode == LogNameIsEnvID && envID != "" {
@@ -820,3 +1021,25 @@ func makeLogPrefix(logNameMode LogNameMode, sdkKey config.SDKKey, envID config.E
 	}
 	return fmt.Sprintf("[env: %s]", name)
 }
+
+// eventSpoolDirName returns the name of the subdirectory of the event spool directory that is used for
+// this environment. It must stay the same when Relay is restarted, so that undelivered events from the
+// previous run can be found; that rules out the SDK key, which can be rotated. Characters that are not
+// safe in a file name are replaced, so a short hash of the original name is added to keep names such as
+// "a b" and "a/b" from sharing a directory.
+func eventSpoolDirName(ids EnvIdentifiers) string {
+	name := ids.ConfiguredName
+	if name == "" {
+		name = ids.ProjKey + "-" + ids.EnvKey
+		if ids.FilterKey != "" {
+			name += "-" + string(ids.FilterKey)
+		}
+	}
+	hash := sha256.Sum256([]byte(name))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '_'
+	}, name) + "-" + hex.EncodeToString(hash[:4])
+}
@@ -323,6 +323,19 @@ func TestDisplayName(t *testing.T) {
 	assert.Equal(t, "a b", ei2.GetDisplayName())
 }
 
+func TestEventSpoolDirName(t *testing.T) {
+	name1 := eventSpoolDirName(EnvIdentifiers{ConfiguredName: "my env"})
+	assert.Regexp(t, `^my_env-[0-9a-f]{8}$`, name1)
+	assert.Equal(t, name1, eventSpoolDirName(EnvIdentifiers{ConfiguredName: "my env"}))
+
+	name2 := eventSpoolDirName(EnvIdentifiers{ConfiguredName: "my/env"})
+	assert.Regexp(t, `^my_env-[0-9a-f]{8}$`, name2)
+	assert.NotEqual(t, name1, name2)
+
+	name3 := eventSpoolDirName(EnvIdentifiers{ProjKey: "proj", EnvKey: "env", FilterKey: "filter"})
+	assert.Regexp(t, `^proj-env-filter-[0-9a-f]{8}$`, name3)
+}
+
 func TestMetricsAreExportedForEnvironment(t *testing.T) {
 	// We already have tests for openCensusEventsExporter in the metrics package, but this test verifies that
 	// exporting is configured automatically for every environment that we add (if not disabled).
@@ -705,6 +718,176 @@ func TestReceivingBigSegmentsUpdateCausesClientSideInvalidationEvent(t *testing.
 	})
 }
 
+func TestDataStatusIsUpdatedByStoreUpdates(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	env := makeBasicEnv(t, st.EnvMain.Config, testclient.FakeLDClientFactory(true), mockLog.Loggers, nil)
+	defer env.Close()
+
+	assert.Equal(t, EnvDataStatus{}, env.GetDataStatus())
+
+	updates := env.(*envContextImpl).storeAdapter.GetUpdates()
+	f1 := ldbuilders.NewFlagBuilder("f1").Version(3).Build()
+	f2 := ldbuilders.NewFlagBuilder("f2").Version(5).Build()
+	s1 := ldbuilders.NewSegmentBuilder("s1").Version(4).Build()
+	updates.SendAllDataUpdate([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: f1.Key, Item: st.FlagDesc(f1)}, {Key: f2.Key, Item: st.FlagDesc(f2)},
+		}},
+		{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: s1.Key, Item: st.SegmentDesc(s1)},
+		}},
+	})
+
+	status := env.GetDataStatus()
+	assert.Equal(t, 2, status.FlagCount)
+	assert.Equal(t, 1, status.SegmentCount)
+	assert.Equal(t, 5, status.LatestVersion)
+	assert.False(t, status.LastUpdated.IsZero())
+
+	updates.SendSingleItemUpdate(ldstoreimpl.Features(), f1.Key, ldstoretypes.ItemDescriptor{Version: 6})
+
+	status = env.GetDataStatus()
+	assert.Equal(t, 1, status.FlagCount)
+	assert.Equal(t, 1, status.SegmentCount)
+	assert.Equal(t, 6, status.LatestVersion)
+}
+
+func TestStreamConnectionCounts(t *testing.T) {
+	envConfig := st.EnvMain.Config
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	serverSideStreams := streams.NewStreamProvider(basictypes.ServerSideStream, time.Hour)
+	flagsStreams := streams.NewStreamProvider(basictypes.ServerSideFlagsOnlyStream, time.Hour)
+	sdkStartedCh := make(chan EnvContext)
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:      EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+		EnvConfig:        envConfig,
+		ClientFactory:    testclient.FakeLDClientFactory(true),
+		StreamProviders:  []streams.StreamProvider{serverSideStreams, flagsStreams},
+		Loggers:          mockLog.Loggers,
+		ConnectionMapper: mockConnectionMapper{},
+	}, sdkStartedCh)
+	require.NoError(t, err)
+	defer env.Close()
+	<-sdkStartedCh
+	_ = env.GetStore().Init(nil)
+
+	assert.Equal(t, map[basictypes.StreamKind]int{basictypes.ServerSideStream: 0, basictypes.ServerSideFlagsOnlyStream: 0},
+		env.GetStreamConnectionCounts())
+
+	req, _ := http.NewRequest("GET", "", nil)
+	st.WithStreamRequest(t, req, env.GetStreamHandler(serverSideStreams, envConfig.SDKKey), func(eventCh <-chan eventsource.Event) {
+		helpers.RequireValue(t, eventCh, time.Second)
+		assert.Equal(t, map[basictypes.StreamKind]int{basictypes.ServerSideStream: 1, basictypes.ServerSideFlagsOnlyStream: 0},
+			env.GetStreamConnectionCounts())
+	})
+
+	assert.Eventually(t, func() bool {
+		return env.GetStreamConnectionCounts()[basictypes.ServerSideStream] == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestStreamConnectionLimits(t *testing.T) {
+	envConfig := st.EnvMain.Config
+
+	withEnv := func(t *testing.T, allConfig config.Config, globalLimit *StreamConnectionLimit, action func(EnvContext, streams.StreamProvider, streams.StreamProvider)) {
+		mockLog := ldlogtest.NewMockLog()
+		defer mockLog.DumpIfTestFailed(t)
+
+		serverSideStreams := streams.NewStreamProvider(basictypes.ServerSideStream, time.Hour)
+		flagsStreams := streams.NewStreamProvider(basictypes.ServerSideFlagsOnlyStream, time.Hour)
+		sdkStartedCh := make(chan EnvContext)
+		env, err := NewEnvContext(EnvContextImplParams{
+			Identifiers:           EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+			EnvConfig:             envConfig,
+			AllConfig:             allConfig,
+			ClientFactory:         testclient.FakeLDClientFactory(true),
+			StreamProviders:       []streams.StreamProvider{serverSideStreams, flagsStreams},
+			Loggers:               mockLog.Loggers,
+			ConnectionMapper:      mockConnectionMapper{},
+			StreamConnectionLimit: globalLimit,
+		}, sdkStartedCh)
+		require.NoError(t, err)
+		defer env.Close()
+		<-sdkStartedCh
+		_ = env.GetStore().Init(nil)
+
+		action(env, serverSideStreams, flagsStreams)
+	}
+
+	expectRejected := func(t *testing.T, handler http.Handler) {
+		req, _ := http.NewRequest("GET", "", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	}
+
+	t.Run("per-environment limit applies to each stream kind", func(t *testing.T) {
+		var allConfig config.Config
+		allConfig.Main.MaxStreamConnectionsPerEnv, _ = configtypes.NewOptIntGreaterThanZero(1)
+
+		withEnv(t, allConfig, nil, func(env EnvContext, serverSideStreams, flagsStreams streams.StreamProvider) {
+			req, _ := http.NewRequest("GET", "", nil)
+			st.WithStreamRequest(t, req, env.GetStreamHandler(serverSideStreams, envConfig.SDKKey), func(eventCh <-chan eventsource.Event) {
+				helpers.RequireValue(t, eventCh, time.Second)
+
+				expectRejected(t, env.GetStreamHandler(serverSideStreams, envConfig.SDKKey))
+
+				req, _ := http.NewRequest("GET", "", nil)
+				st.WithStreamRequest(t, req, env.GetStreamHandler(flagsStreams, envConfig.SDKKey), func(eventCh <-chan eventsource.Event) {
+					helpers.RequireValue(t, eventCh, time.Second)
+				})
+			})
+
+			assert.Eventually(t, func() bool {
+				return env.GetStreamConnectionCounts()[basictypes.ServerSideStream] == 0
+			}, time.Second, time.Millisecond*10)
+			req, _ = http.NewRequest("GET", "", nil)
+			st.WithStreamRequest(t, req, env.GetStreamHandler(serverSideStreams, envConfig.SDKKey), func(eventCh <-chan eventsource.Event) {
+				helpers.RequireValue(t, eventCh, time.Second)
+			})
+		})
+	})
+
+	t.Run("global limit applies to all stream kinds", func(t *testing.T) {
+		globalLimit := NewStreamConnectionLimit(1)
+
+		withEnv(t, config.Config{}, globalLimit, func(env EnvContext, serverSideStreams, flagsStreams streams.StreamProvider) {
+			req, _ := http.NewRequest("GET", "", nil)
+			st.WithStreamRequest(t, req, env.GetStreamHandler(serverSideStreams, envConfig.SDKKey), func(eventCh <-chan eventsource.Event) {
+				helpers.RequireValue(t, eventCh, time.Second)
+
+				expectRejected(t, env.GetStreamHandler(serverSideStreams, envConfig.SDKKey))
+				expectRejected(t, env.GetStreamHandler(flagsStreams, envConfig.SDKKey))
+			})
+
+			assert.Eventually(t, func() bool {
+				return globalLimit.count.Load() == 0
+			}, time.Second, time.Millisecond*10)
+		})
+	})
+
+	t.Run("connection time jitter", func(t *testing.T) {
+		var allConfig config.Config
+		allConfig.Main.MaxClientConnectionTime = configtypes.NewOptDuration(time.Millisecond * 200)
+		allConfig.Main.MaxClientConnectionTimeJitter = configtypes.NewOptDuration(time.Millisecond * 100)
+
+		withEnv(t, allConfig, nil, func(env EnvContext, serverSideStreams, flagsStreams streams.StreamProvider) {
+			req, _ := http.NewRequest("GET", "", nil)
+			st.WithStreamRequest(t, req, env.GetStreamHandler(serverSideStreams, envConfig.SDKKey), func(eventCh <-chan eventsource.Event) {
+				helpers.RequireValue(t, eventCh, time.Second)
+				// The stream provider's own MaxConnTime is an hour, so the stream is closed by the jitter logic.
+				assert.Nil(t, helpers.RequireValue(t, eventCh, time.Second))
+			})
+		})
+	})
+}
+
 // This method forces the metrics events exporter to post an event to the event publisher, and then triggers a
 // flush of the event publisher. Because both of those actions are asynchronous, it may be necessary to call it
 // more than once to ensure that the newly posted event is included in the flush.
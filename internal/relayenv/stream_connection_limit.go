@@ -0,0 +1,122 @@
+package relayenv
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v8/internal/util"
+)
+
+const (
+	// streamConnectionRetryAfter is the Retry-After value for a stream request that is rejected because of
+	// a connection limit. SDKs reconnect with their own backoff and jitter, but a proxy or load balancer
+	// may use this.
+	streamConnectionRetryAfter = 30 * time.Second
+
+	// streamConnectionRejectedLogInterval is the minimum time between warnings about rejected stream
+	// connections for an environment, so that a busy Relay instance that stays at its limit does not
+	// log a warning for every rejected connection.
+	streamConnectionRejectedLogInterval = time.Minute
+
+	streamConnsRejectedReasonEnvLimit    = "env_limit"
+	streamConnsRejectedReasonGlobalLimit = "global_limit"
+)
+
+// StreamConnectionLimit tracks the total number of stream connections that are open in all of the
+// environments that share it, so that Relay can enforce MainConfig.MaxStreamConnections.
+//
+// A nil *StreamConnectionLimit is valid and means there is no limit.
+type StreamConnectionLimit struct {
+	max   int64
+	count atomic.Int64
+}
+
+// NewStreamConnectionLimit creates a StreamConnectionLimit with a maximum number of connections.
+func NewStreamConnectionLimit(max int) *StreamConnectionLimit {
+	return &StreamConnectionLimit{max: int64(max)}
+}
+
+// acquire counts a new connection and returns true, or returns false if the limit has been reached.
+func (l *StreamConnectionLimit) acquire() bool {
+	if l == nil {
+		return true
+	}
+	if l.count.Add(1) > l.max {
+		l.count.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (l *StreamConnectionLimit) release() {
+	if l != nil {
+		l.count.Add(-1)
+	}
+}
+
+// streamConnectionPolicy decides whether an environment's stream handlers can accept another connection,
+// according to the MaxStreamConnectionsPerEnv and MaxStreamConnections options, and how long each
+// connection can stay open, according to the MaxClientConnectionTime and MaxClientConnectionTimeJitter
+// options.
+type streamConnectionPolicy struct {
+	maxPerKind  int // zero if there is no per-environment limit
+	globalLimit *StreamConnectionLimit
+	maxConnTime time.Duration
+	jitter      time.Duration
+	lastWarning atomic.Int64 // time of the last warning about rejected connections, in Unix nanoseconds
+	rejected    atomic.Int64 // number of rejected connections since the last warning
+}
+
+func newStreamConnectionPolicy(mainConfig config.MainConfig, globalLimit *StreamConnectionLimit) *streamConnectionPolicy {
+	return &streamConnectionPolicy{
+		maxPerKind:  mainConfig.MaxStreamConnectionsPerEnv.GetOrElse(0),
+		globalLimit: globalLimit,
+		maxConnTime: mainConfig.MaxClientConnectionTime.GetOrElse(0),
+		jitter:      mainConfig.MaxClientConnectionTimeJitter.GetOrElse(0),
+	}
+}
+
+// connectionTime returns the maximum time for a new connection, or zero if there is no jitter. In that
+// case, the stream provider's own MaxClientConnectionTime applies. Otherwise, each connection is closed
+// after a random time between MaxClientConnectionTime minus the jitter and MaxClientConnectionTime, so
+// that connections which were opened at the same time, for instance after a restart, do not all have
+// to reconnect at the same time.
+func (p *streamConnectionPolicy) connectionTime() time.Duration {
+	if p.maxConnTime <= 0 || p.jitter <= 0 {
+		return 0
+	}
+	return p.maxConnTime - time.Duration(rand.Int63n(int64(p.jitter))) //nolint:gosec // doesn't need to be cryptographically secure
+}
+
+// shouldWarnOfRejection counts a rejected connection, and returns the number of connections that have
+// been rejected since the last warning if it is time to log another warning, or zero otherwise.
+func (p *streamConnectionPolicy) shouldWarnOfRejection(now time.Time) int64 {
+	p.rejected.Add(1)
+	last := p.lastWarning.Load()
+	if last != 0 && now.Sub(time.Unix(0, last)) < streamConnectionRejectedLogInterval {
+		return 0
+	}
+	if !p.lastWarning.CompareAndSwap(last, now.UnixNano()) {
+		return 0 // another goroutine is logging the warning
+	}
+	return p.rejected.Swap(0)
+}
+
+// rejectStreamConnection writes a 503 response with a Retry-After header, and records the rejection in
+// metrics.
+func (c *envContextImpl) rejectStreamConnection(w http.ResponseWriter, kind basictypes.StreamKind, reason string) {
+	if n := c.streamConnPolicy.shouldWarnOfRejection(time.Now()); n != 0 {
+		c.loggers.Warnf("Too many %s stream connections (%s); rejected %d connection(s) since the last warning",
+			kind, reason, n)
+	}
+	metrics.RecordStreamConnectionRejected(c.GetMetricsContext(), string(kind), reason)
+	w.Header().Set("Retry-After", strconv.Itoa(int(streamConnectionRetryAfter/time.Second)))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write(util.ErrorJSONMsg("too many stream connections; try again later"))
+}
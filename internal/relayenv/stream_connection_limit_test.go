@@ -0,0 +1,61 @@
+package relayenv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	"github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamConnectionLimit(t *testing.T) {
+	t.Run("acquire and release", func(t *testing.T) {
+		l := NewStreamConnectionLimit(2)
+		assert.True(t, l.acquire())
+		assert.True(t, l.acquire())
+		assert.False(t, l.acquire())
+		l.release()
+		assert.True(t, l.acquire())
+		assert.False(t, l.acquire())
+	})
+
+	t.Run("nil limit", func(t *testing.T) {
+		var l *StreamConnectionLimit
+		for i := 0; i < 10; i++ {
+			assert.True(t, l.acquire())
+		}
+		l.release()
+	})
+}
+
+func TestStreamConnectionPolicyConnectionTime(t *testing.T) {
+	t.Run("no jitter", func(t *testing.T) {
+		var mainConfig config.MainConfig
+		mainConfig.MaxClientConnectionTime = configtypes.NewOptDuration(time.Hour)
+		assert.Equal(t, time.Duration(0), newStreamConnectionPolicy(mainConfig, nil).connectionTime())
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		var mainConfig config.MainConfig
+		mainConfig.MaxClientConnectionTime = configtypes.NewOptDuration(time.Hour)
+		mainConfig.MaxClientConnectionTimeJitter = configtypes.NewOptDuration(time.Minute * 10)
+		p := newStreamConnectionPolicy(mainConfig, nil)
+		for i := 0; i < 100; i++ {
+			connTime := p.connectionTime()
+			assert.Greater(t, connTime, time.Minute*50)
+			assert.LessOrEqual(t, connTime, time.Hour)
+		}
+	})
+}
+
+func TestStreamConnectionPolicyRejectionWarnings(t *testing.T) {
+	p := newStreamConnectionPolicy(config.MainConfig{}, nil)
+	start := time.Now()
+	assert.Equal(t, int64(1), p.shouldWarnOfRejection(start))
+	assert.Equal(t, int64(0), p.shouldWarnOfRejection(start.Add(time.Second)))
+	assert.Equal(t, int64(0), p.shouldWarnOfRejection(start.Add(streamConnectionRejectedLogInterval-time.Second)))
+	assert.Equal(t, int64(3), p.shouldWarnOfRejection(start.Add(streamConnectionRejectedLogInterval)))
+}
@@ -97,7 +97,20 @@ func (e *TestMetricsExporter) ExportView(viewData *view.Data) {
 		for k, v := range e.lastData {
 			dataCopy[k] = v
 		}
-		e.dataCh <- dataCopy
+		// Never block the OpenCensus worker: if no test is reading the data, discard the oldest snapshot,
+		// since each snapshot contains the latest rows for every view anyway. Blocking here would also
+		// deadlock UnregisterExporter, which waits for the worker.
+		for {
+			select {
+			case e.dataCh <- dataCopy:
+				return
+			default:
+				select {
+				case <-e.dataCh:
+				default:
+				}
+			}
+		}
 	}
 }
 
@@ -171,6 +171,23 @@ func (es *EnvStreams) InvalidateClientSideState() {
 	}
 }
 
+// SendShutdownNotice tells all currently connected stream clients for this environment that Relay is
+// shutting down.
+func (es *EnvStreams) SendShutdownNotice() {
+	for _, esp := range es.getEnvStreamProviders() {
+		esp.SendShutdownNotice()
+	}
+}
+
+// DisconnectAll closes all currently active stream connections for this environment, by closing each of
+// its EnvStreamProviders. Unlike Close, it does not stop the heartbeats. It is used during a graceful
+// shutdown, before Close; the environment cannot serve streams after this.
+func (es *EnvStreams) DisconnectAll() {
+	for _, esp := range es.getEnvStreamProviders() {
+		esp.Close()
+	}
+}
+
 // Close shuts down all currently active streams for this environment and releases its resources.
 func (es *EnvStreams) Close() error {
 	close(es.closeCh)
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
 
 	"github.com/launchdarkly/ld-relay/v8/internal/credential"
@@ -29,15 +30,20 @@ type mockStreamProvider struct {
 }
 
 type mockEnvStreamProvider struct {
-	parent         *mockStreamProvider
-	credential     sdkauth.ScopedCredential
-	store          EnvStoreQueries
-	allDataUpdates [][]ldstoretypes.Collection
-	itemUpdates    []sharedtest.ReceivedItemUpdate
-	clientSideUps  int
-	numHeartbeats  int
-	closed         bool
-	lock           sync.Mutex
+	parent          *mockStreamProvider
+	credential      sdkauth.ScopedCredential
+	store           EnvStoreQueries
+	allDataUpdates  [][]ldstoretypes.Collection
+	itemUpdates     []sharedtest.ReceivedItemUpdate
+	clientSideUps   int
+	numHeartbeats   int
+	shutdownNotices int
+	closed          bool
+	lock            sync.Mutex
+}
+
+func (p *mockStreamProvider) Kind() basictypes.StreamKind {
+	return basictypes.ServerSideStream
 }
 
 func (p *mockStreamProvider) Handler(credential sdkauth.ScopedCredential) http.HandlerFunc {
@@ -77,6 +83,12 @@ func (e *mockEnvStreamProvider) SendHeartbeat() {
 	e.numHeartbeats++
 }
 
+func (e *mockEnvStreamProvider) SendShutdownNotice() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.shutdownNotices++
+}
+
 func (e *mockEnvStreamProvider) Close() {
 	e.closed = true
 }
@@ -169,6 +181,26 @@ func TestCloseEnvStreamsClosesAll(t *testing.T) {
 	assert.False(t, esp2.closed)
 }
 
+func TestDisconnectAllClosesStreams(t *testing.T) {
+	sp := &mockStreamProvider{credentialOfDesiredType: config.SDKKey("")}
+
+	store := makeMockStore(nil, nil)
+	es := NewEnvStreams([]StreamProvider{sp}, store, 0, config.DefaultFilter, ldlog.NewDisabledLoggers())
+	defer es.Close()
+
+	sdkKey1, sdkKey2 := config.SDKKey("sdk-key1"), config.SDKKey("sdk-key2")
+	es.AddCredential(sdkKey1)
+	es.AddCredential(sdkKey2)
+
+	require.Len(t, sp.createdStreams, 2)
+	esp1, esp2 := sp.createdStreams[0], sp.createdStreams[1]
+
+	es.DisconnectAll()
+
+	assert.True(t, esp1.closed)
+	assert.True(t, esp2.closed)
+}
+
 func TestSendAllDataUpdateGoesToAllStreams(t *testing.T) {
 	sp := &mockStreamProvider{credentialOfDesiredType: config.SDKKey("")}
 
@@ -243,6 +275,28 @@ func TestInvalidateClientSideStateGoesToAllStreams(t *testing.T) {
 	assert.Equal(t, 1, esp3.clientSideUps)
 }
 
+func TestShutdownNoticeGoesToAllStreams(t *testing.T) {
+	sp := &mockStreamProvider{credentialOfDesiredType: config.SDKKey("")}
+
+	store := makeMockStore(nil, nil)
+	es := NewEnvStreams([]StreamProvider{sp}, store, 0, config.DefaultFilter, ldlog.NewDisabledLoggers())
+	defer es.Close()
+
+	sdkKey1, sdkKey2 := config.SDKKey("sdk-key1"), config.SDKKey("sdk-key2")
+	es.AddCredential(sdkKey1)
+	es.AddCredential(sdkKey2)
+
+	require.Len(t, sp.createdStreams, 2)
+	esp1, esp2 := sp.createdStreams[0], sp.createdStreams[1]
+
+	es.RemoveCredential(sdkKey2)
+
+	es.SendShutdownNotice()
+
+	assert.Equal(t, 1, esp1.shutdownNotices)
+	assert.Equal(t, 0, esp2.shutdownNotices)
+}
+
 func TestHeartbeatsGoToAllStreams(t *testing.T) {
 	heartbeatInterval := time.Millisecond * 20
 
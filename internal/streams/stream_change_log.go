@@ -0,0 +1,140 @@
+package streams
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/launchdarkly/eventsource"
+)
+
+// streamChangeLogCapacity is the maximum number of patch and delete events that are remembered for each
+// environment's server-side stream. A client that has missed more updates than this gets a full "put"
+// event when it reconnects, as it would if there were no change log.
+const streamChangeLogCapacity = 1000
+
+// streamChangeLog assigns IDs to the events that are published on one environment's server-side stream,
+// and remembers the most recent patch and delete events, so that when an SDK reconnects with the ID of
+// the last event it received in its Last-Event-ID header, it can be sent only the events it missed
+// rather than the full data set. This greatly reduces the cost of a large number of SDKs reconnecting
+// at once, for instance after a network interruption.
+//
+// Each ID consists of a random prefix that is unique to this change log, and a sequence number that
+// increases with each event. The prefix ensures that an ID from another Relay instance, or from before
+// Relay was restarted, is never mistaken for one of ours.
+//
+// A nil *streamChangeLog is valid: it does not assign IDs, and never has any events to replay.
+type streamChangeLog struct {
+	prefix   string
+	capacity int
+	lastSeq  uint64
+	baseSeq  uint64 // the earliest sequence number that a client can resume from
+	entries  []streamChangeLogEntry
+	lock     sync.Mutex
+}
+
+type streamChangeLogEntry struct {
+	seq   uint64
+	event eventsource.Event
+}
+
+// identifiedEvent adds an ID to an event.
+type identifiedEvent struct {
+	wrapped eventsource.Event
+	id      string
+}
+
+func (e identifiedEvent) Id() string    { return e.id }              //nolint:golint,stylecheck
+func (e identifiedEvent) Event() string { return e.wrapped.Event() } //nolint:golint
+func (e identifiedEvent) Data() string  { return e.wrapped.Data() }  //nolint:golint
+
+func newStreamChangeLog(capacity int) *streamChangeLog {
+	prefix := make([]byte, 6)
+	_, _ = rand.Read(prefix)
+	return &streamChangeLog{
+		prefix:   hex.EncodeToString(prefix),
+		capacity: capacity,
+	}
+}
+
+// addPut assigns an ID to a "put" event. Since the put event replaces all of the data, the events before
+// it are discarded; a client that has not received the put event must get the full data set.
+func (l *streamChangeLog) addPut(event eventsource.Event) eventsource.Event {
+	if l == nil {
+		return event
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lastSeq++
+	l.baseSeq = l.lastSeq
+	l.entries = nil
+	return identifiedEvent{wrapped: event, id: l.makeID(l.lastSeq)}
+}
+
+// addChange assigns an ID to a "patch" or "delete" event and adds it to the log, discarding the oldest
+// event if the log is full.
+func (l *streamChangeLog) addChange(event eventsource.Event) eventsource.Event {
+	if l == nil {
+		return event
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lastSeq++
+	ret := identifiedEvent{wrapped: event, id: l.makeID(l.lastSeq)}
+	l.entries = append(l.entries, streamChangeLogEntry{seq: l.lastSeq, event: ret})
+	if len(l.entries) > l.capacity {
+		l.baseSeq = l.entries[0].seq
+		l.entries[0] = streamChangeLogEntry{}
+		l.entries = l.entries[1:]
+	}
+	return ret
+}
+
+// currentID returns the ID of the most recently published event. A "put" event that is generated from
+// the data store for a new connection is tagged with this ID, so that the client can resume from that
+// point if it reconnects. The ID must be obtained before querying the store: then the put event may
+// already include some of the changes after that ID, but replaying those again is harmless, since SDKs
+// ignore an update whose version is not newer than what they have.
+func (l *streamChangeLog) currentID() string {
+	if l == nil {
+		return ""
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.makeID(l.lastSeq)
+}
+
+// eventsSince returns the events that were published after the event with the specified ID. It returns
+// false if the ID is empty, is not one of ours, or is too old to resume from, in which case the client
+// needs the full data set.
+func (l *streamChangeLog) eventsSince(id string) ([]eventsource.Event, bool) {
+	if l == nil || id == "" {
+		return nil, false
+	}
+	prefix, seqStr, found := strings.Cut(id, "-")
+	if !found || prefix != l.prefix {
+		return nil, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if seq < l.baseSeq || seq > l.lastSeq {
+		return nil, false
+	}
+	ret := make([]eventsource.Event, 0, l.lastSeq-seq)
+	for _, e := range l.entries {
+		if e.seq > seq {
+			ret = append(ret, e.event)
+		}
+	}
+	return ret, true
+}
+
+func (l *streamChangeLog) makeID(seq uint64) string {
+	return l.prefix + "-" + strconv.FormatUint(seq, 10)
+}
@@ -0,0 +1,104 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/eventsource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamChangeLog(t *testing.T) {
+	patch := func(data string) eventsource.Event { return testEvent{event: "patch", data: data} }
+
+	eventData := func(events []eventsource.Event) []string {
+		ret := make([]string, 0, len(events))
+		for _, e := range events {
+			ret = append(ret, e.Data())
+		}
+		return ret
+	}
+
+	t.Run("IDs increase and are unique to each log", func(t *testing.T) {
+		log1, log2 := newStreamChangeLog(10), newStreamChangeLog(10)
+		id1 := log1.addPut(testEvent{event: "put"}).Id()
+		id2 := log1.addChange(patch("a")).Id()
+		assert.NotEqual(t, "", id1)
+		assert.NotEqual(t, id1, id2)
+		assert.Equal(t, id2, log1.currentID())
+		assert.NotEqual(t, log1.currentID(), log2.currentID())
+	})
+
+	t.Run("events since an ID", func(t *testing.T) {
+		log := newStreamChangeLog(10)
+		id0 := log.currentID()
+		id1 := log.addChange(patch("a")).Id()
+		log.addChange(patch("b"))
+		id3 := log.addChange(patch("c")).Id()
+
+		events, ok := log.eventsSince(id0)
+		require.True(t, ok)
+		assert.Equal(t, []string{"a", "b", "c"}, eventData(events))
+
+		events, ok = log.eventsSince(id1)
+		require.True(t, ok)
+		assert.Equal(t, []string{"b", "c"}, eventData(events))
+		assert.Equal(t, id3, events[1].Id())
+
+		events, ok = log.eventsSince(id3)
+		require.True(t, ok)
+		assert.Len(t, events, 0)
+	})
+
+	t.Run("put event discards earlier events", func(t *testing.T) {
+		log := newStreamChangeLog(10)
+		id1 := log.addChange(patch("a")).Id()
+		id2 := log.addPut(testEvent{event: "put"}).Id()
+		log.addChange(patch("b"))
+
+		_, ok := log.eventsSince(id1)
+		assert.False(t, ok)
+
+		events, ok := log.eventsSince(id2)
+		require.True(t, ok)
+		assert.Equal(t, []string{"b"}, eventData(events))
+	})
+
+	t.Run("oldest events are discarded when log is full", func(t *testing.T) {
+		log := newStreamChangeLog(2)
+		id1 := log.addChange(patch("a")).Id()
+		id2 := log.addChange(patch("b")).Id()
+		log.addChange(patch("c"))
+		log.addChange(patch("d"))
+
+		_, ok := log.eventsSince(id1)
+		assert.False(t, ok)
+
+		events, ok := log.eventsSince(id2)
+		require.True(t, ok)
+		assert.Equal(t, []string{"c", "d"}, eventData(events))
+	})
+
+	t.Run("unknown IDs", func(t *testing.T) {
+		log := newStreamChangeLog(10)
+		log.addChange(patch("a"))
+		otherLog := newStreamChangeLog(10)
+		otherLog.addChange(patch("a"))
+
+		for _, id := range []string{"", "1", "x-1", log.prefix + "-x", log.prefix + "-2", otherLog.currentID()} {
+			_, ok := log.eventsSince(id)
+			assert.False(t, ok, "for ID %q", id)
+		}
+	})
+
+	t.Run("nil log", func(t *testing.T) {
+		var log *streamChangeLog
+		e := testEvent{event: "patch"}
+		assert.Equal(t, e, log.addChange(e))
+		assert.Equal(t, e, log.addPut(e))
+		assert.Equal(t, "", log.currentID())
+		_, ok := log.eventsSince("")
+		assert.False(t, ok)
+	})
+}
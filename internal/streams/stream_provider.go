@@ -21,6 +21,9 @@ import (
 // streams. If the wrong kind of credential is passed, it should behave as it would for an unrecognized
 // key. It is important that there can be more than one StreamProvider for a given credential.
 type StreamProvider interface {
+	// Kind returns the kind of stream endpoint that this StreamProvider implements.
+	Kind() basictypes.StreamKind
+
 	// Handler returns an HTTP request handler for the given scoped SDK credential.
 	// It can return nil if it does not support this type of credential.
 	Handler(credential sdkauth.ScopedCredential) http.HandlerFunc
@@ -42,6 +45,11 @@ type EnvStreamProvider interface {
 	// SendHeartbeat sends keep-alive data on the stream.
 	SendHeartbeat()
 
+	// SendShutdownNotice sends an SSE comment telling clients that Relay is shutting down, shortly
+	// before their connections are closed. SDKs ignore comments, but the notice makes it clear to
+	// anyone inspecting the stream why it ended.
+	SendShutdownNotice()
+
 	// Close releases all resources for this EnvStreamProvider and closes all connections to it.
 	Close()
 }
@@ -63,6 +71,10 @@ func NewStreamProvider(kind basictypes.StreamKind, maxConnTime time.Duration) St
 			server:     newSSEServer(maxConnTime),
 			isJSClient: true,
 		}
+	case basictypes.MobileEvalStream:
+		return newClientSideEvalStreamProvider(maxConnTime, false)
+	case basictypes.JSClientEvalStream:
+		return newClientSideEvalStreamProvider(maxConnTime, true)
 	default:
 		return &serverSideStreamProvider{
 			server: newSSEServer(maxConnTime),
@@ -70,9 +82,12 @@ func NewStreamProvider(kind basictypes.StreamKind, maxConnTime time.Duration) St
 	}
 }
 
+// ShutdownNoticeComment is the text of the SSE comment that is sent by SendShutdownNotice.
+const ShutdownNoticeComment = "relay is shutting down, please reconnect"
+
 func newSSEServer(maxConnTime time.Duration) *eventsource.Server {
 	s := eventsource.NewServer()
-	s.Gzip = false
+	s.Gzip = false // compression, if enabled, is done by middleware.CompressedStreaming so that it can be measured
 	s.AllowCORS = true
 	s.ReplayAll = true
 	s.MaxConnTime = maxConnTime
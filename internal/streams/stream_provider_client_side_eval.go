@@ -0,0 +1,740 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/credential"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+
+	"github.com/launchdarkly/go-jsonstream/v3/jwriter"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	ldeval "github.com/launchdarkly/go-server-sdk-evaluation/v3"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// This is the implementation of a stream for client-side/mobile SDKs that sends flag evaluation results
+// for the evaluation context of each connection, instead of "ping" events. The client receives a "put"
+// event with the results for all of the flags that are available to its kind of SDK when it connects,
+// and after that, whenever there is a data update of any kind, a "patch" or "delete" event for each flag
+// whose result has changed for that context-- so it never needs to make a separate request to get the
+// flag values.
+//
+// Since every connection gets different events, this does not use eventsource.Server; each connection
+// has its own queue of events, which is written to by a goroutine per environment that re-evaluates the
+// flags for every connection after each update. It uses a limited number of worker goroutines to do so
+// for several connections at once. After an update of individual flags or segments, only the flags
+// whose results could have changed are re-evaluated: the updated flags, and the flags that depend on the
+// updated flags or segments through prerequisites or segmentMatch clauses.
+
+// clientSideEvalConnQueueSize is the number of events that can be waiting to be written to a connection.
+// If one update would produce more patch events than will fit in half of that space, the client gets a
+// new "put" event instead, so a slow client only falls further behind by one event per update. If it
+// falls so far behind that the queue is full anyway, it is disconnected; when it reconnects, it gets a
+// new "put" event.
+const clientSideEvalConnQueueSize = 100
+
+// EvalStreamParams are the properties of a request for a client-side evaluation stream. The handler of
+// the StreamProvider for basictypes.MobileEvalStream or basictypes.JSClientEvalStream requires these to
+// be attached to the request with WithEvalStreamParams.
+type EvalStreamParams struct {
+	// Context is the evaluation context that was provided by the SDK.
+	Context ldcontext.Context
+
+	// Evaluator is the environment's evaluator.
+	Evaluator ldeval.Evaluator
+
+	// WithReasons is true if the SDK requested evaluation reasons.
+	WithReasons bool
+}
+
+type evalStreamParamsKey struct{}
+
+// WithEvalStreamParams returns a copy of the request with the specified EvalStreamParams attached.
+func WithEvalStreamParams(req *http.Request, params EvalStreamParams) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), evalStreamParamsKey{}, params))
+}
+
+type clientSideEvalStreamProvider struct {
+	maxConnTime time.Duration
+	isJSClient  bool
+	envs        map[string]*clientSideEvalEnvStreamProvider // keyed by the credential's String() value
+	lock        sync.RWMutex
+}
+
+type clientSideEvalEnvStreamProvider struct {
+	provider   *clientSideEvalStreamProvider
+	channel    string
+	store      EnvStoreQueries
+	isJSClient bool
+	loggers    ldlog.Loggers
+	conns      map[*clientSideEvalConn]struct{}
+	pending    clientSideEvalChanges // changes that the update goroutine has not processed yet
+	updateCh   chan struct{}
+	closeCh    chan struct{}
+	closeOnce  sync.Once
+	lock       sync.Mutex
+}
+
+type clientSideEvalConn struct {
+	params      EvalStreamParams
+	queue       chan clientSideEvalMessage
+	closeCh     chan struct{}
+	closeOnce   sync.Once
+	initialized bool                              // true if the client has received a "put" event
+	sent        map[string]clientSideEvalSentFlag // flag key -> what the client currently has
+	lock        sync.Mutex
+}
+
+// clientSideEvalChanges describes the data changes since the last re-evaluation.
+type clientSideEvalChanges struct {
+	all      bool                // true if all flags must be re-evaluated
+	flags    map[string]struct{} // keys of flags that were updated or deleted
+	segments map[string]struct{} // keys of segments that were updated or deleted
+}
+
+// clientSideEvalSentFlag is what we remember about a flag result that was sent to a client.
+type clientSideEvalSentFlag struct {
+	result  string // the serialized result properties, not including the versions
+	version int
+}
+
+// clientSideEvalMessage is an SSE event, or a comment if event is empty.
+type clientSideEvalMessage struct {
+	event   string
+	data    string
+	comment string
+}
+
+// clientSideFlagResult is the result of evaluating one flag for a client-side SDK.
+type clientSideFlagResult struct {
+	flag          *ldmodel.FeatureFlag
+	detail        ldreason.EvaluationDetail
+	isExperiment  bool
+	prerequisites []string
+}
+
+func newClientSideEvalStreamProvider(maxConnTime time.Duration, isJSClient bool) *clientSideEvalStreamProvider {
+	return &clientSideEvalStreamProvider{
+		maxConnTime: maxConnTime,
+		isJSClient:  isJSClient,
+		envs:        make(map[string]*clientSideEvalEnvStreamProvider),
+	}
+}
+
+func (s *clientSideEvalStreamProvider) validateCredential(credential credential.SDKCredential) bool {
+	if s.isJSClient {
+		_, ok := credential.(config.EnvironmentID)
+		return ok
+	}
+	_, ok := credential.(config.MobileKey)
+	return ok
+}
+
+func (s *clientSideEvalStreamProvider) Kind() basictypes.StreamKind {
+	if s.isJSClient {
+		return basictypes.JSClientEvalStream
+	}
+	return basictypes.MobileEvalStream
+}
+
+func (s *clientSideEvalStreamProvider) Handler(credential sdkauth.ScopedCredential) http.HandlerFunc {
+	if !s.validateCredential(credential.SDKCredential) {
+		return nil
+	}
+	channel := credential.String()
+	return func(w http.ResponseWriter, req *http.Request) {
+		params, ok := req.Context().Value(evalStreamParamsKey{}).(EvalStreamParams)
+		flusher, canFlush := w.(http.Flusher)
+		if !ok || params.Evaluator == nil || !canFlush {
+			// This would be a programming error: the request should have gone through WithEvalStreamParams,
+			// and every response writer that Relay uses for streams supports flushing.
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.lock.RLock()
+		env := s.envs[channel]
+		s.lock.RUnlock()
+		if env == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		env.serve(w, flusher, req, params, s.maxConnTime)
+	}
+}
+
+func (s *clientSideEvalStreamProvider) Register(
+	credential sdkauth.ScopedCredential,
+	store EnvStoreQueries,
+	loggers ldlog.Loggers,
+) EnvStreamProvider {
+	if !s.validateCredential(credential.SDKCredential) {
+		return nil
+	}
+	env := &clientSideEvalEnvStreamProvider{
+		provider:   s,
+		channel:    credential.String(),
+		store:      store,
+		isJSClient: s.isJSClient,
+		loggers:    loggers,
+		conns:      make(map[*clientSideEvalConn]struct{}),
+		updateCh:   make(chan struct{}, 1),
+		closeCh:    make(chan struct{}),
+	}
+	go env.runUpdates()
+	s.lock.Lock()
+	s.envs[env.channel] = env
+	s.lock.Unlock()
+	return env
+}
+
+func (s *clientSideEvalStreamProvider) Close() {
+	s.lock.Lock()
+	envs := s.envs
+	s.envs = make(map[string]*clientSideEvalEnvStreamProvider)
+	s.lock.Unlock()
+	for _, env := range envs {
+		env.Close()
+	}
+}
+
+func (e *clientSideEvalEnvStreamProvider) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	e.requestUpdate(func(changes *clientSideEvalChanges) { changes.all = true })
+}
+
+func (e *clientSideEvalEnvStreamProvider) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	e.requestUpdate(func(changes *clientSideEvalChanges) {
+		switch kind {
+		case ldstoreimpl.Features():
+			if changes.flags == nil {
+				changes.flags = make(map[string]struct{})
+			}
+			changes.flags[key] = struct{}{}
+		case ldstoreimpl.Segments():
+			if changes.segments == nil {
+				changes.segments = make(map[string]struct{})
+			}
+			changes.segments[key] = struct{}{}
+		default:
+			changes.all = true
+		}
+	})
+}
+
+func (e *clientSideEvalEnvStreamProvider) InvalidateClientSideState() {
+	e.requestUpdate(func(changes *clientSideEvalChanges) { changes.all = true })
+}
+
+func (e *clientSideEvalEnvStreamProvider) SendHeartbeat() {
+	for _, conn := range e.getConns() {
+		conn.enqueue(clientSideEvalMessage{})
+	}
+}
+
+func (e *clientSideEvalEnvStreamProvider) SendShutdownNotice() {
+	for _, conn := range e.getConns() {
+		conn.enqueue(clientSideEvalMessage{comment: ShutdownNoticeComment})
+	}
+}
+
+func (e *clientSideEvalEnvStreamProvider) Close() {
+	e.closeOnce.Do(func() {
+		e.lock.Lock()
+		close(e.closeCh)
+		conns := e.conns
+		e.conns = make(map[*clientSideEvalConn]struct{})
+		e.lock.Unlock()
+		for conn := range conns {
+			conn.close()
+		}
+		e.provider.lock.Lock()
+		if e.provider.envs[e.channel] == e {
+			delete(e.provider.envs, e.channel)
+		}
+		e.provider.lock.Unlock()
+	})
+}
+
+// requestUpdate records a data change with addChange, and tells the update goroutine to re-evaluate the
+// affected flags for all connections. If it is already doing so, it will do it again afterward; any
+// number of requests in the meantime are coalesced into one.
+func (e *clientSideEvalEnvStreamProvider) requestUpdate(addChange func(*clientSideEvalChanges)) {
+	e.lock.Lock()
+	addChange(&e.pending)
+	e.lock.Unlock()
+	select {
+	case e.updateCh <- struct{}{}:
+	default:
+	}
+}
+
+func (e *clientSideEvalEnvStreamProvider) runUpdates() {
+	for {
+		select {
+		case <-e.updateCh:
+			e.lock.Lock()
+			changes := e.pending
+			e.pending = clientSideEvalChanges{}
+			e.lock.Unlock()
+			flagKeys := e.affectedFlags(changes)
+			if flagKeys != nil && len(flagKeys) == 0 {
+				continue
+			}
+			e.updateConns(e.getConns(), flagKeys)
+		case <-e.closeCh:
+			return
+		}
+	}
+}
+
+// updateConns calls update for each of the connections, using up to GOMAXPROCS worker goroutines.
+func (e *clientSideEvalEnvStreamProvider) updateConns(conns []*clientSideEvalConn, flagKeys map[string]struct{}) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(conns) {
+		workers = len(conns)
+	}
+	connCh := make(chan *clientSideEvalConn)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for conn := range connCh {
+				e.update(conn, flagKeys)
+			}
+		}()
+	}
+	for _, conn := range conns {
+		connCh <- conn
+	}
+	close(connCh)
+	wg.Wait()
+}
+
+// affectedFlags returns the keys of the flags whose results could have been changed by the specified
+// changes: the changed flags themselves, and the flags that depend on a changed flag or segment, directly
+// or indirectly, through prerequisites or segmentMatch clauses. It returns nil if all flags must be
+// re-evaluated.
+func (e *clientSideEvalEnvStreamProvider) affectedFlags(changes clientSideEvalChanges) map[string]struct{} {
+	if changes.all {
+		return nil
+	}
+	affectedSegments := make(map[string]struct{}, len(changes.segments))
+	for key := range changes.segments {
+		affectedSegments[key] = struct{}{}
+	}
+	if len(affectedSegments) != 0 {
+		segmentItems, err := e.store.GetAll(ldstoreimpl.Segments())
+		if err != nil {
+			e.loggers.Errorf("Error getting all segments: %s\n", err.Error())
+			return nil
+		}
+		addDependents(segmentItems, affectedSegments, func(item ldstoretypes.ItemDescriptor) bool {
+			segment, ok := item.Item.(*ldmodel.Segment)
+			if !ok {
+				return false
+			}
+			for _, rule := range segment.Rules {
+				if clausesReferToSegments(rule.Clauses, affectedSegments) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	affectedFlags := make(map[string]struct{}, len(changes.flags))
+	for key := range changes.flags {
+		affectedFlags[key] = struct{}{}
+	}
+	flagItems, err := e.store.GetAll(ldstoreimpl.Features())
+	if err != nil {
+		e.loggers.Errorf("Error getting all flags: %s\n", err.Error())
+		return nil
+	}
+	addDependents(flagItems, affectedFlags, func(item ldstoretypes.ItemDescriptor) bool {
+		flag, ok := item.Item.(*ldmodel.FeatureFlag)
+		if !ok {
+			return false
+		}
+		for _, prereq := range flag.Prerequisites {
+			if _, ok := affectedFlags[prereq.Key]; ok {
+				return true
+			}
+		}
+		for _, rule := range flag.Rules {
+			if clausesReferToSegments(rule.Clauses, affectedSegments) {
+				return true
+			}
+		}
+		return false
+	})
+	return affectedFlags
+}
+
+// addDependents adds the key of each item for which dependsOnAffected returns true to affected, until
+// there are no more such items.
+func addDependents(
+	items []ldstoretypes.KeyedItemDescriptor,
+	affected map[string]struct{},
+	dependsOnAffected func(ldstoretypes.ItemDescriptor) bool,
+) {
+	for added := true; added; {
+		added = false
+		for _, item := range items {
+			if _, ok := affected[item.Key]; !ok && dependsOnAffected(item.Item) {
+				affected[item.Key] = struct{}{}
+				added = true
+			}
+		}
+	}
+}
+
+func clausesReferToSegments(clauses []ldmodel.Clause, segmentKeys map[string]struct{}) bool {
+	for _, clause := range clauses {
+		if clause.Op != ldmodel.OperatorSegmentMatch {
+			continue
+		}
+		for _, value := range clause.Values {
+			if _, ok := segmentKeys[value.StringValue()]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e *clientSideEvalEnvStreamProvider) getConns() []*clientSideEvalConn {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	ret := make([]*clientSideEvalConn, 0, len(e.conns))
+	for conn := range e.conns {
+		ret = append(ret, conn)
+	}
+	return ret
+}
+
+func (e *clientSideEvalEnvStreamProvider) serve(
+	w http.ResponseWriter,
+	flusher http.Flusher,
+	req *http.Request,
+	params EvalStreamParams,
+	maxConnTime time.Duration,
+) {
+	conn := &clientSideEvalConn{
+		params:  params,
+		queue:   make(chan clientSideEvalMessage, clientSideEvalConnQueueSize),
+		closeCh: make(chan struct{}),
+		sent:    make(map[string]clientSideEvalSentFlag),
+	}
+	e.lock.Lock()
+	select {
+	case <-e.closeCh:
+		e.lock.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	e.conns[conn] = struct{}{}
+	e.lock.Unlock()
+	defer func() {
+		e.lock.Lock()
+		delete(e.conns, conn)
+		e.lock.Unlock()
+	}()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Since the connection was added to e.conns before this, any data update that happens from now on
+	// will be reflected either in this initial evaluation or in a later one, and the "put" event is
+	// always queued before any "patch" events. If the data store is not initialized yet, there is no
+	// "put" event until it is, just as for the ping streams.
+	e.update(conn, nil)
+
+	var maxConnTimer <-chan time.Time
+	if maxConnTime > 0 {
+		timer := time.NewTimer(maxConnTime)
+		defer timer.Stop()
+		maxConnTimer = timer.C
+	}
+	for {
+		select {
+		case m := <-conn.queue:
+			if !m.writeTo(w) {
+				return
+			}
+			flusher.Flush()
+		case <-conn.closeCh:
+			// Write whatever is already queued, such as a shutdown notice, before disconnecting.
+			for {
+				select {
+				case m := <-conn.queue:
+					if !m.writeTo(w) {
+						return
+					}
+				default:
+					flusher.Flush()
+					return
+				}
+			}
+		case <-maxConnTimer:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// update evaluates the flags that are available to this kind of SDK for a connection's context, and
+// queues the events that will bring the client up to date: a "put" event if it has not received one yet,
+// or otherwise a "patch" or "delete" event for each flag whose result has changed. If flagKeys is not nil,
+// only those flags are evaluated.
+func (e *clientSideEvalEnvStreamProvider) update(conn *clientSideEvalConn, flagKeys map[string]struct{}) {
+	// Holding the lock during the evaluations ensures that if two updates for the same connection overlap,
+	// the one that finishes last is the one that read the latest data.
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	if !e.store.IsInitialized() {
+		return
+	}
+	if !conn.initialized {
+		flagKeys = nil
+	}
+	results, ok := e.evaluate(conn, flagKeys)
+	if !ok {
+		return
+	}
+	if !conn.initialized {
+		conn.sendPut(results)
+		return
+	}
+	messages := conn.makePatches(results, flagKeys)
+	if len(messages) > cap(conn.queue)/2-len(conn.queue) {
+		// A "put" event replaces all of the client's flags, so it can take the place of any number of
+		// patches; see clientSideEvalConnQueueSize.
+		if flagKeys != nil {
+			if results, ok = e.evaluate(conn, nil); !ok {
+				return
+			}
+		}
+		conn.sendPut(results)
+		return
+	}
+	for _, m := range messages {
+		conn.enqueue(m)
+	}
+}
+
+// evaluate returns the results of the flags that are available to this kind of SDK for a connection's
+// context. If flagKeys is not nil, only those flags are evaluated.
+func (e *clientSideEvalEnvStreamProvider) evaluate(
+	conn *clientSideEvalConn,
+	flagKeys map[string]struct{},
+) (map[string]clientSideFlagResult, bool) {
+	items, err := e.store.GetAll(ldstoreimpl.Features())
+	if err != nil {
+		e.loggers.Errorf("Error getting all flags: %s\n", err.Error())
+		return nil, false
+	}
+	results := make(map[string]clientSideFlagResult, len(items))
+	for _, item := range items {
+		flag, ok := item.Item.Item.(*ldmodel.FeatureFlag)
+		if !ok || !e.isAvailable(flag) {
+			continue
+		}
+		if flagKeys != nil {
+			if _, ok := flagKeys[flag.Key]; !ok {
+				continue
+			}
+		}
+		results[flag.Key] = evaluateClientSideFlag(conn.params.Evaluator, flag, conn.params.Context)
+	}
+	return results, true
+}
+
+func (e *clientSideEvalEnvStreamProvider) isAvailable(flag *ldmodel.FeatureFlag) bool {
+	if e.isJSClient {
+		return flag.ClientSideAvailability.UsingEnvironmentID
+	}
+	return flag.ClientSideAvailability.UsingMobileKey
+}
+
+// enqueue adds an event to the connection's queue without blocking. If the queue is full, the client
+// is disconnected; see clientSideEvalConnQueueSize.
+func (c *clientSideEvalConn) enqueue(m clientSideEvalMessage) {
+	select {
+	case c.queue <- m:
+	default:
+		c.close()
+	}
+}
+
+func (c *clientSideEvalConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+	})
+}
+
+// sendPut queues a "put" event with all of the flag results, which replaces whatever flags the client
+// had before.
+func (c *clientSideEvalConn) sendPut(results map[string]clientSideFlagResult) {
+	w := jwriter.NewWriter()
+	obj := w.Object()
+	c.sent = make(map[string]clientSideEvalSentFlag, len(results))
+	for _, key := range sortedResultKeys(results) {
+		r := results[key]
+		flagObj := obj.Name(key).Object()
+		r.writeProperties(&flagObj, c.params.WithReasons)
+		flagObj.Name("version").Int(r.flag.Version)
+		flagObj.End()
+		c.sent[key] = clientSideEvalSentFlag{result: r.fingerprint(c.params.WithReasons), version: r.flag.Version}
+	}
+	obj.End()
+	c.initialized = true
+	c.enqueue(clientSideEvalMessage{event: "put", data: string(w.Bytes())})
+}
+
+// makePatches returns a "patch" event for each flag result that is different from what the client has,
+// and a "delete" event for each flag that the client has but that is no longer available, and updates
+// what we remember about the client's flags accordingly. If flagKeys is not nil, only those flags are
+// considered.
+func (c *clientSideEvalConn) makePatches(
+	results map[string]clientSideFlagResult,
+	flagKeys map[string]struct{},
+) []clientSideEvalMessage {
+	var messages []clientSideEvalMessage
+	for _, key := range sortedResultKeys(results) {
+		r := results[key]
+		fingerprint := r.fingerprint(c.params.WithReasons)
+		old, had := c.sent[key]
+		if had && old.result == fingerprint {
+			continue
+		}
+		// The SDK ignores a patch whose version is not greater than the one it has. The result can change
+		// without a new flag version, for instance if a segment changed, so in that case we increment the
+		// version the client already has; the actual flag version is always in "flagVersion".
+		version := r.flag.Version
+		if had && version <= old.version {
+			version = old.version + 1
+		}
+		w := jwriter.NewWriter()
+		obj := w.Object()
+		obj.Name("key").String(key)
+		r.writeProperties(&obj, c.params.WithReasons)
+		obj.Name("version").Int(version)
+		obj.Name("flagVersion").Int(r.flag.Version)
+		obj.End()
+		c.sent[key] = clientSideEvalSentFlag{result: fingerprint, version: version}
+		messages = append(messages, clientSideEvalMessage{event: "patch", data: string(w.Bytes())})
+	}
+
+	deletedKeys := make([]string, 0)
+	for key := range c.sent {
+		if _, ok := results[key]; ok {
+			continue
+		}
+		if _, ok := flagKeys[key]; ok || flagKeys == nil {
+			deletedKeys = append(deletedKeys, key)
+		}
+	}
+	sort.Strings(deletedKeys)
+	for _, key := range deletedKeys {
+		w := jwriter.NewWriter()
+		obj := w.Object()
+		obj.Name("key").String(key)
+		obj.Name("version").Int(c.sent[key].version + 1)
+		obj.End()
+		delete(c.sent, key)
+		messages = append(messages, clientSideEvalMessage{event: "delete", data: string(w.Bytes())})
+	}
+	return messages
+}
+
+func sortedResultKeys(results map[string]clientSideFlagResult) []string {
+	keys := make([]string, 0, len(results))
+	for key := range results {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m clientSideEvalMessage) writeTo(w http.ResponseWriter) bool {
+	var b strings.Builder
+	if m.event == "" {
+		b.WriteString(":" + m.comment + "\n\n")
+	} else {
+		b.WriteString("event: " + m.event + "\n")
+		for _, line := range strings.Split(m.data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	_, err := w.Write([]byte(b.String()))
+	return err == nil
+}
+
+func evaluateClientSideFlag(
+	evaluator ldeval.Evaluator,
+	flag *ldmodel.FeatureFlag,
+	ldContext ldcontext.Context,
+) clientSideFlagResult {
+	var prerequisites []string
+	result := evaluator.Evaluate(flag, ldContext, func(event ldeval.PrerequisiteFlagEvent) {
+		if event.TargetFlagKey == flag.Key {
+			prerequisites = append(prerequisites, event.PrerequisiteFlag.Key)
+		}
+	})
+	return clientSideFlagResult{
+		flag:          flag,
+		detail:        result.Detail,
+		isExperiment:  result.IsExperiment,
+		prerequisites: prerequisites,
+	}
+}
+
+// writeProperties writes the same properties of a flag result that are returned by the client-side
+// evaluation endpoints, except for the version.
+func (r clientSideFlagResult) writeProperties(obj *jwriter.ObjectState, withReasons bool) {
+	r.detail.Value.WriteToJSONWriter(obj.Name("value"))
+	r.detail.VariationIndex.WriteToJSONWriter(obj.Name("variation"))
+	obj.Maybe("trackEvents", r.flag.TrackEvents || r.isExperiment).Bool(true)
+	obj.Maybe("trackReason", r.isExperiment).Bool(true)
+	if withReasons || r.isExperiment {
+		r.detail.Reason.WriteToJSONWriter(obj.Name("reason"))
+	}
+	obj.Maybe("debugEventsUntilDate", r.flag.DebugEventsUntilDate != 0).
+		Float64(float64(r.flag.DebugEventsUntilDate))
+	if len(r.prerequisites) > 0 {
+		prereqArray := obj.Name("prerequisites").Array()
+		for _, p := range r.prerequisites {
+			prereqArray.String(p)
+		}
+		prereqArray.End()
+	}
+}
+
+// fingerprint returns the serialized properties of the result, for detecting whether it has changed.
+func (r clientSideFlagResult) fingerprint(withReasons bool) string {
+	w := jwriter.NewWriter()
+	obj := w.Object()
+	r.writeProperties(&obj, withReasons)
+	obj.End()
+	return string(w.Bytes())
+}
@@ -0,0 +1,414 @@
+package streams
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ldeval "github.com/launchdarkly/go-server-sdk-evaluation/v3"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvalContext = ldcontext.New("user-key")
+
+// evalTestFlag returns a flag that is available to both mobile and JS client SDKs, and whose value is
+// "a" if it is on or "b" if it is off.
+func evalTestFlag(key string, version int, on bool) ldmodel.FeatureFlag {
+	return ldbuilders.NewFlagBuilder(key).Version(version).On(on).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).FallthroughVariation(0).OffVariation(1).
+		ClientSideUsingMobileKey(true).ClientSideUsingEnvironmentID(true).Build()
+}
+
+type evalTestDataProvider struct{}
+
+func (p evalTestDataProvider) GetFeatureFlag(key string) *ldmodel.FeatureFlag { return nil }
+func (p evalTestDataProvider) GetSegment(key string) *ldmodel.Segment         { return nil }
+
+// evalTestStreamProvider wraps a client-side evaluation StreamProvider so that its handler gets the
+// request parameters that would normally be provided by Relay's endpoint handler. This lets us use the
+// same test helpers as for the other stream kinds.
+type evalTestStreamProvider struct {
+	StreamProvider
+	params EvalStreamParams
+}
+
+func (p evalTestStreamProvider) Handler(credential sdkauth.ScopedCredential) http.HandlerFunc {
+	handler := p.StreamProvider.Handler(credential)
+	if handler == nil {
+		return nil
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		handler(w, WithEvalStreamParams(req, p.params))
+	}
+}
+
+// mutableFlagsStore is a store whose flags can be replaced during a test.
+func mutableFlagsStore(flags ...ldmodel.FeatureFlag) (*mockStoreQueries, func(...ldmodel.FeatureFlag)) {
+	store := newMockStoreQueries()
+	setFlags := func(flags ...ldmodel.FeatureFlag) {
+		var items []ldstoretypes.KeyedItemDescriptor
+		for _, f := range flags {
+			items = append(items, ldstoretypes.KeyedItemDescriptor{Key: f.Key, Item: sharedtest.FlagDesc(f)})
+		}
+		store.setupGetAllFn(func(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedItemDescriptor, error) {
+			if kind == ldstoreimpl.Features() {
+				return items, nil
+			}
+			return nil, nil
+		})
+	}
+	setFlags(flags...)
+	return store, setFlags
+}
+
+func TestStreamProviderClientSideEvalConstructors(t *testing.T) {
+	for _, p := range []struct {
+		kind       basictypes.StreamKind
+		isJSClient bool
+		valid      sdkauth.ScopedCredential
+		invalid    []sdkauth.ScopedCredential
+	}{
+		{basictypes.MobileEvalStream, false, sdkauth.New(testMobileKey), []sdkauth.ScopedCredential{sdkauth.New(testSDKKey), sdkauth.New(testEnvID)}},
+		{basictypes.JSClientEvalStream, true, sdkauth.New(testEnvID), []sdkauth.ScopedCredential{sdkauth.New(testSDKKey), sdkauth.New(testMobileKey)}},
+	} {
+		t.Run(string(p.kind), func(t *testing.T) {
+			sp := NewStreamProvider(p.kind, time.Hour)
+			require.NotNil(t, sp)
+			defer sp.Close()
+
+			require.IsType(t, &clientSideEvalStreamProvider{}, sp)
+			assert.Equal(t, p.isJSClient, sp.(*clientSideEvalStreamProvider).isJSClient)
+			assert.Equal(t, time.Hour, sp.(*clientSideEvalStreamProvider).maxConnTime)
+			assert.Equal(t, p.kind, sp.Kind())
+
+			assert.NotNil(t, sp.Handler(p.valid))
+			for _, c := range p.invalid {
+				assert.Nil(t, sp.Handler(c))
+				assert.Nil(t, sp.Register(c, newMockStoreQueries(), ldlog.NewDisabledLoggers()))
+			}
+
+			esp := sp.Register(p.valid, newMockStoreQueries(), ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+			require.IsType(t, &clientSideEvalEnvStreamProvider{}, esp)
+		})
+	}
+}
+
+func TestStreamProviderClientSideEval(t *testing.T) {
+	validCredential := sdkauth.New(testMobileKey)
+	evaluator := ldeval.NewEvaluator(evalTestDataProvider{})
+
+	withStreamProvider := func(t *testing.T, withReasons bool, action func(StreamProvider)) {
+		sp := NewStreamProvider(basictypes.MobileEvalStream, 0)
+		require.NotNil(t, sp)
+		defer sp.Close()
+		action(evalTestStreamProvider{
+			StreamProvider: sp,
+			params:         EvalStreamParams{Context: testEvalContext, Evaluator: evaluator, WithReasons: withReasons},
+		})
+	}
+
+	t.Run("initial event", func(t *testing.T) {
+		jsOnlyFlag := ldbuilders.NewFlagBuilder("js-only").Version(1).ClientSideUsingEnvironmentID(true).
+			ClientSideUsingMobileKey(false).Build()
+		store, _ := mutableFlagsStore(evalTestFlag("flag1", 1, true), evalTestFlag("flag2", 2, false), jsOnlyFlag)
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerInitialEvent(t, sp, validCredential, testEvent{event: "put", data: `{
+				"flag1": {"value": "a", "variation": 0, "version": 1},
+				"flag2": {"value": "b", "variation": 1, "version": 2}
+			}`})
+		})
+	})
+
+	t.Run("initial event with reasons", func(t *testing.T) {
+		store, _ := mutableFlagsStore(evalTestFlag("flag1", 1, true))
+
+		withStreamProvider(t, true, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerInitialEvent(t, sp, validCredential, testEvent{event: "put", data: `{
+				"flag1": {"value": "a", "variation": 0, "version": 1, "reason": {"kind": "FALLTHROUGH"}}
+			}`})
+		})
+	})
+
+	t.Run("initial event - store not initialized", func(t *testing.T) {
+		store, _ := mutableFlagsStore(evalTestFlag("flag1", 1, true))
+		store.setupIsInitialized(false)
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerInitialEvent(t, sp, validCredential, nil)
+		})
+	})
+
+	t.Run("patch is sent only for a flag whose result changed", func(t *testing.T) {
+		store, setFlags := mutableFlagsStore(evalTestFlag("flag1", 1, true), evalTestFlag("flag2", 1, true))
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			initialEvent := testEvent{event: "put", data: `{
+				"flag1": {"value": "a", "variation": 0, "version": 1},
+				"flag2": {"value": "a", "variation": 0, "version": 1}
+			}`}
+			verifyHandlerUpdateEvent(t, sp, validCredential, initialEvent,
+				func() {
+					setFlags(evalTestFlag("flag1", 2, true), evalTestFlag("flag2", 2, false))
+					esp.SendAllDataUpdate(nil)
+				},
+				testEvent{event: "patch", data: `{"key": "flag2", "value": "b", "variation": 1, "version": 2, "flagVersion": 2}`},
+			)
+		})
+	})
+
+	t.Run("patch version increases even if flag version does not", func(t *testing.T) {
+		// flag1 refers to segment1, so a change to segment1 causes it to be re-evaluated
+		segmentFlag := func(on bool) ldmodel.FeatureFlag {
+			flag := evalTestFlag("flag1", 5, on)
+			flag.Rules = []ldmodel.FlagRule{ldbuilders.NewRuleBuilder().ID("r").Variation(1).
+				Clauses(ldbuilders.SegmentMatchClause("segment1")).Build()}
+			return flag
+		}
+		store, setFlags := mutableFlagsStore(segmentFlag(true))
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			handler := sp.Handler(validCredential)
+			req, _ := http.NewRequest("GET", "", nil)
+			sharedtest.WithStreamRequest(t, req, handler, func(eventCh <-chan eventsource.Event) {
+				expectEvent(t, eventCh, testEvent{event: "put", data: `{"flag1": {"value": "a", "variation": 0, "version": 5}}`})
+
+				setFlags(segmentFlag(false))
+				esp.SendSingleItemUpdate(ldstoreimpl.Segments(), "segment1", ldstoretypes.ItemDescriptor{Version: 1})
+				expectEvent(t, eventCh, testEvent{event: "patch",
+					data: `{"key": "flag1", "value": "b", "variation": 1, "version": 6, "flagVersion": 5}`})
+
+				setFlags(segmentFlag(true))
+				esp.InvalidateClientSideState()
+				expectEvent(t, eventCh, testEvent{event: "patch",
+					data: `{"key": "flag1", "value": "a", "variation": 0, "version": 7, "flagVersion": 5}`})
+			})
+		})
+	})
+
+	t.Run("only flags affected by an item update are re-evaluated", func(t *testing.T) {
+		store, setFlags := mutableFlagsStore(evalTestFlag("flag1", 1, true), evalTestFlag("flag2", 1, true))
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			handler := sp.Handler(validCredential)
+			req, _ := http.NewRequest("GET", "", nil)
+			sharedtest.WithStreamRequest(t, req, handler, func(eventCh <-chan eventsource.Event) {
+				expectEvent(t, eventCh, testEvent{event: "put", data: `{
+					"flag1": {"value": "a", "variation": 0, "version": 1},
+					"flag2": {"value": "a", "variation": 0, "version": 1}
+				}`})
+
+				// flag2 has changed in the store too, but only flag1 was reported as updated
+				setFlags(evalTestFlag("flag1", 2, false), evalTestFlag("flag2", 2, false))
+				esp.SendSingleItemUpdate(ldstoreimpl.Features(), "flag1", ldstoretypes.ItemDescriptor{Version: 2})
+				expectEvent(t, eventCh, testEvent{event: "patch",
+					data: `{"key": "flag1", "value": "b", "variation": 1, "version": 2, "flagVersion": 2}`})
+
+				esp.SendSingleItemUpdate(ldstoreimpl.Features(), "flag2", ldstoretypes.ItemDescriptor{Version: 2})
+				expectEvent(t, eventCh, testEvent{event: "patch",
+					data: `{"key": "flag2", "value": "b", "variation": 1, "version": 2, "flagVersion": 2}`})
+			})
+		})
+	})
+
+	t.Run("put replaces patches if connection queue is congested", func(t *testing.T) {
+		store, setFlags := mutableFlagsStore(evalTestFlag("flag1", 1, true), evalTestFlag("flag2", 1, true))
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			conn := &clientSideEvalConn{
+				params:  EvalStreamParams{Context: testEvalContext, Evaluator: evaluator},
+				queue:   make(chan clientSideEvalMessage, clientSideEvalConnQueueSize),
+				closeCh: make(chan struct{}),
+				sent:    make(map[string]clientSideEvalSentFlag),
+			}
+			e := esp.(*clientSideEvalEnvStreamProvider)
+			e.update(conn, nil)
+			require.Len(t, conn.queue, 1)
+			assert.Equal(t, "put", (<-conn.queue).event)
+
+			// leave room for only one more patch event
+			for i := 0; i < clientSideEvalConnQueueSize/2-1; i++ {
+				conn.enqueue(clientSideEvalMessage{event: "ping"})
+			}
+			setFlags(evalTestFlag("flag1", 2, false), evalTestFlag("flag2", 2, false))
+			e.update(conn, map[string]struct{}{"flag1": {}, "flag2": {}})
+
+			require.Len(t, conn.queue, clientSideEvalConnQueueSize/2)
+			for i := 0; i < clientSideEvalConnQueueSize/2-1; i++ {
+				<-conn.queue
+			}
+			m := <-conn.queue
+			assert.Equal(t, "put", m.event)
+			assert.JSONEq(t, `{
+				"flag1": {"value": "b", "variation": 1, "version": 2},
+				"flag2": {"value": "b", "variation": 1, "version": 2}
+			}`, m.data)
+			select {
+			case <-conn.closeCh:
+				assert.Fail(t, "connection should not have been closed")
+			default:
+			}
+		})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store, setFlags := mutableFlagsStore(evalTestFlag("flag1", 1, true), evalTestFlag("flag2", 3, true))
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			initialEvent := testEvent{event: "put", data: `{
+				"flag1": {"value": "a", "variation": 0, "version": 1},
+				"flag2": {"value": "a", "variation": 0, "version": 3}
+			}`}
+			verifyHandlerUpdateEvent(t, sp, validCredential, initialEvent,
+				func() {
+					setFlags(evalTestFlag("flag1", 1, true))
+					esp.SendSingleItemUpdate(ldstoreimpl.Features(), "flag2", ldstoretypes.ItemDescriptor{Version: 4})
+				},
+				testEvent{event: "delete", data: `{"key": "flag2", "version": 4}`},
+			)
+		})
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		store, _ := mutableFlagsStore(evalTestFlag("flag1", 1, true))
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerHeartbeat(t, sp, esp, validCredential)
+		})
+	})
+
+	t.Run("Shutdown notice", func(t *testing.T) {
+		store, _ := mutableFlagsStore(evalTestFlag("flag1", 1, true))
+
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerShutdownNotice(t, sp, esp, validCredential)
+		})
+	})
+
+	t.Run("handler returns 404 for unregistered environment", func(t *testing.T) {
+		withStreamProvider(t, false, func(sp StreamProvider) {
+			handler := sp.Handler(validCredential)
+			require.NotNil(t, handler)
+
+			req, _ := http.NewRequest("GET", "", nil)
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+}
+
+func TestStreamProviderClientSideEvalAffectedFlags(t *testing.T) {
+	prereqFlag := func(key, prereqKey string) ldmodel.FeatureFlag {
+		return ldbuilders.NewFlagBuilder(key).AddPrerequisite(prereqKey, 0).Build()
+	}
+	segmentFlag := func(key, segmentKey string) ldmodel.FeatureFlag {
+		return ldbuilders.NewFlagBuilder(key).
+			AddRule(ldbuilders.NewRuleBuilder().ID("r").Clauses(ldbuilders.SegmentMatchClause(segmentKey))).Build()
+	}
+	flags := []ldmodel.FeatureFlag{
+		ldbuilders.NewFlagBuilder("a").Build(),
+		prereqFlag("b", "a"),
+		prereqFlag("c", "b"),
+		segmentFlag("d", "s2"),
+		ldbuilders.NewFlagBuilder("e").Build(),
+	}
+	segments := []ldmodel.Segment{
+		ldbuilders.NewSegmentBuilder("s1").Build(),
+		ldbuilders.NewSegmentBuilder("s2").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("s1"))).Build(),
+	}
+	store := newMockStoreQueries()
+	store.setupGetAllFn(func(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedItemDescriptor, error) {
+		var items []ldstoretypes.KeyedItemDescriptor
+		if kind == ldstoreimpl.Features() {
+			for _, f := range flags {
+				items = append(items, ldstoretypes.KeyedItemDescriptor{Key: f.Key, Item: sharedtest.FlagDesc(f)})
+			}
+		} else {
+			for _, s := range segments {
+				items = append(items, ldstoretypes.KeyedItemDescriptor{Key: s.Key, Item: sharedtest.SegmentDesc(s)})
+			}
+		}
+		return items, nil
+	})
+	sp := NewStreamProvider(basictypes.MobileEvalStream, 0)
+	defer sp.Close()
+	esp := sp.Register(sdkauth.New(testMobileKey), store, ldlog.NewDisabledLoggers())
+	require.NotNil(t, esp)
+	defer esp.Close()
+	e := esp.(*clientSideEvalEnvStreamProvider)
+
+	keys := func(keys ...string) map[string]struct{} {
+		ret := make(map[string]struct{})
+		for _, k := range keys {
+			ret[k] = struct{}{}
+		}
+		return ret
+	}
+
+	assert.Nil(t, e.affectedFlags(clientSideEvalChanges{all: true, flags: keys("a")}))
+	assert.Equal(t, keys("a", "b", "c"), e.affectedFlags(clientSideEvalChanges{flags: keys("a")}))
+	assert.Equal(t, keys("c"), e.affectedFlags(clientSideEvalChanges{flags: keys("c")}))
+	assert.Equal(t, keys("deleted"), e.affectedFlags(clientSideEvalChanges{flags: keys("deleted")}))
+	assert.Equal(t, keys("d"), e.affectedFlags(clientSideEvalChanges{segments: keys("s1")}))
+	assert.Equal(t, keys("b", "c", "d"), e.affectedFlags(clientSideEvalChanges{flags: keys("b"), segments: keys("s2")}))
+	assert.Equal(t, keys(), e.affectedFlags(clientSideEvalChanges{segments: keys("unused")}))
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
 
 	"github.com/launchdarkly/ld-relay/v8/internal/credential"
@@ -47,6 +48,13 @@ func (s *clientSidePingStreamProvider) validateCredential(credential credential.
 	return false
 }
 
+func (s *clientSidePingStreamProvider) Kind() basictypes.StreamKind {
+	if s.isJSClient {
+		return basictypes.JSClientPingStream
+	}
+	return basictypes.MobilePingStream
+}
+
 func (s *clientSidePingStreamProvider) Handler(credential sdkauth.ScopedCredential) http.HandlerFunc {
 	if !s.validateCredential(credential.SDKCredential) {
 		return nil
@@ -90,6 +98,10 @@ func (e *clientSidePingEnvStreamProvider) SendHeartbeat() {
 	e.server.PublishComment(e.channels, "")
 }
 
+func (e *clientSidePingEnvStreamProvider) SendShutdownNotice() {
+	e.server.PublishComment(e.channels, ShutdownNoticeComment)
+}
+
 func (e *clientSidePingEnvStreamProvider) Close() {
 	for _, key := range e.channels {
 		e.server.Unregister(key, true)
@@ -202,4 +202,16 @@ func TestStreamProviderAllClientSidePing(t *testing.T) {
 			verifyHandlerHeartbeat(t, sp, esp, validCredential)
 		})
 	})
+
+	t.Run("Shutdown notice", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerShutdownNotice(t, sp, esp, validCredential)
+		})
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
@@ -23,17 +24,23 @@ type serverSideStreamProvider struct {
 }
 
 type serverSideEnvStreamProvider struct {
-	server   *eventsource.Server
-	channels []string
+	server    *eventsource.Server
+	channels  []string
+	changeLog *streamChangeLog
 }
 
 type serverSideEnvStreamRepository struct {
-	store   EnvStoreQueries
-	loggers ldlog.Loggers
+	store     EnvStoreQueries
+	changeLog *streamChangeLog
+	loggers   ldlog.Loggers
 
 	flightGroup singleflight.Group
 }
 
+func (s *serverSideStreamProvider) Kind() basictypes.StreamKind {
+	return basictypes.ServerSideStream
+}
+
 func (s *serverSideStreamProvider) Handler(credential sdkauth.ScopedCredential) http.HandlerFunc {
 	if _, ok := credential.SDKCredential.(config.SDKKey); !ok {
 		return nil
@@ -49,9 +56,10 @@ func (s *serverSideStreamProvider) Register(
 	if _, ok := credential.SDKCredential.(config.SDKKey); !ok {
 		return nil
 	}
-	repo := &serverSideEnvStreamRepository{store: store, loggers: loggers}
+	changeLog := newStreamChangeLog(streamChangeLogCapacity)
+	repo := &serverSideEnvStreamRepository{store: store, changeLog: changeLog, loggers: loggers}
 	s.server.Register(credential.String(), repo)
-	envStream := &serverSideEnvStreamProvider{server: s.server, channels: []string{credential.String()}}
+	envStream := &serverSideEnvStreamProvider{server: s.server, channels: []string{credential.String()}, changeLog: changeLog}
 	return envStream
 }
 
@@ -62,14 +70,14 @@ func (s *serverSideStreamProvider) Close() {
 }
 
 func (e *serverSideEnvStreamProvider) SendAllDataUpdate(allData []ldstoretypes.Collection) {
-	e.server.Publish(e.channels, MakeServerSidePutEvent(allData))
+	e.server.Publish(e.channels, e.changeLog.addPut(MakeServerSidePutEvent(allData)))
 }
 
 func (e *serverSideEnvStreamProvider) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
 	if item.Item == nil {
-		e.server.Publish(e.channels, MakeServerSideDeleteEvent(kind, key, item.Version))
+		e.server.Publish(e.channels, e.changeLog.addChange(MakeServerSideDeleteEvent(kind, key, item.Version)))
 	} else {
-		e.server.Publish(e.channels, MakeServerSidePatchEvent(kind, key, item))
+		e.server.Publish(e.channels, e.changeLog.addChange(MakeServerSidePatchEvent(kind, key, item)))
 	}
 }
 
@@ -79,6 +87,10 @@ func (e *serverSideEnvStreamProvider) SendHeartbeat() {
 	e.server.PublishComment(e.channels, "")
 }
 
+func (e *serverSideEnvStreamProvider) SendShutdownNotice() {
+	e.server.PublishComment(e.channels, ShutdownNoticeComment)
+}
+
 func (e *serverSideEnvStreamProvider) Close() {
 	for _, key := range e.channels {
 		e.server.Unregister(key, true)
@@ -95,6 +107,17 @@ func (r *serverSideEnvStreamRepository) Replay(channel, id string) chan eventsou
 		close(out)
 		return out
 	}
+	if events, ok := r.changeLog.eventsSince(id); ok {
+		// The client is reconnecting, and it has not missed so many updates that we no longer know what
+		// they were, so we only need to send those updates rather than all of the data.
+		go func() {
+			defer close(out)
+			for _, event := range events {
+				out <- event
+			}
+		}()
+		return out
+	}
 	go func() {
 		defer close(out)
 		event, err := r.getReplayEvent()
@@ -109,6 +132,7 @@ func (r *serverSideEnvStreamRepository) Replay(channel, id string) chan eventsou
 // getReplayEvent will return a ServerSidePutEvent with all the data needed for a Replay.
 func (r *serverSideEnvStreamRepository) getReplayEvent() (eventsource.Event, error) {
 	data, err, _ := r.flightGroup.Do("getReplayEvent", func() (interface{}, error) {
+		id := r.changeLog.currentID() // see streamChangeLog.currentID about why this comes first
 		flags, err := r.store.GetAll(ldstoreimpl.Features())
 
 		if err != nil {
@@ -126,7 +150,10 @@ func (r *serverSideEnvStreamRepository) getReplayEvent() (eventsource.Event, err
 			{Kind: ldstoreimpl.Segments(), Items: removeDeleted(segments)},
 		}
 
-		event := MakeServerSidePutEvent(allData)
+		var event eventsource.Event = MakeServerSidePutEvent(allData)
+		if id != "" {
+			event = identifiedEvent{wrapped: event, id: id}
+		}
 		return event, nil
 	})
 
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
@@ -23,17 +24,23 @@ type serverSideFlagsOnlyStreamProvider struct {
 }
 
 type serverSideFlagsOnlyEnvStreamProvider struct {
-	server   *eventsource.Server
-	channels []string
+	server    *eventsource.Server
+	channels  []string
+	changeLog *streamChangeLog
 }
 
 type serverSideFlagsOnlyEnvStreamRepository struct {
-	store   EnvStoreQueries
-	loggers ldlog.Loggers
+	store     EnvStoreQueries
+	changeLog *streamChangeLog
+	loggers   ldlog.Loggers
 
 	flightGroup singleflight.Group
 }
 
+func (s *serverSideFlagsOnlyStreamProvider) Kind() basictypes.StreamKind {
+	return basictypes.ServerSideFlagsOnlyStream
+}
+
 func (s *serverSideFlagsOnlyStreamProvider) Handler(params sdkauth.ScopedCredential) http.HandlerFunc {
 	if _, ok := params.SDKCredential.(config.SDKKey); !ok {
 		return nil
@@ -49,9 +56,10 @@ func (s *serverSideFlagsOnlyStreamProvider) Register(
 	if _, ok := params.SDKCredential.(config.SDKKey); !ok {
 		return nil
 	}
-	repo := &serverSideFlagsOnlyEnvStreamRepository{store: store, loggers: loggers}
+	changeLog := newStreamChangeLog(streamChangeLogCapacity)
+	repo := &serverSideFlagsOnlyEnvStreamRepository{store: store, changeLog: changeLog, loggers: loggers}
 	s.server.Register(params.String(), repo)
-	envStream := &serverSideFlagsOnlyEnvStreamProvider{server: s.server, channels: []string{params.String()}}
+	envStream := &serverSideFlagsOnlyEnvStreamProvider{server: s.server, channels: []string{params.String()}, changeLog: changeLog}
 	return envStream
 }
 
@@ -62,7 +70,7 @@ func (s *serverSideFlagsOnlyStreamProvider) Close() {
 }
 
 func (e *serverSideFlagsOnlyEnvStreamProvider) SendAllDataUpdate(allData []ldstoretypes.Collection) {
-	e.server.Publish(e.channels, MakeServerSideFlagsOnlyPutEvent(allData))
+	e.server.Publish(e.channels, e.changeLog.addPut(MakeServerSideFlagsOnlyPutEvent(allData)))
 }
 
 func (e *serverSideFlagsOnlyEnvStreamProvider) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
@@ -70,9 +78,9 @@ func (e *serverSideFlagsOnlyEnvStreamProvider) SendSingleItemUpdate(kind ldstore
 		return
 	}
 	if item.Item == nil {
-		e.server.Publish(e.channels, MakeServerSideFlagsOnlyDeleteEvent(key, item.Version))
+		e.server.Publish(e.channels, e.changeLog.addChange(MakeServerSideFlagsOnlyDeleteEvent(key, item.Version)))
 	} else {
-		e.server.Publish(e.channels, MakeServerSideFlagsOnlyPatchEvent(key, item))
+		e.server.Publish(e.channels, e.changeLog.addChange(MakeServerSideFlagsOnlyPatchEvent(key, item)))
 	}
 }
 
@@ -82,6 +90,10 @@ func (e *serverSideFlagsOnlyEnvStreamProvider) SendHeartbeat() {
 	e.server.PublishComment(e.channels, "")
 }
 
+func (e *serverSideFlagsOnlyEnvStreamProvider) SendShutdownNotice() {
+	e.server.PublishComment(e.channels, ShutdownNoticeComment)
+}
+
 func (e *serverSideFlagsOnlyEnvStreamProvider) Close() {
 	for _, key := range e.channels {
 		e.server.Unregister(key, true)
@@ -94,6 +106,15 @@ func (r *serverSideFlagsOnlyEnvStreamRepository) Replay(channel, id string) chan
 		close(out)
 		return out
 	}
+	if events, ok := r.changeLog.eventsSince(id); ok { // See serverSideEnvStreamRepository.Replay
+		go func() {
+			defer close(out)
+			for _, event := range events {
+				out <- event
+			}
+		}()
+		return out
+	}
 	go func() {
 		defer close(out)
 		event, err := r.getReplayEvent()
@@ -109,6 +130,7 @@ func (r *serverSideFlagsOnlyEnvStreamRepository) getReplayEvent() (eventsource.E
 		if !r.store.IsInitialized() {
 			return nil, nil
 		}
+		id := r.changeLog.currentID()
 		flags, err := r.store.GetAll(ldstoreimpl.Features())
 
 		if err != nil {
@@ -118,6 +140,9 @@ func (r *serverSideFlagsOnlyEnvStreamRepository) getReplayEvent() (eventsource.E
 
 		event := MakeServerSideFlagsOnlyPutEvent(
 			[]ldstoretypes.Collection{{Kind: ldstoreimpl.Features(), Items: removeDeleted(flags)}})
+		if id != "" {
+			event = identifiedEvent{wrapped: event, id: id}
+		}
 		return event, nil
 	})
 
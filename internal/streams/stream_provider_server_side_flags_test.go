@@ -9,6 +9,7 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 
+	"github.com/launchdarkly/eventsource"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
@@ -192,4 +193,38 @@ func TestStreamProviderServerSideFlagsOnly(t *testing.T) {
 			verifyHandlerHeartbeat(t, sp, esp, validCredential)
 		})
 	})
+
+	t.Run("Shutdown notice", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerShutdownNotice(t, sp, esp, validCredential)
+		})
+	})
+
+	t.Run("Replay with Last-Event-ID", func(t *testing.T) {
+		store := makeMockStore([]ldmodel.FeatureFlag{testFlag1}, nil)
+		changeLog := newStreamChangeLog(streamChangeLogCapacity)
+		repo := &serverSideFlagsOnlyEnvStreamRepository{store: store, changeLog: changeLog, loggers: ldlog.NewDisabledLoggers()}
+		esp := &serverSideFlagsOnlyEnvStreamProvider{server: eventsource.NewServer(), changeLog: changeLog}
+		defer esp.server.Close()
+
+		events1 := readAllEvents(repo.Replay("", ""))
+		require.Len(t, events1, 1)
+		assert.Equal(t, "put", events1[0].Event())
+
+		esp.SendSingleItemUpdate(ldstoreimpl.Segments(), testSegment1.Key, sharedtest.SegmentDesc(testSegment1)) // ignored
+		esp.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag2.Key, sharedtest.FlagDesc(testFlag2))
+
+		events2 := readAllEvents(repo.Replay("", events1[0].Id()))
+		require.Len(t, events2, 1)
+		expected := MakeServerSideFlagsOnlyPatchEvent(testFlag2.Key, sharedtest.FlagDesc(testFlag2))
+		assert.Equal(t, "patch", events2[0].Event())
+		assert.JSONEq(t, expected.Data(), events2[0].Data())
+		assert.Equal(t, changeLog.currentID(), events2[0].Id())
+	})
 }
@@ -223,6 +223,18 @@ func TestStreamProviderServerSide(t *testing.T) {
 		})
 	})
 
+	t.Run("Shutdown notice", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			verifyHandlerShutdownNotice(t, sp, esp, validCredential)
+		})
+	})
+
 	t.Run("Replay", func(t *testing.T) {
 		const flagKey = "flagkey"
 
@@ -323,5 +335,41 @@ func TestStreamProviderServerSide(t *testing.T) {
 			assert.Equal(t, 1, getFlagFromEventData(t, events1[0]).Version)
 			assert.Equal(t, 1, getFlagFromEventData(t, events2[0]).Version) // only one computation was done
 		})
+
+		t.Run("client reconnects with Last-Event-ID", func(t *testing.T) {
+			store := makeMockStore([]ldmodel.FeatureFlag{testFlag1}, nil)
+			changeLog := newStreamChangeLog(streamChangeLogCapacity)
+			repo := &serverSideEnvStreamRepository{store: store, changeLog: changeLog, loggers: ldlog.NewDisabledLoggers()}
+
+			events1 := expectReplayedEvents(t, repo.Replay("", ""))
+			require.Len(t, events1, 1)
+			assert.Equal(t, "put", events1[0].Event())
+			lastEventID := events1[0].Id()
+			require.NotEqual(t, "", lastEventID)
+
+			patch := changeLog.addChange(
+				MakeServerSidePatchEvent(ldstoreimpl.Features(), testFlag2.Key, sharedtest.FlagDesc(testFlag2)))
+
+			events2 := expectReplayedEvents(t, repo.Replay("", lastEventID))
+			require.Len(t, events2, 1)
+			assert.Equal(t, "patch", events2[0].Event())
+			assert.Equal(t, patch.Id(), events2[0].Id())
+			assert.Equal(t, patch.Data(), events2[0].Data())
+
+			events3 := expectReplayedEvents(t, repo.Replay("", patch.Id()))
+			assert.Len(t, events3, 0)
+		})
+
+		t.Run("client reconnects with unknown Last-Event-ID", func(t *testing.T) {
+			store := makeMockStore([]ldmodel.FeatureFlag{testFlag1}, nil)
+			changeLog := newStreamChangeLog(streamChangeLogCapacity)
+			repo := &serverSideEnvStreamRepository{store: store, changeLog: changeLog, loggers: ldlog.NewDisabledLoggers()}
+			changeLog.addChange(MakeServerSidePatchEvent(ldstoreimpl.Features(), testFlag2.Key, sharedtest.FlagDesc(testFlag2)))
+
+			events := expectReplayedEvents(t, repo.Replay("", "some-other-id"))
+			require.Len(t, events, 1)
+			assert.Equal(t, "put", events[0].Event())
+			assert.Equal(t, changeLog.currentID(), events[0].Id())
+		})
 	})
 }
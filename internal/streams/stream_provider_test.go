@@ -122,6 +122,25 @@ func verifyHandlerHeartbeat(
 	sp StreamProvider,
 	esp EnvStreamProvider,
 	credential sdkauth.ScopedCredential,
+) {
+	verifyHandlerComment(t, sp, credential, esp.SendHeartbeat, ":")
+}
+
+func verifyHandlerShutdownNotice(
+	t *testing.T,
+	sp StreamProvider,
+	esp EnvStreamProvider,
+	credential sdkauth.ScopedCredential,
+) {
+	verifyHandlerComment(t, sp, credential, esp.SendShutdownNotice, ":"+ShutdownNoticeComment)
+}
+
+func verifyHandlerComment(
+	t *testing.T,
+	sp StreamProvider,
+	credential sdkauth.ScopedCredential,
+	action func(),
+	expectedPrefix string,
 ) {
 	handler := sp.Handler(credential)
 	require.NotNil(t, handler)
@@ -140,11 +159,11 @@ func verifyHandlerHeartbeat(
 			}
 		}
 
-		esp.SendHeartbeat()
+		action()
 
-		line := helpers.RequireValue(t, linesCh, time.Second, "timed out waiting for heartbeat")
-		if !strings.HasPrefix(line, ":") {
-			assert.Fail(t, "received unexpected non-comment data")
+		line := helpers.RequireValue(t, linesCh, time.Second, "timed out waiting for comment")
+		if !strings.HasPrefix(line, expectedPrefix) {
+			assert.Fail(t, "received unexpected data", "expected %q, got %q", expectedPrefix, line)
 		}
 	})
 }
@@ -0,0 +1,176 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketHandler adapts a stream handler, such as one returned by StreamProvider.Handler, so that the
+// stream is delivered over a WebSocket connection instead of as an SSE response. This is for clients in
+// networks where long-lived HTTP responses are not allowed through, but WebSockets are.
+//
+// The stream handler runs exactly as it would for an SSE request, so it sees the same credential,
+// Last-Event-ID header, and request context, and it is subject to the same connection limits and
+// maximum connection time. Each SSE event that it writes is sent as a text message containing a JSON
+// object with "event" and "data" properties, and an "id" property if the event has an ID; the "data"
+// value is the same string as the SSE data. Each SSE comment, such as a heartbeat, is sent as a JSON
+// object with a "comment" property. If the handler returns an error status instead of starting the
+// stream, that is sent as a JSON object with an "error" property containing "status" and "message",
+// and then the connection is closed.
+func WebSocketHandler(sseHandler http.Handler) http.Handler {
+	return websocket.Server{
+		// The browser stream endpoints allow any origin, and other clients normally do not send an Origin
+		// header at all, so unlike the default websocket.Handler we do not check it.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			serveWebSocketStream(ws, sseHandler)
+		},
+	}
+}
+
+type webSocketEventMessage struct {
+	Event string `json:"event"`
+	Data  string `json:"data"`
+	ID    string `json:"id,omitempty"`
+}
+
+type webSocketCommentMessage struct {
+	Comment string `json:"comment"`
+}
+
+type webSocketErrorMessage struct {
+	Error webSocketErrorInfo `json:"error"`
+}
+
+type webSocketErrorInfo struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func serveWebSocketStream(ws *websocket.Conn, sseHandler http.Handler) {
+	req := ws.Request()
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	// Clients are not expected to send anything, but we have to keep reading in order to find out when
+	// the client has closed the connection, so that the stream handler can stop.
+	go func() {
+		var discard []byte
+		for {
+			if err := websocket.Message.Receive(ws, &discard); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	w := newWebSocketStreamWriter(ws)
+	sseHandler.ServeHTTP(w, req.WithContext(ctx))
+	w.finish()
+}
+
+// webSocketStreamWriter is the http.ResponseWriter that the stream handler writes to. It parses the SSE
+// output and sends each event or comment as a WebSocket message.
+type webSocketStreamWriter struct {
+	ws      *websocket.Conn
+	header  http.Header
+	status  int
+	pending []byte // output that does not yet make up a complete line
+	event   webSocketEventMessage
+	data    []string
+	errBody []byte
+}
+
+func newWebSocketStreamWriter(ws *websocket.Conn) *webSocketStreamWriter {
+	return &webSocketStreamWriter{ws: ws, header: make(http.Header)}
+}
+
+func (w *webSocketStreamWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *webSocketStreamWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *webSocketStreamWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status != http.StatusOK {
+		w.errBody = append(w.errBody, data...)
+		return len(data), nil
+	}
+	w.pending = append(w.pending, data...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSuffix(string(w.pending[:i]), "\r")
+		w.pending = w.pending[i+1:]
+		if err := w.processLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flush does nothing, since each message is sent as soon as it is complete; but the stream handlers
+// require the ResponseWriter to be an http.Flusher.
+func (w *webSocketStreamWriter) Flush() {}
+
+// processLine interprets one line of SSE output as described in the SSE specification: a blank line
+// ends the current event, a line starting with a colon is a comment, and any other line is a field.
+func (w *webSocketStreamWriter) processLine(line string) error {
+	if line == "" {
+		if w.data == nil {
+			return nil
+		}
+		w.event.Data = strings.Join(w.data, "\n")
+		err := w.send(w.event)
+		w.event, w.data = webSocketEventMessage{}, nil
+		return err
+	}
+	if strings.HasPrefix(line, ":") {
+		return w.send(webSocketCommentMessage{Comment: strings.TrimPrefix(line[1:], " ")})
+	}
+	name, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+	switch name {
+	case "event":
+		w.event.Event = value
+	case "data":
+		w.data = append(w.data, value)
+	case "id":
+		w.event.ID = value
+	}
+	return nil
+}
+
+// finish sends an error message if the stream handler returned an error status.
+func (w *webSocketStreamWriter) finish() {
+	if w.status == 0 || w.status == http.StatusOK {
+		return
+	}
+	message := strings.TrimSpace(string(w.errBody))
+	if message == "" {
+		message = http.StatusText(w.status)
+	}
+	_ = w.send(webSocketErrorMessage{Error: webSocketErrorInfo{Status: w.status, Message: message}})
+}
+
+func (w *webSocketStreamWriter) send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return websocket.Message.Send(w.ws, string(data))
+}
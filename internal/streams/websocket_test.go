@@ -0,0 +1,95 @@
+package streams
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func dialWebSocketStream(t *testing.T, handler http.Handler, lastEventID string) *websocket.Conn {
+	server := httptest.NewServer(WebSocketHandler(handler))
+	t.Cleanup(server.Close)
+	wsConfig, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http"), server.URL)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		wsConfig.Header.Set("Last-Event-ID", lastEventID)
+	}
+	ws, err := websocket.DialConfig(wsConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+func receiveWebSocketMessage(t *testing.T, ws *websocket.Conn) map[string]interface{} {
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(time.Second)))
+	var data string
+	require.NoError(t, websocket.Message.Receive(ws, &data))
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &message))
+	return message
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	t.Run("sends events and comments", func(t *testing.T) {
+		ws := dialWebSocketStream(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("event: put\ndata: {\"a\":\n"))
+			_, _ = w.Write([]byte("data: 1}\nid: 3\n\n"))
+			_, _ = w.Write([]byte(":\n"))
+			_, _ = w.Write([]byte(":" + ShutdownNoticeComment + "\n\nevent: ping\ndata: \n\n"))
+			w.(http.Flusher).Flush()
+		}), "")
+
+		assert.Equal(t, map[string]interface{}{"event": "put", "data": "{\"a\":\n1}", "id": "3"},
+			receiveWebSocketMessage(t, ws))
+		assert.Equal(t, map[string]interface{}{"comment": ""}, receiveWebSocketMessage(t, ws))
+		assert.Equal(t, map[string]interface{}{"comment": ShutdownNoticeComment}, receiveWebSocketMessage(t, ws))
+		assert.Equal(t, map[string]interface{}{"event": "ping", "data": ""}, receiveWebSocketMessage(t, ws))
+	})
+
+	t.Run("passes Last-Event-ID header to stream handler", func(t *testing.T) {
+		ws := dialWebSocketStream(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("event: put\ndata: " + r.Header.Get("Last-Event-ID") + "\n\n"))
+		}), "5")
+
+		assert.Equal(t, map[string]interface{}{"event": "put", "data": "5"}, receiveWebSocketMessage(t, ws))
+	})
+
+	t.Run("sends error status", func(t *testing.T) {
+		ws := dialWebSocketStream(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("try again later"))
+		}), "")
+
+		assert.Equal(t, map[string]interface{}{
+			"error": map[string]interface{}{"status": float64(http.StatusServiceUnavailable), "message": "try again later"},
+		}, receiveWebSocketMessage(t, ws))
+		var data string
+		assert.Error(t, websocket.Message.Receive(ws, &data))
+	})
+
+	t.Run("stops stream handler when client disconnects", func(t *testing.T) {
+		handlerDone := make(chan struct{})
+		ws := dialWebSocketStream(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("event: put\ndata: {}\n\n"))
+			<-r.Context().Done()
+			close(handlerDone)
+		}), "")
+
+		receiveWebSocketMessage(t, ws)
+		require.NoError(t, ws.Close())
+		select {
+		case <-handlerDone:
+		case <-time.After(time.Second):
+			assert.Fail(t, "timed out waiting for stream handler to stop")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package tlscert
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+func errNoCertificatesInFile(path string) error {
+	return fmt.Errorf("no PEM-encoded certificates found in %q", path)
+}
+
+// LoadCertPool reads a bundle of one or more PEM-encoded CA certificates, such as the ones that are
+// used to verify client certificates.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // the path is provided by the administrator
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errNoCertificatesInFile(path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,32 @@
+package tlscert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertPool(t *testing.T) {
+	t.Run("valid certificate", func(t *testing.T) {
+		certFile, _ := makeCertFiles(t)
+		pool, err := LoadCertPool(certFile)
+		require.NoError(t, err)
+		assert.NotNil(t, pool)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadCertPool(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+
+	t.Run("file without certificates", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0600))
+		_, err := LoadCertPool(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no PEM-encoded certificates found")
+	})
+}
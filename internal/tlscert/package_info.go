@@ -0,0 +1,3 @@
+// Package tlscert loads the TLS certificate for Relay's HTTPS server and reloads it when its files change,
+// and loads the CA certificates that are used to verify client certificates.
+package tlscert
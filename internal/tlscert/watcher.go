@@ -0,0 +1,165 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// DefaultCheckInterval is how often the certificate and key files are checked for changes.
+const DefaultCheckInterval = time.Second * 10
+
+const (
+	logMsgCertLoaded       = "Loaded TLS certificate from %q (expires %s)"
+	logMsgCertReloadFailed = "Unable to reload TLS certificate; will keep using the previous certificate: %s"
+	logMsgCertCheckFailed  = "Unable to check TLS certificate file %q for changes: %s"
+)
+
+func errLoadingCertificate(certFile, keyFile string, err error) error {
+	return fmt.Errorf("unable to load TLS certificate %q with key %q: %w", certFile, keyFile, err)
+}
+
+// Watcher provides the TLS certificate for a server, and replaces it whenever the certificate or key
+// file changes, so that a renewed certificate can be used without restarting the server. Its
+// GetCertificate method can be used as the GetCertificate callback in tls.Config.
+//
+// If a changed file cannot be loaded, for instance because the certificate has been updated but the
+// key has not yet been, the previous certificate stays in use and the Watcher tries again the next
+// time it checks the files.
+type Watcher struct {
+	certFile  string
+	keyFile   string
+	onLoad    func(expiry time.Time)
+	cert      *tls.Certificate
+	expiry    time.Time
+	fileInfos [2]os.FileInfo
+	lock      sync.RWMutex
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	loggers   ldlog.Loggers
+}
+
+// NewWatcher loads the certificate from the specified files, and starts checking them for changes at
+// the specified interval. It returns an error if the certificate cannot be loaded initially.
+//
+// If onLoad is not nil, it is called with the certificate's expiry time whenever a certificate is
+// loaded, including the first time.
+func NewWatcher(
+	certFile, keyFile string,
+	checkInterval time.Duration,
+	onLoad func(expiry time.Time),
+	loggers ldlog.Loggers,
+) (*Watcher, error) {
+	w := &Watcher{
+		certFile: certFile,
+		keyFile:  keyFile,
+		onLoad:   onLoad,
+		closeCh:  make(chan struct{}),
+		loggers:  loggers,
+	}
+	w.fileInfos = w.statFiles()
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	go w.checkLoop(checkInterval)
+	return w, nil
+}
+
+// GetCertificate returns the most recently loaded certificate.
+func (w *Watcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.cert, nil
+}
+
+// Expiry returns the expiry time (NotAfter) of the most recently loaded certificate.
+func (w *Watcher) Expiry() time.Time {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.expiry
+}
+
+// Close stops checking for changes.
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+	})
+}
+
+func (w *Watcher) checkLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-ticker.C:
+			w.checkFiles()
+		}
+	}
+}
+
+func (w *Watcher) checkFiles() {
+	infos := w.statFiles()
+	changed := false
+	for i, info := range infos {
+		if info == nil {
+			return // statFiles has logged the error; try again next time
+		}
+		prev := w.fileInfos[i]
+		if prev == nil || info.ModTime() != prev.ModTime() || info.Size() != prev.Size() {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := w.load(); err != nil {
+		w.loggers.Errorf(logMsgCertReloadFailed, err)
+		return // fileInfos is not updated, so we'll retry on the next check
+	}
+	w.fileInfos = infos
+}
+
+func (w *Watcher) statFiles() [2]os.FileInfo {
+	var ret [2]os.FileInfo
+	for i, path := range []string{w.certFile, w.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			w.loggers.Warnf(logMsgCertCheckFailed, path, err)
+			continue
+		}
+		ret[i] = info
+	}
+	return ret
+}
+
+func (w *Watcher) load() error {
+	cert, err := tls.LoadX509KeyPair(w.certFile, w.keyFile)
+	if err != nil {
+		return errLoadingCertificate(w.certFile, w.keyFile, err)
+	}
+	leaf := cert.Leaf
+	if leaf == nil { // before Go 1.23, LoadX509KeyPair did not populate Leaf
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return errLoadingCertificate(w.certFile, w.keyFile, err)
+		}
+		cert.Leaf = leaf
+	}
+
+	w.lock.Lock()
+	w.cert = &cert
+	w.expiry = leaf.NotAfter
+	w.lock.Unlock()
+
+	w.loggers.Infof(logMsgCertLoaded, w.certFile, leaf.NotAfter.Format(time.RFC3339))
+	if w.onLoad != nil {
+		w.onLoad(leaf.NotAfter)
+	}
+	return nil
+}
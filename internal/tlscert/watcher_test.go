@@ -0,0 +1,98 @@
+package tlscert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCheckInterval = time.Millisecond * 10
+
+func makeCertFiles(t *testing.T) (certFile, keyFile string) {
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, httphelpers.MakeSelfSignedCert(certFile, keyFile))
+	return certFile, keyFile
+}
+
+func TestWatcherLoadsCertificate(t *testing.T) {
+	certFile, keyFile := makeCertFiles(t)
+	mockLog := ldlogtest.NewMockLog()
+	loadedCh := make(chan time.Time, 10)
+
+	w, err := NewWatcher(certFile, keyFile, testCheckInterval, func(expiry time.Time) { loadedCh <- expiry }, mockLog.Loggers)
+	require.NoError(t, err)
+	defer w.Close()
+
+	cert, err := w.GetCertificate(nil)
+	require.NoError(t, err)
+	require.NotNil(t, cert)
+	require.NotNil(t, cert.Leaf)
+	assert.Equal(t, cert.Leaf.NotAfter, w.Expiry())
+	assert.Equal(t, w.Expiry(), helpers.RequireValue(t, loadedCh, time.Second))
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Loaded TLS certificate from .*cert.pem")
+}
+
+func TestWatcherReturnsErrorIfCertificateCannotBeLoaded(t *testing.T) {
+	certFile, _ := makeCertFiles(t)
+
+	_, err := NewWatcher(certFile, filepath.Join(t.TempDir(), "missing"), testCheckInterval, nil,
+		ldlog.NewDisabledLoggers())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to load TLS certificate")
+}
+
+func TestWatcherReloadsChangedCertificate(t *testing.T) {
+	certFile, keyFile := makeCertFiles(t)
+
+	w, err := NewWatcher(certFile, keyFile, testCheckInterval, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer w.Close()
+
+	oldCert, _ := w.GetCertificate(nil)
+
+	// The watcher may see the new key before the new certificate; in that case it should keep using the
+	// old certificate until both files have been updated.
+	newCertFile, newKeyFile := makeCertFiles(t)
+	newCertData, err := os.ReadFile(newCertFile)
+	require.NoError(t, err)
+	newKeyData, err := os.ReadFile(newKeyFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, newKeyData, 0600))
+	require.NoError(t, os.WriteFile(certFile, newCertData, 0600))
+
+	require.Eventually(t, func() bool {
+		cert, _ := w.GetCertificate(nil)
+		return cert != oldCert
+	}, time.Second, testCheckInterval)
+	newCert, _ := w.GetCertificate(nil)
+	assert.NotEqual(t, oldCert.Certificate[0], newCert.Certificate[0])
+	assert.Equal(t, newCert.Leaf.NotAfter, w.Expiry())
+}
+
+func TestWatcherKeepsPreviousCertificateIfNewOneIsInvalid(t *testing.T) {
+	certFile, keyFile := makeCertFiles(t)
+	mockLog := ldlogtest.NewMockLog()
+
+	w, err := NewWatcher(certFile, keyFile, testCheckInterval, nil, mockLog.Loggers)
+	require.NoError(t, err)
+	defer w.Close()
+
+	oldCert, _ := w.GetCertificate(nil)
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0600))
+
+	require.Eventually(t, func() bool {
+		return mockLog.HasMessageMatch(ldlog.Error, "Unable to reload TLS certificate")
+	}, time.Second, testCheckInterval)
+	cert, _ := w.GetCertificate(nil)
+	assert.Same(t, oldCert, cert)
+}
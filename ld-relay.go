@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/kardianos/minwinsvc"
 
@@ -10,10 +12,11 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 	"github.com/launchdarkly/ld-relay/v8/relay"
 	"github.com/launchdarkly/ld-relay/v8/relay/version"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 )
 
 func main() {
-	var c config.Config
 	loggers := logging.MakeDefaultLoggers()
 
 	opts, err := application.ReadOptions(os.Args, os.Stderr)
@@ -30,23 +33,24 @@ func main() {
 		os.Exit(0)
 	}
 
+	if opts.Validate {
+		if err := application.RunValidateCommand(opts, os.Stdout, loggers); err != nil {
+			loggers.Errorf("Configuration is invalid: %s", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	loggers.Infof(
 		"Starting LaunchDarkly relay version %s with %s\n",
 		application.DescribeRelayVersion(version.Version),
 		opts.DescribeConfigSource(),
 	)
 
-	if opts.ConfigFile != "" {
-		if err := config.LoadConfigFile(&c, opts.ConfigFile, loggers); err != nil {
-			loggers.Errorf("Error loading config file: %s", err)
-			os.Exit(1)
-		}
-	}
-	if opts.UseEnvironment {
-		if err := config.LoadConfigFromEnvironment(&c, loggers); err != nil {
-			loggers.Errorf("Configuration error: %s", err)
-			os.Exit(1)
-		}
+	c, err := application.LoadConfig(opts, loggers)
+	if err != nil {
+		loggers.Error(err)
+		os.Exit(1)
 	}
 
 	r, err := relay.NewRelay(c, loggers, nil)
@@ -61,18 +65,76 @@ func main() {
 
 	port := c.Main.Port.GetOrElse(config.DefaultPort)
 
-	_, errs := application.StartHTTPServer(
+	srv, errs := application.StartHTTPServer(
 		port,
 		r,
-		c.Main.TLSEnabled,
-		c.Main.TLSCert,
-		c.Main.TLSKey,
-		c.Main.TLSMinVersion.Get(),
+		r.TLSConfig(),
 		loggers,
 	)
 
-	for err := range errs {
-		loggers.Errorf("Error starting http listener on port: %d  %s", port, err)
-		os.Exit(1)
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+
+	shutdownSignals := make(chan os.Signal, 1)
+	signal.Notify(shutdownSignals, syscall.SIGTERM)
+
+	var configFileChanges <-chan struct{}
+	if opts.WatchConfigFile && opts.ConfigFile != "" {
+		configFileChanges = application.WatchConfigFile(opts.ConfigFile, application.DefaultConfigFileWatchInterval,
+			nil, loggers)
+	}
+
+	// Files that secrets were read from are always watched, so that rotated secrets are picked up without
+	// a restart. The set of files can change whenever the configuration is reloaded.
+	secretFileChanges, stopWatchingSecretFiles := watchSecretFiles(c, loggers)
+	reload := func() {
+		if newConfig, ok := reloadConfig(r, opts, loggers); ok {
+			close(stopWatchingSecretFiles)
+			secretFileChanges, stopWatchingSecretFiles = watchSecretFiles(newConfig, loggers)
+		}
+	}
+
+	for {
+		select {
+		case err := <-errs:
+			loggers.Errorf("Error starting http listener on port: %d  %s", port, err)
+			os.Exit(1)
+		case <-reloadSignals:
+			reload()
+		case <-configFileChanges:
+			reload()
+		case <-secretFileChanges:
+			reload()
+		case <-shutdownSignals:
+			if err := r.Shutdown(srv); err != nil {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+}
+
+// reloadConfig reads the configuration again from the same sources that were used at startup, and
+// applies it to the running Relay. Errors are logged, and the previous configuration stays in effect.
+// It returns the new configuration and true if it was applied.
+func reloadConfig(r *relay.Relay, opts application.Options, loggers ldlog.Loggers) (config.Config, bool) {
+	c, err := application.LoadConfig(opts, loggers)
+	if err != nil {
+		loggers.Errorf("Not reloading configuration: %s", err)
+		return c, false
+	}
+	if err := r.Reload(c); err != nil {
+		return c, false // Reload logs its own errors
+	}
+	return c, true
+}
+
+// watchSecretFiles starts watching the files that secrets in the configuration were read from, if any.
+// Closing the returned closer channel stops the watcher.
+func watchSecretFiles(c config.Config, loggers ldlog.Loggers) (changes <-chan struct{}, closer chan struct{}) {
+	closer = make(chan struct{})
+	if paths := c.SecretFiles(); len(paths) != 0 {
+		changes = application.WatchFiles(paths, application.DefaultConfigFileWatchInterval, closer, loggers)
 	}
+	return changes, closer
 }
@@ -1,6 +1,8 @@
 package relay
 
 import (
+	"time"
+
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
 	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
@@ -23,14 +25,26 @@ type relayAutoConfigActions struct {
 }
 
 func (a *relayAutoConfigActions) AddEnvironment(params envfactory.EnvironmentParams) {
-	// Since we're not holding the lock on the RelayCore, there is theoretically a race condition here
-	// where an environment could be added from elsewhere after we checked in AddOrUpdateEnvironment.
-	// But in reality, this method is only going to be called from a single goroutine in the auto-config
-	// stream handler.
+	a.r.envChangeLock.Lock()
+	defer a.r.envChangeLock.Unlock()
+	a.addEnvironment(params)
+}
+
+// addEnvironment is the implementation of AddEnvironment. The caller must hold envChangeLock.
+func (a *relayAutoConfigActions) addEnvironment(params envfactory.EnvironmentParams) {
+	// We keep the parameters so that Relay.Reload can recreate the environment if a password changes.
+	// This is done even if creating the environment fails, since the failure might be caused by that
+	// password.
+	if a.r.autoConfigEnvParams == nil {
+		a.r.autoConfigEnvParams = make(map[sdkauth.ScopedCredential]envfactory.EnvironmentParams)
+	}
+	a.r.autoConfigEnvParams[sdkauth.NewScoped(params.Identifiers.FilterKey, params.EnvID)] = params
+
 	envConfig := envfactory.NewEnvConfigFactoryForAutoConfig(a.r.config.AutoConfig).MakeEnvironmentConfig(params)
 	env, _, err := a.r.addEnvironment(params.Identifiers, envConfig, nil)
 	if err != nil {
 		a.r.loggers.Errorf(logMsgAutoConfEnvInitError, params.Identifiers.GetDisplayName(), err)
+		return
 	}
 
 	if params.ExpiringSDKKey.Defined() {
@@ -40,11 +54,16 @@ func (a *relayAutoConfigActions) AddEnvironment(params envfactory.EnvironmentPar
 }
 
 func (a *relayAutoConfigActions) UpdateEnvironment(params envfactory.EnvironmentParams) {
-	env, err := a.r.getEnvironment(sdkauth.NewScoped(params.Identifiers.FilterKey, params.EnvID))
+	a.r.envChangeLock.Lock()
+	defer a.r.envChangeLock.Unlock()
+
+	id := sdkauth.NewScoped(params.Identifiers.FilterKey, params.EnvID)
+	env, err := a.r.getEnvironment(id)
 	if err != nil {
 		a.r.loggers.Warnf(logMsgAutoConfUpdateUnknownEnv, params.Identifiers.GetDisplayName())
 		return
 	}
+	a.r.autoConfigEnvParams[id] = params
 
 	env.SetIdentifiers(params.Identifiers)
 	env.SetTTL(params.TTL)
@@ -63,6 +82,10 @@ func (a *relayAutoConfigActions) UpdateEnvironment(params envfactory.Environment
 }
 
 func (a *relayAutoConfigActions) DeleteEnvironment(id config.EnvironmentID, filter config.FilterKey) {
+	a.r.envChangeLock.Lock()
+	defer a.r.envChangeLock.Unlock()
+
+	delete(a.r.autoConfigEnvParams, sdkauth.NewScoped(filter, id))
 	removed := a.r.removeEnvironment(sdkauth.NewScoped(filter, id))
 	if !removed {
 		a.r.loggers.Warnf(logMsgAutoConfDeleteUnknownEnv, id)
@@ -73,3 +96,18 @@ func (a *relayAutoConfigActions) ReceivedAllEnvironments() {
 	a.r.loggers.Info(logMsgAutoConfReceivedAllEnvironments)
 	a.r.setFullyConfigured(true)
 }
+
+// recreateEnvironments replaces every auto-configured environment with a new one, so that it picks up
+// changed settings such as a database password. It returns the number of environments. The caller must
+// hold envChangeLock.
+func (a *relayAutoConfigActions) recreateEnvironments() int {
+	for id, params := range a.r.autoConfigEnvParams {
+		a.r.removeEnvironment(id)
+		if params.ExpiringSDKKey.Defined() && !params.ExpiringSDKKey.Expiration.After(time.Now()) {
+			// the old key has already expired, so the new environment shouldn't accept it again
+			params.ExpiringSDKKey = envfactory.ExpiringSDKKey{}
+		}
+		a.addEnvironment(params)
+	}
+	return len(a.r.autoConfigEnvParams)
+}
@@ -0,0 +1,404 @@
+package relay
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/api"
+	"github.com/launchdarkly/ld-relay/v8/internal/credential"
+	"github.com/launchdarkly/ld-relay/v8/internal/events"
+	"github.com/launchdarkly/ld-relay/v8/internal/logging"
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	"github.com/launchdarkly/ld-relay/v8/internal/util"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	logMsgAdminEnvAdded   = "Added environment %q via admin API"
+	logMsgAdminEnvRemoved = "Removed environment %q via admin API"
+)
+
+var (
+	errAdminEnvsNotStatic    = errors.New("environments are managed by auto-configuration or offline mode and cannot be changed")
+	errAdminEnvAlreadyExists = errors.New("an environment with this name already exists")
+	errAdminEnvNotFound      = errors.New("no environment with this name exists")
+	errAdminEnvNoSDKKey      = errors.New("SDK key is required")
+	errAdminEnvKeyInUse      = errors.New("one of this environment's credentials is already used by another environment")
+	errAdminEnvNoEvents      = errors.New("this environment is not forwarding events")
+	errAdminNoToken          = errors.New("admin API token is required")
+)
+
+func errAdminListenerFailed(err error) error {
+	return fmt.Errorf("failed to start admin API listener: %w", err)
+}
+
+func errAdminEnvBadProperty(name string, err error) error {
+	return fmt.Errorf("invalid value for %q: %w", name, err)
+}
+
+// startAdminServer starts the listener for the admin API on its own port. Unless another host is
+// configured, it accepts connections only on the loopback interface; if TLS is enabled for the main
+// port, the admin API uses the same certificate. It returns an error immediately if the port is not
+// available or if there is no admin token; otherwise the server keeps running until Close.
+func (r *Relay) startAdminServer(c config.Config) error {
+	if c.Admin.Token == "" {
+		return errAdminListenerFailed(errAdminNoToken)
+	}
+	host := c.Admin.Host
+	if host == "" {
+		host = config.DefaultAdminHost
+	}
+	server := &http.Server{
+		Addr:              net.JoinHostPort(host, strconv.Itoa(c.Admin.Port.GetOrElse(0))),
+		Handler:           r.makeAdminRouter(),
+		TLSConfig:         r.TLSConfig(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Separate Listen and Serve here instead of calling ListenAndServe() so that we can immediately
+	// detect if the port isn't available
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return errAdminListenerFailed(err)
+	}
+	r.adminServer = server
+	r.loggers.Infof("Starting admin API listening on %s", server.Addr)
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(listener, "", "") // the certificate is provided by TLSConfig
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != http.ErrServerClosed { // Serve never returns a nil error value
+			r.loggers.Error(errAdminListenerFailed(err))
+		}
+	}()
+	return nil
+}
+
+// makeAdminRouter creates the router for the admin API. These routes are never served on the main
+// port, and every request must provide the configured admin token.
+func (r *Relay) makeAdminRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(logging.GlobalContextLoggersMiddleware(r.loggers), adminTokenMiddleware(r.config.Admin.Token))
+	router.HandleFunc("/environments", r.adminListEnvironments).Methods("GET")
+	router.HandleFunc("/environments/{name:.+}", r.adminAddEnvironment).Methods("PUT")
+	router.HandleFunc("/environments/{name:.+}", r.adminRemoveEnvironment).Methods("DELETE")
+	if r.config.Admin.EventDebug {
+		router.HandleFunc("/environments/{name:.+}/events", r.adminStreamEvents).Methods("GET")
+	}
+	return router
+}
+
+func adminTokenMiddleware(token string) mux.MiddlewareFunc {
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			provided := []byte(req.Header.Get("Authorization"))
+			if token == "" || subtle.ConstantTimeCompare(provided, expected) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func (r *Relay) adminListEnvironments(w http.ResponseWriter, req *http.Request) {
+	reps := make([]api.AdminEnvironmentRep, 0)
+	for _, env := range r.getAllEnvironments() {
+		reps = append(reps, makeAdminEnvironmentRep(env))
+	}
+	sort.Slice(reps, func(i, j int) bool { return reps[i].Name < reps[j].Name })
+	writeAdminJSON(w, http.StatusOK, reps)
+}
+
+func (r *Relay) adminAddEnvironment(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	if !r.hasStaticEnvironments() {
+		writeAdminError(w, http.StatusConflict, errAdminEnvsNotStatic)
+		return
+	}
+
+	// Holding this lock for the whole operation ensures that two concurrent requests can't both pass
+	// the duplicate name and credential checks below.
+	r.envChangeLock.Lock()
+	defer r.envChangeLock.Unlock()
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	var rep api.AdminEnvironmentConfigRep
+	if err := json.Unmarshal(body, &rep); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	envConfig, err := makeEnvConfigFromAdminRep(rep)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := config.ValidateEnvConfig(name, &envConfig, &r.config); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Apply any payload filters that are configured for the environment's project, the same way as
+	// for environments in the configuration file.
+	filtered := makeFilteredEnvironments(&config.Config{
+		Environment: map[string]*config.EnvConfig{name: &envConfig},
+		Filters:     r.config.Filters,
+	})
+	for _, env := range r.getAllEnvironments() {
+		if _, exists := filtered[env.GetIdentifiers().ConfiguredName]; exists {
+			writeAdminError(w, http.StatusConflict, errAdminEnvAlreadyExists)
+			return
+		}
+	}
+	for _, cred := range []credential.SDKCredential{envConfig.SDKKey, envConfig.MobileKey, envConfig.EnvID} {
+		if _, found := r.envsByCredential.Lookup(sdkauth.New(cred)); cred.Defined() && found {
+			writeAdminError(w, http.StatusConflict, errAdminEnvKeyInUse)
+			return
+		}
+	}
+
+	var added []relayenv.EnvContext
+	for envName, ec := range filtered {
+		env, _, err := r.addEnvironment(relayenv.EnvIdentifiers{ConfiguredName: envName}, *ec, nil)
+		if err != nil {
+			for _, a := range added {
+				r.removeEnvironmentContext(a)
+			}
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		added = append(added, env)
+	}
+	if r.adminEnvNames == nil {
+		r.adminEnvNames = make(map[string]struct{})
+	}
+	for _, env := range added {
+		r.adminEnvNames[env.GetIdentifiers().ConfiguredName] = struct{}{}
+	}
+	r.loggers.Infof(logMsgAdminEnvAdded, name)
+
+	reps := make([]api.AdminEnvironmentRep, 0, len(added))
+	for _, env := range added {
+		reps = append(reps, makeAdminEnvironmentRep(env))
+	}
+	sort.Slice(reps, func(i, j int) bool { return reps[i].Name < reps[j].Name })
+	writeAdminJSON(w, http.StatusCreated, reps)
+}
+
+func (r *Relay) adminRemoveEnvironment(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	if !r.hasStaticEnvironments() {
+		writeAdminError(w, http.StatusConflict, errAdminEnvsNotStatic)
+		return
+	}
+	r.envChangeLock.Lock()
+	defer r.envChangeLock.Unlock()
+
+	envs := r.findEnvironmentsByConfiguredName(name)
+	if len(envs) == 0 {
+		writeAdminError(w, http.StatusNotFound, errAdminEnvNotFound)
+		return
+	}
+	for _, env := range envs {
+		r.removeEnvironmentContext(env)
+		delete(r.adminEnvNames, env.GetIdentifiers().ConfiguredName)
+	}
+	r.loggers.Infof(logMsgAdminEnvRemoved, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminStreamEvents streams the analytics event payloads that an environment receives and delivers, as
+// Server-Sent Events, until the client disconnects. The environment is identified by its display name,
+// which is the name shown by adminListEnvironments.
+func (r *Relay) adminStreamEvents(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	var env relayenv.EnvContext
+	for _, e := range r.getAllEnvironments() {
+		if e.GetIdentifiers().GetDisplayName() == name {
+			env = e
+			break
+		}
+	}
+	if env == nil {
+		writeAdminError(w, http.StatusNotFound, errAdminEnvNotFound)
+		return
+	}
+	var inspector *events.EventInspector
+	if dispatcher := env.GetEventDispatcher(); dispatcher != nil {
+		inspector = dispatcher.GetInspector()
+	}
+	flusher, ok := w.(http.Flusher)
+	if inspector == nil || !ok {
+		writeAdminError(w, http.StatusNotFound, errAdminEnvNoEvents)
+		return
+	}
+
+	records, unsubscribe := inspector.Subscribe()
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case record := <-records:
+			data, _ := json.Marshal(record)
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", record.Direction, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// hasStaticEnvironments returns true if the environments come from the Relay configuration, rather
+// than from auto-configuration or offline mode; only in that case can they be changed by the admin API.
+func (r *Relay) hasStaticEnvironments() bool {
+	return !r.config.AutoConfig.Key.Defined() && r.config.OfflineMode.FileDataSource == ""
+}
+
+// findEnvironmentsByConfiguredName returns the environment with the given configured name, along with
+// any environments that were derived from it by applying payload filters. A derived environment is named
+// after the original environment and its filter key, so it is matched by both of those; an unrelated
+// environment whose own name happens to contain a slash, such as "a/b", is not mistaken for a filtered
+// environment derived from "a".
+func (r *Relay) findEnvironmentsByConfiguredName(name string) []relayenv.EnvContext {
+	var ret []relayenv.EnvContext
+	for _, env := range r.getAllEnvironments() {
+		configuredName, filterKey := env.GetIdentifiers().ConfiguredName, env.GetPayloadFilter()
+		if configuredName == name || (filterKey != config.DefaultFilter && configuredName == name+"/"+string(filterKey)) {
+			ret = append(ret, env)
+		}
+	}
+	return ret
+}
+
+// isAdminEnvironment returns true if the environment with the given configured name was added with the
+// admin API, or was derived from such an environment by applying payload filters. The caller must hold
+// envChangeLock.
+func (r *Relay) isAdminEnvironment(configuredName string) bool {
+	_, found := r.adminEnvNames[configuredName]
+	return found
+}
+
+// removeEnvironmentContext is a shortcut for calling removeEnvironment with whatever SDK key the
+// environment is currently using.
+func (r *Relay) removeEnvironmentContext(env relayenv.EnvContext) bool {
+	for _, cred := range env.GetCredentials() {
+		if key, ok := cred.(config.SDKKey); ok {
+			return r.removeEnvironment(sdkauth.NewScoped(env.GetPayloadFilter(), key))
+		}
+	}
+	return false
+}
+
+func makeEnvConfigFromAdminRep(rep api.AdminEnvironmentConfigRep) (config.EnvConfig, error) {
+	if rep.SDKKey == "" {
+		return config.EnvConfig{}, errAdminEnvNoSDKKey
+	}
+	ec := config.EnvConfig{
+		SDKKey:     config.SDKKey(rep.SDKKey),
+		MobileKey:  config.MobileKey(rep.MobileKey),
+		EnvID:      config.EnvironmentID(rep.EnvID),
+		Prefix:     rep.Prefix,
+		TableName:  rep.TableName,
+		SecureMode: rep.SecureMode,
+		ProjKey:    rep.ProjKey,
+	}
+	for _, list := range []struct {
+		values []string
+		target *ct.OptStringList
+	}{
+		{rep.AllowedOrigin, &ec.AllowedOrigin},
+		{rep.AllowedHeader, &ec.AllowedHeader},
+		{rep.AllowedClientCert, &ec.AllowedClientCert},
+		{rep.DropCustomEvent, &ec.DropCustomEvent},
+		{rep.SampleCustomEvent, &ec.SampleCustomEvent},
+		{rep.StripContextAttribute, &ec.StripContextAttribute},
+		{rep.HashContextAttribute, &ec.HashContextAttribute},
+		{rep.DropIdentifyEvents, &ec.DropIdentifyEvents},
+	} {
+		if len(list.values) != 0 {
+			*list.target = ct.NewOptStringList(list.values)
+		}
+	}
+	if rep.LogLevel != "" {
+		if err := ec.LogLevel.UnmarshalText([]byte(rep.LogLevel)); err != nil {
+			return ec, errAdminEnvBadProperty("logLevel", err)
+		}
+	}
+	if rep.TTL != "" {
+		if err := ec.TTL.UnmarshalText([]byte(rep.TTL)); err != nil {
+			return ec, errAdminEnvBadProperty("ttl", err)
+		}
+	}
+	return ec, nil
+}
+
+func makeAdminEnvironmentRep(env relayenv.EnvContext) api.AdminEnvironmentRep {
+	identifiers := env.GetIdentifiers()
+	rep := api.AdminEnvironmentRep{
+		Name:      identifiers.GetDisplayName(),
+		EnvKey:    identifiers.EnvKey,
+		EnvName:   identifiers.EnvName,
+		ProjKey:   identifiers.ProjKey,
+		ProjName:  identifiers.ProjName,
+		FilterKey: string(env.GetPayloadFilter()),
+	}
+	for _, c := range env.GetCredentials() {
+		switch c := c.(type) {
+		case config.SDKKey:
+			rep.SDKKey = sdks.ObscureKey(string(c))
+		case config.MobileKey:
+			rep.MobileKey = sdks.ObscureKey(string(c))
+		case config.EnvironmentID:
+			rep.EnvID = string(c)
+		}
+	}
+	for _, c := range env.GetDeprecatedCredentials() {
+		if key, ok := c.(config.SDKKey); ok {
+			rep.ExpiringSDKKey = sdks.ObscureKey(string(key))
+		}
+	}
+	if err := env.GetInitError(); err != nil {
+		rep.InitError = err.Error()
+	} else if client := env.GetClient(); client != nil {
+		rep.Initialized = client.Initialized()
+	}
+	return rep
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, value interface{}) {
+	data, _ := json.Marshal(value)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+}
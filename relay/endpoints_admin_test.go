@@ -0,0 +1,374 @@
+package relay
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/events"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-secret"
+
+func makeAdminRequest(method, requestURL string, body []byte) *http.Request {
+	h := make(http.Header)
+	h.Set("Authorization", "Bearer "+testAdminToken)
+	return st.BuildRequest(method, requestURL, body, h)
+}
+
+func TestAdminAPIRequiresToken(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		handler := p.relay.makeAdminRouter()
+
+		result, _ := st.DoRequest(st.BuildRequest("GET", "http://localhost/environments", nil, nil), handler)
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+
+		h := make(http.Header)
+		h.Set("Authorization", "Bearer wrong-token")
+		result, _ = st.DoRequest(st.BuildRequest("GET", "http://localhost/environments", nil, h), handler)
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+
+		result, _ = st.DoRequest(makeAdminRequest("GET", "http://localhost/environments", nil), handler)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+	})
+}
+
+func TestAdminServerListensOnLoopbackByDefault(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Admin.Port, _ = ct.NewOptIntGreaterThanZero(st.GetAvailablePort(t))
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		addr := "127.0.0.1:" + strconv.Itoa(config.Admin.Port.GetOrElse(0))
+		require.NotNil(t, p.relay.adminServer)
+		assert.Equal(t, addr, p.relay.adminServer.Addr)
+
+		resp, err := http.DefaultClient.Do(makeAdminRequest("GET", "http://"+addr+"/environments", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestAdminServerUsesTLSIfEnabled(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, httphelpers.MakeSelfSignedCert(certFile, keyFile))
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Main.TLSEnabled = true
+	config.Main.TLSCert, config.Main.TLSKey = certFile, keyFile
+	config.Admin.Port, _ = ct.NewOptIntGreaterThanZero(st.GetAvailablePort(t))
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // self-signed test certificate
+		}}
+		adminURL := "https://127.0.0.1:" + strconv.Itoa(config.Admin.Port.GetOrElse(0)) + "/environments"
+		resp, err := client.Do(makeAdminRequest("GET", adminURL, nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotNil(t, resp.TLS)
+	})
+}
+
+func TestAdminServerDoesNotStartWithoutToken(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		adminConfig := config
+		adminConfig.Admin.Port, _ = ct.NewOptIntGreaterThanZero(st.GetAvailablePort(t))
+		assert.Error(t, p.relay.startAdminServer(adminConfig))
+		assert.Nil(t, p.relay.adminServer)
+	})
+}
+
+func TestAdminAPIListEnvironments(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		result, body := st.DoRequest(makeAdminRequest("GET", "http://localhost/environments", nil), p.relay.makeAdminRouter())
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		envs := ldvalue.Parse(body)
+		require.Equal(t, 2, envs.Count())
+
+		// results are sorted by name, and EnvMobile's name sorts first
+		mobile, main := envs.GetByIndex(0), envs.GetByIndex(1)
+		st.AssertJSONPathMatch(t, st.EnvMobile.Name, mobile, "name")
+		st.AssertJSONPathMatch(t, sdks.ObscureKey(string(st.EnvMobile.Config.SDKKey)), mobile, "sdkKey")
+		st.AssertJSONPathMatch(t, sdks.ObscureKey(string(st.EnvMobile.Config.MobileKey)), mobile, "mobileKey")
+		st.AssertJSONPathMatch(t, true, mobile, "initialized")
+
+		st.AssertJSONPathMatch(t, st.EnvMain.Name, main, "name")
+		st.AssertJSONPathMatch(t, sdks.ObscureKey(string(st.EnvMain.Config.SDKKey)), main, "sdkKey")
+		st.AssertJSONPathMatch(t, true, main, "initialized")
+	})
+}
+
+func TestAdminAPIAddEnvironment(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		handler := p.relay.makeAdminRouter()
+		body := []byte(`{"sdkKey": "` + string(st.EnvMobile.Config.SDKKey) + `", "mobileKey": "` +
+			string(st.EnvMobile.Config.MobileKey) + `", "ttl": "5m"}`)
+
+		result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/added", body), handler)
+		require.Equal(t, http.StatusCreated, result.StatusCode)
+
+		env, err := p.relay.getEnvironment(sdkauth.New(st.EnvMobile.Config.MobileKey))
+		require.NoError(t, err)
+		assert.Equal(t, "added", env.GetIdentifiers().ConfiguredName)
+
+		t.Run("same name is rejected", func(t *testing.T) {
+			body := []byte(`{"sdkKey": "sdk-99999999-9999-4999-8999-999999999998"}`)
+			result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/added", body), handler)
+			assert.Equal(t, http.StatusConflict, result.StatusCode)
+		})
+
+		t.Run("existing credential is rejected", func(t *testing.T) {
+			body := []byte(`{"sdkKey": "` + string(st.EnvMain.Config.SDKKey) + `"}`)
+			result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/other", body), handler)
+			assert.Equal(t, http.StatusConflict, result.StatusCode)
+		})
+
+		t.Run("missing SDK key is rejected", func(t *testing.T) {
+			result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/other", []byte(`{}`)), handler)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		})
+
+		t.Run("invalid property is rejected", func(t *testing.T) {
+			body := []byte(`{"sdkKey": "sdk-99999999-9999-4999-8999-999999999998", "ttl": "x"}`)
+			result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/other", body), handler)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		})
+
+		t.Run("invalid event rule is rejected", func(t *testing.T) {
+			body := []byte(`{"sdkKey": "sdk-99999999-9999-4999-8999-999999999998", "stripContextAttribute": ["key"]}`)
+			result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/other", body), handler)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		})
+
+		t.Run("allowed client certificate without client CA is rejected", func(t *testing.T) {
+			body := []byte(`{"sdkKey": "sdk-99999999-9999-4999-8999-999999999998", "allowedClientCert": ["client-a"]}`)
+			result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/other", body), handler)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		})
+	})
+}
+
+func TestAdminAPIAddEnvironmentWithAllowedClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, httphelpers.MakeSelfSignedCert(certFile, keyFile))
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Main.TLSEnabled = true
+	config.Main.TLSCert, config.Main.TLSKey = certFile, keyFile
+	config.Main.TLSClientCA = certFile
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		body := []byte(`{"sdkKey": "` + string(st.EnvClientSide.Config.SDKKey) + `", "envId": "` +
+			string(st.EnvClientSide.Config.EnvID) + `", "allowedClientCert": ["client-a"], "dropCustomEvent": ["x"]}`)
+		result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/added", body), p.relay.makeAdminRouter())
+		require.Equal(t, http.StatusCreated, result.StatusCode)
+
+		env, err := p.relay.getEnvironment(sdkauth.New(st.EnvClientSide.Config.EnvID))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"client-a"}, env.GetAllowedClientCertificates())
+	})
+}
+
+func TestAdminAPIRemoveEnvironment(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		handler := p.relay.makeAdminRouter()
+
+		result, _ := st.DoRequest(makeAdminRequest("DELETE", "http://localhost/environments/"+url.PathEscape(st.EnvMobile.Name), nil), handler)
+		require.Equal(t, http.StatusNoContent, result.StatusCode)
+
+		_, err := p.relay.getEnvironment(sdkauth.New(st.EnvMobile.Config.SDKKey))
+		assert.Equal(t, errUnrecognizedEnvironment, err)
+		_, err = p.relay.getEnvironment(sdkauth.New(st.EnvMain.Config.SDKKey))
+		assert.NoError(t, err)
+
+		result, _ = st.DoRequest(makeAdminRequest("DELETE", "http://localhost/environments/"+url.PathEscape(st.EnvMobile.Name), nil), handler)
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+}
+
+func TestAdminAPIAddsAndRemovesFilteredEnvironments(t *testing.T) {
+	// The configured environment "added/filter1" is not derived from the environment "added", even though
+	// its name looks like the name of a filtered environment.
+	lookalike := st.EnvMobile.Config
+	lookalike.ProjKey = "proj2"
+	filtered := st.EnvClientSide.Config
+	filtered.ProjKey = "proj1"
+	var config c.Config
+	config.Environment = map[string]*c.EnvConfig{"added/filter1": &lookalike, "base": &filtered}
+	config.Filters = map[string]*c.FiltersConfig{"proj1": {Keys: ct.NewOptStringList([]string{"filter1"})}}
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		handler := p.relay.makeAdminRouter()
+		body := []byte(`{"sdkKey": "` + string(st.EnvMain.Config.SDKKey) + `", "projKey": "proj1"}`)
+
+		result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/added", body), handler)
+		require.Equal(t, http.StatusConflict, result.StatusCode, "filtered environment name is already in use")
+
+		body = []byte(`{"sdkKey": "` + string(st.EnvMain.Config.SDKKey) + `", "projKey": "proj2"}`)
+		result, _ = st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/added", body), handler)
+		require.Equal(t, http.StatusCreated, result.StatusCode)
+
+		result, _ = st.DoRequest(makeAdminRequest("DELETE", "http://localhost/environments/added", nil), handler)
+		require.Equal(t, http.StatusNoContent, result.StatusCode)
+		_, err := p.relay.getEnvironment(sdkauth.New(st.EnvMain.Config.SDKKey))
+		assert.Equal(t, errUnrecognizedEnvironment, err)
+		_, err = p.relay.getEnvironment(sdkauth.New(st.EnvMobile.Config.SDKKey))
+		assert.NoError(t, err)
+
+		body = []byte(`{"sdkKey": "` + string(st.EnvMain.Config.SDKKey) + `", "projKey": "proj1"}`)
+		result, _ = st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/other", body), handler)
+		require.Equal(t, http.StatusCreated, result.StatusCode)
+		_, err = p.relay.getEnvironment(sdkauth.NewScoped("filter1", st.EnvMain.Config.SDKKey))
+		require.NoError(t, err)
+
+		result, _ = st.DoRequest(makeAdminRequest("DELETE", "http://localhost/environments/other", nil), handler)
+		require.Equal(t, http.StatusNoContent, result.StatusCode)
+		_, err = p.relay.getEnvironment(sdkauth.NewScoped("filter1", st.EnvMain.Config.SDKKey))
+		assert.Equal(t, errUnrecognizedEnvironment, err)
+		_, err = p.relay.getEnvironment(sdkauth.New(st.EnvMobile.Config.SDKKey))
+		assert.NoError(t, err)
+	})
+}
+
+func TestAdminAPICannotChangeEnvironmentsInOfflineMode(t *testing.T) {
+	relay := &Relay{config: c.Config{OfflineMode: c.OfflineModeConfig{FileDataSource: "archive.tar.gz"}}}
+	relay.config.Admin.Token = testAdminToken
+	relay.envsByCredential = NewEnvironmentLookup()
+
+	handler := relay.makeAdminRouter()
+	body := []byte(`{"sdkKey": "` + string(st.EnvMain.Config.SDKKey) + `"}`)
+	result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/x", body), handler)
+	assert.Equal(t, http.StatusConflict, result.StatusCode)
+
+	result, _ = st.DoRequest(makeAdminRequest("DELETE", "http://localhost/environments/x", nil), handler)
+	assert.Equal(t, http.StatusConflict, result.StatusCode)
+}
+
+// readAdminEventStream reads the events from an event debugging stream into a channel, until the body
+// is closed.
+func readAdminEventStream(resp *http.Response) <-chan events.EventInspectorRecord {
+	ch := make(chan events.EventInspectorRecord, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var record events.EventInspectorRecord
+				if json.Unmarshal([]byte(data), &record) == nil {
+					ch <- record
+				}
+			}
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+func TestAdminAPIStreamsEventPayloads(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Admin.Port, _ = ct.NewOptIntGreaterThanZero(st.GetAvailablePort(t))
+	config.Admin.Token = testAdminToken
+	config.Admin.EventDebug = true
+
+	relayEventsTest(t, config, func(p relayEventsTestParams) {
+		adminServer := httptest.NewServer(p.relay.makeAdminRouter())
+		defer adminServer.Close()
+
+		streamURL := adminServer.URL + "/environments/" + url.PathEscape(st.EnvMain.Name) + "/events"
+		resp, err := http.DefaultClient.Do(makeAdminRequest("GET", streamURL, nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		records := readAdminEventStream(resp)
+
+		body := makeTestFeatureEventPayload("me")
+		header := make(http.Header)
+		header.Set("Authorization", string(st.EnvMain.Config.SDKKey))
+		header.Set(events.EventSchemaHeader, strconv.Itoa(events.SummaryEventsSchemaVersion))
+		result, _ := st.DoRequest(st.BuildRequest("POST", "http://localhost/bulk", body, header), p.relay)
+		require.Equal(t, http.StatusAccepted, result.StatusCode)
+
+		inbound := helpers.RequireValue(t, records, time.Second)
+		assert.Equal(t, events.EventInspectorInbound, inbound.Direction)
+		assert.Equal(t, "/bulk", inbound.Path)
+		assert.Equal(t, sdks.ObscureKey(string(st.EnvMain.Config.SDKKey)), inbound.Credential)
+		assert.Equal(t, 1, inbound.Count)
+		assert.JSONEq(t, string(body), string(inbound.Payload))
+
+		outbound := helpers.RequireValue(t, records, time.Second*3) // delivered at the next flush
+		assert.Equal(t, events.EventInspectorOutbound, outbound.Direction)
+		assert.Equal(t, sdks.ObscureKey(string(st.EnvMain.Config.SDKKey)), outbound.Credential)
+		assert.JSONEq(t, string(body), string(outbound.Payload))
+	})
+}
+
+func TestAdminAPIEventStreamForUnknownEnvironment(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Admin.Port, _ = ct.NewOptIntGreaterThanZero(st.GetAvailablePort(t))
+	config.Admin.Token = testAdminToken
+	config.Admin.EventDebug = true
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		handler := p.relay.makeAdminRouter()
+
+		result, _ := st.DoRequest(makeAdminRequest("GET", "http://localhost/environments/unknown/events", nil), handler)
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+
+		// events are not enabled, so there is nothing to stream
+		streamURL := "http://localhost/environments/" + url.PathEscape(st.EnvMain.Name) + "/events"
+		result, _ = st.DoRequest(makeAdminRequest("GET", streamURL, nil), handler)
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+}
@@ -0,0 +1,78 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointsForEnvironmentIDCheckAllowedClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, httphelpers.MakeSelfSignedCert(certFile, keyFile))
+
+	envConfig := st.EnvClientSide.Config
+	envConfig.AllowedClientCert = ct.NewOptStringList([]string{"client-a"})
+	var config c.Config
+	config.Environment = map[string]*c.EnvConfig{st.EnvClientSide.Name: &envConfig}
+	config.Main.TLSEnabled = true
+	config.Main.TLSCert, config.Main.TLSKey = certFile, keyFile
+	config.Main.TLSClientCA = certFile
+
+	envID := string(st.EnvClientSide.Config.EnvID)
+	userJSON := []byte(`{"key":"userkey"}`)
+	userBase64 := "eyJrZXkiOiJ1c2Vya2V5In0="
+	endpoints := []struct {
+		method, path string
+		body         []byte
+	}{
+		{"GET", "/eval/" + envID + "/" + userBase64, nil},
+		{"REPORT", "/eval/" + envID, userJSON},
+		{"GET", "/sdk/evalx/" + envID + "/contexts/" + userBase64, nil},
+		{"REPORT", "/sdk/evalx/" + envID + "/context", userJSON},
+		{"GET", "/ping/" + envID, nil},
+		{"POST", "/events/bulk/" + envID, []byte(`[]`)},
+	}
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		for _, e := range endpoints {
+			callEndpoint := func(cert *x509.Certificate) int {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				headers := make(http.Header)
+				if e.body != nil {
+					headers.Set("Content-Type", "application/json")
+				}
+				req := st.BuildRequest(e.method, e.path, e.body, headers).WithContext(ctx)
+				if cert != nil {
+					req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+				}
+				return st.CallHandlerAndAwaitStatus(t, p.relay, req, time.Second)
+			}
+
+			t.Run(e.method+" "+e.path, func(t *testing.T) {
+				assert.Equal(t, http.StatusForbidden, callEndpoint(nil), "request without certificate")
+				assert.Equal(t, http.StatusForbidden,
+					callEndpoint(&x509.Certificate{Subject: pkix.Name{CommonName: "client-b"}}),
+					"request with certificate that is not allowed")
+				assert.NotEqual(t, http.StatusForbidden,
+					callEndpoint(&x509.Certificate{Subject: pkix.Name{CommonName: "client-a"}}),
+					"request with allowed certificate")
+			})
+		}
+	})
+}
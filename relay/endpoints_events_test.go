@@ -56,7 +56,9 @@ func relayEventsTest(t *testing.T, config c.Config, action func(relayEventsTestP
 
 	config.Events.SendEvents = true
 	config.Events.EventsURI, _ = ct.NewOptURLAbsoluteFromString(eventsServer.URL)
-	config.Events.FlushInterval = ct.NewOptDuration(time.Second)
+	if !config.Events.FlushInterval.IsDefined() {
+		config.Events.FlushInterval = ct.NewOptDuration(time.Second)
+	}
 
 	withStartedRelay(t, config, func(pBase relayTestParams) {
 		p := relayEventsTestParams{
@@ -0,0 +1,83 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/api"
+)
+
+// livenessHandler serves /health/live. It only indicates whether the Relay instance is running at all;
+// it returns a 503 status once Relay has been closed.
+func livenessHandler(relay *Relay) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		relay.lock.RLock()
+		closed := relay.closed
+		relay.lock.RUnlock()
+
+		if closed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// readinessHandler serves /health/ready. It returns a 200 status if Relay is ready to receive traffic
+// according to the configured readiness policy, or a 503 status if not.
+func readinessHandler(relay *Relay) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rep := relay.getReadiness()
+		data, _ := json.Marshal(rep)
+		w.Header().Set("Content-Type", "application/json")
+		if rep.Ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write(data)
+	})
+}
+
+// getReadiness evaluates the configured readiness policy.
+//
+// Under every policy, Relay is not ready until it knows what its environments are, which in
+// auto-configuration mode means that it has received the full environment list from LaunchDarkly.
+// It is also not ready once a graceful shutdown has started.
+// An environment counts as initialized if its SDK client has successfully received flag data.
+func (r *Relay) getReadiness() api.ReadinessRep {
+	r.lock.RLock()
+	fullyConfigured := r.fullyConfigured
+	closed := r.closed || r.shuttingDown
+	policy := r.config.Main.ReadinessPolicy
+	minPercent := r.config.Main.ReadinessMinPercent.GetOrElse(100)
+	r.lock.RUnlock()
+
+	if policy == "" {
+		policy = config.ReadinessAllEnvironments
+	}
+	rep := api.ReadinessRep{Policy: string(policy)}
+
+	for _, env := range r.getAllEnvironments() {
+		rep.TotalEnvironments++
+		if env.GetInitError() == nil {
+			if client := env.GetClient(); client != nil && client.Initialized() {
+				rep.InitializedEnvironments++
+			}
+		}
+	}
+
+	if closed || !fullyConfigured {
+		return rep
+	}
+	switch policy {
+	case config.ReadinessAutoConfigReceived:
+		rep.Ready = true
+	case config.ReadinessMinPercent:
+		rep.Ready = rep.InitializedEnvironments*100 >= minPercent*rep.TotalEnvironments
+	default:
+		rep.Ready = rep.InitializedEnvironments == rep.TotalEnvironments
+	}
+	return rep
+}
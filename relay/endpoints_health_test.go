@@ -0,0 +1,119 @@
+package relay
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testclient"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ld "github.com/launchdarkly/go-server-sdk/v7"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withRelayInitializingOnly is like withStartedRelay, except that only the environments with the
+// specified SDK keys will have an initialized SDK client.
+func withRelayInitializingOnly(t *testing.T, config c.Config, initializedKeys []c.SDKKey, action func(*Relay)) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	clientFactory := func(sdkKey c.SDKKey, sdkConfig ld.Config, timeout time.Duration) (sdks.LDClientContext, error) {
+		return testclient.FakeLDClientFactory(slices.Contains(initializedKeys, sdkKey))(sdkKey, sdkConfig, timeout)
+	}
+	relay, err := newRelayInternal(config, relayInternalOptions{loggers: mockLog.Loggers, clientFactory: clientFactory})
+	require.NoError(t, err)
+	defer relay.Close()
+	require.NoError(t, relay.waitForAllClients(time.Second))
+
+	action(relay)
+}
+
+func getReadiness(t *testing.T, relay *Relay) (int, ldvalue.Value) {
+	r, _ := http.NewRequest("GET", "http://localhost/health/ready", nil)
+	result, body := st.DoRequest(r, readinessHandler(relay))
+	return result.StatusCode, ldvalue.Parse(body)
+}
+
+func TestEndpointsHealthLive(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		r, _ := http.NewRequest("GET", "http://localhost/health/live", nil)
+		result, _ := st.DoRequest(r, p.relay)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+
+		require.NoError(t, p.relay.Close())
+		result, _ = st.DoRequest(r, p.relay)
+		assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	})
+}
+
+func TestEndpointsHealthReady(t *testing.T) {
+	t.Run("all environments initialized", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			status, rep := getReadiness(t, p.relay)
+			assert.Equal(t, http.StatusOK, status)
+			st.AssertJSONPathMatch(t, true, rep, "ready")
+			st.AssertJSONPathMatch(t, string(c.ReadinessAllEnvironments), rep, "policy")
+			st.AssertJSONPathMatch(t, float64(2), rep, "initializedEnvironments")
+			st.AssertJSONPathMatch(t, float64(2), rep, "totalEnvironments")
+		})
+	})
+
+	t.Run("not all environments initialized", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+
+		withRelayInitializingOnly(t, config, []c.SDKKey{st.EnvMain.Config.SDKKey}, func(relay *Relay) {
+			status, rep := getReadiness(t, relay)
+			assert.Equal(t, http.StatusServiceUnavailable, status)
+			st.AssertJSONPathMatch(t, false, rep, "ready")
+			st.AssertJSONPathMatch(t, float64(1), rep, "initializedEnvironments")
+			st.AssertJSONPathMatch(t, float64(2), rep, "totalEnvironments")
+		})
+	})
+
+	t.Run("minimum percent of environments initialized", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+		config.Main.ReadinessPolicy = c.ReadinessMinPercent
+
+		config.Main.ReadinessMinPercent, _ = ct.NewOptIntGreaterThanZero(50)
+		withRelayInitializingOnly(t, config, []c.SDKKey{st.EnvMain.Config.SDKKey}, func(relay *Relay) {
+			status, rep := getReadiness(t, relay)
+			assert.Equal(t, http.StatusOK, status)
+			st.AssertJSONPathMatch(t, string(c.ReadinessMinPercent), rep, "policy")
+		})
+
+		config.Main.ReadinessMinPercent, _ = ct.NewOptIntGreaterThanZero(51)
+		withRelayInitializingOnly(t, config, []c.SDKKey{st.EnvMain.Config.SDKKey}, func(relay *Relay) {
+			status, _ := getReadiness(t, relay)
+			assert.Equal(t, http.StatusServiceUnavailable, status)
+		})
+	})
+
+	t.Run("not ready before auto-configuration is received", func(t *testing.T) {
+		relay := &Relay{envsByCredential: NewEnvironmentLookup()}
+		relay.config.Main.ReadinessPolicy = c.ReadinessAutoConfigReceived
+
+		status, _ := getReadiness(t, relay)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+
+		relay.setFullyConfigured(true)
+		status, _ = getReadiness(t, relay)
+		assert.Equal(t, http.StatusOK, status)
+	})
+}
@@ -3,6 +3,7 @@ package relay
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
@@ -13,6 +14,8 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
 	ld "github.com/launchdarkly/go-server-sdk/v7"
 	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+
+	"github.com/gorilla/mux"
 )
 
 const (
@@ -20,8 +23,13 @@ const (
 	statusEnvDisconnected = "disconnected"
 	statusRelayHealthy    = "healthy"
 	statusRelayDegraded   = "degraded"
+
+	statusDetailsParam = "details"
 )
 
+// statusHandler serves the /status resource, which summarizes the state of Relay and of every
+// environment. If the query parameter "details" is "true", each environment also includes the same
+// details as in environmentStatusHandler.
 func statusHandler(relay *Relay) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -30,6 +38,12 @@ func statusHandler(relay *Relay) http.Handler {
 			Version:       relay.version,
 			ClientVersion: ld.Version,
 		}
+		if relay.tlsCertWatcher != nil {
+			resp.TLSCertificate = &api.TLSCertificateStatusRep{
+				Expiry: ldtime.UnixMillisFromTime(relay.tlsCertWatcher.Expiry()),
+			}
+		}
+		includeDetails := req.URL.Query().Get(statusDetailsParam) == "true"
 
 		relay.lock.Lock()
 		fullyConfigured := relay.fullyConfigured
@@ -37,118 +51,203 @@ func statusHandler(relay *Relay) http.Handler {
 
 		healthy := fullyConfigured
 		for _, clientCtx := range relay.getAllEnvironments() {
-			identifiers := clientCtx.GetIdentifiers()
-
-			status := api.EnvironmentStatusRep{
-				EnvKey:   identifiers.EnvKey, // these will only be non-empty if we're in auto-configured mode
-				EnvName:  identifiers.EnvName,
-				ProjKey:  identifiers.ProjKey,
-				ProjName: identifiers.ProjName,
+			status, envHealthy := makeEnvironmentStatusRep(relay, clientCtx, includeDetails)
+			if !envHealthy {
+				healthy = false
 			}
+			resp.Environments[getEnvironmentStatusKey(relay, clientCtx, status)] = status
+		}
 
-			for _, c := range clientCtx.GetCredentials() {
-				switch c := c.(type) {
-				case config.SDKKey:
-					status.SDKKey = sdks.ObscureKey(string(c))
-				case config.MobileKey:
-					status.MobileKey = sdks.ObscureKey(string(c))
-				case config.EnvironmentID:
-					status.EnvID = string(c)
-				}
-			}
+		if healthy {
+			resp.Status = statusRelayHealthy
+		} else {
+			resp.Status = statusRelayDegraded
+		}
 
-			for _, c := range clientCtx.GetDeprecatedCredentials() {
-				if key, ok := c.(config.SDKKey); ok {
-					status.ExpiringSDKKey = sdks.ObscureKey(string(key))
-				}
-			}
+		data, _ := json.Marshal(resp)
 
-			client := clientCtx.GetClient()
-			if client == nil {
-				status.Status = statusEnvDisconnected
-				status.ConnectionStatus.State = interfaces.DataSourceStateInitializing
-				status.ConnectionStatus.StateSince = ldtime.UnixMillisFromTime(clientCtx.GetCreationTime())
-				status.DataStoreStatus.State = "INITIALIZING"
-				healthy = false
-			} else {
-				connected := client.Initialized()
+		_, _ = w.Write(data)
+	})
+}
 
-				sourceStatus := client.GetDataSourceStatus()
-				status.ConnectionStatus = api.ConnectionStatusRep{
-					State:      sourceStatus.State,
-					StateSince: ldtime.UnixMillisFromTime(sourceStatus.StateSince),
-				}
-				if sourceStatus.LastError.Kind != "" {
-					status.ConnectionStatus.LastError = &api.ConnectionErrorRep{
-						Kind: sourceStatus.LastError.Kind,
-						Time: ldtime.UnixMillisFromTime(sourceStatus.LastError.Time),
-					}
-				}
-				if sourceStatus.State != interfaces.DataSourceStateValid &&
-					time.Since(sourceStatus.StateSince) >=
-						relay.config.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime) {
-					connected = false
-				}
+// environmentStatusHandler serves the /status/environments/{envId} resource, which has the same
+// properties as that environment's entry in /status, plus details about its data, connections, and
+// event queues. The environment can be identified either by its environment ID, or by the same key
+// that is used for it in /status.
+func environmentStatusHandler(relay *Relay) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		envKey := mux.Vars(req)["envId"]
+		var found *api.EnvironmentStatusRep
+		for _, clientCtx := range relay.getAllEnvironments() {
+			status, _ := makeEnvironmentStatusRep(relay, clientCtx, true)
+			if getEnvironmentStatusKey(relay, clientCtx, status) == envKey {
+				found = &status
+				break
+			}
+			// An environment ID is shared by any filtered environments derived from the same environment, so
+			// for an environment ID we prefer the unfiltered one.
+			if status.EnvID == envKey && (found == nil || clientCtx.GetPayloadFilter() == "") {
+				found = &status
+			}
+		}
+		if found == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := json.Marshal(found)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
 
-				storeStatus := client.GetDataStoreStatus()
-				status.DataStoreStatus.State = "VALID"
-				status.DataStoreStatus.StateSince = ldtime.UnixMillisFromTime(storeStatus.LastUpdated)
-				if !storeStatus.Available {
-					status.DataStoreStatus.State = "INTERRUPTED"
-				}
+// getEnvironmentStatusKey returns the property name that identifies the environment in /status.
+func getEnvironmentStatusKey(relay *Relay, clientCtx relayenv.EnvContext, status api.EnvironmentStatusRep) string {
+	if relay.envLogNameMode == relayenv.LogNameIsEnvID {
+		// If we're identifying environments by environment ID in the log (which we do if there's any
+		// chance that the environment name could change) then we should also identify them that way here.
+		return status.EnvID
+	}
+	return clientCtx.GetIdentifiers().GetDisplayName()
+}
 
-				if connected {
-					status.Status = statusEnvConnected
-				} else {
-					status.Status = statusEnvDisconnected
-					healthy = false
-				}
-			}
+// makeEnvironmentStatusRep builds the status representation of an environment, and also returns
+// false if the environment's state should make Relay's overall status "degraded".
+func makeEnvironmentStatusRep(
+	relay *Relay,
+	clientCtx relayenv.EnvContext,
+	includeDetails bool,
+) (api.EnvironmentStatusRep, bool) {
+	identifiers := clientCtx.GetIdentifiers()
+	healthy := true
+
+	status := api.EnvironmentStatusRep{
+		EnvKey:   identifiers.EnvKey, // these will only be non-empty if we're in auto-configured mode
+		EnvName:  identifiers.EnvName,
+		ProjKey:  identifiers.ProjKey,
+		ProjName: identifiers.ProjName,
+	}
+
+	for _, c := range clientCtx.GetCredentials() {
+		switch c := c.(type) {
+		case config.SDKKey:
+			status.SDKKey = sdks.ObscureKey(string(c))
+		case config.MobileKey:
+			status.MobileKey = sdks.ObscureKey(string(c))
+		case config.EnvironmentID:
+			status.EnvID = string(c)
+		}
+	}
 
-			bigSegmentStore := clientCtx.GetBigSegmentStore()
-			if bigSegmentStore != nil {
-				bigSegmentStatus := api.BigSegmentStatusRep{}
-				synchronizedOn, err := bigSegmentStore.GetSynchronizedOn()
-				if err != nil {
-					bigSegmentStatus.Available = false
-				} else {
-					bigSegmentStatus.Available = true
-					bigSegmentStatus.LastSynchronizedOn = synchronizedOn
-					now := ldtime.UnixMillisNow()
-					stalenessThreshold := relay.config.Main.BigSegmentsStaleThreshold.GetOrElse(config.DefaultBigSegmentsStaleThreshold)
-					if !synchronizedOn.IsDefined() || now > (synchronizedOn+ldtime.UnixMillisecondTime(stalenessThreshold.Milliseconds())) {
-						bigSegmentStatus.PotentiallyStale = true
-						if relay.config.Main.BigSegmentsStaleAsDegraded {
-							healthy = false
-						}
-					}
-				}
-				status.BigSegmentStatus = &bigSegmentStatus
+	for _, c := range clientCtx.GetDeprecatedCredentials() {
+		if key, ok := c.(config.SDKKey); ok {
+			status.ExpiringSDKKey = sdks.ObscureKey(string(key))
+		}
+	}
+
+	client := clientCtx.GetClient()
+	if client == nil {
+		status.Status = statusEnvDisconnected
+		status.ConnectionStatus.State = interfaces.DataSourceStateInitializing
+		status.ConnectionStatus.StateSince = ldtime.UnixMillisFromTime(clientCtx.GetCreationTime())
+		status.DataStoreStatus.State = "INITIALIZING"
+		healthy = false
+	} else {
+		connected := client.Initialized()
+
+		sourceStatus := client.GetDataSourceStatus()
+		status.ConnectionStatus = api.ConnectionStatusRep{
+			State:      sourceStatus.State,
+			StateSince: ldtime.UnixMillisFromTime(sourceStatus.StateSince),
+		}
+		if sourceStatus.LastError.Kind != "" {
+			status.ConnectionStatus.LastError = &api.ConnectionErrorRep{
+				Kind: sourceStatus.LastError.Kind,
+				Time: ldtime.UnixMillisFromTime(sourceStatus.LastError.Time),
 			}
+		}
+		if sourceStatus.State != interfaces.DataSourceStateValid &&
+			time.Since(sourceStatus.StateSince) >=
+				relay.config.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime) {
+			connected = false
+		}
 
-			storeInfo := clientCtx.GetDataStoreInfo()
-			status.DataStoreStatus.Database = storeInfo.DBType
-			status.DataStoreStatus.DBServer = storeInfo.DBServer
-			status.DataStoreStatus.DBPrefix = storeInfo.DBPrefix
-			status.DataStoreStatus.DBTable = storeInfo.DBTable
-
-			statusKey := identifiers.GetDisplayName()
-			if relay.envLogNameMode == relayenv.LogNameIsEnvID {
-				// If we're identifying environments by environment ID in the log (which we do if there's any
-				// chance that the environment name could change) then we should also identify them that way here.
-				statusKey = status.EnvID
-			}
-			resp.Environments[statusKey] = status
+		storeStatus := client.GetDataStoreStatus()
+		status.DataStoreStatus.State = "VALID"
+		status.DataStoreStatus.StateSince = ldtime.UnixMillisFromTime(storeStatus.LastUpdated)
+		if !storeStatus.Available {
+			status.DataStoreStatus.State = "INTERRUPTED"
 		}
 
-		if healthy {
-			resp.Status = statusRelayHealthy
+		if connected {
+			status.Status = statusEnvConnected
 		} else {
-			resp.Status = statusRelayDegraded
+			status.Status = statusEnvDisconnected
+			healthy = false
 		}
+	}
+
+	bigSegmentStore := clientCtx.GetBigSegmentStore()
+	if bigSegmentStore != nil {
+		bigSegmentStatus := api.BigSegmentStatusRep{}
+		synchronizedOn, err := bigSegmentStore.GetSynchronizedOn()
+		if err != nil {
+			bigSegmentStatus.Available = false
+		} else {
+			bigSegmentStatus.Available = true
+			bigSegmentStatus.LastSynchronizedOn = synchronizedOn
+			now := ldtime.UnixMillisNow()
+			stalenessThreshold := relay.config.Main.BigSegmentsStaleThreshold.GetOrElse(config.DefaultBigSegmentsStaleThreshold)
+			if !synchronizedOn.IsDefined() || now > (synchronizedOn+ldtime.UnixMillisecondTime(stalenessThreshold.Milliseconds())) {
+				bigSegmentStatus.PotentiallyStale = true
+				if relay.config.Main.BigSegmentsStaleAsDegraded {
+					healthy = false
+				}
+			}
+		}
+		status.BigSegmentStatus = &bigSegmentStatus
+	}
 
-		data, _ := json.Marshal(resp)
+	storeInfo := clientCtx.GetDataStoreInfo()
+	status.DataStoreStatus.Database = storeInfo.DBType
+	status.DataStoreStatus.DBServer = storeInfo.DBServer
+	status.DataStoreStatus.DBPrefix = storeInfo.DBPrefix
+	status.DataStoreStatus.DBTable = storeInfo.DBTable
 
-		_, _ = w.Write(data)
+	if includeDetails {
+		status.Details = makeEnvironmentStatusDetailsRep(clientCtx)
+	}
+
+	return status, healthy
+}
+
+func makeEnvironmentStatusDetailsRep(clientCtx relayenv.EnvContext) *api.EnvironmentStatusDetailsRep {
+	dataStatus := clientCtx.GetDataStatus()
+	details := api.EnvironmentStatusDetailsRep{
+		FlagCount:         dataStatus.FlagCount,
+		SegmentCount:      dataStatus.SegmentCount,
+		LatestVersion:     dataStatus.LatestVersion,
+		StreamConnections: make(map[string]int),
+	}
+	if !dataStatus.LastUpdated.IsZero() {
+		details.LastUpdated = ldtime.UnixMillisFromTime(dataStatus.LastUpdated)
+	}
+	for kind, count := range clientCtx.GetStreamConnectionCounts() {
+		details.StreamConnections[string(kind)] = count
+	}
+	if dispatcher := clientCtx.GetEventDispatcher(); dispatcher != nil {
+		details.EventQueueDepth = make(map[string]int)
+		for sdkKind, count := range dispatcher.GetQueuedEventCounts() {
+			details.EventQueueDepth[string(sdkKind)] = count
+		}
+	}
+	for key, expiry := range clientCtx.GetDeprecatedSDKKeyExpiries() {
+		details.DeprecatedCredentials = append(details.DeprecatedCredentials, api.DeprecatedCredentialRep{
+			SDKKey: sdks.ObscureKey(string(key)),
+			Expiry: ldtime.UnixMillisFromTime(expiry),
+		})
+	}
+	sort.Slice(details.DeprecatedCredentials, func(i, j int) bool {
+		return details.DeprecatedCredentials[i].Expiry < details.DeprecatedCredentials[j].Expiry
 	})
+	return &details
 }
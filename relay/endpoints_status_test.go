@@ -1,7 +1,11 @@
 package relay
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -17,6 +21,7 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	ld "github.com/launchdarkly/go-server-sdk/v7"
 	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -130,4 +135,94 @@ func TestEndpointsStatus(t *testing.T) {
 			st.AssertJSONPathMatch(t, "degraded", status, "status")
 		})
 	})
+
+	t.Run("TLS certificate expiry", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		require.NoError(t, httphelpers.MakeSelfSignedCert(certFile, keyFile))
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		config.Main.TLSEnabled = true
+		config.Main.TLSCert, config.Main.TLSKey = certFile, keyFile
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			r, _ := http.NewRequest("GET", "http://localhost/status", nil)
+			_, body := st.DoRequest(r, p.relay)
+			status := ldvalue.Parse(body)
+
+			st.AssertJSONPathMatch(t, float64(ldtime.UnixMillisFromTime(leaf.NotAfter)), status, "tlsCertificate", "expiry")
+		})
+	})
+
+	t.Run("no TLS certificate", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			r, _ := http.NewRequest("GET", "http://localhost/status", nil)
+			_, body := st.DoRequest(r, p.relay)
+			assert.Equal(t, ldvalue.Null(), ldvalue.Parse(body).GetByKey("tlsCertificate"))
+		})
+	})
+}
+
+func TestEndpointsStatusDetails(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvClientSide)
+	config.Events.SendEvents = true
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		t.Run("details are omitted by default", func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/status", nil)
+			_, body := st.DoRequest(r, p.relay)
+			status := ldvalue.Parse(body)
+			assert.Equal(t, ldvalue.Null(), status.GetByKey("environments").GetByKey(st.EnvMain.Name).GetByKey("details"))
+		})
+
+		t.Run("details are included if requested", func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/status?details=true", nil)
+			result, body := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			status := ldvalue.Parse(body)
+			details := status.GetByKey("environments").GetByKey(st.EnvMain.Name).GetByKey("details")
+
+			st.AssertJSONPathMatch(t, float64(len(st.AllData[0].Items)), details, "flagCount")
+			st.AssertJSONPathMatch(t, float64(len(st.AllData[1].Items)), details, "segmentCount")
+			st.AssertJSONPathMatch(t, float64(0), details, "streamConnections", "server")
+			st.AssertJSONPathMatch(t, float64(0), details, "eventQueueDepth", "server")
+			assert.True(t, details.GetByKey("lastUpdated").IsNumber())
+		})
+
+		t.Run("single environment by name", func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/status/environments/"+url.PathEscape(st.EnvMain.Name), nil)
+			result, body := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			status := ldvalue.Parse(body)
+
+			st.AssertJSONPathMatch(t, sdks.ObscureKey(string(st.EnvMain.Config.SDKKey)), status, "sdkKey")
+			st.AssertJSONPathMatch(t, "connected", status, "status")
+			st.AssertJSONPathMatch(t, float64(len(st.AllData[0].Items)), status, "details", "flagCount")
+		})
+
+		t.Run("single environment by environment ID", func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/status/environments/"+string(st.EnvClientSide.Config.EnvID), nil)
+			result, body := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			status := ldvalue.Parse(body)
+
+			st.AssertJSONPathMatch(t, sdks.ObscureKey(string(st.EnvClientSide.Config.SDKKey)), status, "sdkKey")
+			st.AssertJSONPathMatch(t, float64(0), status, "details", "streamConnections", "js-ping")
+		})
+
+		t.Run("unknown environment", func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/status/environments/unknown", nil)
+			result, _ := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusNotFound, result.StatusCode)
+		})
+	})
 }
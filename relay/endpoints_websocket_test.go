@@ -0,0 +1,319 @@
+package relay
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/credential"
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+type webSocketEndpointTestParams struct {
+	endpointTestParams
+	invalidCredential       credential.SDKCredential
+	invalidCredentialStatus int
+	platformCategory        string // the platformCategory tag of the connection metrics
+	streamKind              basictypes.StreamKind
+	expectedEvent           string
+}
+
+func makeWebSocketEndpointTestParams() []webSocketEndpointTestParams {
+	userJSON := []byte(`{"key":"me"}`)
+	sdkKey, mobileKey, envID := st.EnvMain.Config.SDKKey, st.EnvMobile.Config.MobileKey, st.EnvClientSide.Config.EnvID
+	return []webSocketEndpointTestParams{
+		{endpointTestParams{"all stream", "GET", "/ws/all", nil, sdkKey, 0, st.ExpectNoBody()},
+			mobileKey, http.StatusUnauthorized, "server", "server", "put"},
+		{endpointTestParams{"mobile ping", "GET", "/ws/mping", nil, mobileKey, 0, st.ExpectNoBody()},
+			sdkKey, http.StatusUnauthorized, "mobile", "mobile-ping", "ping"},
+		{endpointTestParams{"mobile stream", "GET", "/ws/meval/$DATA", userJSON, mobileKey, 0, st.ExpectNoBody()},
+			sdkKey, http.StatusUnauthorized, "mobile", "mobile-ping", "ping"},
+		{endpointTestParams{"client-side ping", "GET", "/ws/ping/$ENV", nil, envID, 0, st.ExpectNoBody()},
+			st.UndefinedEnvID, http.StatusNotFound, "browser", "js-ping", "ping"},
+		{endpointTestParams{"client-side eval stream", "GET", "/ws/eval/$ENV/$DATA", userJSON, envID, 0, st.ExpectNoBody()},
+			st.UndefinedEnvID, http.StatusNotFound, "browser", "js-ping", "ping"},
+	}
+}
+
+func makeWebSocketTestConfig() c.Config {
+	var config c.Config
+	config.Main.WebSocketStreams = true
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile, st.EnvClientSide)
+	return config
+}
+
+// webSocketURL returns the URL of the endpoint on a test server.
+func (s webSocketEndpointTestParams) webSocketURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http") + strings.TrimPrefix(s.localURL(), "http://localhost")
+}
+
+// dial opens a WebSocket connection to the endpoint. The connection is closed at the end of the test.
+func (s webSocketEndpointTestParams) dial(t *testing.T, server *httptest.Server, header http.Header) *websocket.Conn {
+	wsConfig, err := websocket.NewConfig(s.webSocketURL(server), server.URL)
+	require.NoError(t, err)
+	for name, values := range s.toMulti().header(s.toMulti().requests[0]) {
+		wsConfig.Header[name] = values
+	}
+	for name, values := range header {
+		wsConfig.Header[name] = values
+	}
+	ws, err := websocket.DialConfig(wsConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+// handshakeStatus sends a WebSocket handshake request for the endpoint, with the specified credential
+// and any additional headers, and returns the response status: 101 if the connection was upgraded, or
+// otherwise the error status.
+func (s webSocketEndpointTestParams) handshakeStatus(
+	t *testing.T,
+	server *httptest.Server,
+	cred credential.SDKCredential,
+	header http.Header,
+) int {
+	s1 := s
+	s1.credential = cred
+	req, err := http.NewRequest("GET", server.URL+strings.TrimPrefix(s1.localURL(), "http://localhost"), nil)
+	require.NoError(t, err)
+	req.Header = s1.toMulti().header(s1.toMulti().requests[0])
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func receiveWebSocketMessage(t *testing.T, ws *websocket.Conn, timeout time.Duration) map[string]interface{} {
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(timeout)))
+	var data string
+	require.NoError(t, websocket.Message.Receive(ws, &data))
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &message))
+	return message
+}
+
+func requireWebSocketClosed(t *testing.T, ws *websocket.Conn, timeout time.Duration) {
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(timeout)))
+	for {
+		var data string
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			require.NotContains(t, err.Error(), "timeout", "timed out waiting for connection to be closed")
+			return
+		}
+	}
+}
+
+func awaitStreamConnectionCount(t *testing.T, env relayenv.EnvContext, kind basictypes.StreamKind, count int) {
+	require.Eventually(t, func() bool { return env.GetStreamConnectionCounts()[kind] == count },
+		time.Second, time.Millisecond*10, "timed out waiting for %d %s stream connections", count, kind)
+}
+
+func TestEndpointsWebSocketStreams(t *testing.T) {
+	config := makeWebSocketTestConfig()
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		server := httptest.NewServer(p.relay)
+		defer server.Close()
+
+		for _, spec := range makeWebSocketEndpointTestParams() {
+			s := spec
+			t.Run(s.name, func(t *testing.T) {
+				t.Run("success", func(t *testing.T) {
+					ws := s.dial(t, server, nil)
+					message := receiveWebSocketMessage(t, ws, time.Second*3)
+					assert.Equal(t, s.expectedEvent, message["event"])
+				})
+
+				t.Run("valid credential", func(t *testing.T) {
+					assert.Equal(t, http.StatusSwitchingProtocols, s.handshakeStatus(t, server, s.credential, nil))
+				})
+
+				t.Run("invalid credential is rejected before upgrade", func(t *testing.T) {
+					assert.Equal(t, s.invalidCredentialStatus, s.handshakeStatus(t, server, s.invalidCredential, nil))
+				})
+			})
+		}
+	})
+}
+
+func TestEndpointsWebSocketStreamsCountConnections(t *testing.T) {
+	config := makeWebSocketTestConfig()
+	exporter := st.NewTestMetricsExporter()
+
+	exporter.WithExporter(func() {
+		withStartedRelay(t, config, func(p relayTestParams) {
+			server := httptest.NewServer(p.relay)
+			defer server.Close()
+
+			for _, spec := range makeWebSocketEndpointTestParams() {
+				s := spec
+				t.Run(s.name, func(t *testing.T) {
+					env, _ := p.relay.getEnvironment(sdkauth.New(s.credential))
+					require.NotNil(t, env)
+
+					// Since the OpenCensus state is global, a different user agent for each test run keeps the
+					// counts separate.
+					userAgent := fmt.Sprintf("websocket-test-%s-%d", strings.ReplaceAll(s.name, " ", "-"), time.Now().UnixNano())
+					expectedTags := map[string]string{
+						"env":              env.GetIdentifiers().GetDisplayName(),
+						"platformCategory": s.platformCategory,
+						"userAgent":        userAgent,
+					}
+					expectConnections := func(connections float64) {
+						exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+							return d.HasRow("connections", st.TestMetricsRow{Tags: expectedTags, Sum: connections}) &&
+								d.HasRow("newconnections", st.TestMetricsRow{Tags: expectedTags, Sum: 1})
+						})
+					}
+
+					ws := s.dial(t, server, http.Header{"User-Agent": {userAgent}})
+					_ = receiveWebSocketMessage(t, ws, time.Second*3)
+					expectConnections(1)
+					assert.Equal(t, 1, env.GetStreamConnectionCounts()[s.streamKind])
+
+					require.NoError(t, ws.Close())
+					expectConnections(0)
+					awaitStreamConnectionCount(t, env, s.streamKind, 0)
+				})
+			}
+		})
+	})
+}
+
+func TestEndpointsWebSocketStreamsConnectionLimit(t *testing.T) {
+	// A connection that is over the limit is rejected by the stream handler, which only runs after the
+	// connection has been upgraded; so, unlike an SSE request, which gets a 503 response, the client gets
+	// an "error" message with a 503 status, and then the connection is closed.
+	config := makeWebSocketTestConfig()
+	config.Main.MaxStreamConnectionsPerEnv, _ = ct.NewOptIntGreaterThanZero(1)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		server := httptest.NewServer(p.relay)
+		defer server.Close()
+
+		for _, spec := range makeWebSocketEndpointTestParams() {
+			s := spec
+			t.Run(s.name, func(t *testing.T) {
+				ws1 := s.dial(t, server, nil)
+				assert.Equal(t, s.expectedEvent, receiveWebSocketMessage(t, ws1, time.Second*3)["event"])
+
+				ws2 := s.dial(t, server, nil)
+				message := receiveWebSocketMessage(t, ws2, time.Second)
+				require.Contains(t, message, "error")
+				errorInfo := message["error"].(map[string]interface{})
+				assert.Equal(t, float64(http.StatusServiceUnavailable), errorInfo["status"])
+				assert.NotEmpty(t, errorInfo["message"])
+				requireWebSocketClosed(t, ws2, time.Second)
+
+				require.NoError(t, ws1.Close())
+				env, _ := p.relay.getEnvironment(sdkauth.New(s.credential))
+				require.NotNil(t, env)
+				awaitStreamConnectionCount(t, env, s.streamKind, 0)
+
+				ws3 := s.dial(t, server, nil)
+				assert.Equal(t, s.expectedEvent, receiveWebSocketMessage(t, ws3, time.Second*3)["event"])
+
+				// some of the endpoints share a connection limit, so the next test must not start until
+				// this connection is gone
+				require.NoError(t, ws3.Close())
+				awaitStreamConnectionCount(t, env, s.streamKind, 0)
+			})
+		}
+	})
+}
+
+func TestEndpointsWebSocketStreamsMaxClientConnectionTime(t *testing.T) {
+	maxConnTime := 100 * time.Millisecond
+	config := makeWebSocketTestConfig()
+	config.Main.MaxClientConnectionTime = ct.NewOptDuration(maxConnTime)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		server := httptest.NewServer(p.relay)
+		defer server.Close()
+
+		for _, spec := range makeWebSocketEndpointTestParams() {
+			s := spec
+			t.Run(s.name, func(t *testing.T) {
+				startTime := time.Now()
+				ws := s.dial(t, server, nil)
+				assert.Equal(t, s.expectedEvent, receiveWebSocketMessage(t, ws, time.Second*3)["event"])
+				requireWebSocketClosed(t, ws, maxConnTime+time.Second)
+				assert.GreaterOrEqual(t, time.Since(startTime), maxConnTime, "stream closed too soon")
+			})
+		}
+	})
+}
+
+func TestEndpointsWebSocketStreamsCheckAllowedClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, httphelpers.MakeSelfSignedCert(certFile, keyFile))
+
+	envConfig := st.EnvClientSide.Config
+	envConfig.AllowedClientCert = ct.NewOptStringList([]string{"client-a"})
+	config := makeWebSocketTestConfig()
+	config.Environment = map[string]*c.EnvConfig{st.EnvClientSide.Name: &envConfig}
+	config.Main.TLSEnabled = true
+	config.Main.TLSCert, config.Main.TLSKey = certFile, keyFile
+	config.Main.TLSClientCA = certFile
+
+	// The test server does not use TLS, so it gives each request the certificate that the test specifies
+	// in this header, as if the client had presented it in the TLS handshake.
+	const certNameHeader = "X-Test-Client-Cert"
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if name := req.Header.Get(certNameHeader); name != "" {
+				req.TLS = &tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: name}}},
+				}
+			}
+			p.relay.ServeHTTP(w, req)
+		}))
+		defer server.Close()
+
+		for _, spec := range makeWebSocketEndpointTestParams() {
+			s := spec
+			if _, ok := s.credential.(c.EnvironmentID); !ok {
+				continue
+			}
+			handshakeStatus := func(certName string) int {
+				return s.handshakeStatus(t, server, s.credential, http.Header{certNameHeader: {certName}})
+			}
+
+			t.Run(s.name, func(t *testing.T) {
+				assert.Equal(t, http.StatusForbidden, handshakeStatus(""), "request without certificate")
+				assert.Equal(t, http.StatusForbidden, handshakeStatus("client-b"),
+					"request with certificate that is not allowed")
+				assert.Equal(t, http.StatusSwitchingProtocols, handshakeStatus("client-a"),
+					"request with allowed certificate")
+			})
+		}
+	})
+}
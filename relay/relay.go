@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"crypto/x509"
 	"errors"
 	"net/http"
 	"net/http/httputil"
@@ -18,12 +19,16 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/autoconfig"
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+	"github.com/launchdarkly/ld-relay/v8/internal/events"
 	"github.com/launchdarkly/ld-relay/v8/internal/filedata"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
 	"github.com/launchdarkly/ld-relay/v8/internal/streams"
+	"github.com/launchdarkly/ld-relay/v8/internal/tlscert"
 	"github.com/launchdarkly/ld-relay/v8/internal/util"
 	"github.com/launchdarkly/ld-relay/v8/relay/version"
 
@@ -47,8 +52,8 @@ const rpacProtocolVersion = 2
 // It can also be referenced externally in order to embed Relay Proxy functionality into a customized
 // application; see docs/in-app.md.
 //
-// This type deliberately exports no methods other than ServeHTTP and Close. Everything else is an
-// implementation detail which is subject to change.
+// This type deliberately exports no methods other than ServeHTTP, Reload, Shutdown, TLSConfig, and
+// Close. Everything else is an implementation detail which is subject to change.
 type Relay struct {
 	http.Handler
 	envsByCredential              *EnvironmentLookup
@@ -58,6 +63,8 @@ type Relay struct {
 	serverSideFlagsStreamProvider streams.StreamProvider
 	mobileStreamProvider          streams.StreamProvider
 	jsClientStreamProvider        streams.StreamProvider
+	mobileEvalStreamProvider      streams.StreamProvider // nil unless Main.ClientSideEvalStreams is set
+	jsClientEvalStreamProvider    streams.StreamProvider // nil unless Main.ClientSideEvalStreams is set
 	clientInitCh                  chan relayenv.EnvContext
 	fullyConfigured               bool
 	clientSideSDKBaseURL          url.URL
@@ -65,11 +72,28 @@ type Relay struct {
 	userAgent                     string
 	envLogNameMode                relayenv.LogNameMode
 	closed                        bool
+	shuttingDown                  bool
 	lock                          sync.RWMutex
 	autoConfigStream              *autoconfig.StreamManager
 	archiveManager                filedata.ArchiveManagerInterface
 	config                        config.Config
+	adminServer                   *http.Server
+	tlsCertWatcher                *tlscert.Watcher
+	tlsClientCAs                  *x509.CertPool
+	eventSink                     events.EventSink
+	eventMemoryLimit              *events.EventMemoryLimit
+	streamConnectionLimit         *relayenv.StreamConnectionLimit
+	otlpEventExporter             *events.OTLPEventExporter
 	loggers                       ldlog.Loggers
+	logLevels                     *logging.DynamicLevelLoggers
+	defaultLogLevel               ldlog.LogLevel // the level of the Loggers passed to NewRelay, used if Main.LogLevel is not set
+	envChangeLock                 sync.Mutex
+
+	// These are guarded by envChangeLock. adminEnvNames has the configured names of environments that
+	// were added with the admin API, including any filtered environments derived from them;
+	// autoConfigEnvParams has the latest parameters of auto-configured environments.
+	adminEnvNames       map[string]struct{}
+	autoConfigEnvParams map[sdkauth.ScopedCredential]envfactory.EnvironmentParams
 }
 
 // ClientFactoryFunc is a function that can be used with NewRelay to specify custom behavior when
@@ -134,9 +158,10 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		clientFactory = sdks.DefaultClientFactory()
 	}
 
-	if c.Main.LogLevel.IsDefined() {
-		loggers.SetMinLevel(c.Main.LogLevel.GetOrElse(ldlog.Info))
-	}
+	// Main.LogLevel can be changed by Reload, so the level is controlled by logLevels rather than by the
+	// Loggers instance that each component receives.
+	logLevels := logging.NewDynamicLevelLoggers(loggers, c.Main.LogLevel.GetOrElse(loggers.GetMinLevel()))
+	loggers = logLevels.Loggers()
 
 	metricsManager, err := metrics.NewManager(c.MetricsConfig, 0, loggers)
 	if err != nil {
@@ -144,12 +169,61 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 	}
 	thingsToCleanUp.AddFunc(metricsManager.Close)
 
+	var tlsCertWatcher *tlscert.Watcher
+	if c.Main.TLSEnabled {
+		tlsCertWatcher, err = tlscert.NewWatcher(c.Main.TLSCert, c.Main.TLSKey, tlscert.DefaultCheckInterval,
+			metricsManager.RecordTLSCertificateExpiry, loggers)
+		if err != nil {
+			return nil, err
+		}
+		thingsToCleanUp.AddFunc(tlsCertWatcher.Close)
+	}
+
+	var tlsClientCAs *x509.CertPool
+	if c.Main.TLSClientCA != "" {
+		if tlsClientCAs, err = tlscert.LoadCertPool(c.Main.TLSClientCA); err != nil {
+			return nil, errLoadingTLSClientCA(err)
+		}
+	}
+
 	clientInitCh := make(chan relayenv.EnvContext, len(c.Environment))
 
 	maxConnTime := c.Main.MaxClientConnectionTime.GetOrElse(0)
 
 	userAgent := "LDRelay/" + version.Version
 
+	var eventSink events.EventSink
+	if c.Events.Sink != "" {
+		sinkHTTPConfig, err := httpconfig.NewHTTPConfig(c.Proxy, nil, userAgent, loggers)
+		if err != nil {
+			return nil, err
+		}
+		if eventSink, err = events.NewEventSink(c.Events, sinkHTTPConfig, loggers); err != nil {
+			return nil, errCreatingEventSink(err)
+		}
+	}
+
+	var otlpEventExporter *events.OTLPEventExporter
+	if c.Events.OTLPEndpoint.IsDefined() {
+		otlpHTTPConfig, err := httpconfig.NewHTTPConfig(c.Proxy, nil, userAgent, loggers)
+		if err != nil {
+			return nil, err
+		}
+		if otlpEventExporter, err = events.NewOTLPEventExporter(c.Events, otlpHTTPConfig, loggers); err != nil {
+			return nil, errCreatingOTLPEventExporter(err)
+		}
+	}
+
+	var eventMemoryLimit *events.EventMemoryLimit
+	if c.Events.MaxMemory.IsDefined() {
+		eventMemoryLimit = events.NewEventMemoryLimit(int64(c.Events.MaxMemory.GetOrElse(0)))
+	}
+
+	var streamConnectionLimit *relayenv.StreamConnectionLimit
+	if c.Main.MaxStreamConnections.IsDefined() {
+		streamConnectionLimit = relayenv.NewStreamConnectionLimit(c.Main.MaxStreamConnections.GetOrElse(0))
+	}
+
 	r := &Relay{
 		envsByCredential:              NewEnvironmentLookup(),
 		serverSideStreamProvider:      streams.NewStreamProvider(basictypes.ServerSideStream, maxConnTime),
@@ -157,6 +231,12 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		mobileStreamProvider:          streams.NewStreamProvider(basictypes.MobilePingStream, maxConnTime),
 		jsClientStreamProvider:        streams.NewStreamProvider(basictypes.JSClientPingStream, maxConnTime),
 		metricsManager:                metricsManager,
+		tlsCertWatcher:                tlsCertWatcher,
+		tlsClientCAs:                  tlsClientCAs,
+		eventSink:                     eventSink,
+		eventMemoryLimit:              eventMemoryLimit,
+		streamConnectionLimit:         streamConnectionLimit,
+		otlpEventExporter:             otlpEventExporter,
 		clientFactory:                 clientFactory,
 		clientInitCh:                  clientInitCh,
 		version:                       version.Version,
@@ -164,6 +244,12 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		envLogNameMode:                logNameMode,
 		config:                        c,
 		loggers:                       loggers,
+		logLevels:                     logLevels,
+		defaultLogLevel:               options.loggers.GetMinLevel(),
+	}
+	if c.Main.ClientSideEvalStreams {
+		r.mobileEvalStreamProvider = streams.NewStreamProvider(basictypes.MobileEvalStream, maxConnTime)
+		r.jsClientEvalStreamProvider = streams.NewStreamProvider(basictypes.JSClientEvalStream, maxConnTime)
 	}
 
 	thingsToCleanUp.AddCloser(r)
@@ -248,6 +334,13 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 	}
 
 	r.Handler = r.makeRouter()
+
+	if c.Admin.Port.IsDefined() {
+		if err := r.startAdminServer(c); err != nil {
+			return nil, err
+		}
+	}
+
 	thingsToCleanUp.Clear() // we succeeded, don't close anything
 	return r, nil
 }
@@ -314,6 +407,14 @@ func (r *Relay) Close() error {
 
 	r.metricsManager.Close()
 
+	if r.tlsCertWatcher != nil {
+		r.tlsCertWatcher.Close()
+	}
+
+	if r.adminServer != nil {
+		_ = r.adminServer.Close()
+	}
+
 	if r.autoConfigStream != nil {
 		r.autoConfigStream.Close()
 	}
@@ -331,16 +432,32 @@ func (r *Relay) Close() error {
 		sp.Close()
 	}
 
+	if r.eventSink != nil {
+		// this is done after closing the environments, since that flushes their events to the sink
+		if err := r.eventSink.Close(); err != nil {
+			r.loggers.Warnf("unexpected error when closing events sink: %s", err)
+		}
+	}
+
+	if r.otlpEventExporter != nil {
+		// likewise, closing the environments has exported their last events
+		r.otlpEventExporter.Close()
+	}
+
 	return nil
 }
 
 func (r *Relay) allStreamProviders() []streams.StreamProvider {
-	return []streams.StreamProvider{
+	ret := []streams.StreamProvider{
 		r.serverSideStreamProvider,
 		r.serverSideFlagsStreamProvider,
 		r.mobileStreamProvider,
 		r.jsClientStreamProvider,
 	}
+	if r.mobileEvalStreamProvider != nil {
+		ret = append(ret, r.mobileEvalStreamProvider, r.jsClientEvalStreamProvider)
+	}
+	return ret
 }
 
 var errRelayNotReady = errors.New("relay is not yet fully configured")
@@ -456,8 +573,13 @@ func (r *Relay) addEnvironment(
 		UserAgent:                        r.userAgent,
 		LogNameMode:                      r.envLogNameMode,
 		Loggers:                          r.loggers,
+		LogLevels:                        r.logLevels,
 		ConnectionMapper:                 r,
 		ExpiredCredentialCleanupInterval: r.config.Main.ExpiredCredentialCleanupInterval.GetOrElse(0),
+		EventSink:                        r.eventSink,
+		EventMemoryLimit:                 r.eventMemoryLimit,
+		OTLPEventExporter:                r.otlpEventExporter,
+		StreamConnectionLimit:            r.streamConnectionLimit,
 	}, resultCh)
 	if err != nil {
 		return nil, nil, errNewClientContextFailed(identifiers.GetDisplayName(), err)
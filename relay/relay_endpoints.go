@@ -99,6 +99,35 @@ func pingStreamHandlerWithContext(sdkKind basictypes.SDKKind, streamProvider str
 	})
 }
 
+// This handler is used for client-side streaming endpoints that require context properties, if
+// Main.ClientSideEvalStreams is set. The stream sends the flag evaluation results for the context, rather
+// than "ping" events.
+func evalStreamHandler(sdkKind basictypes.SDKKind, streamProvider streams.StreamProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		clientCtx := middleware.GetEnvContextInfo(req.Context())
+		clientCtx.Env.GetLoggers().Debug("Application requested client-side evaluation stream")
+
+		ldContext, ok := getClientSideContextProperties(clientCtx.Env, sdkKind, req, w)
+		if !ok {
+			return
+		}
+		evaluator := clientCtx.Env.GetEvaluator()
+		if evaluator == nil {
+			// The SDK client for this environment has not been created yet, so there is no data store.
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write(util.ErrorJSONMsg("Service not initialized"))
+			return
+		}
+		req = streams.WithEvalStreamParams(req, streams.EvalStreamParams{
+			Context:     ldContext,
+			Evaluator:   evaluator,
+			WithReasons: req.URL.Query().Get("withReasons") == "true",
+		})
+		clientCtx.Env.GetStreamHandler(streamProvider, clientCtx.Credential).ServeHTTP(w, req)
+	})
+}
+
 // Multi-purpose streaming handler; all details of the behavior of the particular type of stream are
 // abstracted in StreamProvider and EnvStreams
 func streamHandler(streamProvider streams.StreamProvider, logMessage string) http.Handler {
@@ -148,16 +177,17 @@ func pollSegmentHandler(w http.ResponseWriter, req *http.Request) {
 // events.ld.com/events/diagnostic/{envId} (JS)
 func bulkEventHandler(sdkKind basictypes.SDKKind, eventsKind ldevents.EventDataKind, offline bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if offline {
+		clientCtx := middleware.GetEnvContextInfo(req.Context())
+		dispatcher := clientCtx.Env.GetEventDispatcher()
+		if offline && dispatcher == nil {
+			// In offline mode, there is only an event dispatcher if events are going to an events sink;
+			// otherwise we accept the events but discard them.
 			w.WriteHeader(http.StatusAccepted)
 			if req.Body != nil {
 				_ = req.Body.Close()
 			}
 			return
 		}
-
-		clientCtx := middleware.GetEnvContextInfo(req.Context())
-		dispatcher := clientCtx.Env.GetEventDispatcher()
 		if dispatcher == nil {
 			w.WriteHeader(http.StatusServiceUnavailable)
 			_, _ = w.Write(util.ErrorJSONMsg("Event proxy is not enabled for this environment"))
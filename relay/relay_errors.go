@@ -18,3 +18,15 @@ func errNewClientContextFailed(envName string, err error) error {
 func errNewMetricsManagerFailed(err error) error {
 	return fmt.Errorf("unable to create metrics manager: %w", err)
 }
+
+func errLoadingTLSClientCA(err error) error {
+	return fmt.Errorf("unable to load TLS client CA certificates: %w", err)
+}
+
+func errCreatingEventSink(err error) error {
+	return fmt.Errorf("unable to create events sink: %w", err)
+}
+
+func errCreatingOTLPEventExporter(err error) error {
+	return fmt.Errorf("unable to create OpenTelemetry exporter for events: %w", err)
+}
@@ -0,0 +1,256 @@
+package relay
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+const (
+	logMsgReloadStarted      = "Reloading configuration"
+	logMsgReloadFinished     = "Finished reloading configuration (%d environment(s) added, %d removed, %d updated)"
+	logMsgReloadInvalid      = "Not reloading configuration because it is invalid: %s"
+	logMsgReloadNeedsRestart = "Not reloading configuration because these settings cannot be changed without a restart: %s"
+	logMsgReloadEnvAddFailed = "Unable to add environment %q during configuration reload: %s"
+	logMsgReloadEnvIsAdmin   = "Not adding environment %q from the configuration because an environment with that name was added with the admin API"
+
+	logMsgReloadAutoConfigFailed = "Unable to reconnect to auto-configuration stream during configuration reload: %s"
+)
+
+func errReloadNeedsRestart(settings []string) error {
+	return fmt.Errorf("changed settings require a restart: %s", strings.Join(settings, ", "))
+}
+
+// Reload applies a new configuration to a running Relay instance, without dropping connections for
+// environments that have not changed.
+//
+// Environments that are in the new configuration but not the old one are added, and environments that
+// are no longer configured are removed. For an environment that is in both, changes to allowedOrigin,
+// allowedHeader, allowedClientCert, secureMode, ttl, and logLevel are applied in place; any other change to that
+// environment causes it to be recreated. The global logLevel is applied to Relay's own logging and to
+// every environment that does not override it.
+//
+// Environments that were added with the admin API are not part of the configuration, so Reload leaves
+// them as they are, except for applying the global logLevel.
+//
+// So that secrets read from files can be rotated, the Redis password, Consul token, and proxy password
+// can also change, unless Relay is in offline mode; this causes every environment to be recreated with
+// the new value. In auto-configuration mode, the auto-configuration key can change as well, which causes
+// Relay to reconnect to the auto-configuration stream with the new key without recreating environments.
+//
+// All other settings, such as the port, TLS options, or the database type, cannot be changed this way.
+// If any of them differ from the running configuration, or if the new configuration is invalid, Reload
+// logs an error and returns it without changing anything.
+func (r *Relay) Reload(newConfig config.Config) error {
+	r.loggers.Info(logMsgReloadStarted)
+
+	if err := config.ValidateConfig(&newConfig, r.loggers); err != nil {
+		r.loggers.Errorf(logMsgReloadInvalid, err)
+		return err
+	}
+
+	r.envChangeLock.Lock()
+	defer r.envChangeLock.Unlock()
+
+	r.lock.RLock()
+	oldConfig := r.config
+	closed := r.closed
+	r.lock.RUnlock()
+	if closed {
+		return errAlreadyClosed
+	}
+
+	staticEnvs := r.hasStaticEnvironments()
+	autoConfig := oldConfig.AutoConfig.Key.Defined()
+	comparableOldConfig, passwordsChanged, autoConfigKeyChanged := oldConfig, false, false
+	if staticEnvs || autoConfig {
+		passwordsChanged = oldConfig.Redis.Password != newConfig.Redis.Password ||
+			oldConfig.Consul.Token != newConfig.Consul.Token ||
+			oldConfig.Proxy.Password != newConfig.Proxy.Password
+		comparableOldConfig.Redis.Password = newConfig.Redis.Password
+		comparableOldConfig.Consul.Token = newConfig.Consul.Token
+		comparableOldConfig.Proxy.Password = newConfig.Proxy.Password
+	}
+	if autoConfig && newConfig.AutoConfig.Key.Defined() {
+		autoConfigKeyChanged = oldConfig.AutoConfig.Key != newConfig.AutoConfig.Key
+		comparableOldConfig.AutoConfig.Key = newConfig.AutoConfig.Key
+	}
+
+	if changed := describeChangesRequiringRestart(&comparableOldConfig, &newConfig); len(changed) != 0 {
+		r.loggers.Errorf(logMsgReloadNeedsRestart, strings.Join(changed, ", "))
+		return errReloadNeedsRestart(changed)
+	}
+
+	r.lock.Lock()
+	r.config.Environment = newConfig.Environment
+	r.config.Filters = newConfig.Filters
+	r.config.Main.LogLevel = newConfig.Main.LogLevel
+	r.config.Redis.Password = newConfig.Redis.Password
+	r.config.Consul.Token = newConfig.Consul.Token
+	r.config.Proxy.Password = newConfig.Proxy.Password
+	r.config.AutoConfig.Key = newConfig.AutoConfig.Key
+	r.lock.Unlock()
+	r.logLevels.SetMinLevel(newConfig.Main.LogLevel.GetOrElse(r.defaultLogLevel))
+
+	if autoConfigKeyChanged || (autoConfig && oldConfig.Proxy.Password != newConfig.Proxy.Password) {
+		httpConfig, err := httpconfig.NewHTTPConfig(newConfig.Proxy, newConfig.AutoConfig.Key, r.userAgent, r.loggers)
+		if err != nil {
+			// COVERAGE: can't happen, since only the password differs from a proxy configuration that was valid
+			r.loggers.Errorf(logMsgReloadAutoConfigFailed, err)
+		} else {
+			r.autoConfigStream.Reconnect(newConfig.AutoConfig.Key, httpConfig)
+		}
+	}
+
+	oldEnvConfigs := makeFilteredEnvironments(&oldConfig)
+	newEnvConfigs := makeFilteredEnvironments(&newConfig)
+	globalLogLevel := newConfig.Main.LogLevel.GetOrElse(ldlog.Info)
+
+	var numAdded, numRemoved, numUpdated int
+	currentEnvs := make(map[string]relayenv.EnvContext)
+	for _, env := range r.getAllEnvironments() {
+		name := env.GetIdentifiers().ConfiguredName
+		if name == "" || !staticEnvs || r.isAdminEnvironment(name) {
+			// environments from auto-configuration, offline mode, or the admin API only pick up the global
+			// log level
+			env.SetLogLevel(globalLogLevel)
+			continue
+		}
+		currentEnvs[name] = env
+	}
+
+	for name, env := range currentEnvs {
+		newEnvConfig, stillConfigured := newEnvConfigs[name]
+		oldEnvConfig, wasConfigured := oldEnvConfigs[name]
+		if stillConfigured && wasConfigured && !passwordsChanged && !envConfigNeedsRecreate(*oldEnvConfig, *newEnvConfig) {
+			updateEnvironmentInPlace(env, *newEnvConfig, globalLogLevel)
+			if !reflect.DeepEqual(*oldEnvConfig, *newEnvConfig) {
+				numUpdated++
+			}
+			continue
+		}
+		// Either the environment has been removed from the configuration, or it has changed in a way
+		// that requires a new SDK client. In the latter case it'll be added back below.
+		r.removeEnvironmentContext(env)
+		delete(currentEnvs, name)
+		if !stillConfigured {
+			numRemoved++
+		}
+	}
+
+	for name, envConfig := range newEnvConfigs {
+		if _, exists := currentEnvs[name]; exists {
+			continue
+		}
+		if r.isAdminEnvironment(name) {
+			r.loggers.Errorf(logMsgReloadEnvIsAdmin, name)
+			continue
+		}
+		if _, _, err := r.addEnvironment(relayenv.EnvIdentifiers{ConfiguredName: name}, *envConfig, nil); err != nil {
+			r.loggers.Errorf(logMsgReloadEnvAddFailed, name, err)
+			continue
+		}
+		if _, wasConfigured := oldEnvConfigs[name]; wasConfigured {
+			numUpdated++
+		} else {
+			numAdded++
+		}
+	}
+
+	if autoConfig && passwordsChanged {
+		numUpdated += (&relayAutoConfigActions{r}).recreateEnvironments()
+	}
+
+	r.loggers.Infof(logMsgReloadFinished, numAdded, numRemoved, numUpdated)
+	return nil
+}
+
+// updateEnvironmentInPlace applies the environment properties that can be changed without recreating
+// the environment.
+func updateEnvironmentInPlace(env relayenv.EnvContext, envConfig config.EnvConfig, globalLogLevel ldlog.LogLevel) {
+	env.SetJSClientCORS(envConfig.AllowedOrigin.Values(), envConfig.AllowedHeader.Values())
+	env.SetTTL(envConfig.TTL.GetOrElse(0))
+	env.SetSecureMode(envConfig.SecureMode)
+	env.SetAllowedClientCertificates(envConfig.AllowedClientCert.Values())
+	env.SetLogLevel(envConfig.LogLevel.GetOrElse(globalLogLevel))
+}
+
+// envConfigNeedsRecreate returns true if the two environment configurations differ in any property
+// other than the ones that updateEnvironmentInPlace can apply.
+func envConfigNeedsRecreate(oldConfig, newConfig config.EnvConfig) bool {
+	for _, ec := range []*config.EnvConfig{&oldConfig, &newConfig} {
+		ec.AllowedOrigin = newConfig.AllowedOrigin
+		ec.AllowedHeader = newConfig.AllowedHeader
+		ec.TTL = newConfig.TTL
+		ec.SecureMode = newConfig.SecureMode
+		ec.AllowedClientCert = newConfig.AllowedClientCert
+		ec.LogLevel = newConfig.LogLevel
+	}
+	return !reflect.DeepEqual(oldConfig, newConfig)
+}
+
+// describeChangesRequiringRestart returns the names of any settings that differ between the two
+// configurations and that Reload cannot apply to a running Relay.
+func describeChangesRequiringRestart(oldConfig, newConfig *config.Config) []string {
+	var changed []string
+
+	if !reflect.DeepEqual(databaseTypes(oldConfig), databaseTypes(newConfig)) {
+		changed = append(changed, "database type")
+	}
+
+	// In the [Main] section, only logLevel can be changed.
+	oldMain, newMain := reflect.ValueOf(oldConfig.Main), reflect.ValueOf(newConfig.Main)
+	for i := 0; i < oldMain.NumField(); i++ {
+		name := oldMain.Type().Field(i).Name
+		if name == "LogLevel" {
+			continue
+		}
+		if !reflect.DeepEqual(oldMain.Field(i).Interface(), newMain.Field(i).Interface()) {
+			changed = append(changed, "Main."+name)
+		}
+	}
+
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"AutoConfig", oldConfig.AutoConfig, newConfig.AutoConfig},
+		{"OfflineMode", oldConfig.OfflineMode, newConfig.OfflineMode},
+		{"Events", oldConfig.Events, newConfig.Events},
+		{"Redis", oldConfig.Redis, newConfig.Redis},
+		{"Consul", oldConfig.Consul, newConfig.Consul},
+		{"DynamoDB", oldConfig.DynamoDB, newConfig.DynamoDB},
+		{"Proxy", oldConfig.Proxy, newConfig.Proxy},
+		{"Admin", oldConfig.Admin, newConfig.Admin},
+		{"Datadog", oldConfig.Datadog, newConfig.Datadog},
+		{"Stackdriver", oldConfig.Stackdriver, newConfig.Stackdriver},
+		{"Prometheus", oldConfig.Prometheus, newConfig.Prometheus},
+	}
+	for _, s := range sections {
+		if !reflect.DeepEqual(s.old, s.new) {
+			changed = append(changed, s.name)
+		}
+	}
+
+	return changed
+}
+
+func databaseTypes(c *config.Config) []string {
+	var ret []string
+	if c.Redis.URL.IsDefined() {
+		ret = append(ret, "Redis")
+	}
+	if c.Consul.Host != "" {
+		ret = append(ret, "Consul")
+	}
+	if c.DynamoDB.Enabled {
+		ret = append(ret, "DynamoDB")
+	}
+	return ret
+}
@@ -0,0 +1,253 @@
+package relay
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireEnvForKey(t *testing.T, r *Relay, key c.SDKKey) relayenv.EnvContext {
+	env, err := r.getEnvironment(sdkauth.New(key))
+	require.NoError(t, err)
+	require.NotNil(t, env)
+	return env
+}
+
+func TestReloadAddsAndRemovesEnvironments(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		mainEnv := requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey)
+
+		newConfig := config
+		newConfig.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvClientSide)
+		require.NoError(t, p.relay.Reload(newConfig))
+
+		assert.Same(t, mainEnv, requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey))
+		requireEnvForKey(t, p.relay, st.EnvClientSide.Config.SDKKey)
+		_, err := p.relay.getEnvironment(sdkauth.New(st.EnvMobile.Config.SDKKey))
+		assert.Equal(t, errUnrecognizedEnvironment, err)
+		assert.Len(t, p.relay.getAllEnvironments(), 2)
+	})
+}
+
+func TestReloadUpdatesEnvironmentPropertiesInPlace(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvClientSide)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		env := requireEnvForKey(t, p.relay, st.EnvClientSide.Config.SDKKey)
+		envLoggers := env.GetLoggers() // a copy, like the ones given to the SDK client and event publisher
+
+		newEnvConfig := st.EnvClientSide.Config
+		newEnvConfig.AllowedOrigin = ct.NewOptStringList([]string{"https://example.com"})
+		newEnvConfig.AllowedHeader = ct.NewOptStringList([]string{"X-Custom"})
+		newEnvConfig.TTL = ct.NewOptDuration(time.Minute)
+		newEnvConfig.LogLevel = c.NewOptLogLevel(ldlog.Warn)
+		newConfig := config
+		newConfig.Environment = map[string]*c.EnvConfig{st.EnvClientSide.Name: &newEnvConfig}
+		require.NoError(t, p.relay.Reload(newConfig))
+
+		assert.Same(t, env, requireEnvForKey(t, p.relay, st.EnvClientSide.Config.SDKKey))
+		assert.Equal(t, []string{"https://example.com"}, env.GetJSClientContext().Origins)
+		assert.Equal(t, []string{"X-Custom"}, env.GetJSClientContext().Headers)
+		assert.Equal(t, time.Minute, env.GetTTL())
+		envLoggers.Info("info message after reload")
+		envLoggers.Warn("warn message after reload")
+		p.mockLog.AssertMessageMatch(t, false, ldlog.Info, "info message after reload")
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "warn message after reload")
+	})
+}
+
+func TestReloadAppliesGlobalLogLevel(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		globalLoggers := p.relay.loggers
+		envLoggers := requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey).GetLoggers()
+		globalLoggers.Debug("global debug message before reload")
+		envLoggers.Debug("env debug message before reload")
+
+		newConfig := config
+		newConfig.Main.LogLevel = c.NewOptLogLevel(ldlog.Warn)
+		require.NoError(t, p.relay.Reload(newConfig))
+
+		globalLoggers.Info("global info message after reload")
+		envLoggers.Info("env info message after reload")
+		globalLoggers.Warn("global warn message after reload")
+
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Debug, "global debug message before reload")
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Debug, "env debug message before reload")
+		p.mockLog.AssertMessageMatch(t, false, ldlog.Info, "global info message after reload")
+		p.mockLog.AssertMessageMatch(t, false, ldlog.Info, "env info message after reload")
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "global warn message after reload")
+	})
+}
+
+func TestReloadKeepsEnvironmentsAddedWithAdminAPI(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Admin.Token = testAdminToken
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		body := []byte(`{"sdkKey": "` + string(st.EnvMobile.Config.SDKKey) + `"}`)
+		result, _ := st.DoRequest(makeAdminRequest("PUT", "http://localhost/environments/added", body), p.relay.makeAdminRouter())
+		require.Equal(t, http.StatusCreated, result.StatusCode)
+		added := requireEnvForKey(t, p.relay, st.EnvMobile.Config.SDKKey)
+
+		t.Run("environment is not removed", func(t *testing.T) {
+			require.NoError(t, p.relay.Reload(config))
+			assert.Same(t, added, requireEnvForKey(t, p.relay, st.EnvMobile.Config.SDKKey))
+			assert.Len(t, p.relay.getAllEnvironments(), 2)
+		})
+
+		t.Run("configured environment with the same name is not added", func(t *testing.T) {
+			newConfig := config
+			newConfig.Environment = st.MakeEnvConfigs(st.EnvMain)
+			newConfig.Environment["added"] = &st.EnvClientSide.Config
+			require.NoError(t, p.relay.Reload(newConfig))
+			assert.Same(t, added, requireEnvForKey(t, p.relay, st.EnvMobile.Config.SDKKey))
+			_, err := p.relay.getEnvironment(sdkauth.New(st.EnvClientSide.Config.SDKKey))
+			assert.Equal(t, errUnrecognizedEnvironment, err)
+			p.mockLog.AssertMessageMatch(t, true, ldlog.Error, `Not adding environment "added"`)
+		})
+
+		t.Run("configured environment whose name starts with the same name is added", func(t *testing.T) {
+			newConfig := config
+			newConfig.Environment = st.MakeEnvConfigs(st.EnvMain)
+			newConfig.Environment["added/other"] = &st.EnvClientSide.Config
+			require.NoError(t, p.relay.Reload(newConfig))
+			assert.Same(t, added, requireEnvForKey(t, p.relay, st.EnvMobile.Config.SDKKey))
+			requireEnvForKey(t, p.relay, st.EnvClientSide.Config.SDKKey)
+		})
+	})
+}
+
+func TestReloadRecreatesEnvironmentWhenCredentialsChange(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		env := requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey)
+
+		newEnvConfig := st.EnvMain.Config
+		newEnvConfig.MobileKey = st.EnvMobile.Config.MobileKey
+		newConfig := config
+		newConfig.Environment = map[string]*c.EnvConfig{st.EnvMain.Name: &newEnvConfig}
+		require.NoError(t, p.relay.Reload(newConfig))
+
+		newEnv := requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey)
+		assert.NotSame(t, env, newEnv)
+		envForMobileKey, err := p.relay.getEnvironment(sdkauth.New(st.EnvMobile.Config.MobileKey))
+		require.NoError(t, err)
+		assert.Same(t, newEnv, envForMobileKey)
+	})
+}
+
+func TestReloadRecreatesEnvironmentsWhenPasswordChanges(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Proxy.URL, _ = ct.NewOptURLAbsoluteFromString("http://proxy")
+	config.Proxy.Password = "old-password"
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		env := requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey)
+
+		newConfig := config
+		newConfig.Proxy.Password = "new-password"
+		require.NoError(t, p.relay.Reload(newConfig))
+
+		assert.NotSame(t, env, requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey))
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Info, `0 environment\(s\) added, 0 removed, 1 updated`)
+	})
+}
+
+func TestReloadReconnectsWithNewAutoConfigKey(t *testing.T) {
+	newKey := c.AutoConfigKey("new-auto-conf-key")
+	initialEvent := makeAutoConfPutEvent(testAutoConfEnv1)
+	autoConfTest(t, testAutoConfDefaultConfig, &initialEvent, func(p autoConfTestParams) {
+		req := helpers.RequireValue(t, p.streamRequestsCh, time.Second, "timed out waiting for stream request")
+		assert.Equal(t, string(testAutoConfKey), req.Request.Header.Get("Authorization"))
+		p.awaitClient()
+		env := p.awaitEnvironment(testAutoConfEnv1.id)
+
+		newConfig := p.relay.config
+		newConfig.AutoConfig.Key = newKey
+		require.NoError(t, p.relay.Reload(newConfig))
+
+		req = helpers.RequireValue(t, p.streamRequestsCh, time.Second, "timed out waiting for stream reconnect")
+		assert.Equal(t, string(newKey), req.Request.Header.Get("Authorization"))
+		p.shouldNotCreateClient(time.Millisecond * 100)
+		assert.Same(t, env, p.awaitEnvironment(testAutoConfEnv1.id))
+	})
+}
+
+func TestReloadRecreatesAutoConfiguredEnvironmentsWhenPasswordChanges(t *testing.T) {
+	config := testAutoConfDefaultConfig
+	config.Redis.Password = "old-password"
+	initialEvent := makeAutoConfPutEvent(testAutoConfEnv1)
+	autoConfTest(t, config, &initialEvent, func(p autoConfTestParams) {
+		p.awaitClient()
+		env := p.awaitEnvironment(testAutoConfEnv1.id)
+
+		newConfig := p.relay.config
+		newConfig.Redis.Password = "new-password"
+		require.NoError(t, p.relay.Reload(newConfig))
+
+		client := p.awaitClient()
+		assert.Equal(t, testAutoConfEnv1.SDKKey(), client.Key)
+		newEnv := p.awaitEnvironment(testAutoConfEnv1.id)
+		assert.NotSame(t, env, newEnv)
+		assertEnvProps(t, testAutoConfEnv1.params(), newEnv)
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Info, `0 environment\(s\) added, 0 removed, 1 updated`)
+	})
+}
+
+func TestReloadRejectsChangesRequiringRestart(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		newConfig := config
+		newConfig.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+		newConfig.Main.Port, _ = ct.NewOptIntGreaterThanZero(9999)
+		newConfig.Main.TLSEnabled = true
+		newConfig.Main.TLSCert, newConfig.Main.TLSKey = "cert", "key"
+
+		err := p.relay.Reload(newConfig)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Main.Port")
+		assert.Contains(t, err.Error(), "Main.TLSEnabled")
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Error, "cannot be changed without a restart")
+
+		assert.Len(t, p.relay.getAllEnvironments(), 1)
+	})
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		newConfig := config
+		newConfig.Environment = map[string]*c.EnvConfig{"no-key": {}}
+
+		require.Error(t, p.relay.Reload(newConfig))
+		assert.Len(t, p.relay.getAllEnvironments(), 1)
+		requireEnvForKey(t, p.relay, st.EnvMain.Config.SDKKey)
+	})
+}
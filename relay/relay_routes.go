@@ -10,8 +10,8 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v8/internal/middleware"
 	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/streams"
 
-	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	ldevents "github.com/launchdarkly/go-sdk-events/v3"
 
 	"github.com/gorilla/mux"
@@ -31,10 +31,11 @@ const (
 func (r *Relay) makeRouter() *mux.Router {
 	router := mux.NewRouter()
 	router.Use(logging.GlobalContextLoggersMiddleware(r.loggers))
-	if r.loggers.GetMinLevel() == ldlog.Debug {
-		router.Use(logging.RequestLoggerMiddleware(r.loggers))
-	}
+	router.Use(logging.DynamicRequestLoggerMiddleware(r.loggers, r.logLevels))
 	router.Handle("/status", statusHandler(r)).Methods("GET")
+	router.Handle("/status/environments/{envId:.+}", environmentStatusHandler(r)).Methods("GET")
+	router.Handle("/health/live", livenessHandler(r)).Methods("GET")
+	router.Handle("/health/ready", readinessHandler(r)).Methods("GET")
 
 	environmentGetters := relayEnvironmentGetters{r}
 	sdkKeySelector := middleware.SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, environmentGetters)
@@ -104,6 +105,9 @@ func (r *Relay) makeRouter() *mux.Router {
 	mobileStreamRouter := router.PathPrefix("/meval").Subrouter()
 	mobileStreamRouter.Use(mobileMiddlewareStack, middleware.Streaming)
 	mobilePingWithUser := pingStreamHandlerWithContext(basictypes.MobileSDK, r.mobileStreamProvider)
+	if r.mobileEvalStreamProvider != nil {
+		mobilePingWithUser = evalStreamHandler(basictypes.MobileSDK, r.mobileEvalStreamProvider)
+	}
 	mobileStreamRouter.Handle("", middleware.CountMobileConns(mobilePingWithUser)).Methods("REPORT")
 	mobileStreamRouter.Handle("/{context}", middleware.CountMobileConns(mobilePingWithUser)).Methods("GET")
 
@@ -112,6 +116,9 @@ func (r *Relay) makeRouter() *mux.Router {
 
 	jsPing := pingStreamHandler(r.jsClientStreamProvider)
 	jsPingWithUser := pingStreamHandlerWithContext(basictypes.JSClientSDK, r.jsClientStreamProvider)
+	if r.jsClientEvalStreamProvider != nil {
+		jsPingWithUser = evalStreamHandler(basictypes.JSClientSDK, r.jsClientEvalStreamProvider)
+	}
 
 	clientSidePingRouter := router.PathPrefix("/ping/{envId}").Subrouter()
 	clientSidePingRouter.Use(jsClientSideMiddlewareStack(clientSidePingRouter), middleware.Streaming)
@@ -119,10 +126,35 @@ func (r *Relay) makeRouter() *mux.Router {
 
 	clientSideStreamEvalRouter := router.PathPrefix("/eval/{envId}").Subrouter()
 	clientSideStreamEvalRouter.Use(jsClientSideMiddlewareStack(clientSideStreamEvalRouter), middleware.Streaming)
-	// For now we implement eval as simply ping
+	// Unless Main.ClientSideEvalStreams is set, we implement eval as simply ping
 	clientSideStreamEvalRouter.Handle("/{context}", middleware.CountBrowserConns(jsPingWithUser)).Methods("GET", "OPTIONS")
 	clientSideStreamEvalRouter.Handle("", middleware.CountBrowserConns(jsPingWithUser)).Methods("REPORT", "OPTIONS")
 
+	// If Main.WebSocketStreams is set, each of the GET stream endpoints is also available over a WebSocket
+	// connection, with the same path prefixed by "/ws". These must be added before the server-side routes
+	// below, which match any path.
+	if r.config.Main.WebSocketStreams {
+		router.Handle("/ws/all", serverSideMiddlewareStack(middleware.CountServerConns(streams.WebSocketHandler(
+			streamHandler(r.serverSideStreamProvider, serverSideStreamLogMessage),
+		)))).Methods("GET")
+		router.Handle("/ws/flags", serverSideMiddlewareStack(middleware.CountServerConns(streams.WebSocketHandler(
+			streamHandler(r.serverSideFlagsStreamProvider, serverSideFlagsOnlyStreamLogMessage),
+		)))).Methods("GET")
+
+		router.Handle("/ws/meval/{context}", mobileMiddlewareStack(
+			middleware.CountMobileConns(streams.WebSocketHandler(mobilePingWithUser)))).Methods("GET")
+		router.Handle("/ws/mping", mobileKeySelector(
+			middleware.CountMobileConns(streams.WebSocketHandler(pingStreamHandler(r.mobileStreamProvider))))).Methods("GET")
+
+		webSocketPingRouter := router.PathPrefix("/ws/ping/{envId}").Subrouter()
+		webSocketPingRouter.Use(jsClientSideMiddlewareStack(webSocketPingRouter))
+		webSocketPingRouter.Handle("", middleware.CountBrowserConns(streams.WebSocketHandler(jsPing))).Methods("GET")
+
+		webSocketEvalRouter := router.PathPrefix("/ws/eval/{envId}").Subrouter()
+		webSocketEvalRouter.Use(jsClientSideMiddlewareStack(webSocketEvalRouter))
+		webSocketEvalRouter.Handle("/{context}", middleware.CountBrowserConns(streams.WebSocketHandler(jsPingWithUser))).Methods("GET")
+	}
+
 	mobileEventsRouter := router.PathPrefix("/mobile").Subrouter()
 	mobileEventsRouter.Use(mobileMiddlewareStack, middleware.GzipMiddleware(r.config.Events.MaxInboundPayloadSize))
 	mobileEventsRouter.Handle("/events/bulk", bulkEventHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST")
@@ -150,10 +182,16 @@ func (r *Relay) makeRouter() *mux.Router {
 	serverSideBulkEventsRouter.Handle("/bulk", bulkEventHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST")
 	serverSideBulkEventsRouter.Handle("/diagnostic", bulkEventHandler(basictypes.ServerSDK, ldevents.DiagnosticEventDataKind, offlineMode)).Methods("POST")
 
-	serverSideRouter.Handle("/all", middleware.CountServerConns(middleware.Streaming(
+	serverSideStreaming := middleware.Streaming
+	if r.config.Main.CompressServerSideStreams {
+		serverSideStreaming = func(next http.Handler) http.Handler {
+			return middleware.Streaming(middleware.CompressedStreaming(next))
+		}
+	}
+	serverSideRouter.Handle("/all", middleware.CountServerConns(serverSideStreaming(
 		streamHandler(r.serverSideStreamProvider, serverSideStreamLogMessage),
 	))).Methods("GET")
-	serverSideRouter.Handle("/flags", middleware.CountServerConns(middleware.Streaming(
+	serverSideRouter.Handle("/flags", middleware.CountServerConns(serverSideStreaming(
 		streamHandler(r.serverSideFlagsStreamProvider, serverSideFlagsOnlyStreamLogMessage),
 	))).Methods("GET")
 
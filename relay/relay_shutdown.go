@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+)
+
+const (
+	logMsgShutdownStarted       = "Shutting down gracefully; waiting up to %s for connections to drain"
+	logMsgShutdownTimedOut      = "Connections did not drain within %s; closing remaining connections"
+	logMsgShutdownCloseTimedOut = "Could not deliver remaining analytics events within %s"
+	logMsgShutdownFinished      = "Finished shutting down"
+)
+
+var errShutdownCloseTimedOut = errors.New("timed out while closing Relay")
+
+const (
+	// shutdownNoticeDelay is how long Shutdown waits between sending a shutdown notice on every stream
+	// and closing the streams, so that the notices can be written to the connections first.
+	shutdownNoticeDelay = 200 * time.Millisecond
+
+	// shutdownCloseTimeout is how long Shutdown waits for Close, after connections have been drained or
+	// the drain timeout has elapsed.
+	shutdownCloseTimeout = 10 * time.Second
+)
+
+// Shutdown stops the Relay Proxy gracefully, as opposed to Close which drops everything immediately.
+//
+// If server is not nil, it stops accepting new connections on that server; /health/ready also starts
+// returning a 503 status. Then every SDK stream connection receives a final SSE comment and is closed,
+// so that the SDK clients reconnect to another instance. Requests that are already in progress, such as
+// event posts, are allowed to finish. Finally, Shutdown calls Close, which delivers any analytics events
+// that are still queued.
+//
+// If the connections have not finished within the configured shutdown drain timeout, Shutdown closes any
+// remaining ones and returns an error, but it still waits for Close first. Close is given a separate time
+// limit; if it has not finished by then, Shutdown also returns an error.
+func (r *Relay) Shutdown(server *http.Server) error {
+	r.lock.Lock()
+	if r.closed || r.shuttingDown {
+		r.lock.Unlock()
+		return nil
+	}
+	r.shuttingDown = true
+	timeout := r.config.Main.ShutdownDrainTimeout.GetOrElse(config.DefaultShutdownDrainTimeout)
+	r.lock.Unlock()
+
+	r.loggers.Infof(logMsgShutdownStarted, timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// http.Server.Shutdown closes the listener immediately, but then waits for all active requests to
+	// finish; streaming requests won't finish until we disconnect the streams below.
+	serverDone := make(chan error, 1)
+	if server != nil {
+		go func() {
+			serverDone <- server.Shutdown(ctx)
+		}()
+	} else {
+		serverDone <- nil
+	}
+
+	envs := r.getAllEnvironments()
+	for _, env := range envs {
+		env.SendShutdownNotice()
+	}
+	select {
+	case <-time.After(shutdownNoticeDelay):
+	case <-ctx.Done():
+	}
+	for _, env := range envs {
+		env.DisconnectStreams()
+	}
+
+	err := <-serverDone
+	if err != nil {
+		r.loggers.Warnf(logMsgShutdownTimedOut, timeout)
+		if server != nil {
+			_ = server.Close()
+		}
+	}
+
+	// Close delivers any analytics events that are still queued, so we wait for it even if draining the
+	// connections timed out; but not indefinitely, in case it is stuck.
+	closeDone := make(chan struct{})
+	go func() {
+		_ = r.Close()
+		close(closeDone)
+	}()
+	closeTimer := time.NewTimer(shutdownCloseTimeout)
+	defer closeTimer.Stop()
+	select {
+	case <-closeDone:
+	case <-closeTimer.C:
+		r.loggers.Warnf(logMsgShutdownCloseTimedOut, shutdownCloseTimeout)
+		if err == nil {
+			err = errShutdownCloseTimedOut
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	r.loggers.Info(logMsgShutdownFinished)
+	return nil
+}
@@ -0,0 +1,176 @@
+package relay
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/events"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+	"github.com/launchdarkly/ld-relay/v8/internal/streams"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownSendsNoticeAndClosesStreams(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		h := make(http.Header)
+		h.Set("Authorization", string(st.EnvMain.Config.SDKKey))
+		req := st.BuildRequest("GET", "http://localhost/all", nil, h)
+
+		shutdownErrCh := make(chan error, 1)
+		st.WithStreamRequestLines(t, req, p.relay, func(linesCh <-chan string) {
+		ReadInitialEvent:
+			for {
+				if line := helpers.RequireValue(t, linesCh, time.Second); line == "\n" {
+					break ReadInitialEvent
+				}
+			}
+
+			go func() {
+				shutdownErrCh <- p.relay.Shutdown(nil)
+			}()
+
+			line := helpers.RequireValue(t, linesCh, time.Second, "timed out waiting for shutdown notice")
+			assert.True(t, strings.HasPrefix(line, ":"+streams.ShutdownNoticeComment), "unexpected line: %q", line)
+
+			for {
+				line := helpers.RequireValue(t, linesCh, time.Second, "timed out waiting for stream to be closed")
+				if line == "" {
+					break // the stream was closed
+				}
+			}
+		})
+
+		require.NoError(t, helpers.RequireValue(t, shutdownErrCh, time.Second*5))
+		p.relay.lock.RLock()
+		assert.True(t, p.relay.closed)
+		p.relay.lock.RUnlock()
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Info, logMsgShutdownFinished)
+	})
+}
+
+func TestShutdownDrainsStreamsOnServer(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		server := httptest.NewServer(p.relay)
+		defer server.Close()
+
+		req, _ := http.NewRequest("GET", server.URL+"/all", nil)
+		req.Header.Set("Authorization", string(st.EnvMain.Config.SDKKey))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				break // end of the initial event
+			}
+		}
+
+		shutdownErrCh := make(chan error, 1)
+		go func() {
+			shutdownErrCh <- p.relay.Shutdown(server.Config)
+		}()
+
+		rest, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Contains(t, string(rest), ":"+streams.ShutdownNoticeComment)
+
+		require.NoError(t, helpers.RequireValue(t, shutdownErrCh, time.Second*5))
+		_, err = http.Get(server.URL + "/status")
+		assert.Error(t, err, "server should not accept new connections")
+	})
+}
+
+func TestShutdownDeliversQueuedEvents(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Events.FlushInterval = ct.NewOptDuration(time.Hour)
+
+	relayEventsTest(t, config, func(p relayEventsTestParams) {
+		header := make(http.Header)
+		header.Set("Authorization", string(st.EnvMain.Config.SDKKey))
+		header.Set(events.EventSchemaHeader, strconv.Itoa(events.SummaryEventsSchemaVersion))
+		body := makeTestFeatureEventPayload("me")
+		result, _ := st.DoRequest(st.BuildRequest("POST", "http://localhost/bulk", body, header), p.relay)
+		require.Equal(t, http.StatusAccepted, result.StatusCode)
+
+		require.NoError(t, p.relay.Shutdown(nil))
+
+		select {
+		case event := <-p.publishedEvents:
+			assert.Equal(t, "/bulk", event.url)
+		default:
+			assert.Fail(t, "queued events were not delivered before Shutdown returned")
+		}
+	})
+}
+
+func TestShutdownTimesOutButStillDeliversQueuedEvents(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Events.FlushInterval = ct.NewOptDuration(time.Hour)
+	config.Main.ShutdownDrainTimeout = ct.NewOptDuration(time.Millisecond * 100)
+
+	relayEventsTest(t, config, func(p relayEventsTestParams) {
+		slowRequestStarted, releaseSlowRequest := make(chan struct{}), make(chan struct{})
+		mux := http.NewServeMux()
+		mux.Handle("/", p.relay)
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(slowRequestStarted)
+			<-releaseSlowRequest
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		defer close(releaseSlowRequest) // must happen before server.Close, which waits for the handler
+
+		header := make(http.Header)
+		header.Set("Authorization", string(st.EnvMain.Config.SDKKey))
+		header.Set(events.EventSchemaHeader, strconv.Itoa(events.SummaryEventsSchemaVersion))
+		body := makeTestFeatureEventPayload("me")
+		result, _ := st.DoRequest(st.BuildRequest("POST", "http://localhost/bulk", body, header), p.relay)
+		require.Equal(t, http.StatusAccepted, result.StatusCode)
+
+		go func() {
+			if resp, err := http.Get(server.URL + "/slow"); err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+		select {
+		case <-slowRequestStarted:
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for slow request")
+		}
+
+		err := p.relay.Shutdown(server.Config)
+		require.Error(t, err)
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "did not drain")
+		p.mockLog.AssertMessageMatch(t, false, ldlog.Info, logMsgShutdownFinished)
+
+		select {
+		case event := <-p.publishedEvents:
+			assert.Equal(t, "/bulk", event.url)
+		default:
+			assert.Fail(t, "queued events were not delivered before Shutdown returned")
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package relay
+
+import (
+	"crypto/tls"
+)
+
+// TLSConfig returns the TLS configuration for Relay's HTTPS server, or nil if TLS is not enabled.
+//
+// The certificate is provided by a GetCertificate callback rather than being loaded once, so that if
+// the certificate or key file changes, new connections will use the new certificate without a restart.
+//
+// If a TLS client CA is configured, every client must present a certificate signed by one of those CAs.
+// Whether a given certificate may be used for a particular environment is checked later, when the
+// request is routed to that environment.
+func (r *Relay) TLSConfig() *tls.Config {
+	if r.tlsCertWatcher == nil {
+		return nil
+	}
+	tlsConfig := &tls.Config{ //nolint:gosec // linter doesn't want to see MinVersion being set to a variable
+		MinVersion:     r.config.Main.TLSMinVersion.Get(),
+		GetCertificate: r.tlsCertWatcher.GetCertificate,
+	}
+	if r.tlsClientCAs != nil {
+		tlsConfig.ClientCAs = r.tlsClientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig
+}